@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	opScript = "script"
+
+	// actionTimeout is how long a download action script may run before
+	// it's killed, so one stuck script can't hold up the run.
+	actionTimeout = 5 * time.Minute
+)
+
+// runAction runs script with the path of a Downloads file as its argument,
+// in place of moving the file into its category. What the script does with
+// the file is up to it; the last line it prints goes in the summary.
+func (app *App) runAction(script, path string) error {
+	if strings.HasPrefix(script, "~/") {
+		script = filepath.Join(app.homeDir, script[2:])
+	}
+	if app.isProtected(path) {
+		return errProtected
+	}
+	name := filepath.Base(path)
+	if app.dryRun {
+		app.addItem(&app.summary.ScriptActions, fmt.Sprintf("%s → %s", name, app.displayPath(script)))
+		return nil
+	}
+
+	var output []byte
+	entry := journalEntry{Op: opScript, Path: path, Dest: script}
+	err := app.audited(entry, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), actionTimeout)
+		defer cancel()
+		var err error
+		output, err = exec.CommandContext(ctx, script, path).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s failed: %w: %s", app.displayPath(script), err, lastLine(output))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	item := fmt.Sprintf("%s → %s", name, app.displayPath(script))
+	if result := lastLine(output); result != "" {
+		item += ": " + result
+	}
+	app.addItem(&app.summary.ScriptActions, item)
+	return nil
+}
+
+// lastLine returns the last non-empty line of a command's output.
+func lastLine(output []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
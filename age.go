@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// What a file's age is counted from, by cleaner in age_by.
+const (
+	// ageByModified is the last time the file was changed, the default
+	ageByModified = "mtime"
+	// ageByAccessed is the last time it was read or changed, for files that
+	// are read often but never changed
+	ageByAccessed = "atime"
+	// ageByBirth is when it was created, where the file system records it
+	ageByBirth = "birthtime"
+)
+
+var ageByNames = []string{ageByModified, ageByAccessed, ageByBirth}
+
+// ageByCleaners are the cleaners whose ages age_by changes: those going by
+// the ages of the files and folders they remove. The mail and VM image
+// cleaners already wait for both the access and modification times.
+var ageByCleaners = []string{"downloads", "node_modules", "sync_conflicts", "backup_files"}
+
+// validateAgeBy checks the config's age_by, keyed by cleaner name or
+// "default".
+func validateAgeBy(ageBy map[string]string) error {
+	for _, key := range sortedKeys(ageBy) {
+		if key != defaultLimitsKey && !slices.Contains(ageByCleaners, key) {
+			return fmt.Errorf("invalid age_by key %q, expected %s or one of: %s", key, defaultLimitsKey, strings.Join(ageByCleaners, ", "))
+		}
+		if by := ageBy[key]; by != "" && !slices.Contains(ageByNames, by) {
+			return fmt.Errorf("invalid age_by.%s %q, expected one of: %s", key, by, strings.Join(ageByNames, ", "))
+		}
+	}
+	return nil
+}
+
+// ageBy returns what the running cleaner counts ages from.
+func (app *App) ageBy() string {
+	by := app.ageByConfig[defaultLimitsKey]
+	if own := app.ageByConfig[app.cleaner]; own != "" {
+		by = own
+	}
+	if by == "" || !slices.Contains(ageByCleaners, app.cleaner) {
+		return ageByModified
+	}
+	return by
+}
+
+// fileTime returns the time the running cleaner counts the age of the file
+// at path from. Where a file system doesn't record the time asked for, it's
+// the modification time.
+func (app *App) fileTime(path string, info os.FileInfo) time.Time {
+	switch app.ageBy() {
+	case ageByAccessed:
+		// Writing a file doesn't update its access time
+		return maxTime(accessTime(info), info.ModTime())
+	case ageByBirth:
+		if born, ok := birthTime(path); ok {
+			return born
+		}
+	}
+	return info.ModTime()
+}
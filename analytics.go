@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const analyticsIntervalDays = 1
+
+// sparkBlocks draw stats trends' sparklines, lowest to highest.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// AnalyticsConfig tracks disk usage over time, strictly on this machine:
+// the sizes are recorded in the history, which nothing is ever sent from,
+// for stats trends and the dashboard to show whether cleanups keep up.
+type AnalyticsConfig struct {
+	Enabled bool `json:"enabled"`
+	// Dirs are the directories to track, relative to the home directory if
+	// not absolute. By default they're the home directory's top-level
+	// directories, or /home, /opt, /srv, /usr and /var for the system
+	// service.
+	Dirs []string `json:"dirs"`
+	// IntervalDays is how many days apart the sizes are measured (default
+	// 1), as measuring walks every directory in full.
+	IntervalDays int `json:"interval_days"`
+}
+
+// usageDirs returns the directories the config tracks.
+func (app *App) usageDirs(cfg AnalyticsConfig) []string {
+	var dirs []string
+	for _, dir := range cfg.Dirs {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(app.homeDir, dir)
+		}
+		dirs = append(dirs, filepath.Clean(dir))
+	}
+	if len(dirs) > 0 {
+		return dirs
+	}
+	if app.system {
+		return []string{"/home", "/opt", "/srv", "/usr", "/var"}
+	}
+	entries, _ := os.ReadDir(app.homeDir)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, filepath.Join(app.homeDir, entry.Name()))
+		}
+	}
+	return dirs
+}
+
+// sampleUsage measures the tracked directories for the run's history
+// record, if the last measurement is IntervalDays old.
+func (app *App) sampleUsage(cfg AnalyticsConfig) {
+	interval := cfg.IntervalDays
+	if interval <= 0 {
+		interval = analyticsIntervalDays
+	}
+	if records, err := app.loadHistory(); err == nil {
+		for i := len(records) - 1; i >= 0; i-- {
+			if records[i].DirSizes != nil {
+				// An hour's slack, so a daily run that starts a little
+				// earlier than yesterday's still measures
+				if records[i].Time.After(daysAgo(time.Now(), float64(interval)).Add(time.Hour)) {
+					return
+				}
+				break
+			}
+		}
+	}
+
+	defer app.timeAction("measure usage", time.Now())
+	app.dirSizes = make(map[string]int64)
+	for _, dir := range app.usageDirs(cfg) {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			app.dirSizes[app.displayPath(dir)] = app.usageSize(dir)
+		}
+	}
+}
+
+// usageSize adds up the sizes of the files under dir on its own file
+// system, leaving out what's mounted inside it, like du -x.
+func (app *App) usageSize(dir string) int64 {
+	dev, _ := deviceOf(dir)
+	var total int64
+	walkTreeLimited(dir, app.scanLimits, nil, func(rel string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && rel != "." {
+			if sub, ok := deviceOf(filepath.Join(dir, rel)); ok && sub != dev {
+				return filepath.SkipDir
+			}
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
+
+// usageSeries is one tracked directory's sizes over time.
+type usageSeries struct {
+	dir   string
+	times []time.Time
+	sizes []int64
+}
+
+// usageTrends returns the sizes the records measured, by directory.
+func usageTrends(records []runRecord) []usageSeries {
+	byDir := make(map[string]*usageSeries)
+	for _, record := range records {
+		for dir, size := range record.DirSizes {
+			s := byDir[dir]
+			if s == nil {
+				s = &usageSeries{dir: dir}
+				byDir[dir] = s
+			}
+			s.times = append(s.times, record.Time)
+			s.sizes = append(s.sizes, size)
+		}
+	}
+	var series []usageSeries
+	for _, s := range byDir {
+		series = append(series, *s)
+	}
+	// Biggest now first
+	sort.Slice(series, func(i, j int) bool {
+		a, b := series[i].sizes[len(series[i].sizes)-1], series[j].sizes[len(series[j].sizes)-1]
+		if a != b {
+			return a > b
+		}
+		return series[i].dir < series[j].dir
+	})
+	return series
+}
+
+// sparkline draws sizes as a line of block characters, at most width wide.
+func sparkline(sizes []int64, width int) string {
+	if len(sizes) > width {
+		// Every so many samples, always keeping the last
+		var picked []int64
+		for i := 0; i < width; i++ {
+			picked = append(picked, sizes[(len(sizes)-1)*i/(width-1)])
+		}
+		sizes = picked
+	}
+	lo, hi := sizes[0], sizes[0]
+	for _, size := range sizes {
+		lo, hi = min(lo, size), max(hi, size)
+	}
+	var b strings.Builder
+	for _, size := range sizes {
+		level := 0
+		if hi > lo {
+			level = int((size - lo) * int64(len(sparkBlocks)-1) / (hi - lo))
+		}
+		b.WriteRune(sparkBlocks[level])
+	}
+	return b.String()
+}
+
+// statsTrends prints how the tracked directories grew between from and to,
+// and whether the space runs freed kept up with it.
+func (app *App) statsTrends(from, to string) error {
+	records, err := app.loadHistory()
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	start, end, err := dateRange(from, to)
+	if err != nil {
+		return err
+	}
+	var kept []runRecord
+	for _, record := range records {
+		if !record.Time.Before(start) && record.Time.Before(end) {
+			kept = append(kept, record)
+		}
+	}
+	records = kept
+	series := usageTrends(records)
+	if len(series) == 0 {
+		return fmt.Errorf("no disk usage recorded yet; set analytics.enabled in the config to track it")
+	}
+
+	first, last := series[0].times[0], series[0].times[0]
+	for _, s := range series {
+		first = minTime(first, s.times[0])
+		last = maxTime(last, s.times[len(s.times)-1])
+	}
+	fmt.Println(T("stats.trends", first.Format("2006-01-02"), last.Format("2006-01-02")))
+	fmt.Println()
+
+	var dirs []string
+	for _, s := range series {
+		dirs = append(dirs, s.dir)
+	}
+	width := columnWidth(24, dirs)
+	days := last.Sub(first).Hours() / 24
+	var growth int64
+	for _, s := range series {
+		delta := s.sizes[len(s.sizes)-1] - s.sizes[0]
+		growth += delta
+		rate := ""
+		if days >= 1 {
+			rate = T("stats.per_month", signedSize(int64(float64(delta)*30/days)))
+		}
+		fmt.Printf("   %s %10s → %-10s %10s  %-16s %s\n", padRight(s.dir, width), formatSize(s.sizes[0]), formatSize(s.sizes[len(s.sizes)-1]), signedSize(delta), rate, sparkline(s.sizes, 24))
+	}
+	fmt.Println()
+
+	var freed int64
+	for _, record := range records {
+		if record.Time.After(first) && !record.Time.After(last) {
+			freed += record.FreedBytes
+		}
+	}
+	if growth <= 0 {
+		fmt.Println(T("stats.keeping_up", formatSize(freed), formatSize(-growth)))
+	} else {
+		fmt.Println(T("stats.outgrowing", formatSize(growth), formatSize(freed)))
+	}
+	return nil
+}
+
+// signedSize formats a change in size with its sign.
+func signedSize(delta int64) string {
+	if delta < 0 {
+		return "-" + formatSize(-delta)
+	}
+	return "+" + formatSize(delta)
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
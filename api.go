@@ -0,0 +1,209 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const apiTokenFileName = "api-token"
+
+// serveHTTP serves the control API and the web dashboard on addr, for
+// machines without a desktop bus. Every API request needs the token kept in
+// the state directory, which is generated on first start.
+func (d *daemon) serveHTTP(addr string) (*http.Server, error) {
+	token, path, err := d.apiToken()
+	if err != nil {
+		return nil, err
+	}
+
+	api := http.NewServeMux()
+	api.HandleFunc("POST /run", d.handleRun)
+	api.HandleFunc("GET /status", d.handleStatus)
+	api.HandleFunc("GET /history", d.handleHistory)
+	api.HandleFunc("GET /plan", d.handlePlan)
+	api.HandleFunc("GET /quarantine", d.handleQuarantine)
+	api.HandleFunc("POST /restore", d.handleRestore)
+	api.HandleFunc("GET /config", d.handleGetConfig)
+	api.HandleFunc("PUT /config", d.handlePutConfig)
+
+	// The dashboard page itself holds nothing; it asks for the token
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", d.handleDashboard)
+	mux.Handle("/", requireToken(token, api))
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HTTP API stopped: %v", err)
+		}
+	}()
+
+	if !isLoopback(addr) {
+		log.Printf("Warning: the HTTP API on %s is reachable from other machines, over plain HTTP", addr)
+	}
+	log.Printf("HTTP API listening on %s (token in %s)", ln.Addr(), path)
+	return srv, nil
+}
+
+// apiToken returns the API token and the file it's kept in, creating one
+// readable only by the daemon's user if there's none yet.
+func (d *daemon) apiToken() (string, string, error) {
+	app, err := commandApp(d.system)
+	if err != nil {
+		return "", "", err
+	}
+	path := filepath.Join(app.stateDir, apiTokenFileName)
+
+	if data, err := os.ReadFile(path); err == nil {
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, path, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", "", fmt.Errorf("failed to read API token: %w", err)
+	}
+
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", "", fmt.Errorf("failed to generate API token: %w", err)
+	}
+	token := hex.EncodeToString(b[:])
+	if err := os.MkdirAll(app.stateDir, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", "", fmt.Errorf("failed to write API token: %w", err)
+	}
+	return token, path, nil
+}
+
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// requireToken turns away requests without "Authorization: Bearer <token>".
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="saafsafai"`)
+			writeJSONError(w, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (d *daemon) handleRun(w http.ResponseWriter, r *http.Request) {
+	d.runNow()
+	writeJSON(w, http.StatusAccepted, map[string]any{"queued": true})
+}
+
+func (d *daemon) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, d.status())
+}
+
+// handleHistory returns the recorded runs, oldest first; ?last=N returns
+// only the last N.
+func (d *daemon) handleHistory(w http.ResponseWriter, r *http.Request) {
+	last := 0
+	if v := r.URL.Query().Get("last"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeJSONError(w, http.StatusBadRequest, "invalid last "+strconv.Quote(v)+", expected a positive number")
+			return
+		}
+		last = n
+	}
+
+	app, err := commandApp(d.system)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	records, err := app.loadHistory()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to read run history: %v", err))
+		return
+	}
+	if last > 0 && len(records) > last {
+		records = records[len(records)-last:]
+	}
+	if records == nil {
+		records = []runRecord{}
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+// handlePlan returns what a run would clean now, from a dry run.
+func (d *daemon) handlePlan(w http.ResponseWriter, r *http.Request) {
+	summary, err := d.plan()
+	if err != nil {
+		writeJSONError(w, lockStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// plan does a dry run with a fresh App and returns its summary. Unlike
+// run, it writes no report.
+func (d *daemon) plan() (*Summary, error) {
+	app, err := commandApp(d.system)
+	if err != nil {
+		return nil, err
+	}
+	app.dryRun, app.unattended = true, true
+	app.summary.DryRun = true
+
+	config, err := app.loadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	unlock, err := app.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	app.pickLevel(config)
+	app.runCleaners(config)
+	return &app.summary, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// appImageVersion matches the start of the version part of an AppImage file
+// name, e.g. "-1.5.3" in "Obsidian-1.5.3.AppImage" or "_v2" in "tool_v2.AppImage".
+var appImageVersion = regexp.MustCompile(`[-_ ]v?\d`)
+
+type appImageFile struct {
+	path    string
+	modTime int64
+}
+
+func (app *App) cleanOldAppImages(config Config) error {
+	dirs := []string{
+		filepath.Join(app.homeDir, "Applications"),
+		app.downloadsDir,
+		filepath.Join(app.downloadsDir, categoryFolder(config.CategoryNames, categoryFor(config, ".appimage"))),
+	}
+
+	groups := make(map[string][]appImageFile)
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				app.skipItem("Failed to read", dir, err)
+			}
+			continue
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || strings.ToLower(filepath.Ext(entry.Name())) != ".appimage" {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			name := appImageName(entry.Name())
+			groups[name] = append(groups[name], appImageFile{
+				path:    filepath.Join(dir, entry.Name()),
+				modTime: info.ModTime().UnixNano(),
+			})
+		}
+	}
+
+	for _, files := range groups {
+		if len(files) < 2 {
+			continue
+		}
+
+		newest := files[0]
+		for _, f := range files[1:] {
+			if f.modTime > newest.modTime {
+				newest = f
+			}
+		}
+
+		for _, f := range files {
+			if f.path == newest.path {
+				continue
+			}
+			if err := app.remove(f.path); err != nil {
+				app.skipItem("Failed to remove AppImage", f.path, err)
+				continue
+			}
+			app.addItem(&app.summary.RemovedAppImages, fmt.Sprintf("%s (keeping %s)", f.path, filepath.Base(newest.path)))
+		}
+	}
+
+	return nil
+}
+
+// appImageName returns the application part of an AppImage file name, so
+// that different versions of the same application (however their names are
+// composed) group together.
+func appImageName(fileName string) string {
+	stem := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	if loc := appImageVersion.FindStringIndex(stem); loc != nil && loc[0] > 0 {
+		stem = stem[:loc[0]]
+	}
+	return strings.ToLower(composeName(stem))
+}
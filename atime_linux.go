@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	// statxBirthTime asks statx for the creation time
+	statxBirthTime = 0x800
+	// statxBirthOffset is where it is in struct statx, after the access time
+	statxBirthOffset = 80
+	atFdcwd          = -100
+	atNoFollow       = 0x100
+)
+
+// statxSyscalls are the statx system call numbers by architecture, which
+// the syscall package doesn't have.
+var statxSyscalls = map[string]uintptr{
+	"amd64": 332, "386": 383, "arm": 397, "arm64": 291, "riscv64": 291,
+	"loong64": 291, "ppc64": 383, "ppc64le": 383, "s390x": 379,
+}
+
+// accessTime returns the file's last access time, falling back to the
+// modification time if it isn't available.
+func accessTime(info os.FileInfo) time.Time {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(stat.Atim.Sec, stat.Atim.Nsec)
+	}
+	return info.ModTime()
+}
+
+// birthTime returns when the file at path was created, from statx, if the
+// kernel and file system record it (ext4, Btrfs, XFS and tmpfs do).
+func birthTime(path string) (time.Time, bool) {
+	nr, ok := statxSyscalls[runtime.GOARCH]
+	if !ok {
+		return time.Time{}, false
+	}
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	var buf [256]byte
+	dirfd := atFdcwd
+	if _, _, errno := syscall.Syscall6(nr, uintptr(dirfd), uintptr(unsafe.Pointer(p)), atNoFollow, statxBirthTime, uintptr(unsafe.Pointer(&buf[0])), 0); errno != 0 {
+		return time.Time{}, false
+	}
+	if binary.NativeEndian.Uint32(buf[0:])&statxBirthTime == 0 {
+		return time.Time{}, false
+	}
+	sec := int64(binary.NativeEndian.Uint64(buf[statxBirthOffset:]))
+	nsec := int64(binary.NativeEndian.Uint32(buf[statxBirthOffset+8:]))
+	return time.Unix(sec, nsec), true
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// accessTime returns the modification time on platforms where the access
+// time isn't exposed the same way.
+func accessTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
+
+// birthTime finds no creation times on platforms without statx.
+func birthTime(path string) (time.Time, bool) {
+	return time.Time{}, false
+}
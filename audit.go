@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	auditFileName = "audit.jsonl"
+	// auditHashLimit is the size up to which removed and moved files are
+	// hashed for the audit log; bigger ones would slow a run down too much.
+	auditHashLimit = 256 << 20
+)
+
+// auditRecord is one line of the audit log: a file or folder a run removed,
+// quarantined or moved, or a file it ran a download action script (Dst) on. Unlike the summary it's never rewritten, so it can
+// answer what happened to a file long after the run's report is gone.
+type auditRecord struct {
+	Time    time.Time `json:"time"`
+	RunID   string    `json:"run_id"`
+	Cleaner string    `json:"cleaner"`
+	Action  string    `json:"action"` // "remove", "quarantine", "move" or "script"
+	Src     string    `json:"src"`
+	Dst     string    `json:"dst,omitempty"`
+	Size    int64     `json:"size"`
+	// Hash is the SHA-256 of a file's content; folders and files over
+	// auditHashLimit have none.
+	Hash string `json:"hash,omitempty"`
+	// Origin and Downloaded are where and when a browser downloaded the
+	// file, with browser_history
+	Origin     string    `json:"origin,omitempty"`
+	Downloaded time.Time `json:"downloaded,omitzero"`
+}
+
+func (app *App) auditPath() string {
+	return filepath.Join(app.stateDir, auditFileName)
+}
+
+// audited carries out a journaled action and, if it succeeds, appends it to
+// the audit log. The size and hash are taken before, while the file is
+// still there.
+func (app *App) audited(entry journalEntry, action func() error) error {
+	if app.dryRun {
+		return action()
+	}
+
+	record := auditRecord{
+		RunID:   app.summary.RunID,
+		Cleaner: app.cleaner,
+		Action:  entry.Op,
+		Src:     entry.Path,
+		Dst:     entry.Dest,
+	}
+	if origin, ok := app.origins[entry.Path]; ok {
+		record.Origin, record.Downloaded = origin.URL, origin.Time
+	}
+	if entry.Op == opRemove && app.quarantine {
+		record.Action = "quarantine"
+	} else if entry.Op == opRemove && app.trashConfig.Enabled {
+		record.Action = "trash"
+	}
+	if info, err := os.Lstat(entry.Path); err == nil {
+		record.Size = info.Size()
+		if info.IsDir() {
+			record.Size = dirSize(entry.Path)
+		}
+		if info.Mode().IsRegular() && info.Size() <= auditHashLimit {
+			record.Hash, _ = fileHash(entry.Path, app.limiter())
+		}
+	}
+
+	if err := action(); err != nil {
+		return err
+	}
+	record.Time = time.Now()
+	if record.Action != opRemove && record.Action != opScript {
+		app.undoable++
+	}
+	if err := app.writeAudit(record); err != nil {
+		log.Printf("Failed to write audit log: %v", err)
+	}
+	return nil
+}
+
+func (app *App) writeAudit(record auditRecord) error {
+	record.Time, record.Downloaded = record.Time.UTC(), record.Downloaded.UTC()
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if app.auditFile == nil {
+		if err := os.MkdirAll(app.stateDir, 0755); err != nil {
+			return fmt.Errorf("failed to create state directory: %w", err)
+		}
+		f, err := os.OpenFile(app.auditPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		app.auditFile = f
+	}
+	_, err = app.auditFile.Write(append(data, '\n'))
+	return err
+}
+
+// showAudit prints the audit records of path, or of what was under it, as
+// the source or the destination.
+func (app *App) showAudit(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(app.auditPath())
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no audit log yet at %s", app.auditPath())
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	found := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record auditRecord
+		if json.Unmarshal(scanner.Bytes(), &record) != nil {
+			continue
+		}
+		if !matchesPath(record.Src, path) && !matchesPath(record.Dst, path) {
+			continue
+		}
+		found++
+		line := fmt.Sprintf("%s  %s  %-10s %s", record.Time.Local().Format("2006-01-02 15:04:05"), record.RunID, record.Action, record.Src)
+		if record.Dst != "" {
+			line += " → " + record.Dst
+		}
+		line += fmt.Sprintf("  (%s, %s", record.Cleaner, formatSize(record.Size))
+		if record.Hash != "" {
+			line += ", sha256 " + record.Hash
+		}
+		if record.Origin != "" {
+			line += ", " + T("audit.origin", record.Origin, record.Downloaded.Local().Format("2006-01-02"))
+		}
+		fmt.Println(line + ")")
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	if found == 0 {
+		fmt.Println(T("audit.none", path))
+	}
+	return nil
+}
+
+func matchesPath(path, target string) bool {
+	return path != "" && (path == target || isUnder(path, target))
+}
+
+func (app *App) closeAudit() {
+	if app.auditFile != nil {
+		app.auditFile.Close()
+		app.auditFile = nil
+	}
+}
+
+// fileHash returns the hex SHA-256 of the file at path, read through lim.
+func fileHash(path string, lim *readLimiter) (string, error) {
+	f, err := lim.open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+const (
+	auditKeyFileName = "audit-key.pem"
+	auditReportAlg   = "ed25519"
+)
+
+// auditReport is what saafsafai audit found reclaimable on a host, for one
+// user or, from the system-wide configuration, for the system and each user.
+type auditReport struct {
+	Host      string         `json:"host"`
+	User      string         `json:"user"`
+	Generated time.Time      `json:"generated"`
+	Level     string         `json:"level"`
+	Cleaners  []auditCleaner `json:"cleaners"`
+	// ReclaimableBytes totals the cleaners, and with Users, theirs too.
+	ReclaimableBytes int64         `json:"reclaimable_bytes"`
+	Users            []auditReport `json:"users,omitempty"`
+	Errors           []string      `json:"errors,omitempty"`
+}
+
+// auditCleaner is what one cleaner could free. Docker and Kubernetes dry
+// runs only list what they'd remove, so their bytes aren't Measured.
+type auditCleaner struct {
+	Name     string `json:"name"`
+	Items    int    `json:"items"`
+	Bytes    int64  `json:"bytes"`
+	Measured bool   `json:"measured"`
+}
+
+// signedAuditReport is the report as printed: its JSON, the signature over
+// that JSON written compact and the public key to check it with.
+type signedAuditReport struct {
+	Report    json.RawMessage `json:"report"`
+	Algorithm string          `json:"algorithm"`
+	PublicKey string          `json:"public_key"`
+	Signature string          `json:"signature"`
+}
+
+func defineAuditCommand(fs *flag.FlagSet) func(args []string) error {
+	system := fs.Bool("system", false, "audit the system and every user under /home (as root)")
+	level := fs.String("level", "", "audit at this `level` (default: the config's level)")
+	keyPath := fs.String("key", "", "sign with the Ed25519 private key in this PEM `file` (default: a key of this machine's own)")
+	output := fs.String("output", "", "write the report to `file` instead of standard output")
+	unsigned := fs.Bool("unsigned", false, "print the report without signing it")
+	verify := fs.String("verify", "", "check the signature of the report in `file` instead of auditing")
+
+	return func(args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("expected: audit")
+		}
+		if *verify != "" {
+			return verifyAuditReport(*verify)
+		}
+		if *level != "" {
+			if _, err := findLevel(*level); err != nil {
+				return err
+			}
+		}
+		app, err := commandApp(*system)
+		if err != nil {
+			return err
+		}
+		app.levelFlag = *level
+		return app.audit(*keyPath, *output, *unsigned)
+	}
+}
+
+// audit runs every cleaner of this mode as a dry run, enabled or not, and
+// writes what they could free as a signed JSON report. Nothing is changed,
+// logged or recorded in the history.
+func (app *App) audit(keyPath, output string, unsigned bool) error {
+	var key ed25519.PrivateKey
+	if !unsigned {
+		var err error
+		if key, err = app.auditKey(keyPath); err != nil {
+			return err
+		}
+	}
+
+	// Whatever the cleaners print goes to standard error, so standard
+	// output only has the report
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	report, err := app.auditSpace()
+	os.Stdout = stdout
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	if !unsigned {
+		data, err = json.MarshalIndent(signedAuditReport{
+			Report:    data,
+			Algorithm: auditReportAlg,
+			PublicKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+			Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)),
+		}, "", "  ")
+		if err != nil {
+			return err
+		}
+	}
+	data = append(data, '\n')
+
+	if output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// auditSpace dry-runs the cleaners and, from the system-wide configuration,
+// has each user audit their own home.
+func (app *App) auditSpace() (auditReport, error) {
+	config, err := app.loadConfig()
+	if err != nil {
+		// Users without a config of their own are audited with the defaults
+		if app.system {
+			return auditReport{}, fmt.Errorf("failed to load config: %w", err)
+		}
+		config = defaultConfig()
+	}
+	unlock, err := app.lock()
+	if err != nil {
+		return auditReport{}, err
+	}
+	defer unlock()
+
+	app.dryRun, app.summary.DryRun, app.unattended = true, true, true
+	app.pickLevel(config)
+
+	mode := app.mode()
+	for _, o := range setupOptions() {
+		if o.modes&mode != 0 && (o.available == nil || o.available()) {
+			*o.field(&config) = true
+		}
+	}
+
+	host, _ := os.Hostname()
+	report := auditReport{Host: host, Generated: time.Now().UTC(), Level: app.level.name}
+	if u, err := user.Current(); err == nil {
+		report.User = u.Username
+	}
+	for _, c := range cleaners {
+		if c.modes&mode == 0 || !c.enabled(config) || !app.selected(c.name) || c.name == "users" || c.name == "maintenance" {
+			continue
+		}
+		freed, items := app.summary.FreedBytes, app.foundCount()
+		if err := c.run(app, config); err != nil {
+			app.logError("Error auditing %s: %v", c.description, err)
+		}
+		row := auditCleaner{Name: c.name, Items: app.foundCount() - items, Bytes: app.summary.FreedBytes - freed, Measured: c.name != "docker" && c.name != "kube"}
+		report.Cleaners = append(report.Cleaners, row)
+		report.ReclaimableBytes += row.Bytes
+	}
+
+	if app.system {
+		users, err := app.auditUsers()
+		if err != nil {
+			app.logError("Failed to audit users: %v", err)
+		}
+		for _, u := range users {
+			report.Users = append(report.Users, u)
+			report.ReclaimableBytes += u.ReclaimableBytes
+		}
+	}
+	report.Errors = app.summary.Errors.Sample
+	return report, nil
+}
+
+// auditUsers has each user with a home under /home audit it, as themselves,
+// and returns their unsigned reports; the system report they're part of is
+// signed as a whole.
+func (app *App) auditUsers() ([]auditReport, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get executable path: %w", err)
+	}
+	homes, err := userHomes()
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []auditReport
+	for _, h := range homes {
+		args := []string{"audit", "--unsigned"}
+		if app.levelFlag != "" {
+			args = append(args, "--level", app.levelFlag)
+		}
+		var output bytes.Buffer
+		cmd := userCommand(self, h, args...)
+		cmd.Stdout = &output
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			app.logError("Audit of %s failed: %v", h.user.Username, err)
+			continue
+		}
+		var report auditReport
+		if err := json.Unmarshal(output.Bytes(), &report); err != nil {
+			app.logError("Audit of %s returned an invalid report: %v", h.user.Username, err)
+			continue
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// auditKey loads the Ed25519 key reports are signed with: the one in
+// keyPath, or this machine's own, created in the state directory the first
+// time.
+func (app *App) auditKey(keyPath string) (ed25519.PrivateKey, error) {
+	if keyPath == "" {
+		keyPath = filepath.Join(app.stateDir, auditKeyFileName)
+		if _, err := os.Stat(keyPath); os.IsNotExist(err) {
+			return app.createAuditKey(keyPath)
+		}
+	}
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM key in %s", keyPath)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an Ed25519 key", keyPath)
+	}
+	return key, nil
+}
+
+func (app *App) createAuditKey(path string) (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(app.stateDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return nil, fmt.Errorf("failed to save signing key: %w", err)
+	}
+	log.Printf("Created the audit signing key %s, fingerprint %s", path, keyFingerprint(key.Public().(ed25519.PublicKey)))
+	return key, nil
+}
+
+// keyFingerprint identifies a public key the way ssh-keygen does: the
+// unpadded base64 of its SHA-256.
+func keyFingerprint(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// verifyAuditReport checks the signature of a saved report and prints the
+// fingerprint of the key that made it, to compare with the one expected of
+// the host.
+func verifyAuditReport(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var signed signedAuditReport
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return fmt.Errorf("failed to parse report: %w", err)
+	}
+	if signed.Algorithm != auditReportAlg {
+		return fmt.Errorf("unsupported signature algorithm %q", signed.Algorithm)
+	}
+	key, err := base64.StdEncoding.DecodeString(signed.PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New("invalid public key")
+	}
+	sig, sigErr := base64.StdEncoding.DecodeString(signed.Signature)
+	// The report's JSON was signed compact, as Marshal writes it
+	var report bytes.Buffer
+	if err := json.Compact(&report, signed.Report); err != nil {
+		return fmt.Errorf("failed to parse report: %w", err)
+	}
+	if sigErr != nil || !ed25519.Verify(key, report.Bytes(), sig) {
+		return errors.New("the signature doesn't match the report")
+	}
+	fmt.Println(T("audit_report.valid", keyFingerprint(key)))
+	return nil
+}
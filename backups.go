@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+const backupFileMaxAge = 30 // days
+
+// backupExts are the extensions of the copies editors and patch tools leave
+// behind; swapExts those of Vim's (and nano's) swap files, which are only
+// removed when no editor has them.
+var (
+	backupExts = []string{".bak", ".orig", ".rej"}
+	swapExts   = []string{".swp", ".swo"}
+)
+
+// swapHeader is how Vim and nano swap files start; the creator's PID is at
+// swapPIDOffset, little-endian, and its host name at swapHostOffset.
+const (
+	swapHeader     = "b0"
+	swapPIDOffset  = 24
+	swapHostOffset = 68
+	swapHostSize   = 40
+)
+
+type BackupFilesConfig struct {
+	// MaxAgeDays only removes backup files older than this many days
+	// (default 30).
+	MaxAgeDays int `json:"max_age_days"`
+	// Roots are the directories looked in, relative to the home directory
+	// unless absolute; empty looks everywhere in it.
+	Roots []string `json:"roots"`
+}
+
+func isBackupFile(name string) bool {
+	if len(name) > 1 && strings.HasSuffix(name, "~") {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	return len(name) > len(ext) && (slices.Contains(backupExts, ext) || slices.Contains(swapExts, ext))
+}
+
+// backupKind returns the kind of backup file name is, for the summary, e.g.
+// *~ or *.bak.
+func backupKind(name string) string {
+	if strings.HasSuffix(name, "~") {
+		return "*~"
+	}
+	return "*" + strings.ToLower(filepath.Ext(name))
+}
+
+func isSwapFile(name string) bool {
+	return slices.Contains(swapExts, strings.ToLower(filepath.Ext(name)))
+}
+
+// scanBackupFiles is the backup file matcher for the home directory scan,
+// unless the config has roots of its own to look in.
+func (app *App) scanBackupFiles(config Config) homeMatcher {
+	if len(config.BackupFiles.Roots) > 0 {
+		return nil
+	}
+	return app.matchBackupFiles()
+}
+
+// matchBackupFiles collects the backup and swap files, leaving saafsafai's
+// own state alone.
+func (app *App) matchBackupFiles() homeMatcher {
+	return func(path string, d fs.DirEntry) error {
+		if d.IsDir() {
+			if path == app.stateDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && isBackupFile(d.Name()) {
+			app.scan.backupFiles = append(app.scan.backupFiles, path)
+		}
+		return nil
+	}
+}
+
+// cleanBackupFiles removes the editor backups (file~, .bak, .orig, .rej) and
+// swap files older than the configured age, in the home directory or the
+// configured roots, except those a running program has open.
+func (app *App) cleanBackupFiles(config Config) error {
+	cfg := config.BackupFiles
+	cutoff := app.ageCutoff(cfg.MaxAgeDays, backupFileMaxAge)
+
+	if err := app.scanRoots(config, cfg.Roots, app.matchBackupFiles()); err != nil {
+		return fmt.Errorf("error scanning for backup files: %w", err)
+	}
+
+	// Only looked up if there's something to remove
+	var open map[string]bool
+	for _, path := range app.scan.backupFiles {
+		info, err := os.Lstat(path)
+		if err != nil || !app.fileTime(path, info).Before(cutoff) {
+			continue
+		}
+		if open == nil {
+			open = openFiles()
+		}
+		if open[path] || (isSwapFile(path) && swapInUse(path)) {
+			continue
+		}
+		if err := app.remove(path); err != nil {
+			app.skipItem("Failed to remove backup file", path, err)
+			continue
+		}
+		app.addGroupedItem(&app.summary.RemovedBackups, backupKind(filepath.Base(path)), fmt.Sprintf("%s (%s)", app.displayPath(path), formatSize(info.Size())))
+	}
+	return nil
+}
+
+// swapInUse reports whether the Vim or nano session that made a swap file
+// may still be running: its process is alive, or it ran on another host
+// sharing the directory, where that can't be told.
+func swapInUse(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return true
+	}
+	defer f.Close()
+	header := make([]byte, swapHostOffset+swapHostSize)
+	if _, err := io.ReadFull(f, header); err != nil || !strings.HasPrefix(string(header), swapHeader) {
+		// Not a swap file an editor would recognise
+		return false
+	}
+
+	host, _, _ := strings.Cut(string(header[swapHostOffset:]), "\x00")
+	if self, err := os.Hostname(); err == nil && host != "" && host != self {
+		return true
+	}
+	pid := int(binary.LittleEndian.Uint32(header[swapPIDOffset:]))
+	if pid <= 0 {
+		return false
+	}
+	err = syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
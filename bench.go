@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	benchFileName = "bench.json"
+	// benchWalkMax and benchWalkTime bound the walk timed on each disk.
+	benchWalkMax  = 200000
+	benchWalkTime = 10 * time.Second
+	// benchReadBytes is how much is hashed at each worker count, and copied.
+	benchReadBytes = 64 << 20
+	benchMinFile   = 1 << 20
+	// benchBetter is how much faster more workers have to hash to be worth it.
+	benchBetter = 1.05
+)
+
+// benchWorkerCounts are the worker counts hashing is timed with.
+var benchWorkerCounts = []int{1, 2, 4, 8}
+
+// benchResult is what saafsafai bench measured on a disk, through one of its
+// directories.
+type benchResult struct {
+	Dir               string    `json:"dir"`
+	Time              time.Time `json:"time"`
+	WalkEntriesPerSec float64   `json:"walk_entries_per_sec"`
+	// HashMBps are the megabytes a second hashed, by worker count
+	HashMBps map[int]float64 `json:"hash_mbps"`
+	CopyMBps float64         `json:"copy_mbps"`
+	// Workers is the count hashing was fastest with, which cleaners working
+	// on the disk use when their max_workers is automatic
+	Workers int `json:"workers"`
+}
+
+func defineBenchCommand(fs *flag.FlagSet) func(args []string) error {
+	system := fs.Bool("system", false, "benchmark the disks of the system-wide configuration")
+
+	return func(args []string) error {
+		app, err := commandApp(*system)
+		if err != nil {
+			return err
+		}
+		return app.bench(args)
+	}
+}
+
+// bench times walking, hashing and copying on the disks the cleaners work
+// on (or those of dirs), recommends limits for them and saves the results,
+// so later runs pick their worker counts by them.
+func (app *App) bench(dirs []string) error {
+	// The config only moves Downloads, and isn't needed to benchmark
+	if _, err := os.Stat(app.configPath); err == nil {
+		if _, err := app.loadConfig(); err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+	}
+	if len(dirs) == 0 {
+		dirs = []string{app.homeDir, app.downloadsDir, filepath.Join(app.homeDir, "Pictures"), app.stateDir}
+	}
+
+	results := app.loadBench()
+	seen := make(map[uint64]bool)
+	for _, dir := range dirs {
+		dir, _ = filepath.Abs(dir)
+		dev, ok := deviceOf(dir)
+		if !ok || seen[dev] {
+			continue
+		}
+		seen[dev] = true
+
+		fmt.Println(T("bench.disk", app.displayPath(dir)))
+		r, err := benchDir(dir)
+		if err != nil {
+			log.Printf("Warning: failed to benchmark %s: %v", dir, err)
+			continue
+		}
+		app.printBench(r)
+
+		// The new result replaces any earlier one for the disk
+		kept := []benchResult{r}
+		for _, old := range results {
+			if d, ok := deviceOf(old.Dir); !ok || d != dev {
+				kept = append(kept, old)
+			}
+		}
+		results = kept
+	}
+	if err := app.saveBench(results); err != nil {
+		return fmt.Errorf("failed to save benchmark results: %w", err)
+	}
+	fmt.Println(T("bench.saved", app.displayPath(filepath.Join(app.stateDir, benchFileName))))
+	return nil
+}
+
+// benchDir walks dir for a while, then hashes the bigger files it found at
+// each worker count and copies one next to it. Files hashed recently may be
+// read from memory rather than the disk, so a second run can measure faster.
+func benchDir(dir string) (benchResult, error) {
+	r := benchResult{Dir: dir, Time: time.Now(), HashMBps: make(map[int]float64)}
+
+	// Each worker count hashes files of its own, and the copy another, so
+	// none reads what an earlier one left in the page cache
+	groups := make([][]string, len(benchWorkerCounts)+1)
+	var groupBytes int64
+	group := 0
+	entries := 0
+	start := time.Now()
+	err := walkTree(dir, func(rel string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if rel == "." {
+				return err
+			}
+			return nil
+		}
+		entries++
+		if group < len(groups) && d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil && info.Size() >= benchMinFile {
+				groups[group] = append(groups[group], filepath.Join(dir, rel))
+				if groupBytes += info.Size(); groupBytes >= benchReadBytes {
+					group, groupBytes = group+1, 0
+				}
+			}
+		}
+		if entries >= benchWalkMax || time.Since(start) >= benchWalkTime {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return r, err
+	}
+	r.WalkEntriesPerSec = float64(entries) / time.Since(start).Seconds()
+
+	best := 0.0
+	for i, workers := range benchWorkerCounts {
+		if len(groups[i]) == 0 || workers > 2*runtime.NumCPU() {
+			break
+		}
+		mbps := benchHash(groups[i], workers)
+		r.HashMBps[workers] = mbps
+		if mbps > best*benchBetter {
+			best, r.Workers = mbps, workers
+		}
+	}
+
+	copyFiles := groups[len(groups)-1]
+	if len(copyFiles) == 0 {
+		for i := len(groups) - 2; i >= 0 && len(copyFiles) == 0; i-- {
+			copyFiles = groups[i]
+		}
+	}
+	if len(copyFiles) > 0 {
+		mbps, err := benchCopy(copyFiles[0], dir)
+		if err != nil {
+			log.Printf("Warning: failed to time copying in %s: %v", dir, err)
+		}
+		r.CopyMBps = mbps
+	}
+	return r, nil
+}
+
+// benchHash returns how many megabytes a second workers hash files at.
+func benchHash(files []string, workers int) float64 {
+	lim := &readLimiter{workers: workers}
+	sizes := make([]int64, len(files))
+	start := time.Now()
+	lim.each(len(files), func(i int) {
+		if info, err := os.Stat(files[i]); err == nil {
+			if _, err := fileHash(files[i], nil); err == nil {
+				sizes[i] = info.Size()
+			}
+		}
+	})
+	elapsed := time.Since(start).Seconds()
+	var total int64
+	for _, size := range sizes {
+		total += size
+	}
+	return float64(total) / 1e6 / elapsed
+}
+
+// benchCopy returns how many megabytes a second file copies at, up to
+// benchReadBytes of it, into a temporary file in dir that it then removes.
+// The copy is synced, so that it's timed to the disk.
+func benchCopy(file, dir string) (float64, error) {
+	in, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	out, err := os.CreateTemp(dir, ".saafsafai-bench-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	start := time.Now()
+	n, err := io.Copy(out, io.LimitReader(in, benchReadBytes))
+	if err == nil {
+		err = out.Sync()
+	}
+	if err != nil {
+		return 0, err
+	}
+	return float64(n) / 1e6 / time.Since(start).Seconds(), nil
+}
+
+// printBench prints a disk's results and the limits they suggest.
+func (app *App) printBench(r benchResult) {
+	fmt.Println(T("bench.walk", r.WalkEntriesPerSec))
+	if len(r.HashMBps) == 0 {
+		fmt.Println(T("bench.no_files", formatSize(benchMinFile)))
+		return
+	}
+	var hashes []string
+	for _, workers := range benchWorkerCounts {
+		if mbps, ok := r.HashMBps[workers]; ok {
+			hashes = append(hashes, fmt.Sprintf("%d: %.0f MB/s", workers, mbps))
+		}
+	}
+	fmt.Println(T("bench.hash", strings.Join(hashes, ", ")))
+	if r.CopyMBps > 0 {
+		fmt.Println(T("bench.copy", r.CopyMBps))
+	}
+	// Half the disk's read rate leaves the other half to whatever else runs
+	fmt.Println(T("bench.recommend", r.Workers, max(int(r.HashMBps[r.Workers]/2), 1)))
+}
+
+// benchWorkers returns the worker count saafsafai bench found fastest on the
+// disk the running cleaner works on, or 0 if it hasn't been run there.
+func (app *App) benchWorkers() int {
+	dir := app.homeDir
+	if paths, ok := cleanerPaths[app.cleaner]; ok {
+		for _, path := range paths(app) {
+			if _, err := os.Stat(path); err == nil {
+				dir = path
+				break
+			}
+		}
+	}
+	dev, ok := deviceOf(dir)
+	if !ok {
+		return 0
+	}
+	for _, r := range app.loadBench() {
+		if d, ok := deviceOf(r.Dir); ok && d == dev {
+			return r.Workers
+		}
+	}
+	return 0
+}
+
+// deviceOf returns the device of the file system path is on.
+func deviceOf(path string) (uint64, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Dev), true
+}
+
+func (app *App) loadBench() []benchResult {
+	var results []benchResult
+	if data, err := os.ReadFile(filepath.Join(app.stateDir, benchFileName)); err == nil {
+		json.Unmarshal(data, &results)
+	}
+	return results
+}
+
+func (app *App) saveBench(results []benchResult) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(app.stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return os.WriteFile(filepath.Join(app.stateDir, benchFileName), data, 0644)
+}
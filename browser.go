@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// downloadOrigin is where and when a browser downloaded a file, from its
+// download history.
+type downloadOrigin struct {
+	URL  string
+	Time time.Time
+}
+
+// chromeEpoch is where Chrome's timestamps, in microseconds, start.
+var chromeEpoch = time.Date(1601, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const (
+	firefoxDownloadsQuery = `SELECT a.content AS path, p.url AS url, a.dateAdded AS time
+		FROM moz_annos a
+		JOIN moz_anno_attributes n ON n.id = a.anno_attribute_id
+		JOIN moz_places p ON p.id = a.place_id
+		WHERE n.name = 'downloads/destinationFileURI'`
+	// The last URL of the redirect chain is the file's, the tab's where the
+	// download started
+	chromeDownloadsQuery = `SELECT d.target_path AS path, d.start_time AS time,
+		COALESCE((SELECT c.url FROM downloads_url_chains c WHERE c.id = d.id ORDER BY c.chain_index DESC LIMIT 1), d.tab_url) AS url
+		FROM downloads d`
+)
+
+// browserHistories are the download history databases of the supported
+// browsers, as globs under the home directory.
+var browserHistories = []struct {
+	glob  string
+	query string
+}{
+	{".mozilla/firefox/*/places.sqlite", firefoxDownloadsQuery},
+	{"snap/firefox/common/.mozilla/firefox/*/places.sqlite", firefoxDownloadsQuery},
+	{".config/google-chrome/*/History", chromeDownloadsQuery},
+	{".config/chromium/*/History", chromeDownloadsQuery},
+	{".config/BraveSoftware/Brave-Browser/*/History", chromeDownloadsQuery},
+}
+
+// downloadOrigins reads the browsers' download histories into a map from
+// each downloaded file's path to its origin; the newest download of a path
+// wins. It needs the sqlite3 command. Histories that can't be read are
+// skipped, since they're only a hint.
+func (app *App) downloadOrigins() map[string]downloadOrigin {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		log.Printf("sqlite3 is not installed, not reading browser download history")
+		return nil
+	}
+	defer app.timeAction("browser history", time.Now())
+
+	origins := make(map[string]downloadOrigin)
+	for _, history := range browserHistories {
+		dbs, _ := filepath.Glob(filepath.Join(app.homeDir, history.glob))
+		for _, db := range dbs {
+			rows, err := queryHistory(db, history.query)
+			if err != nil {
+				log.Printf("Failed to read download history %s: %v", db, err)
+				continue
+			}
+			for _, row := range rows {
+				path, when := row.Path, time.UnixMicro(row.Time)
+				if history.query == firefoxDownloadsQuery {
+					u, err := url.Parse(row.Path)
+					if err != nil || u.Scheme != "file" {
+						continue
+					}
+					path = u.Path
+				} else {
+					when = chromeEpoch.Add(time.Duration(row.Time) * time.Microsecond)
+				}
+				if path == "" || row.URL == "" {
+					continue
+				}
+				if prev, ok := origins[path]; !ok || when.After(prev.Time) {
+					origins[path] = downloadOrigin{URL: row.URL, Time: when}
+				}
+			}
+		}
+	}
+	return origins
+}
+
+type historyRow struct {
+	Path string `json:"path"`
+	URL  string `json:"url"`
+	Time int64  `json:"time"`
+}
+
+// queryHistory runs query on a copy of the database at db, since a running
+// browser keeps it locked.
+func queryHistory(db, query string) ([]historyRow, error) {
+	tmp, err := os.MkdirTemp("", "saafsafai-history-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	// Recent downloads may still be in the write-ahead log
+	copied := filepath.Join(tmp, "history.sqlite")
+	for _, suffix := range []string{"", "-wal"} {
+		info, err := os.Stat(db + suffix)
+		if err != nil {
+			if suffix == "" {
+				return nil, err
+			}
+			continue
+		}
+		if err := copyRegularFile(db+suffix, copied+suffix, info, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	output, err := exec.Command("sqlite3", "-json", copied, query).Output()
+	if err != nil {
+		return nil, fmt.Errorf("sqlite3 failed: %w", err)
+	}
+	var rows []historyRow
+	if len(output) == 0 {
+		return nil, nil
+	}
+	if err := json.Unmarshal(output, &rows); err != nil {
+		return nil, fmt.Errorf("failed to parse sqlite3 output: %w", err)
+	}
+	return rows, nil
+}
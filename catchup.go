@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const catchUpServiceName = "saafsafai-catchup.service"
+
+// scheduleIntervals are the systemd calendar shorthands and how far apart
+// their runs are, as a date offset so months and years come out right.
+var scheduleIntervals = map[string]struct{ years, months, days int }{
+	"daily":        {0, 0, 1},
+	"weekly":       {0, 0, 7},
+	"monthly":      {0, 1, 0},
+	"quarterly":    {0, 3, 0},
+	"semiannually": {0, 6, 0},
+	"yearly":       {1, 0, 0},
+	"annually":     {1, 0, 0},
+}
+
+// catchUp does, like anacron, the run the timer would have done while the
+// machine was off: the cleaners whose last run is older than the schedule's
+// interval run once, after a random delay so they don't slow down the boot.
+// It does nothing if no run was missed.
+func (app *App) catchUp() error {
+	config, err := app.loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	app.pickLevel(config)
+	calendar := config.calendar()
+	if calendar == "" {
+		log.Printf("No schedule to catch up with")
+		return nil
+	}
+	next, err := scheduleNext(calendar)
+	if err != nil {
+		return err
+	}
+
+	due, err := app.dueCleaners(config, next)
+	if err != nil {
+		return err
+	}
+	if len(due) == 0 {
+		log.Printf("No scheduled run was missed")
+		return nil
+	}
+
+	delay, err := randomDelay(config.Timer.RandomizedDelaySec)
+	if err != nil {
+		return err
+	}
+	log.Printf("A scheduled run was missed, catching up in %s", delay.Round(time.Second))
+	time.Sleep(delay)
+
+	// The timer may have run in the meantime
+	if due, err = app.dueCleaners(config, next); err != nil {
+		return err
+	}
+	if len(due) == 0 {
+		log.Printf("No scheduled run was missed")
+		return nil
+	}
+	app.only = due
+	app.only["maintenance"] = true
+	return app.run()
+}
+
+// dueCleaners returns the enabled and selected cleaners that haven't run
+// since their last run's next scheduled time.
+func (app *App) dueCleaners(config Config, next func(time.Time) time.Time) (map[string]bool, error) {
+	lastRuns, err := app.cleanerLastRuns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run history: %w", err)
+	}
+
+	due := make(map[string]bool)
+	mode := app.mode()
+	now := time.Now()
+	for _, c := range cleaners {
+		if c.modes&mode == 0 || !c.enabled(config) || !app.selected(c.name) || c.name == "maintenance" {
+			continue
+		}
+		last, ok := lastRuns[c.name]
+		if !ok || !next(last).After(now) {
+			due[c.name] = true
+		}
+	}
+	return due, nil
+}
+
+// scheduleNext returns a function giving the time of the scheduled run
+// after a given one. Calendar expressions other than the shorthands are
+// measured with systemd-analyze, as the gap between their next two runs.
+func scheduleNext(calendar string) (func(time.Time) time.Time, error) {
+	switch calendar {
+	case "minutely":
+		return func(t time.Time) time.Time { return t.Add(time.Minute) }, nil
+	case "hourly":
+		return func(t time.Time) time.Time { return t.Add(time.Hour) }, nil
+	}
+	if interval, ok := scheduleIntervals[calendar]; ok {
+		return func(t time.Time) time.Time { return t.AddDate(interval.years, interval.months, interval.days) }, nil
+	}
+
+	out, err := exec.Command("systemd-analyze", "calendar", "--iterations=2", calendar).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to work out how often %q runs: %w", calendar, err)
+	}
+	var elapses []time.Time
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ": ")
+		if !ok || (key != "Next elapse" && !strings.HasPrefix(key, "Iter. #")) {
+			continue
+		}
+		// e.g. "Thu 2026-10-15 00:00:00 UTC"; both are in the same zone
+		fields := strings.Fields(value)
+		if len(fields) < 3 {
+			continue
+		}
+		if t, err := time.Parse("2006-01-02 15:04:05", fields[1]+" "+fields[2]); err == nil {
+			elapses = append(elapses, t)
+		}
+	}
+	if len(elapses) < 2 || !elapses[1].After(elapses[0]) {
+		return nil, fmt.Errorf("failed to work out how often %q runs", calendar)
+	}
+	interval := elapses[1].Sub(elapses[0])
+	return func(t time.Time) time.Time { return t.Add(interval) }, nil
+}
+
+// randomDelay picks a delay up to the timer's RandomizedDelaySec (default
+// 30min), as the timer itself would.
+func randomDelay(spec string) (time.Duration, error) {
+	if spec == "" {
+		spec = defaultRandomizedDelay
+	}
+	limit, err := parseTimespan(spec)
+	if err != nil {
+		return 0, fmt.Errorf("invalid randomized_delay_sec: %w", err)
+	}
+	if limit <= 0 {
+		return 0, nil
+	}
+	return rand.N(limit), nil
+}
+
+// timespanUnits are the units of systemd time spans.
+var timespanUnits = map[string]time.Duration{
+	"us": time.Microsecond, "usec": time.Microsecond,
+	"ms": time.Millisecond, "msec": time.Millisecond,
+	"s": time.Second, "sec": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+}
+
+// parseTimespan parses a systemd time span such as "30min", "1h 30min" or a
+// bare number of seconds.
+func parseTimespan(s string) (time.Duration, error) {
+	rest := strings.TrimSpace(s)
+	if rest == "" {
+		return 0, fmt.Errorf("invalid time span %q", s)
+	}
+
+	var total time.Duration
+	for rest != "" {
+		digits := strings.IndexFunc(rest, func(r rune) bool { return !unicode.IsDigit(r) && r != '.' })
+		if digits < 0 {
+			digits = len(rest)
+		}
+		n, err := strconv.ParseFloat(rest[:digits], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid time span %q", s)
+		}
+		rest = strings.TrimLeft(rest[digits:], " ")
+
+		unit := strings.IndexFunc(rest, func(r rune) bool { return !unicode.IsLetter(r) })
+		if unit < 0 {
+			unit = len(rest)
+		}
+		multiplier := time.Second
+		if unit > 0 {
+			var ok bool
+			if multiplier, ok = timespanUnits[rest[:unit]]; !ok {
+				return 0, fmt.Errorf("invalid time span %q", s)
+			}
+		}
+		total += time.Duration(n * float64(multiplier))
+		rest = strings.TrimLeft(rest[unit:], " ")
+	}
+	return total, nil
+}
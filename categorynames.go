@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// categoryFoldersFileName records the folder each Downloads category was
+// last filed into, so a changed category_names entry can move the old
+// folder's files into the new one.
+const categoryFoldersFileName = "category-folders.json"
+
+// categoryFolder returns the name of category's folder in Downloads: its
+// category_names entry, such as "Dokumente" for Documents, or the
+// category's own name.
+func categoryFolder(names map[string]string, category string) string {
+	if name := names[category]; name != "" {
+		return name
+	}
+	return category
+}
+
+// knownCategories returns every category files can be filed into: the
+// built-in ones, Others, Review and those the categories config adds.
+func knownCategories(config Config) []string {
+	seen := map[string]bool{otherCategory: true, reviewCategory: true}
+	for category := range defaultCategories {
+		seen[category] = true
+	}
+	for _, category := range config.Categories {
+		seen[category] = true
+	}
+	return sortedKeys(seen)
+}
+
+// categoryOfFolder returns the category filed into folder, or folder
+// itself as a new category's name.
+func categoryOfFolder(config Config, folder string) string {
+	for _, category := range knownCategories(config) {
+		if strings.EqualFold(categoryFolder(config.CategoryNames, category), folder) {
+			return category
+		}
+	}
+	return folder
+}
+
+// validateCategoryNames checks the config's category_names: each key a
+// known category and each name a folder no other category uses.
+func validateCategoryNames(config Config) error {
+	known := knownCategories(config)
+	for _, category := range sortedKeys(config.CategoryNames) {
+		if !slices.Contains(known, category) {
+			return fmt.Errorf("invalid category_names key %q, expected one of: %s", category, strings.Join(known, ", "))
+		}
+		if name := config.CategoryNames[category]; !validCategory(name) {
+			return fmt.Errorf("invalid category_names.%s %q, expected a folder name", category, name)
+		}
+	}
+	folders := make(map[string]string)
+	for _, category := range known {
+		folder := categoryFolder(config.CategoryNames, category)
+		if other, taken := folders[strings.ToLower(folder)]; taken {
+			return fmt.Errorf("invalid category_names: %s and %s would share the folder %q", other, category, folder)
+		}
+		folders[strings.ToLower(folder)] = category
+	}
+	return nil
+}
+
+func (app *App) loadCategoryFolders() map[string]string {
+	folders := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(app.stateDir, categoryFoldersFileName))
+	if err != nil {
+		return folders
+	}
+	// A corrupt record only means the old folders aren't looked for
+	json.Unmarshal(data, &folders)
+	return folders
+}
+
+func (app *App) saveCategoryFolders(folders map[string]string) error {
+	data, err := json.MarshalIndent(folders, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(app.stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return os.WriteFile(filepath.Join(app.stateDir, categoryFoldersFileName), data, 0644)
+}
+
+// renameCategoryFolders moves the category folders whose category_names
+// entry changed since the last run to their new names, merging them into
+// the new folder where there already is one. A category never renamed was
+// filed under its own name.
+func (app *App) renameCategoryFolders(config Config) {
+	// Two categories sharing a folder can't be told apart again
+	if err := validateCategoryNames(config); err != nil {
+		log.Printf("Warning: not renaming category folders: %v", err)
+		return
+	}
+	folders := app.loadCategoryFolders()
+	current := make(map[string]bool)
+	for _, category := range knownCategories(config) {
+		current[categoryFolder(config.CategoryNames, category)] = true
+	}
+
+	changed := false
+	for _, category := range knownCategories(config) {
+		folder := categoryFolder(config.CategoryNames, category)
+		old := categoryFolder(folders, category)
+		if old != folder {
+			// The old folder is now another category's, whose files it
+			// may already hold
+			if current[old] {
+				log.Printf("Warning: not moving category folder %s into %s, as %s is now another category's", old, folder, old)
+			} else if err := app.renameCategoryFolder(config, old, folder); err != nil {
+				app.skipItem("Failed to rename category folder", filepath.Join(app.downloadsDir, old), err)
+				continue
+			}
+		}
+		if folders[category] != folder {
+			folders[category] = folder
+			changed = true
+		}
+	}
+	if changed && !app.dryRun {
+		if err := app.saveCategoryFolders(folders); err != nil {
+			log.Printf("Warning: failed to save the category folders: %v", err)
+		}
+	}
+}
+
+// renameCategoryFolder renames the category folder old to folder, or moves
+// its files into folder if that exists, giving those whose names are taken
+// a free one. Its index is added to folder's, under the new names. The
+// moves are audited, so undo puts them back.
+func (app *App) renameCategoryFolder(config Config, old, folder string) error {
+	oldDir, newDir := filepath.Join(app.downloadsDir, old), filepath.Join(app.downloadsDir, folder)
+	info, err := os.Lstat(oldDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a folder", app.displayPath(oldDir))
+	}
+	if app.isProtected(oldDir) {
+		return errProtected
+	}
+	item := old + " → " + folder
+	_, err = os.Lstat(newDir)
+	merge := !os.IsNotExist(err)
+	if merge {
+		item += " " + T("summary.merged")
+	}
+	if app.dryRun {
+		app.addItem(&app.summary.RenamedCategories, item)
+		return nil
+	}
+
+	if !merge {
+		move := journalEntry{Op: opMove, Path: oldDir, Dest: newDir}
+		if err := app.journaled(move, func() error { return os.Rename(oldDir, newDir) }); err != nil {
+			return fmt.Errorf("failed to rename folder: %w", err)
+		}
+		app.addItem(&app.summary.RenamedCategories, item)
+		return nil
+	}
+
+	entries, err := os.ReadDir(oldDir)
+	if err != nil {
+		return err
+	}
+	renamed := make(map[string]string)
+	var indexes []string
+	for _, entry := range entries {
+		src := filepath.Join(oldDir, entry.Name())
+		if strings.HasPrefix(entry.Name(), categoryIndexName+".") {
+			indexes = append(indexes, src)
+			continue
+		}
+		name := app.freeName(newDir, entry.Name(), fileExt(config, entry.Name()))
+		dest := filepath.Join(newDir, name)
+		move := journalEntry{Op: opMove, Path: src, Dest: dest}
+		if err := app.journaled(move, func() error { return os.Rename(src, dest) }); err != nil {
+			app.skipItem("Failed to move file", src, err)
+			continue
+		}
+		if name != entry.Name() {
+			renamed[entry.Name()] = name
+		}
+	}
+	sort.Strings(indexes)
+	for _, index := range indexes {
+		if err := mergeCategoryIndex(index, newDir, renamed); err != nil {
+			log.Printf("Warning: failed to merge the index %s into %s: %v", app.displayPath(index), app.displayPath(newDir), err)
+		}
+	}
+	// Left in place if a file couldn't be moved
+	os.Remove(oldDir)
+	app.addItem(&app.summary.RenamedCategories, item)
+	return nil
+}
+
+// mergeCategoryIndex adds the entries of the category index at path to the
+// index in dir of the same format, under the names given in renamed to the
+// files that got new ones, and removes it.
+func mergeCategoryIndex(path, dir string, renamed map[string]string) error {
+	entries := readCategoryIndex(path)
+	for i, entry := range entries {
+		if name, ok := renamed[entry.Name]; ok {
+			entries[i].Name = name
+		}
+	}
+	format := categoryIndexCSV
+	if filepath.Ext(path) == ".jsonl" {
+		format = categoryIndexJSON
+	}
+	if len(entries) > 0 {
+		if err := appendCategoryIndex(dir, format, entries); err != nil {
+			return err
+		}
+	}
+	return os.Remove(path)
+}
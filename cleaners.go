@@ -0,0 +1,494 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Where a cleaner runs: from the user's own runs, the root system service,
+// or both.
+const (
+	userMode = 1 << iota
+	systemMode
+)
+
+// cleaner is one cleanup task. Cleaners run in the order listed.
+type cleaner struct {
+	name        string
+	description string // used in error messages
+	modes       int
+	enabled     func(Config) bool
+	run         func(app *App, config Config) error
+	// paths are the directories the cleaner modifies; the service sandbox
+	// only allows writes there
+	paths func(app *App) []string
+	// configPaths, if set, are more directories it modifies, from the config
+	configPaths func(app *App, config Config) []string
+	// scan, if set, returns the cleaner's matcher for the shared home
+	// directory scan, or nil if it doesn't look in the home directory
+	scan    func(app *App, config Config) homeMatcher
+	options []cleanerOption
+}
+
+// cleanerOption is a config switch for a cleaner. Setup asks about it (in the
+// modes listed, if it's available on this machine) and accepts it as a flag.
+type cleanerOption struct {
+	flag     string
+	usage    string
+	question string // message key, asked in user setup
+	// systemQuestion replaces question in the system setup, if set
+	systemQuestion string
+	modes          int
+	// available reports whether the option applies to this machine; nil
+	// means always
+	available func() bool
+	field     func(c *Config) *bool
+}
+
+var cleaners = []cleaner{
+	{
+		name: "downloads", description: "downloads", modes: userMode,
+		enabled: func(c Config) bool { return c.CleanDownloads },
+		run:     (*App).cleanDownloads,
+		paths:   func(app *App) []string { return []string{app.downloadsDir} },
+		options: []cleanerOption{{
+			flag: "clean-downloads", usage: "organize the Downloads folder", question: "setup.clean_downloads", modes: userMode,
+			field: func(c *Config) *bool { return &c.CleanDownloads },
+		}},
+	},
+	{
+		name: "node_modules", description: "node_modules", modes: userMode,
+		enabled: func(c Config) bool { return c.DeleteNodeModules },
+		run:     func(app *App, c Config) error { return app.cleanOldNodeModules(c) },
+		paths:   func(app *App) []string { return []string{app.homeDir} },
+		scan:    func(app *App, _ Config) homeMatcher { return app.matchNodeModules() },
+		options: []cleanerOption{{
+			flag: "delete-node-modules", usage: "delete node_modules folders unused for 30+ days", question: "setup.delete_node_modules", modes: userMode,
+			field: func(c *Config) *bool { return &c.DeleteNodeModules },
+		}},
+	},
+	{
+		name: "wine", description: "Wine prefixes", modes: userMode,
+		enabled: func(c Config) bool { return c.CleanWinePrefixes },
+		run:     func(app *App, c Config) error { return app.cleanWinePrefixes() },
+		paths:   func(app *App) []string { return []string{app.homeDir} },
+		options: []cleanerOption{{
+			flag: "clean-wine-prefixes", usage: "remove Wine prefixes unused for 90+ days", question: "setup.wine_prefixes", modes: userMode,
+			available: func() bool { return commandExists("wine") },
+			field:     func(c *Config) *bool { return &c.CleanWinePrefixes },
+		}},
+	},
+	{
+		name: "appimages", description: "AppImages", modes: userMode,
+		enabled: func(c Config) bool { return c.CleanOldAppImages },
+		run:     (*App).cleanOldAppImages,
+		paths:   func(app *App) []string { return []string{filepath.Join(app.homeDir, "Applications"), app.downloadsDir} },
+		options: []cleanerOption{{
+			flag: "clean-old-appimages", usage: "remove older versions of duplicate AppImages", question: "setup.appimages", modes: userMode,
+			field: func(c *Config) *bool { return &c.CleanOldAppImages },
+		}},
+	},
+	{
+		name: "mail", description: "mail attachments", modes: userMode,
+		enabled: func(c Config) bool { return c.CleanMailAttachments },
+		run:     func(app *App, c Config) error { return app.cleanMailAttachments(c.Mail) },
+		paths:   (*App).mailAttachmentDirs,
+		options: []cleanerOption{{
+			flag: "clean-mail-attachments", usage: "remove old cached and opened Thunderbird/Evolution attachments", question: "setup.mail_attachments", modes: userMode,
+			field: func(c *Config) *bool { return &c.CleanMailAttachments },
+		}},
+	},
+	{
+		name: "sync_conflicts", description: "sync conflict copies", modes: userMode,
+		enabled: func(c Config) bool { return c.CleanSyncConflicts },
+		run:     (*App).cleanSyncConflicts,
+		paths:   func(app *App) []string { return []string{app.homeDir} },
+		scan:    func(app *App, _ Config) homeMatcher { return app.matchSyncConflicts() },
+		options: []cleanerOption{{
+			flag: "clean-sync-conflicts", usage: "gather old Syncthing, Dropbox and Nextcloud conflict copies", question: "setup.sync_conflicts", modes: userMode,
+			field: func(c *Config) *bool { return &c.CleanSyncConflicts },
+		}},
+	},
+	{
+		name: "backup_files", description: "backup files", modes: userMode,
+		enabled:     func(c Config) bool { return c.CleanBackupFiles },
+		run:         (*App).cleanBackupFiles,
+		paths:       func(app *App) []string { return []string{app.homeDir} },
+		scan:        (*App).scanBackupFiles,
+		configPaths: func(app *App, c Config) []string { return app.resolveRoots(c.BackupFiles.Roots) },
+		options: []cleanerOption{{
+			flag: "clean-backup-files", usage: "remove old editor backups (file~, .bak, .orig, .rej) and stale swap files", question: "setup.backup_files", modes: userMode,
+			field: func(c *Config) *bool { return &c.CleanBackupFiles },
+		}},
+	},
+	{
+		name: "metadata_files", description: "metadata files", modes: userMode,
+		enabled:     func(c Config) bool { return c.CleanMetadataFiles },
+		run:         (*App).cleanMetadataFiles,
+		paths:       func(app *App) []string { return []string{app.homeDir} },
+		scan:        (*App).scanMetadataFiles,
+		configPaths: func(app *App, c Config) []string { return app.resolveRoots(c.MetadataFiles.Roots) },
+		options: []cleanerOption{{
+			flag: "clean-metadata-files", usage: "remove .DS_Store, ._* AppleDouble, Thumbs.db and desktop.ini files", question: "setup.metadata_files", modes: userMode,
+			field: func(c *Config) *bool { return &c.CleanMetadataFiles },
+		}},
+	},
+	{
+		name: "git_repos", description: "git repositories", modes: userMode,
+		enabled: func(c Config) bool { return c.CleanGitRepos },
+		run:     (*App).cleanGitRepos,
+		paths:   func(app *App) []string { return []string{app.homeDir} },
+		scan:    (*App).scanGitRepos,
+		configPaths: func(app *App, c Config) []string {
+			return append(app.resolveRoots(c.GitRepos.Roots), app.archiveDir(c.GitRepos))
+		},
+		options: []cleanerOption{{
+			flag: "clean-git-repos", usage: "expire old reflog entries and run git gc --auto in repositories not tidied recently", question: "setup.git_repos", modes: userMode,
+			available: func() bool { return commandExists("git") },
+			field:     func(c *Config) *bool { return &c.CleanGitRepos },
+		}},
+	},
+	{
+		name: "photos", description: "duplicate photos", modes: userMode,
+		enabled: func(c Config) bool { return c.FindDuplicatePhotos },
+		run:     func(app *App, c Config) error { return app.findDuplicatePhotos(c.Photos) },
+		paths:   (*App).photoDirs,
+		options: []cleanerOption{{
+			flag: "find-duplicate-photos", usage: "look for duplicate photos in Downloads and Pictures", question: "setup.duplicate_photos", modes: userMode,
+			field: func(c *Config) *bool { return &c.FindDuplicatePhotos },
+		}},
+	},
+	{
+		name: "font_caches", description: "font and icon caches", modes: userMode | systemMode,
+		enabled: func(c Config) bool { return c.CleanFontCaches },
+		run:     func(app *App, c Config) error { return app.rebuildFontCaches(c.FontCache) },
+		paths:   func(app *App) []string { return append(app.fontCacheDirs(), app.iconThemeDirs()...) },
+		options: []cleanerOption{{
+			flag: "clean-font-caches", usage: "rebuild stale or oversized font and icon caches",
+			question: "setup.font_caches", systemQuestion: "system_setup.font_caches", modes: userMode | systemMode,
+			available: func() bool { return commandExists("fc-cache", "gtk-update-icon-cache") },
+			field:     func(c *Config) *bool { return &c.CleanFontCaches },
+		}},
+	},
+	{
+		name: "docker", description: "Docker", modes: userMode,
+		enabled: func(c Config) bool { return c.CleanDocker },
+		run:     func(app *App, c Config) error { return app.cleanDocker(c.Docker) },
+		paths:   func(app *App) []string { return []string{filepath.Join(app.homeDir, ".docker")} },
+		options: []cleanerOption{{
+			flag: "clean-docker", usage: "remove dangling Docker images and old anonymous volumes", question: "setup.docker", modes: userMode,
+			available: func() bool { return commandExists("docker") },
+			field:     func(c *Config) *bool { return &c.CleanDocker },
+		}},
+	},
+	{
+		name: "vm_images", description: "VM images", modes: userMode,
+		enabled: func(c Config) bool { return c.CleanVMImages },
+		run:     func(app *App, c Config) error { return app.cleanVMImages(c.VM) },
+		paths: func(app *App) []string {
+			return []string{
+				filepath.Join(app.homeDir, ".local", "share", "libvirt", "images"), "/var/lib/libvirt/images",
+				filepath.Join(app.homeDir, "VirtualBox VMs"), filepath.Join(app.homeDir, "vmware"),
+				filepath.Join(app.homeDir, ".vagrant.d"),
+			}
+		},
+		options: []cleanerOption{{
+			flag: "clean-vm-images", usage: "look for unused VM disk images", question: "setup.vm_images", modes: userMode,
+			field: func(c *Config) *bool { return &c.CleanVMImages },
+		}},
+	},
+	{
+		name: "kube", description: "Kubernetes clusters", modes: userMode,
+		enabled: func(c Config) bool { return c.CleanKubeClusters },
+		run:     func(app *App, c Config) error { return app.cleanKubeClusters(c.Kube) },
+		paths: func(app *App) []string {
+			return []string{filepath.Join(app.homeDir, ".minikube"), filepath.Join(app.homeDir, ".kube"), filepath.Join(app.homeDir, ".docker")}
+		},
+		options: []cleanerOption{{
+			flag: "clean-kube-clusters", usage: "delete stale kind/minikube/k3d clusters", question: "setup.kube", modes: userMode,
+			available: func() bool { return commandExists("kind", "k3d", "minikube") },
+			field:     func(c *Config) *bool { return &c.CleanKubeClusters },
+		}},
+	},
+	{
+		name: "removable", description: "removable media", modes: userMode,
+		enabled: func(c Config) bool { return len(c.Removable) > 0 },
+		run:     func(app *App, c Config) error { return app.cleanRemovable(c) },
+		paths:   func(app *App) []string { return nil },
+		// Wherever the desktop mounts them
+		configPaths: func(app *App, c Config) []string { return []string{"/media", "/run/media", "/mnt"} },
+	},
+	{
+		name: "packages", description: "system packages", modes: userMode | systemMode,
+		enabled: func(c Config) bool { return c.CleanPackageCache || c.RemoveOrphanPackages || c.RemoveOldKernels },
+		run:     func(app *App, c Config) error { app.runPrivilegedCleaners(c); return nil },
+		// From a user's run these go through sudo, which the sandbox blocks
+		// anyway; unattended user runs skip them.
+		paths: func(app *App) []string {
+			if !app.system {
+				return nil
+			}
+			return []string{"/boot", "/etc", "/usr", "/var", "/lib"}
+		},
+		options: []cleanerOption{
+			{
+				flag: "clean-package-cache", usage: "clean the package manager cache",
+				question: "setup.package_cache_sudo", systemQuestion: "system_setup.package_cache", modes: userMode | systemMode,
+				available: hasPackageManager,
+				field:     func(c *Config) *bool { return &c.CleanPackageCache },
+			},
+			{
+				flag: "remove-orphan-packages", usage: "remove orphaned packages", question: "system_setup.orphans", modes: systemMode,
+				available: hasPackageManager,
+				field:     func(c *Config) *bool { return &c.RemoveOrphanPackages },
+			},
+			{
+				flag: "remove-old-kernels", usage: "remove old kernels", question: "system_setup.kernels", modes: systemMode,
+				available: func() bool { return commandExists("apt-get", "dnf") },
+				field:     func(c *Config) *bool { return &c.RemoveOldKernels },
+			},
+		},
+	},
+	{
+		name: "users", description: "user home directories", modes: systemMode,
+		enabled: func(c Config) bool { return c.CleanUserHomes },
+		run:     func(app *App, c Config) error { return app.cleanUserHomes() },
+		paths:   func(app *App) []string { return []string{homesRoot} },
+		options: []cleanerOption{{
+			flag: "clean-user-homes", usage: "run each user's cleanup from the system service", question: "system_setup.user_homes", modes: systemMode,
+			field: func(c *Config) *bool { return &c.CleanUserHomes },
+		}},
+	},
+	{
+		// After the others, users' runs included, to follow what they freed
+		name: "post_clean", description: "post-clean hooks", modes: userMode | systemMode,
+		enabled: func(c Config) bool { return c.PostCleanHooks },
+		run:     func(app *App, c Config) error { return app.runPostClean(c.PostClean) },
+		paths:   func(app *App) []string { return nil },
+		configPaths: func(app *App, c Config) []string {
+			if c.PostClean.BtrfsBalanceUsage > 0 && app.system {
+				return btrfsMountPoints()
+			}
+			return nil
+		},
+		options: []cleanerOption{{
+			flag: "post-clean-hooks", usage: "trim file systems after runs that free space on them, and run the post_clean hooks",
+			question: "system_setup.post_clean", modes: systemMode,
+			available: func() bool { return commandExists("fstrim") },
+			field:     func(c *Config) *bool { return &c.PostCleanHooks },
+		}},
+	},
+	{
+		// Always on, and last, so it sees the quarantine as this run left it
+		name: "maintenance", description: "saafsafai's own data", modes: userMode | systemMode,
+		enabled: func(c Config) bool { return true },
+		run:     func(app *App, c Config) error { return app.maintain(c) },
+		// The state and log directories are always writable
+		paths: func(app *App) []string { return nil },
+	},
+}
+
+// userCleanerNames are the cleaners user runs have, in registry order. It's
+// filled in init as the user homes cleaner reads it.
+var userCleanerNames []string
+
+// cleanerPaths are the cleaners' paths by name, filled in init like
+// userCleanerNames.
+var cleanerPaths = map[string]func(app *App) []string{}
+
+func init() {
+	flagValues["only"] = cleanerNames
+	flagValues["skip"] = cleanerNames
+	for _, c := range cleaners {
+		if c.modes&userMode != 0 {
+			userCleanerNames = append(userCleanerNames, c.name)
+		}
+		cleanerPaths[c.name] = c.paths
+	}
+}
+
+func cleanerNames() []string {
+	var names []string
+	for _, c := range cleaners {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// parseCleanerList parses a comma-separated list of cleaner names given to
+// the flag name; an empty list is nil.
+func parseCleanerList(name, list string) (map[string]bool, error) {
+	if list == "" {
+		return nil, nil
+	}
+	set := make(map[string]bool)
+	for _, n := range strings.Split(list, ",") {
+		n = strings.TrimSpace(n)
+		if !slices.Contains(cleanerNames(), n) {
+			return nil, fmt.Errorf("invalid --%s cleaner %q, expected one of: %s", name, n, strings.Join(cleanerNames(), ", "))
+		}
+		set[n] = true
+	}
+	return set, nil
+}
+
+// selected reports whether --only, --skip and the level let the cleaner
+// run.
+func (app *App) selected(name string) bool {
+	return (app.only == nil || app.only[name]) && !app.skip[name] && app.level.runs(name)
+}
+
+// runCleaners runs every enabled cleaner for this mode and records how many
+// items each one found.
+func (app *App) runCleaners(config Config) {
+	mode := app.mode()
+	for _, c := range cleaners {
+		if c.modes&mode == 0 || !c.enabled(config) {
+			// User cleaners in --only are passed on to the users' runs
+			if app.only[c.name] && (mode == userMode || c.modes&userMode == 0) {
+				log.Printf("Warning: --only %s does nothing, it isn't enabled in the config", c.name)
+			}
+			continue
+		}
+		if !app.selected(c.name) {
+			continue
+		}
+
+		start := time.Now()
+		before := app.foundCount()
+		app.cleaner = c.name
+		if err := c.run(app, config); err != nil {
+			app.logError("Error cleaning %s: %v", c.description, err)
+		} else {
+			if app.cleanerRuns == nil {
+				app.cleanerRuns = make(map[string]time.Time)
+			}
+			app.cleanerRuns[c.name] = start
+		}
+
+		if app.summary.Cleaners == nil {
+			app.summary.Cleaners = make(map[string]int)
+		}
+		app.summary.Cleaners[c.name] = app.foundCount() - before
+
+		if app.summary.CleanerTimes == nil {
+			app.summary.CleanerTimes = make(map[string]int64)
+		}
+		app.summary.CleanerTimes[c.name] = time.Since(start).Milliseconds()
+	}
+}
+
+// timeAction adds the time since start to an expensive step's total, as in
+// defer app.timeAction("docker images", time.Now()).
+func (app *App) timeAction(name string, start time.Time) {
+	if app.summary.ActionTimes == nil {
+		app.summary.ActionTimes = make(map[string]int64)
+	}
+	app.summary.ActionTimes[name] += time.Since(start).Milliseconds()
+}
+
+// timingLines lists the cleaner and action times for the verbose report.
+func (app *App) timingLines() []string {
+	if len(app.summary.CleanerTimes) == 0 {
+		return nil
+	}
+
+	lines := []string{T("summary.timings")}
+	for _, name := range sortedKeys(app.summary.CleanerTimes) {
+		lines = append(lines, fmt.Sprintf("   - %s: %s", name, formatMillis(app.summary.CleanerTimes[name])))
+	}
+	for _, name := range sortedKeys(app.summary.ActionTimes) {
+		lines = append(lines, fmt.Sprintf("      · %s: %s", name, formatMillis(app.summary.ActionTimes[name])))
+	}
+	return append(lines, "")
+}
+
+func formatMillis(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).String()
+}
+
+func (app *App) mode() int {
+	if app.system {
+		return systemMode
+	}
+	return userMode
+}
+
+// writablePaths lists the directories runs with config may modify: the
+// state, log and config directories and those of every enabled cleaner.
+func (app *App) writablePaths(config Config) []string {
+	paths := []string{app.stateDir, app.logDir, filepath.Dir(app.configPath)}
+	if config.Trash.Enabled {
+		// Trashed items go to the home trash, or that of their own disk
+		paths = append(paths, app.trashRoot(config.Trash))
+		for _, dir := range app.cleanerDirs(config) {
+			if mount, ok := mountOf(dir); ok {
+				for _, trash := range topTrashes(mount.mountPoint) {
+					if !slices.Contains(paths, trash) {
+						paths = append(paths, trash)
+					}
+				}
+			}
+		}
+	}
+	if config.Snapshot.Enabled && config.Snapshot.Command == "" {
+		// Snapshots go in the subvolumes
+		for _, dir := range app.cleanerDirs(config) {
+			if subvolume, err := btrfsSubvolume(dir); err == nil && !slices.Contains(paths, subvolume) {
+				paths = append(paths, subvolume)
+			}
+		}
+	}
+	for _, c := range cleaners {
+		if c.modes&app.mode() == 0 || !c.enabled(config) {
+			continue
+		}
+		cleanerPaths := c.paths(app)
+		if c.configPaths != nil {
+			cleanerPaths = append(cleanerPaths, c.configPaths(app, config)...)
+		}
+		for _, path := range cleanerPaths {
+			if !slices.Contains(paths, path) {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// cleanerDirs lists the directories the cleaners this run runs work in
+// that exist.
+func (app *App) cleanerDirs(config Config) []string {
+	var dirs []string
+	for _, c := range cleaners {
+		if c.modes&app.mode() == 0 || !c.enabled(config) || !app.selected(c.name) {
+			continue
+		}
+		for _, dir := range c.paths(app) {
+			if _, err := os.Stat(dir); err == nil && !slices.Contains(dirs, dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs
+}
+
+func commandExists(names ...string) bool {
+	for _, name := range names {
+		if _, err := exec.LookPath(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPackageManager() bool {
+	_, ok := detectPackageManager()
+	return ok
+}
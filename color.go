@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ANSI styles of the output to a terminal.
+const (
+	styleBold  = "1"
+	styleDim   = "2"
+	styleRed   = "31"
+	styleGreen = "32"
+	styleCyan  = "36"
+)
+
+// painter styles text with ANSI colors, or leaves it plain when false.
+type painter bool
+
+// plain is how the logs, notifications and reports are written.
+const plain painter = false
+
+func (p painter) paint(style, text string) string {
+	if !p || text == "" {
+		return text
+	}
+	return "\x1b[" + style + "m" + text + "\x1b[0m"
+}
+
+// colorOutput reports whether output to f is colored: only a terminal's,
+// unless --no-color is given, NO_COLOR is set (see no-color.org) or the
+// terminal is a dumb one.
+func colorOutput(f *os.File, noColor bool) painter {
+	if noColor || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return plain
+	}
+	return painter(isTerminal(f))
+}
+
+// columnWidth returns the width of the widest of cells, for lining up the
+// column after them; never under least, so short tables keep their shape.
+func columnWidth(least int, cells []string) int {
+	width := least
+	for _, cell := range cells {
+		width = max(width, utf8.RuneCountInString(cell))
+	}
+	return width
+}
+
+// padRight pads s with spaces to width characters.
+func padRight(s string, width int) string {
+	return s + strings.Repeat(" ", max(0, width-utf8.RuneCountInString(s)))
+}
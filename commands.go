@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+)
+
+// command is a saafsafai subcommand. define registers the command's flags on
+// fs and returns the function that runs it once the flags are parsed, so the
+// flag set is the single definition used for parsing, help and completion.
+type command struct {
+	name    string
+	summary string
+	// args are the fixed values of the first positional argument, if any
+	args   []string
+	define func(fs *flag.FlagSet) func(args []string) error
+}
+
+var commands []command
+
+func init() {
+	// Assigned in init because the completion command reads the table
+	commands = []command{
+		{name: "setup", summary: "Configure saafsafai and install its service", define: defineSetupCommand},
+		{name: "service", summary: "Manage the installed service", args: []string{"install", "repair"}, define: defineServiceCommand},
+		{name: "doctor", summary: "Diagnose common problems and suggest fixes", define: defineDoctorCommand},
+		{name: "selftest", summary: "Check the cleaners against a fixture home", define: defineSelftestCommand},
+		{name: "restore", summary: "List quarantined items or restore one", define: defineRestoreCommand},
+		{name: "undo", summary: "Put back what a run moved, quarantined or trashed", define: defineUndoCommand},
+		{name: "verify", summary: "Check quarantined items against their checksums", define: defineVerifyCommand},
+		{name: "daemon", summary: "Clean up on an interval, controlled over D-Bus", define: defineDaemonCommand},
+		{name: "tray", summary: "Show the daemon in the system tray", define: defineTrayCommand},
+		{name: "emergency", summary: "Free space as fast as safely possible", define: defineEmergencyCommand},
+		{name: "find", summary: "Find where a run moved or removed a file", define: defineFindCommand},
+		{name: "suggest", summary: "Suggest Downloads rules from the run history", define: defineSuggestCommand},
+		{name: "organize", summary: "Organize a long-neglected Downloads folder from a plan", define: defineOrganizeCommand},
+		{name: "similar", summary: "Group similarly named files in Downloads", define: defineSimilarCommand},
+		{name: "audit", summary: "Report reclaimable space as signed JSON, changing nothing", define: defineAuditCommand},
+		{name: "remote", summary: "Run a cleanup or plan on another machine over SSH", define: defineRemoteCommand},
+		{name: "estimate", summary: "Estimate how much space each cleaner could free", define: defineEstimateCommand},
+		{name: "bench", summary: "Measure disk speeds and recommend limits for them", define: defineBenchCommand},
+		{name: "config", summary: "Print the config file's JSON Schema", args: []string{"schema"}, define: defineConfigCommand},
+		{name: "logs", summary: "Show recent run logs", define: defineLogsCommand},
+		{name: "stats", summary: "Show statistics from the run history", args: []string{"diff", "sources", "trends"}, define: defineStatsCommand},
+		{name: "version", summary: "Show the version and build information", define: defineVersionCommand},
+		{name: "completion", summary: "Print a shell completion script", args: completionShells, define: defineCompletionCommand},
+	}
+}
+
+// flagValues lists the possible values of flags that take a fixed set of
+// names, keyed by flag name, for shell completion.
+var flagValues = map[string]func() []string{}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// runCommand parses the subcommand's arguments, runs it and returns the
+// process exit code.
+func runCommand(cmd command, args []string) int {
+	fs := flag.NewFlagSet("saafsafai "+cmd.name, flag.ContinueOnError)
+	run := cmd.define(fs)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if err := run(fs.Args()); err != nil {
+		var code exitError
+		if errors.As(err, &code) {
+			return int(code)
+		}
+		log.Printf("%s failed: %v", cmd.name, err)
+		return exitFatal
+	}
+	return exitOK
+}
+
+// exitError is returned by commands that exit with a code of their own,
+// having reported why themselves.
+type exitError int
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit code %d", int(e))
+}
+
+// commandApp creates the App a subcommand works on: the invoking user's, or
+// the system-wide one with --system.
+func commandApp(system bool) (*App, error) {
+	if system {
+		return NewSystemApp(), nil
+	}
+	return NewApp()
+}
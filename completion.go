@@ -0,0 +1,220 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// completionFlag is a flag as seen by the completion scripts.
+type completionFlag struct {
+	name   string
+	usage  string
+	isBool bool
+	values []string
+}
+
+func defineCompletionCommand(fs *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("expected one shell: %s", strings.Join(completionShells, ", "))
+		}
+		switch args[0] {
+		case "bash":
+			writeBashCompletion(os.Stdout)
+		case "zsh":
+			writeZshCompletion(os.Stdout)
+		case "fish":
+			writeFishCompletion(os.Stdout)
+		default:
+			return fmt.Errorf("unsupported shell %q, expected one of: %s", args[0], strings.Join(completionShells, ", "))
+		}
+		return nil
+	}
+}
+
+// completionFlags collects the flags that define registers, so completions
+// are generated from the same definitions the parser uses.
+func completionFlags(define func(fs *flag.FlagSet)) []completionFlag {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	define(fs)
+
+	var flags []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		_, usage := flag.UnquoteUsage(f)
+		cf := completionFlag{name: f.Name, usage: usage}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			cf.isBool = true
+		}
+		if values, ok := flagValues[f.Name]; ok {
+			cf.values = values()
+		}
+		flags = append(flags, cf)
+	})
+	sort.Slice(flags, func(i, j int) bool { return flags[i].name < flags[j].name })
+	return flags
+}
+
+func rootCompletionFlags() []completionFlag {
+	return completionFlags(func(fs *flag.FlagSet) { defineRootFlags(fs) })
+}
+
+func commandCompletionFlags(cmd command) []completionFlag {
+	return completionFlags(func(fs *flag.FlagSet) { cmd.define(fs) })
+}
+
+func flagNames(flags []completionFlag) []string {
+	var names []string
+	for _, f := range flags {
+		names = append(names, "--"+f.name)
+	}
+	return names
+}
+
+func writeBashCompletion(w io.Writer) {
+	var commandNames []string
+	for _, cmd := range commands {
+		commandNames = append(commandNames, cmd.name)
+	}
+
+	fmt.Fprintln(w, "# bash completion for saafsafai, generated by: saafsafai completion bash")
+	fmt.Fprintln(w, "_saafsafai() {")
+	fmt.Fprintln(w, `    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}" cmd="" word opts`)
+	fmt.Fprintln(w, `    for word in "${COMP_WORDS[@]:1:COMP_CWORD-1}"; do`)
+	fmt.Fprintln(w, `        case "$word" in -*) ;; *) cmd="$word"; break ;; esac`)
+	fmt.Fprintln(w, "    done")
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, `    case "$prev" in`)
+	seen := make(map[string]bool)
+	for _, flags := range append([][]completionFlag{rootCompletionFlags()}, allCommandFlags()...) {
+		for _, f := range flags {
+			if len(f.values) == 0 || seen[f.name] {
+				continue
+			}
+			seen[f.name] = true
+			fmt.Fprintf(w, "        --%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", f.name, strings.Join(f.values, " "))
+		}
+	}
+	fmt.Fprintln(w, "    esac")
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, `    case "$cmd" in`)
+	for _, cmd := range commands {
+		opts := append(append([]string{}, cmd.args...), flagNames(commandCompletionFlags(cmd))...)
+		fmt.Fprintf(w, "        %s) opts=%q ;;\n", cmd.name, strings.Join(opts, " "))
+	}
+	fmt.Fprintf(w, "        *) opts=%q ;;\n", strings.Join(append(commandNames, flagNames(rootCompletionFlags())...), " "))
+	fmt.Fprintln(w, "    esac")
+	fmt.Fprintln(w, `    COMPREPLY=($(compgen -W "$opts" -- "$cur"))`)
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -F _saafsafai saafsafai")
+}
+
+func writeZshCompletion(w io.Writer) {
+	fmt.Fprintln(w, "#compdef saafsafai")
+	fmt.Fprintln(w, "# zsh completion for saafsafai, generated by: saafsafai completion zsh")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "_saafsafai() {")
+	fmt.Fprintln(w, "    local -a commands")
+	fmt.Fprintln(w, "    commands=(")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "        %s\n", zshQuote(cmd.name+":"+cmd.summary))
+	}
+	fmt.Fprintln(w, "    )")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "    if (( CURRENT == 2 )) && [[ $words[2] != -* ]]; then")
+	fmt.Fprintln(w, "        _describe -t commands 'saafsafai command' commands")
+	fmt.Fprintln(w, "        return")
+	fmt.Fprintln(w, "    fi")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "    case $words[2] in")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "        %s)\n", cmd.name)
+		fmt.Fprintln(w, "            shift words; (( CURRENT-- ))")
+		fmt.Fprint(w, "            _arguments -s")
+		for _, f := range commandCompletionFlags(cmd) {
+			fmt.Fprint(w, " \\\n                "+zshFlagSpec(f))
+		}
+		if len(cmd.args) > 0 {
+			fmt.Fprint(w, " \\\n                "+zshQuote("1:argument:("+strings.Join(cmd.args, " ")+")"))
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "            ;;")
+	}
+	fmt.Fprintln(w, "        *)")
+	fmt.Fprint(w, "            _arguments -s")
+	for _, f := range rootCompletionFlags() {
+		fmt.Fprint(w, " \\\n                "+zshFlagSpec(f))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "            ;;")
+	fmt.Fprintln(w, "    esac")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `_saafsafai "$@"`)
+}
+
+func zshFlagSpec(f completionFlag) string {
+	desc := strings.NewReplacer("[", "(", "]", ")", ":", " ").Replace(f.usage)
+	spec := "--" + f.name + "[" + desc + "]"
+	if !f.isBool {
+		spec += ":" + f.name + ":"
+		if len(f.values) > 0 {
+			spec += "(" + strings.Join(f.values, " ") + ")"
+		}
+	}
+	return zshQuote(spec)
+}
+
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprintln(w, "# fish completion for saafsafai, generated by: saafsafai completion fish")
+	fmt.Fprintln(w, "complete -c saafsafai -f")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "complete -c saafsafai -n __fish_use_subcommand -a %s -d %s\n", cmd.name, fishQuote(cmd.summary))
+	}
+	for _, f := range rootCompletionFlags() {
+		fmt.Fprintln(w, "complete -c saafsafai -n __fish_use_subcommand"+fishFlagSpec(f))
+	}
+	for _, cmd := range commands {
+		condition := fishQuote("__fish_seen_subcommand_from " + cmd.name)
+		if len(cmd.args) > 0 {
+			fmt.Fprintf(w, "complete -c saafsafai -n %s -a %s\n", condition, fishQuote(strings.Join(cmd.args, " ")))
+		}
+		for _, f := range commandCompletionFlags(cmd) {
+			fmt.Fprintln(w, "complete -c saafsafai -n "+condition+fishFlagSpec(f))
+		}
+	}
+}
+
+func fishFlagSpec(f completionFlag) string {
+	spec := " -l " + f.name + " -d " + fishQuote(f.usage)
+	if !f.isBool {
+		spec += " -r"
+		if len(f.values) > 0 {
+			spec += " -a " + fishQuote(strings.Join(f.values, " "))
+		}
+	}
+	return spec
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+func allCommandFlags() [][]completionFlag {
+	var all [][]completionFlag
+	for _, cmd := range commands {
+		all = append(all, commandCompletionFlags(cmd))
+	}
+	return all
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	syncConflictMaxAge = 30 // days
+
+	conflictActionReview     = "review"
+	conflictActionQuarantine = "quarantine"
+)
+
+// syncConflictMarkers are what sync clients put in the names of the copies
+// they make when a file changed in two places: Syncthing, Dropbox and
+// Nextcloud, whose newer clients also say "conflicted copy".
+var syncConflictMarkers = []string{".sync-conflict-", "conflicted copy", "_conflict-"}
+
+type SyncConflictConfig struct {
+	// MaxAgeDays only gathers conflict copies older than this many days
+	// (default 30), leaving time to merge recent ones.
+	MaxAgeDays int `json:"max_age_days"`
+	// Action is "review" (the default) to list them in the report, or
+	// "quarantine" to move them into the quarantine, even if it isn't
+	// enabled for other cleaners, so they can be restored.
+	Action string `json:"action"`
+}
+
+func isSyncConflict(name string) bool {
+	lower := strings.ToLower(name)
+	for _, marker := range syncConflictMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSyncConflicts collects the sync conflict copies, leaving saafsafai's
+// own state (and the quarantine in it) alone.
+func (app *App) matchSyncConflicts() homeMatcher {
+	return func(path string, d fs.DirEntry) error {
+		if d.IsDir() {
+			if path == app.stateDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && isSyncConflict(d.Name()) {
+			app.scan.syncConflicts = append(app.scan.syncConflicts, path)
+		}
+		return nil
+	}
+}
+
+// cleanSyncConflicts lists or quarantines the sync conflict copies in the
+// home directory older than the configured age.
+func (app *App) cleanSyncConflicts(config Config) error {
+	cfg := config.SyncConflicts
+	cutoff := app.ageCutoff(cfg.MaxAgeDays, syncConflictMaxAge)
+
+	if err := app.scanHome(config); err != nil {
+		return fmt.Errorf("error scanning for sync conflicts: %w", err)
+	}
+
+	for _, path := range app.scan.syncConflicts {
+		info, err := os.Lstat(path)
+		if err != nil || !app.fileTime(path, info).Before(cutoff) {
+			continue
+		}
+		item := fmt.Sprintf("%s (%s, %s)", app.displayPath(path), formatSize(info.Size()), app.fileTime(path, info).Format("2006-01-02"))
+		if cfg.Action != conflictActionQuarantine {
+			app.addItem(&app.summary.SyncConflicts, item)
+			continue
+		}
+
+		// Conflict copies are kept for restoring whatever the quarantine
+		// setting is
+		quarantine := app.quarantine
+		app.quarantine = true
+		err = app.remove(path)
+		app.quarantine = quarantine
+		if err != nil {
+			app.skipItem("Failed to quarantine sync conflict", path, err)
+			continue
+		}
+		app.addItem(&app.summary.QuarantinedConflicts, item)
+	}
+	return nil
+}
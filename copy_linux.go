@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+const (
+	// lseek whence values for finding the data and holes of sparse files
+	seekData = 3
+	seekHole = 4
+
+	// ficlone is the FICLONE ioctl, which makes a file share another's
+	// blocks (a reflink) on Btrfs, XFS and other file systems that can
+	ficlone = 0x40049409
+)
+
+// copyFileData copies in to out. Where the file system supports it, out is
+// made a clone of in, which takes no time or space whatever the size.
+// Otherwise a sparse file (one taking fewer blocks than its size, like most
+// VM images) is copied a data region at a time, so its holes stay holes
+// instead of being filled with zeros.
+func copyFileData(out, in *os.File, info fs.FileInfo) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, out.Fd(), ficlone, in.Fd()); errno == 0 {
+		return nil
+	}
+
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st.Blocks*512 >= info.Size() {
+		_, err := io.Copy(out, in)
+		return err
+	}
+
+	size := info.Size()
+	for pos := int64(0); pos < size; {
+		data, err := in.Seek(pos, seekData)
+		if errors.Is(err, syscall.ENXIO) {
+			break // only a hole is left
+		}
+		if err != nil {
+			if pos == 0 {
+				// The file system can't tell where the holes are
+				_, err = io.Copy(out, in)
+			}
+			return err
+		}
+		hole, err := in.Seek(data, seekHole)
+		if err != nil {
+			return err
+		}
+
+		if _, err := in.Seek(data, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := out.Seek(data, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(out, in, hole-data); err != nil {
+			return err
+		}
+		pos = hole
+	}
+
+	// A trailing hole only exists as the file's size
+	return out.Truncate(size)
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+import (
+	"io"
+	"io/fs"
+	"os"
+)
+
+// copyFileData copies in to out; holes in sparse files are only kept on
+// Linux.
+func copyFileData(out, in *os.File, info fs.FileInfo) error {
+	_, err := io.Copy(out, in)
+	return err
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+const defaultDaemonInterval = 24 * time.Hour
+
+// daemon runs the cleanup on an interval in a long-lived process, and lets
+// desktop widgets and scripts control it over D-Bus (and, with --listen,
+// HTTP).
+type daemon struct {
+	system   bool
+	interval time.Duration
+	trigger  chan struct{}
+
+	mu      sync.Mutex
+	running bool
+	paused  bool
+	next    time.Time
+	last    *App // the last run's, for its summary
+	lastAt  time.Time
+	lastErr error
+	// finished are called after every run, e.g. to emit the D-Bus signal
+	finished []func(run *App, err error)
+	// mounted are the file systems mounted when the daemon last looked, by
+	// device
+	mounted map[string]bool
+}
+
+func defineDaemonCommand(fs *flag.FlagSet) func(args []string) error {
+	interval := fs.Duration("interval", defaultDaemonInterval, "run the cleanup this often")
+	system := fs.Bool("system", false, "run the system-wide cleanup (as root)")
+	listen := fs.String("listen", "", "also serve the HTTP control API on `ADDR`, e.g. 127.0.0.1:8731")
+
+	return func(args []string) error {
+		if *interval < time.Minute {
+			return fmt.Errorf("invalid --interval %s, expected at least 1m", *interval)
+		}
+		d := &daemon{system: *system, interval: *interval, trigger: make(chan struct{}, 1)}
+
+		if bus, err := d.startDBus(); err != nil {
+			log.Printf("Warning: D-Bus control unavailable: %v", err)
+		} else {
+			defer bus.Close()
+		}
+		if *listen != "" {
+			srv, err := d.serveHTTP(*listen)
+			if err != nil {
+				return err
+			}
+			defer srv.Close()
+		}
+
+		log.Printf("Daemon started, cleaning up every %s", *interval)
+		d.loop()
+		return nil
+	}
+}
+
+// loop runs the cleanup whenever it's due (unless paused) or asked for.
+// The schedule is checked against the wall clock every minute, since
+// timers don't count time spent in suspend. Removable media with a profile
+// are cleaned as soon as they're mounted.
+func (d *daemon) loop() {
+	d.resume()
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	mounts := time.NewTicker(mountPollInterval)
+	defer mounts.Stop()
+	d.newMedia()
+	for {
+		select {
+		case <-mounts.C:
+			if media := d.newMedia(); media != nil {
+				d.runOnce(func(app *App) {
+					app.only = map[string]bool{"removable": true}
+					app.media = media
+				})
+			}
+			continue
+		case <-ticker.C:
+			d.mu.Lock()
+			due := !d.paused && !time.Now().Before(d.next)
+			d.mu.Unlock()
+			if !due {
+				continue
+			}
+		case <-d.trigger:
+		}
+		d.runOnce(nil)
+		d.schedule()
+	}
+}
+
+// resume schedules the first run an interval after the last one in the
+// history, so restarting the daemon neither skips nor repeats a run. A run
+// missed while the machine was off happens after a random delay.
+func (d *daemon) resume() {
+	now := time.Now()
+	next := now.Add(d.interval)
+	if app, err := commandApp(d.system); err == nil {
+		if records, err := app.loadHistory(); err == nil && len(records) > 0 {
+			next = records[len(records)-1].Time.Add(d.interval)
+		}
+		if !next.After(now) {
+			config, _ := app.loadConfig()
+			delay, err := randomDelay(config.Timer.RandomizedDelaySec)
+			if err != nil {
+				log.Printf("Warning: %v", err)
+			}
+			next = now.Add(delay)
+			log.Printf("A scheduled run was missed, catching up in %s", delay.Round(time.Second))
+		}
+	}
+
+	d.mu.Lock()
+	d.next = next
+	d.mu.Unlock()
+}
+
+func (d *daemon) schedule() {
+	d.mu.Lock()
+	d.next = time.Now().Add(d.interval)
+	d.mu.Unlock()
+}
+
+// runNow asks for a run as soon as the current one, if any, is done.
+func (d *daemon) runNow() {
+	select {
+	case d.trigger <- struct{}{}:
+	default: // one is already pending
+	}
+}
+
+func (d *daemon) setPaused(paused bool) {
+	d.mu.Lock()
+	d.paused = paused
+	d.mu.Unlock()
+	if paused {
+		log.Printf("Scheduled runs paused")
+	} else {
+		log.Printf("Scheduled runs resumed")
+	}
+}
+
+// runOnce does a run, with setup, if not nil, applied to its App first.
+func (d *daemon) runOnce(setup func(app *App)) {
+	d.mu.Lock()
+	d.running = true
+	d.mu.Unlock()
+
+	app, err := d.cleanup(setup)
+	if err != nil {
+		log.Printf("Cleanup failed: %v", err)
+	}
+
+	d.mu.Lock()
+	d.running = false
+	d.last, d.lastAt, d.lastErr = app, time.Now(), err
+	finished := d.finished
+	d.mu.Unlock()
+
+	for _, f := range finished {
+		f(app, err)
+	}
+	go app.waitForUndo()
+}
+
+// cleanup does one run with a fresh App, as a separate invocation would.
+func (d *daemon) cleanup(setup func(app *App)) (*App, error) {
+	app, err := commandApp(d.system)
+	if err != nil {
+		return &App{}, err
+	}
+	app.unattended = true
+	if setup != nil {
+		setup(app)
+	}
+	return app, app.run()
+}
+
+// newMedia returns the devices of the removable media with a profile that
+// were mounted since it last looked, if any, unless runs are paused.
+func (d *daemon) newMedia() map[string]bool {
+	mounted := make(map[string]bool)
+	var added []string
+	for _, fs := range mountedFilesystems() {
+		mounted[fs.source] = true
+		if d.mounted != nil && !d.mounted[fs.source] {
+			added = append(added, fs.source)
+		}
+	}
+	d.mounted = mounted
+
+	d.mu.Lock()
+	paused := d.paused
+	d.mu.Unlock()
+	if len(added) == 0 || paused || d.system {
+		return nil
+	}
+	app, err := commandApp(d.system)
+	if err != nil {
+		return nil
+	}
+	config, err := app.loadConfig()
+	if err != nil {
+		return nil
+	}
+	var media map[string]bool
+	for _, m := range mountedProfiles(config.Removable) {
+		if slices.Contains(added, m.fs.source) {
+			if media == nil {
+				media = make(map[string]bool)
+			}
+			media[m.fs.source] = true
+			log.Printf("%s mounted at %s, cleaning it", m.fs.source, m.fs.mountPoint)
+		}
+	}
+	return media
+}
+
+// status describes the daemon's state and its last run.
+func (d *daemon) status() map[string]any {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state := "idle"
+	switch {
+	case d.running:
+		state = "running"
+	case d.paused:
+		state = "paused"
+	}
+	status := map[string]any{
+		"state":    state,
+		"paused":   d.paused,
+		"next_run": d.next.Format(time.RFC3339),
+	}
+	if d.last != nil {
+		status["last_run"] = d.lastAt.Format(time.RFC3339)
+		status["last_run_id"] = d.last.summary.RunID
+		status["last_report"] = d.last.summary.Report
+		status["last_items"] = int32(d.last.itemCount())
+		status["last_errors"] = int32(d.last.failures())
+		status["last_freed_bytes"] = d.last.summary.FreedBytes
+		if d.lastErr != nil {
+			status["last_failure"] = d.lastErr.Error()
+		}
+	}
+	return status
+}
+
+const (
+	dbusName      = "org.saafsafai.Cleaner"
+	dbusObject    = "/org/saafsafai/Cleaner"
+	dbusInterface = "org.saafsafai.Cleaner"
+)
+
+const dbusIntrospection = `<!DOCTYPE node PUBLIC "-//freedesktop//DTD D-BUS Object Introspection 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/introspect.dtd">
+<node>
+  <interface name="org.saafsafai.Cleaner">
+    <method name="RunNow"/>
+    <method name="Pause"/>
+    <method name="Resume"/>
+    <method name="GetStatus">
+      <arg name="status" type="a{sv}" direction="out"/>
+    </method>
+    <signal name="RunFinished">
+      <arg name="run_id" type="s"/>
+      <arg name="items" type="i"/>
+      <arg name="errors" type="i"/>
+      <arg name="freed_bytes" type="x"/>
+    </signal>
+  </interface>
+  <interface name="org.freedesktop.DBus.Introspectable">
+    <method name="Introspect">
+      <arg name="xml" type="s" direction="out"/>
+    </method>
+  </interface>
+  <interface name="org.freedesktop.DBus.Peer">
+    <method name="Ping"/>
+  </interface>
+</node>
+`
+
+// startDBus takes the daemon's name on the session bus (the system bus with
+// --system) and serves its methods until the connection closes.
+func (d *daemon) startDBus() (*dbusConn, error) {
+	bus, err := dialDBus(dbusBusAddress(d.system))
+	if err != nil {
+		return nil, err
+	}
+	if err := bus.requestName(dbusName); err != nil {
+		bus.Close()
+		return nil, err
+	}
+
+	d.finished = append(d.finished, func(run *App, err error) {
+		errors := int32(run.failures())
+		if err != nil {
+			errors++
+		}
+		if err := bus.emit(dbusObject, dbusInterface, "RunFinished",
+			run.summary.RunID, int32(run.itemCount()), errors, run.summary.FreedBytes); err != nil {
+			log.Printf("Failed to emit RunFinished: %v", err)
+		}
+	})
+
+	go func() {
+		for {
+			msg, err := bus.read()
+			if err != nil {
+				log.Printf("D-Bus connection lost: %v", err)
+				return
+			}
+			if msg.typ == dbusMethodCall {
+				if err := d.handleDBus(bus, msg); err != nil {
+					log.Printf("Failed to answer %s: %v", msg.member, err)
+				}
+			}
+		}
+	}()
+	return bus, nil
+}
+
+func (d *daemon) handleDBus(bus *dbusConn, call *dbusMessage) error {
+	switch {
+	case call.member == "Introspect" && (call.iface == "" || call.iface == "org.freedesktop.DBus.Introspectable"):
+		return bus.reply(call, dbusIntrospection)
+	case call.member == "Ping" && (call.iface == "" || call.iface == "org.freedesktop.DBus.Peer"):
+		return bus.reply(call)
+	case call.path != dbusObject:
+		return bus.replyError(call, "org.freedesktop.DBus.Error.UnknownObject", "no object at "+call.path)
+	case call.iface != "" && call.iface != dbusInterface:
+		return bus.replyError(call, "org.freedesktop.DBus.Error.UnknownInterface", "unknown interface "+call.iface)
+	}
+
+	switch call.member {
+	case "RunNow":
+		d.runNow()
+		return bus.reply(call)
+	case "Pause":
+		d.setPaused(true)
+		return bus.reply(call)
+	case "Resume":
+		d.setPaused(false)
+		return bus.reply(call)
+	case "GetStatus":
+		return bus.reply(call, d.status())
+	}
+	return bus.replyError(call, "org.freedesktop.DBus.Error.UnknownMethod", "unknown method "+call.member)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// dashboardHTML is the web dashboard, a single page that talks to the HTTP
+// API with the token the user pastes into it.
+//
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+const maxConfigBytes = 1 << 20
+
+func (d *daemon) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Content-Security-Policy", "default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src 'self'")
+	h.Set("X-Frame-Options", "DENY")
+	w.Write(dashboardHTML)
+}
+
+func (d *daemon) handleQuarantine(w http.ResponseWriter, r *http.Request) {
+	app, err := commandApp(d.system)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	records, err := app.loadQuarantine()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to read quarantine index: %v", err))
+		return
+	}
+	if records == nil {
+		records = []quarantineRecord{}
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+// handleRestore restores the newest quarantined item with the given ID and
+// original path, into "to" if it's set.
+func (d *daemon) handleRestore(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID   string `json:"id"`
+		Path string `json:"path"`
+		To   string `json:"to"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxConfigBytes)).Decode(&req); err != nil || req.ID == "" {
+		writeJSONError(w, http.StatusBadRequest, `expected {"id": ..., "path": ...}`)
+		return
+	}
+
+	app, err := commandApp(d.system)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// A run may be adding to the quarantine index
+	unlock, err := app.lock()
+	if err != nil {
+		writeJSONError(w, lockStatus(err), err.Error())
+		return
+	}
+	defer unlock()
+
+	records, err := app.loadQuarantine()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to read quarantine index: %v", err))
+		return
+	}
+	index := -1
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].ID == req.ID && (req.Path == "" || records[i].Path == req.Path) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		writeJSONError(w, http.StatusNotFound, "nothing quarantined matches "+req.ID)
+		return
+	}
+
+	dest, err := app.restoreRecord(records, index, req.To, false)
+	if err != nil {
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"restored": dest})
+}
+
+func (d *daemon) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	app, err := commandApp(d.system)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	config, err := app.loadConfig()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, config)
+}
+
+// handlePutConfig replaces the config with the request's, once it's known
+// to be valid. The next run picks it up.
+func (d *daemon) handlePutConfig(w http.ResponseWriter, r *http.Request) {
+	var config Config
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxConfigBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&config); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("failed to parse config JSON: %v", err))
+		return
+	}
+	if dec.More() {
+		writeJSONError(w, http.StatusBadRequest, "failed to parse config JSON: unexpected data after the config")
+		return
+	}
+	if err := config.validate(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	app, err := commandApp(d.system)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := app.saveConfig(config); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, config)
+}
+
+// lockStatus is the status for failing to take the run lock: a conflict if
+// a run holds it.
+func lockStatus(err error) int {
+	if errors.Is(err, errLocked) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,725 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Just enough of the D-Bus wire protocol to own a name, make and answer
+// method calls and emit signals, without pulling in a D-Bus library.
+
+const (
+	dbusMethodCall   = 1
+	dbusMethodReturn = 2
+	dbusError        = 3
+	dbusSignal       = 4
+
+	// dbusNoReplyExpected is the message flag for calls that want no reply
+	dbusNoReplyExpected = 0x1
+)
+
+// Header field codes
+const (
+	dbusFieldPath        = 1
+	dbusFieldInterface   = 2
+	dbusFieldMember      = 3
+	dbusFieldErrorName   = 4
+	dbusFieldReplySerial = 5
+	dbusFieldDestination = 6
+	dbusFieldSender      = 7
+	dbusFieldSignature   = 8
+)
+
+// dbusPath and dbusSignature are strings marshaled as D-Bus object paths and
+// signatures.
+type (
+	dbusPath      string
+	dbusSignature string
+)
+
+// dbusStruct is marshaled as a struct of its fields, dbusArray as an array
+// whose elements have the type elem (variants, for "v"), and dbusVariant as
+// a variant holding value.
+type (
+	dbusStruct []any
+	dbusArray  struct {
+		elem  string
+		items []any
+	}
+	dbusVariant struct{ value any }
+)
+
+type dbusMessage struct {
+	typ         byte
+	flags       byte
+	serial      uint32
+	path        string
+	iface       string
+	member      string
+	errorName   string
+	replySerial uint32
+	destination string
+	sender      string
+	signature   string
+	order       binary.ByteOrder
+	body        []byte
+}
+
+// dbusErrorReply is an error a method call was answered with, as opposed to
+// a failure to reach the other end.
+type dbusErrorReply struct {
+	member, name, text string
+}
+
+func (e *dbusErrorReply) Error() string {
+	return fmt.Sprintf("%s failed: %s: %s", e.member, e.name, e.text)
+}
+
+type dbusConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	mu     sync.Mutex // guards writes and serial
+	serial uint32
+}
+
+// dbusBusAddress returns the socket of the session bus, or the system bus.
+func dbusBusAddress(system bool) string {
+	if system {
+		if addr := os.Getenv("DBUS_SYSTEM_BUS_ADDRESS"); addr != "" {
+			return addr
+		}
+		return "unix:path=/var/run/dbus/system_bus_socket"
+	}
+	if addr := os.Getenv("DBUS_SESSION_BUS_ADDRESS"); addr != "" {
+		return addr
+	}
+	return "unix:path=" + filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), "bus")
+}
+
+// dialDBus connects and authenticates to the bus at address and says hello.
+func dialDBus(address string) (*dbusConn, error) {
+	conn, err := dialBusSocket(address)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &dbusConn{conn: conn, reader: bufio.NewReader(conn)}
+	if err := c.authenticate(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to authenticate to the bus: %w", err)
+	}
+	if _, err := c.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "Hello"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// dialBusSocket connects to the first of address's unix sockets that works.
+func dialBusSocket(address string) (net.Conn, error) {
+	err := fmt.Errorf("no unix socket in bus address %q", address)
+	for _, addr := range strings.Split(address, ";") {
+		transport, params, _ := strings.Cut(addr, ":")
+		if transport != "unix" {
+			continue
+		}
+		socket := ""
+		for _, param := range strings.Split(params, ",") {
+			switch key, value, _ := strings.Cut(param, "="); key {
+			case "path":
+				socket = value
+			case "abstract":
+				socket = "@" + value
+			}
+		}
+		if socket == "" {
+			continue
+		}
+		var conn net.Conn
+		if conn, err = net.Dial("unix", socket); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+func (c *dbusConn) authenticate() error {
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := io.WriteString(c.conn, "\x00AUTH EXTERNAL "+uid+"\r\n"); err != nil {
+		return err
+	}
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("bus rejected EXTERNAL authentication: %s", strings.TrimSpace(line))
+	}
+	_, err = io.WriteString(c.conn, "BEGIN\r\n")
+	return err
+}
+
+func (c *dbusConn) Close() error {
+	return c.conn.Close()
+}
+
+// call makes a method call and waits for its reply. It's only used on
+// connections nothing else reads from (or before something does), so
+// messages before the reply are dropped.
+func (c *dbusConn) call(dest, path, iface, member string, args ...any) (*dbusMessage, error) {
+	serial, err := c.send(dbusMethodCall, 0, []dbusField{
+		{dbusFieldDestination, dest},
+		{dbusFieldPath, dbusPath(path)},
+		{dbusFieldInterface, iface},
+		{dbusFieldMember, member},
+	}, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		msg, err := c.read()
+		if err != nil {
+			return nil, err
+		}
+		if msg.replySerial != serial {
+			continue
+		}
+		if msg.typ == dbusError {
+			text, _ := msg.bodyString()
+			return nil, &dbusErrorReply{member, msg.errorName, text}
+		}
+		return msg, nil
+	}
+}
+
+// requestName takes ownership of name on the bus, failing if another
+// connection already has it.
+func (c *dbusConn) requestName(name string) error {
+	const doNotQueue = 0x4
+	reply, err := c.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "RequestName", name, uint32(doNotQueue))
+	if err != nil {
+		return err
+	}
+	result, err := reply.bodyUint32()
+	if err != nil {
+		return err
+	}
+	// 1: primary owner, 4: already the owner
+	if result != 1 && result != 4 {
+		return fmt.Errorf("%s is already taken on the bus", name)
+	}
+	return nil
+}
+
+// addMatch has the bus send the connection the signals matching rule.
+func (c *dbusConn) addMatch(rule string) error {
+	_, err := c.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "AddMatch", rule)
+	return err
+}
+
+// reply answers call with args as the return values.
+func (c *dbusConn) reply(call *dbusMessage, args ...any) error {
+	if call.flags&dbusNoReplyExpected != 0 {
+		return nil
+	}
+	_, err := c.send(dbusMethodReturn, 0, []dbusField{
+		{dbusFieldReplySerial, call.serial},
+		{dbusFieldDestination, call.sender},
+	}, args...)
+	return err
+}
+
+// replyError answers call with the error name and message.
+func (c *dbusConn) replyError(call *dbusMessage, name, message string) error {
+	if call.flags&dbusNoReplyExpected != 0 {
+		return nil
+	}
+	_, err := c.send(dbusError, 0, []dbusField{
+		{dbusFieldReplySerial, call.serial},
+		{dbusFieldDestination, call.sender},
+		{dbusFieldErrorName, name},
+	}, message)
+	return err
+}
+
+// emit broadcasts a signal.
+func (c *dbusConn) emit(path, iface, member string, args ...any) error {
+	_, err := c.send(dbusSignal, 0, []dbusField{
+		{dbusFieldPath, dbusPath(path)},
+		{dbusFieldInterface, iface},
+		{dbusFieldMember, member},
+	}, args...)
+	return err
+}
+
+type dbusField struct {
+	code  byte
+	value any
+}
+
+func (c *dbusConn) send(typ, flags byte, fields []dbusField, args ...any) (uint32, error) {
+	var body dbusEncoder
+	var signature strings.Builder
+	for _, arg := range args {
+		signature.WriteString(dbusSignatureOf(arg))
+		body.value(arg)
+	}
+	if signature.Len() > 0 {
+		fields = append(fields, dbusField{dbusFieldSignature, dbusSignature(signature.String())})
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.serial++
+
+	var msg dbusEncoder
+	msg.bytes('l', typ, flags, 1)
+	msg.uint32(uint32(len(body.buf)))
+	msg.uint32(c.serial)
+	msg.array(8, func() {
+		for _, f := range fields {
+			msg.align(8)
+			msg.bytes(f.code)
+			msg.variant(f.value)
+		}
+	})
+	msg.align(8)
+
+	if _, err := c.conn.Write(append(msg.buf, body.buf...)); err != nil {
+		return 0, err
+	}
+	return c.serial, nil
+}
+
+// read reads the next message from the bus.
+func (c *dbusConn) read() (*dbusMessage, error) {
+	head := make([]byte, 16)
+	if _, err := io.ReadFull(c.reader, head); err != nil {
+		return nil, err
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if head[0] == 'B' {
+		order = binary.BigEndian
+	}
+	bodyLen := order.Uint32(head[4:8])
+	fieldsLen := order.Uint32(head[12:16])
+	if bodyLen > 1<<27 || fieldsLen > 1<<26 {
+		return nil, fmt.Errorf("message too large")
+	}
+
+	headerLen := 16 + int(fieldsLen)
+	padded := (headerLen + 7) &^ 7
+	buf := make([]byte, padded+int(bodyLen))
+	copy(buf, head)
+	if _, err := io.ReadFull(c.reader, buf[16:]); err != nil {
+		return nil, err
+	}
+
+	msg := &dbusMessage{
+		typ:    head[1],
+		flags:  head[2],
+		serial: order.Uint32(head[8:12]),
+		order:  order,
+		body:   buf[padded:],
+	}
+	d := dbusDecoder{buf: buf[:headerLen], pos: 16, order: order}
+	for d.pos < headerLen {
+		d.align(8)
+		code := d.byte()
+		value := d.variant()
+		if d.err != nil {
+			return nil, d.err
+		}
+		s, _ := value.(string)
+		switch code {
+		case dbusFieldPath:
+			msg.path = s
+		case dbusFieldInterface:
+			msg.iface = s
+		case dbusFieldMember:
+			msg.member = s
+		case dbusFieldErrorName:
+			msg.errorName = s
+		case dbusFieldReplySerial:
+			msg.replySerial, _ = value.(uint32)
+		case dbusFieldDestination:
+			msg.destination = s
+		case dbusFieldSender:
+			msg.sender = s
+		case dbusFieldSignature:
+			msg.signature = s
+		}
+	}
+	return msg, nil
+}
+
+func (m *dbusMessage) bodyString() (string, error) {
+	if !strings.HasPrefix(m.signature, "s") {
+		return "", fmt.Errorf("expected a string, got %q", m.signature)
+	}
+	d := dbusDecoder{buf: m.body, order: m.order}
+	s := d.string()
+	return s, d.err
+}
+
+func (m *dbusMessage) bodyUint32() (uint32, error) {
+	if !strings.HasPrefix(m.signature, "u") {
+		return 0, fmt.Errorf("expected a uint32, got %q", m.signature)
+	}
+	d := dbusDecoder{buf: m.body, order: m.order}
+	v := d.uint32()
+	return v, d.err
+}
+
+// bodyValues unmarshals the message's arguments.
+func (m *dbusMessage) bodyValues() ([]any, error) {
+	d := dbusDecoder{buf: m.body, order: m.order}
+	var values []any
+	for rest := m.signature; rest != "" && d.err == nil; {
+		var sig string
+		sig, rest = dbusNextType(rest)
+		values = append(values, d.value(sig))
+	}
+	return values, d.err
+}
+
+// dbusSignatureOf returns the D-Bus type of the values dbusEncoder writes.
+func dbusSignatureOf(v any) string {
+	switch v := v.(type) {
+	case byte:
+		return "y"
+	case bool:
+		return "b"
+	case int32:
+		return "i"
+	case uint32:
+		return "u"
+	case int64:
+		return "x"
+	case string:
+		return "s"
+	case dbusPath:
+		return "o"
+	case dbusSignature:
+		return "g"
+	case map[string]any:
+		return "a{sv}"
+	case []string:
+		return "as"
+	case dbusStruct:
+		var sig strings.Builder
+		sig.WriteByte('(')
+		for _, field := range v {
+			sig.WriteString(dbusSignatureOf(field))
+		}
+		sig.WriteByte(')')
+		return sig.String()
+	case dbusArray:
+		return "a" + v.elem
+	case dbusVariant:
+		return "v"
+	}
+	panic(fmt.Sprintf("no D-Bus type for %T", v))
+}
+
+// dbusAlignment is the alignment of values of the type starting with c.
+func dbusAlignment(c byte) int {
+	switch c {
+	case 'n', 'q':
+		return 2
+	case 'b', 'i', 'u', 'h', 's', 'o', 'a':
+		return 4
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	}
+	return 1
+}
+
+// dbusNextType splits sig into its first complete type and the rest.
+func dbusNextType(sig string) (string, string) {
+	depth := 0
+	for i := 0; i < len(sig); i++ {
+		switch sig[i] {
+		case 'a':
+			continue
+		case '(', '{':
+			depth++
+		case ')', '}':
+			depth--
+		}
+		if depth <= 0 {
+			return sig[:i+1], sig[i+1:]
+		}
+	}
+	return sig, ""
+}
+
+// dbusEncoder marshals values in little-endian D-Bus format. Alignment is
+// relative to the start of buf, which is always 8-aligned in the message.
+type dbusEncoder struct {
+	buf []byte
+}
+
+func (e *dbusEncoder) align(n int) {
+	for len(e.buf)%n != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *dbusEncoder) bytes(b ...byte) {
+	e.buf = append(e.buf, b...)
+}
+
+func (e *dbusEncoder) uint32(v uint32) {
+	e.align(4)
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+func (e *dbusEncoder) string(s string) {
+	e.uint32(uint32(len(s)))
+	e.buf = append(append(e.buf, s...), 0)
+}
+
+func (e *dbusEncoder) signature(s string) {
+	e.buf = append(append(append(e.buf, byte(len(s))), s...), 0)
+}
+
+// array writes an array whose elements, written by elements, are aligned
+// to align.
+func (e *dbusEncoder) array(align int, elements func()) {
+	e.uint32(0)
+	lenAt := len(e.buf) - 4
+	e.align(align)
+	start := len(e.buf)
+	elements()
+	binary.LittleEndian.PutUint32(e.buf[lenAt:], uint32(len(e.buf)-start))
+}
+
+func (e *dbusEncoder) variant(v any) {
+	e.signature(dbusSignatureOf(v))
+	e.value(v)
+}
+
+func (e *dbusEncoder) value(v any) {
+	switch v := v.(type) {
+	case byte:
+		e.bytes(v)
+	case bool:
+		if v {
+			e.uint32(1)
+		} else {
+			e.uint32(0)
+		}
+	case int32:
+		e.uint32(uint32(v))
+	case uint32:
+		e.uint32(v)
+	case int64:
+		e.align(8)
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, uint64(v))
+	case string:
+		e.string(v)
+	case dbusPath:
+		e.string(string(v))
+	case dbusSignature:
+		e.signature(string(v))
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		e.array(8, func() {
+			for _, k := range keys {
+				e.align(8)
+				e.string(k)
+				e.variant(v[k])
+			}
+		})
+	case []string:
+		e.array(4, func() {
+			for _, item := range v {
+				e.string(item)
+			}
+		})
+	case dbusStruct:
+		e.align(8)
+		for _, field := range v {
+			e.value(field)
+		}
+	case dbusArray:
+		e.array(dbusAlignment(v.elem[0]), func() {
+			for _, item := range v.items {
+				if v.elem == "v" {
+					e.variant(item)
+				} else {
+					e.value(item)
+				}
+			}
+		})
+	case dbusVariant:
+		e.variant(v.value)
+	default:
+		panic(fmt.Sprintf("can't marshal %T for D-Bus", v))
+	}
+}
+
+// dbusDecoder unmarshals message headers and bodies. Arrays and structs
+// come out as []any (dictionaries with string keys as map[string]any), and
+// variants as the value they hold.
+type dbusDecoder struct {
+	buf   []byte
+	pos   int
+	order binary.ByteOrder
+	err   error
+}
+
+func (d *dbusDecoder) need(n int) bool {
+	if d.err == nil && d.pos+n > len(d.buf) {
+		d.err = io.ErrUnexpectedEOF
+	}
+	return d.err == nil
+}
+
+func (d *dbusDecoder) align(n int) {
+	d.pos = (d.pos + n - 1) / n * n
+}
+
+func (d *dbusDecoder) byte() byte {
+	if !d.need(1) {
+		return 0
+	}
+	d.pos++
+	return d.buf[d.pos-1]
+}
+
+func (d *dbusDecoder) uint32() uint32 {
+	d.align(4)
+	if !d.need(4) {
+		return 0
+	}
+	d.pos += 4
+	return d.order.Uint32(d.buf[d.pos-4:])
+}
+
+func (d *dbusDecoder) string() string {
+	n := int(d.uint32())
+	if !d.need(n + 1) {
+		return ""
+	}
+	d.pos += n + 1
+	return string(d.buf[d.pos-n-1 : d.pos-1])
+}
+
+func (d *dbusDecoder) signature() string {
+	n := int(d.byte())
+	if !d.need(n + 1) {
+		return ""
+	}
+	d.pos += n + 1
+	return string(d.buf[d.pos-n-1 : d.pos-1])
+}
+
+func (d *dbusDecoder) variant() any {
+	return d.value(d.signature())
+}
+
+// value reads a value of the single complete type sig.
+func (d *dbusDecoder) value(sig string) any {
+	if d.err == nil && sig == "" {
+		d.err = fmt.Errorf("empty D-Bus signature")
+	}
+	if d.err != nil {
+		return nil
+	}
+	d.align(dbusAlignment(sig[0]))
+
+	switch sig[0] {
+	case 'y':
+		return d.byte()
+	case 'b':
+		return d.uint32() != 0
+	case 'n', 'q':
+		if !d.need(2) {
+			return nil
+		}
+		d.pos += 2
+		v := d.order.Uint16(d.buf[d.pos-2:])
+		if sig[0] == 'n' {
+			return int16(v)
+		}
+		return v
+	case 'i':
+		return int32(d.uint32())
+	case 'u', 'h':
+		return d.uint32()
+	case 'x', 't', 'd':
+		if !d.need(8) {
+			return nil
+		}
+		d.pos += 8
+		v := d.order.Uint64(d.buf[d.pos-8:])
+		switch sig[0] {
+		case 'x':
+			return int64(v)
+		case 'd':
+			return math.Float64frombits(v)
+		}
+		return v
+	case 's', 'o':
+		return d.string()
+	case 'g':
+		return d.signature()
+	case 'v':
+		return d.variant()
+	case '(', '{':
+		var fields []any
+		for rest := sig[1 : len(sig)-1]; rest != "" && d.err == nil; {
+			var field string
+			field, rest = dbusNextType(rest)
+			fields = append(fields, d.value(field))
+		}
+		return fields
+	case 'a':
+		n := int(d.uint32())
+		elem := sig[1:]
+		d.align(dbusAlignment(elem[0]))
+		if !d.need(n) {
+			return nil
+		}
+		end := d.pos + n
+		if strings.HasPrefix(elem, "{s") {
+			dict := make(map[string]any)
+			for d.pos < end && d.err == nil {
+				if entry, _ := d.value(elem).([]any); len(entry) == 2 {
+					key, _ := entry[0].(string)
+					dict[key] = entry[1]
+				}
+			}
+			return dict
+		}
+		var items []any
+		for d.pos < end && d.err == nil {
+			items = append(items, d.value(elem))
+		}
+		return items
+	}
+	if d.err == nil {
+		d.err = fmt.Errorf("unsupported D-Bus type %q", sig)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	diskGuardMaxUsedPercent = 95
+	smartctlTimeout         = 30 * time.Second
+)
+
+// errDiskGuard is returned for writes disk_guard holds back.
+var errDiskGuard = errors.New("held back by disk_guard")
+
+type DiskGuardConfig struct {
+	// Enabled checks the disk that copies into the quarantine from another
+	// file system, and archives of stale clones, are written to first,
+	// refusing those that would fill it past MaxUsedPercent (default 95).
+	Enabled        bool `json:"enabled"`
+	MaxUsedPercent int  `json:"max_used_percent"`
+	// CheckHealth also refuses them when smartctl, run as root, says the
+	// disk is failing.
+	CheckHealth bool `json:"check_health"`
+}
+
+// guardWrite checks that writing size bytes into dir, which has to exist,
+// keeps its disk within the disk_guard settings, returning an error wrapping
+// errDiskGuard if not.
+func (app *App) guardWrite(dir string, size int64) error {
+	cfg := app.diskGuard
+	if !cfg.Enabled || app.dryRun || app.estimating {
+		return nil
+	}
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(dir, &fs); err != nil {
+		return fmt.Errorf("failed to check the free space of %s: %w", dir, err)
+	}
+	// Used as df counts it, leaving out the blocks reserved for root
+	used := int64(fs.Blocks-fs.Bfree) * int64(fs.Bsize)
+	avail := int64(fs.Bavail) * int64(fs.Bsize)
+	if used+avail <= 0 {
+		return nil
+	}
+	if size > avail {
+		return fmt.Errorf("%w: %s needs %s, only %s is free", errDiskGuard, app.displayPath(dir), formatSize(size), formatSize(avail))
+	}
+	maxUsed := cfg.MaxUsedPercent
+	if maxUsed <= 0 {
+		maxUsed = diskGuardMaxUsedPercent
+	}
+	if percent := 100 * (used + size) / (used + avail); percent > int64(maxUsed) {
+		return fmt.Errorf("%w: writing %s would leave %s %d%% full, past max_used_percent %d%%", errDiskGuard, formatSize(size), app.displayPath(dir), percent, maxUsed)
+	}
+
+	if cfg.CheckHealth {
+		if disk := diskOf(dir); disk != "" {
+			if err := app.diskHealth(disk); err != nil {
+				return fmt.Errorf("%w: %v", errDiskGuard, err)
+			}
+		}
+	}
+	return nil
+}
+
+// diskHealth returns an error if smartctl says disk is failing, asking it
+// once a run. A disk smartctl can't report on, or a run that can't ask it,
+// passes.
+func (app *App) diskHealth(disk string) error {
+	if err, ok := app.diskHealthChecked[disk]; ok {
+		return err
+	}
+	if app.diskHealthChecked == nil {
+		app.diskHealthChecked = make(map[string]error)
+	}
+	var err error
+	if os.Geteuid() != 0 || !commandExists("smartctl") {
+		log.Printf("Warning: can't check the health of %s, it takes smartctl run as root", disk)
+	} else if passed, ok := smartPassed(disk); ok && !passed {
+		err = fmt.Errorf("S.M.A.R.T. says %s is failing", disk)
+		log.Printf("Warning: %v", err)
+	}
+	app.diskHealthChecked[disk] = err
+	return err
+}
+
+// smartPassed returns smartctl's overall health assessment of disk, if it
+// has one.
+func smartPassed(disk string) (passed, ok bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), smartctlTimeout)
+	defer cancel()
+	// smartctl's exit status is a bit mask of findings, so the JSON is
+	// read whatever it is
+	output, _ := exec.CommandContext(ctx, "smartctl", "--health", "--json", disk).Output()
+	var report struct {
+		SmartStatus *struct {
+			Passed bool `json:"passed"`
+		} `json:"smart_status"`
+	}
+	if json.Unmarshal(output, &report) != nil || report.SmartStatus == nil {
+		return false, false
+	}
+	return report.SmartStatus.Passed, true
+}
+
+// diskOf returns the whole disk holding the file system path is on, e.g.
+// /dev/sda for /dev/sda2, or "" if it isn't on a block device.
+func diskOf(path string) string {
+	fs, ok := mountOf(path)
+	if !ok || !strings.HasPrefix(fs.source, "/dev/") {
+		return ""
+	}
+	source := fs.source
+	if resolved, err := filepath.EvalSymlinks(source); err == nil {
+		source = resolved
+	}
+	// A partition's sysfs entry is inside its disk's
+	name := filepath.Base(source)
+	if _, err := os.Stat(filepath.Join("/sys/class/block", name, "partition")); err == nil {
+		if entry, err := filepath.EvalSymlinks(filepath.Join("/sys/class/block", name)); err == nil {
+			return "/dev/" + filepath.Base(filepath.Dir(entry))
+		}
+	}
+	return source
+}
+
+// guardMove is guardWrite for moving path into dir, which only copies when
+// they're on different file systems.
+func (app *App) guardMove(path, dir string, size int64) error {
+	from, ok := deviceOf(path)
+	to, ok2 := deviceOf(dir)
+	if ok && ok2 && from == to {
+		return nil
+	}
+	return app.guardWrite(dir, size)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dockerVolumeMaxAge = 30 // days
+
+// dockerTimeLayout is the format of CreatedAt in docker's --format output.
+const dockerTimeLayout = "2006-01-02 15:04:05 -0700 MST"
+
+// anonymousVolume matches the random names Docker gives unnamed volumes.
+var anonymousVolume = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+type DockerConfig struct {
+	// VolumeMaxAgeDays removes unnamed volumes no container uses once they
+	// are older than this many days (default 30).
+	VolumeMaxAgeDays int `json:"volume_max_age_days"`
+	// ImageMaxAgeDays also removes unused tagged images older than this many
+	// days. Zero only removes dangling images.
+	ImageMaxAgeDays int `json:"image_max_age_days"`
+	// KeepImages are repository patterns (e.g. "postgres", "ghcr.io/me/*")
+	// whose images are never removed.
+	KeepImages []string `json:"keep_images"`
+	// KeepLabels are label keys that protect an image or volume carrying them.
+	KeepLabels []string `json:"keep_labels"`
+	// BuilderCacheBudget caps the build cache, e.g. "10GB". Empty leaves the
+	// build cache alone.
+	BuilderCacheBudget string `json:"builder_cache_budget"`
+}
+
+func (app *App) cleanDocker(cfg DockerConfig) error {
+	if _, err := exec.LookPath("docker"); err != nil {
+		log.Printf("Docker is not installed, skipping Docker cleanup")
+		return nil
+	}
+
+	if err := app.cleanDockerImages(cfg); err != nil {
+		app.logError("Error cleaning Docker images: %v", err)
+	}
+
+	if err := app.cleanDockerVolumes(cfg); err != nil {
+		app.logError("Error cleaning Docker volumes: %v", err)
+	}
+
+	if cfg.BuilderCacheBudget != "" {
+		if err := app.capDockerBuilderCache(cfg.BuilderCacheBudget); err != nil {
+			app.logError("Error pruning Docker build cache: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (app *App) cleanDockerImages(cfg DockerConfig) error {
+	defer app.timeAction("docker images", time.Now())
+
+	output, err := dockerOutput("image", "ls", "--format", "{{.ID}}\t{{.Repository}}\t{{.Tag}}\t{{.CreatedAt}}")
+	if err != nil {
+		return err
+	}
+
+	cutoff := app.ageCutoff(cfg.ImageMaxAgeDays, 0)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 {
+			continue
+		}
+		id, repo, tag := fields[0], fields[1], fields[2]
+
+		dangling := repo == "<none>"
+		ref := id
+		if !dangling {
+			if cfg.ImageMaxAgeDays <= 0 || matchesAny(cfg.KeepImages, repo) {
+				continue
+			}
+			created, err := time.Parse(dockerTimeLayout, fields[3])
+			if err != nil || !created.Before(cutoff) {
+				continue
+			}
+			ref = repo + ":" + tag
+		}
+
+		if len(cfg.KeepLabels) > 0 {
+			labels, err := dockerLabels("image", ref)
+			if err != nil || hasAnyLabel(labels, cfg.KeepLabels) {
+				continue
+			}
+		}
+
+		if !app.dryRun {
+			// Without --force docker refuses to remove images a container
+			// still uses, which is exactly what we want
+			if _, err := dockerOutput("image", "rm", ref); err != nil {
+				continue
+			}
+		}
+		app.addItem(&app.summary.DockerItems, "image "+ref)
+	}
+
+	return nil
+}
+
+func (app *App) cleanDockerVolumes(cfg DockerConfig) error {
+	defer app.timeAction("docker volumes", time.Now())
+
+	cutoff := app.ageCutoff(cfg.VolumeMaxAgeDays, dockerVolumeMaxAge)
+
+	output, err := dockerOutput("volume", "ls", "--quiet", "--filter", "dangling=true")
+	if err != nil {
+		return err
+	}
+
+	for _, name := range strings.Fields(output) {
+		if !anonymousVolume.MatchString(name) {
+			continue
+		}
+
+		var volume struct {
+			CreatedAt time.Time
+			Labels    map[string]string
+		}
+		data, err := dockerOutput("volume", "inspect", "--format", "{{json .}}", name)
+		if err != nil || json.Unmarshal([]byte(data), &volume) != nil {
+			continue
+		}
+		if !volume.CreatedAt.Before(cutoff) || hasAnyLabel(volume.Labels, cfg.KeepLabels) {
+			continue
+		}
+
+		if !app.dryRun {
+			if _, err := dockerOutput("volume", "rm", name); err != nil {
+				app.skipItem("Failed to remove Docker volume", name, err)
+				continue
+			}
+		}
+		app.addItem(&app.summary.DockerItems, fmt.Sprintf("volume %s (created %s)", name[:12], volume.CreatedAt.Format("2006-01-02")))
+	}
+
+	return nil
+}
+
+func (app *App) capDockerBuilderCache(budget string) error {
+	defer app.timeAction("docker build cache", time.Now())
+
+	size, err := app.sizeBudget(budget)
+	if err != nil {
+		return err
+	}
+
+	// Listed only when something was, or would be, pruned, so a run that
+	// pruned nothing can still find nothing to do
+	if app.dryRun {
+		output, err := dockerOutput("system", "df", "--format", `{{if eq .Type "Build Cache"}}{{.Size}}{{end}}`)
+		if err != nil {
+			return err
+		}
+		if used, err := parseSize(output); err != nil || used <= size {
+			return nil
+		}
+		app.addItem(&app.summary.DockerItems, "build cache capped at "+formatSize(size))
+		return nil
+	}
+
+	output, err := dockerOutput("builder", "prune", "--force", "--keep-storage", strconv.FormatInt(size, 10))
+	if err != nil {
+		return err
+	}
+	if reclaimed := pruneReclaimed(output); reclaimed > 0 {
+		app.addItem(&app.summary.DockerItems, fmt.Sprintf("build cache capped at %s (%s freed)", formatSize(size), formatSize(reclaimed)))
+	}
+	return nil
+}
+
+// pruneReclaimed returns the space a docker prune says it reclaimed: docker
+// ends with "Total reclaimed space: 1.2GB", buildx with "Total: 1.2GB".
+func pruneReclaimed(output string) int64 {
+	for _, line := range strings.Split(output, "\n") {
+		for _, prefix := range []string{"Total reclaimed space:", "Total:"} {
+			if rest, ok := strings.CutPrefix(strings.TrimSpace(line), prefix); ok {
+				if n, err := parseSize(rest); err == nil {
+					return n
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func dockerOutput(args ...string) (string, error) {
+	output, err := exec.Command("docker", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("docker %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func dockerLabels(kind, ref string) (map[string]string, error) {
+	output, err := dockerOutput(kind, "inspect", "--format", "{{json .Config.Labels}}", ref)
+	if err != nil {
+		return nil, err
+	}
+	var labels map[string]string
+	if err := json.Unmarshal([]byte(output), &labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+func hasAnyLabel(labels map[string]string, keys []string) bool {
+	for _, key := range keys {
+		if _, ok := labels[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny reports whether name matches any of the shell-style patterns.
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestPruneReclaimed(t *testing.T) {
+	tests := []struct {
+		name, output string
+		want         int64
+	}{
+		{"docker", "Deleted build cache objects:\nabc123\n\nTotal reclaimed space: 1.5GB", 1500000000},
+		{"buildx", "ID\tRECLAIMABLE\tSIZE\tLAST ACCESSED\nabc123\ttrue\t12kB\t2 days ago\nTotal:\t12kB", 12000},
+		{"nothing pruned", "Total reclaimed space: 0B", 0},
+		{"no total", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pruneReclaimed(tt.output); got != tt.want {
+				t.Errorf("pruneReclaimed(%q) = %d, want %d", tt.output, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// clockSkewSlack is how far in the future a timestamp may be before
+	// doctor blames the clock.
+	clockSkewSlack = 5 * time.Minute
+	// futureFileSlack is how far in the future a file's modification time may
+	// be, allowing for files from machines in other time zones.
+	futureFileSlack = 24 * time.Hour
+)
+
+// doctorStatus is how a doctor check came out.
+type doctorStatus int
+
+const (
+	doctorOK doctorStatus = iota
+	doctorWarn
+	doctorFail
+)
+
+// doctorFinding is one thing doctor found, with how to fix it.
+type doctorFinding struct {
+	status doctorStatus
+	text   string
+	fix    string
+}
+
+func defineDoctorCommand(fs *flag.FlagSet) func(args []string) error {
+	system := fs.Bool("system", false, "check the system-wide (root) service")
+
+	return func(args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+		}
+		app, err := commandApp(*system)
+		if err != nil {
+			return err
+		}
+		return app.doctor()
+	}
+}
+
+// doctor checks for the problems that most often keep cleanups from
+// happening, or make them clean the wrong things, and prints what it found
+// with suggested fixes. It changes nothing.
+func (app *App) doctor() error {
+	// A config from an older version is migrated in memory only
+	app.dryRun = true
+
+	fmt.Println(T("doctor.heading"))
+	fmt.Println()
+	config, findings := app.doctorConfig()
+	if config != nil {
+		findings = append(findings, app.doctorDownloads(*config)...)
+		findings = append(findings, app.doctorAgeBy(*config)...)
+		findings = append(findings, app.doctorService(*config)...)
+	}
+	findings = append(findings, app.doctorLock()...)
+	findings = append(findings, app.doctorPermissions()...)
+	findings = append(findings, app.doctorClock()...)
+
+	problems := 0
+	for _, f := range findings {
+		switch f.status {
+		case doctorOK:
+			fmt.Println("✅ " + f.text)
+		case doctorWarn:
+			fmt.Println("⚠️  " + f.text)
+		case doctorFail:
+			fmt.Println("❌ " + f.text)
+		}
+		if f.status != doctorOK {
+			problems++
+			if f.fix != "" {
+				fmt.Println("   → " + f.fix)
+			}
+		}
+	}
+	fmt.Println()
+	if problems > 0 {
+		return fmt.Errorf("%d problems found", problems)
+	}
+	fmt.Println(T("doctor.healthy"))
+	return nil
+}
+
+// doctorConfig loads and validates the config, returning it if it loaded.
+func (app *App) doctorConfig() (*Config, []doctorFinding) {
+	if _, err := os.Stat(app.configPath); os.IsNotExist(err) && (app.system || !fileExists(systemDefaultUserConfig)) {
+		return nil, []doctorFinding{{doctorFail, T("doctor.config_missing", app.configPath), T("doctor.fix_setup", app.commandLine("setup"))}}
+	}
+	config, err := app.loadConfig()
+	if err != nil {
+		return nil, []doctorFinding{{doctorFail, T("doctor.config_invalid", app.configError(err)), T("doctor.fix_config", app.configPath)}}
+	}
+	if err := config.validate(); err != nil {
+		return &config, []doctorFinding{{doctorFail, T("doctor.config_invalid", err), T("doctor.fix_config", app.configPath)}}
+	}
+	// The SMTP password is better kept in a password file
+	if info, err := os.Stat(app.configPath); err == nil && config.Notify.Email.Password != "" && info.Mode().Perm()&0077 != 0 {
+		return &config, []doctorFinding{{doctorWarn, T("doctor.config_secret", app.configPath), T("doctor.fix_secret", app.configPath)}}
+	}
+	return &config, []doctorFinding{{doctorOK, T("doctor.config_ok", app.configPath), ""}}
+}
+
+// configError adds the line and column to a JSON syntax error, which only
+// gives the byte offset.
+func (app *App) configError(err error) string {
+	var syntax *json.SyntaxError
+	if !errors.As(err, &syntax) {
+		return err.Error()
+	}
+	data, rerr := os.ReadFile(app.configPath)
+	if rerr != nil || syntax.Offset > int64(len(data)) {
+		return err.Error()
+	}
+	before := data[:syntax.Offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Sprintf("%v (line %d, column %d)", err, line, column)
+}
+
+func (app *App) doctorDownloads(config Config) []doctorFinding {
+	if !config.CleanDownloads {
+		return nil
+	}
+	info, err := os.Stat(app.downloadsDir)
+	switch {
+	case os.IsNotExist(err):
+		return []doctorFinding{{doctorWarn, T("doctor.downloads_missing", app.downloadsDir), T("doctor.fix_downloads", app.downloadsDir)}}
+	case err != nil:
+		return []doctorFinding{{doctorFail, err.Error(), ""}}
+	case !info.IsDir():
+		return []doctorFinding{{doctorFail, T("doctor.downloads_not_dir", app.downloadsDir), T("doctor.fix_downloads", app.downloadsDir)}}
+	}
+	if finding, ok := writable(app.downloadsDir); !ok {
+		return []doctorFinding{finding}
+	}
+	return []doctorFinding{{doctorOK, T("doctor.downloads_ok", app.downloadsDir), ""}}
+}
+
+// doctorAgeBy checks that the home directory's file system updates access
+// times, if a cleaner counts ages from them.
+func (app *App) doctorAgeBy(config Config) []doctorFinding {
+	if !slices.Contains(slices.Collect(maps.Values(config.AgeBy)), ageByAccessed) {
+		return nil
+	}
+	if fs, ok := mountOf(app.homeDir); ok && fs.noAtime {
+		return []doctorFinding{{doctorWarn, T("doctor.noatime", fs.mountPoint), T("doctor.fix_noatime", fs.mountPoint)}}
+	}
+	return nil
+}
+
+// doctorService checks that the units the config runs from are installed
+// and enabled, and that the last run didn't fail.
+func (app *App) doctorService(config Config) []doctorFinding {
+	if !commandExists("systemctl") {
+		return nil
+	}
+	repair := T("doctor.fix_service", app.commandLine("service", "install"))
+	var units []string
+	login, timer := config.triggers()
+	if login {
+		units = append(units, serviceName)
+	}
+	if timer {
+		units = append(units, timerName)
+	}
+
+	var findings []doctorFinding
+	for _, unit := range units {
+		if !fileExists(filepath.Join(app.systemdUnitDir, unit)) {
+			findings = append(findings, doctorFinding{doctorFail, T("doctor.unit_missing", unit), repair})
+			continue
+		}
+		state, err := app.unitState("is-enabled", unit)
+		if err != nil {
+			findings = append(findings, doctorFinding{doctorWarn, T("doctor.systemd_unreachable", err), ""})
+			return findings
+		}
+		if state != "enabled" {
+			findings = append(findings, doctorFinding{doctorFail, T("doctor.unit_disabled", unit, state), repair})
+		}
+	}
+	if state, err := app.unitState("is-failed", serviceName); err == nil && state == "failed" {
+		logs := "journalctl --user -u " + serviceName
+		if app.system {
+			logs = "journalctl -u " + serviceName
+		}
+		findings = append(findings, doctorFinding{doctorFail, T("doctor.unit_failed", serviceName), T("doctor.fix_failed", logs, app.commandLine("service", "repair"))})
+	}
+	if len(findings) == 0 && len(units) > 0 {
+		findings = append(findings, doctorFinding{doctorOK, T("doctor.service_ok", strings.Join(units, ", ")), ""})
+	}
+	return findings
+}
+
+// unitState asks systemctl one of its is-* questions about unit, returning
+// its answer. Non-zero exits are answers too; only no answer is an error.
+func (app *App) unitState(question, unit string) (string, error) {
+	args := app.systemctl(question, unit)
+	output, err := exec.Command(args[0], args[1:]...).Output()
+	state := strings.TrimSpace(string(output))
+	if state == "" {
+		if err == nil {
+			err = fmt.Errorf("%s gave no answer", strings.Join(args, " "))
+		}
+		return "", err
+	}
+	return state, nil
+}
+
+// doctorLock checks that runs can take the lock, and for a journal an
+// interrupted run left.
+func (app *App) doctorLock() []doctorFinding {
+	var findings []doctorFinding
+	path := filepath.Join(app.stateDir, "saafsafai.lock")
+	if f, err := os.OpenFile(path, os.O_RDWR, 0); err == nil {
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); errors.Is(err, syscall.EWOULDBLOCK) {
+			findings = append(findings, doctorFinding{doctorWarn, T("doctor.lock_held", path), T("doctor.fix_lock_held", path)})
+		}
+		f.Close()
+	} else if os.IsPermission(err) {
+		findings = append(findings, doctorFinding{doctorFail, T("doctor.lock_denied", path, ownerName(path)), T("doctor.fix_chown", app.chownCommand(path))})
+	}
+	if len(findings) == 0 && fileExists(app.journalPath()) {
+		findings = append(findings, doctorFinding{doctorWarn, T("doctor.journal_left", app.journalPath()), T("doctor.fix_journal", app.commandLine())})
+	}
+	if len(findings) == 0 {
+		findings = append(findings, doctorFinding{doctorOK, T("doctor.lock_ok"), ""})
+	}
+	return findings
+}
+
+// doctorPermissions checks that the state and log directories, and the
+// logs in them, are the user's, as a run with sudo can leave them root's.
+func (app *App) doctorPermissions() []doctorFinding {
+	var findings []doctorFinding
+	for _, dir := range []string{app.stateDir, app.logDir} {
+		if !fileExists(dir) {
+			continue
+		}
+		if finding, ok := writable(dir); !ok {
+			finding.fix = T("doctor.fix_chown", app.chownCommand(dir))
+			findings = append(findings, finding)
+			continue
+		}
+		entries, _ := os.ReadDir(dir)
+		foreign := 0
+		for _, entry := range entries {
+			if !ownedByUs(filepath.Join(dir, entry.Name())) {
+				foreign++
+			}
+		}
+		if foreign > 0 {
+			findings = append(findings, doctorFinding{doctorWarn, T("doctor.foreign_files", foreign, dir), T("doctor.fix_chown", app.chownCommand(dir))})
+		}
+	}
+	if len(findings) == 0 {
+		findings = append(findings, doctorFinding{doctorOK, T("doctor.permissions_ok", app.stateDir), ""})
+	}
+	return findings
+}
+
+// doctorClock looks for signs the clock is off, which makes every cleaner's
+// age limits wrong: runs recorded in the future, NTP not synchronized, and
+// Downloads files dated days ahead.
+func (app *App) doctorClock() []doctorFinding {
+	var findings []doctorFinding
+	now := time.Now()
+	if history, err := app.loadHistory(); err == nil {
+		for _, record := range history {
+			if record.Time.After(now.Add(clockSkewSlack)) {
+				findings = append(findings, doctorFinding{doctorFail, T("doctor.clock_behind", record.RunID, record.Time.Format(time.DateTime)), T("doctor.fix_clock")})
+				break
+			}
+		}
+	}
+	if commandExists("timedatectl") {
+		output, err := exec.Command("timedatectl", "show", "--property", "NTPSynchronized", "--value").Output()
+		if err == nil && strings.TrimSpace(string(output)) == "no" {
+			findings = append(findings, doctorFinding{doctorWarn, T("doctor.ntp_unsynced"), T("doctor.fix_clock")})
+		}
+	}
+	if entries, err := os.ReadDir(app.downloadsDir); err == nil {
+		future := 0
+		for _, entry := range entries {
+			if info, err := entry.Info(); err == nil && info.ModTime().After(now.Add(futureFileSlack)) {
+				future++
+			}
+		}
+		if future > 0 {
+			findings = append(findings, doctorFinding{doctorWarn, T("doctor.future_files", future, app.downloadsDir), T("doctor.fix_future_files")})
+		}
+	}
+	if len(findings) == 0 {
+		findings = append(findings, doctorFinding{doctorOK, T("doctor.clock_ok"), ""})
+	}
+	return findings
+}
+
+// writable checks that a directory can be written to, reporting who owns it
+// if not.
+func writable(dir string) (doctorFinding, bool) {
+	f, err := os.CreateTemp(dir, ".saafsafai-doctor-*")
+	if err != nil {
+		return doctorFinding{doctorFail, T("doctor.not_writable", dir, ownerName(dir)), ""}, false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return doctorFinding{}, true
+}
+
+// ownedByUs reports whether path belongs to the user running saafsafai.
+func ownedByUs(path string) bool {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return true
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return !ok || int(st.Uid) == os.Geteuid()
+}
+
+// ownerName returns the name of the user owning path, or its UID.
+func ownerName(path string) string {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "?"
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "?"
+	}
+	return userName(int(st.Uid))
+}
+
+// chownCommand is the command that gives path back to the user.
+func (app *App) chownCommand(path string) string {
+	return fmt.Sprintf("sudo chown -R %s %s", userName(os.Geteuid()), path)
+}
+
+// commandLine returns how to run saafsafai with args for this app's service.
+func (app *App) commandLine(args ...string) string {
+	line := append([]string{binaryName}, args...)
+	if app.system {
+		line = append(append([]string{"sudo"}, line...), "--system")
+	}
+	return strings.Join(line, " ")
+}
+
+// userName returns the name of the user with uid, or the UID if unknown.
+func userName(uid int) string {
+	if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+		return u.Username
+	}
+	return strconv.Itoa(uid)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// emergencyCaches are the caches under the home directory emptied in an
+// emergency; their tools rebuild them as needed.
+var emergencyCaches = []string{
+	".cache/thumbnails",
+	".cache/pip",
+	".cache/go-build",
+	".cache/yarn",
+	".npm/_cacache",
+}
+
+// emergencyStep is one way of recovering space in an emergency, in the
+// order they're tried: the fastest and safest first.
+type emergencyStep struct {
+	title string // message key
+	modes int
+	run   func(app *App, config Config) error
+}
+
+var emergencySteps = []emergencyStep{
+	{title: "emergency.trash", modes: userMode, run: func(app *App, c Config) error { return app.emptyTrash(c.Trash) }},
+	{title: "emergency.quarantine", modes: userMode | systemMode, run: func(app *App, c Config) error { return app.pruneQuarantine(time.Now(), 0) }},
+	{title: "emergency.caches", modes: userMode, run: func(app *App, c Config) error { return app.emptyCaches() }},
+	{title: "emergency.package_cache", modes: userMode | systemMode, run: (*App).emergencyPackageCache},
+	{title: "emergency.docker", modes: userMode | systemMode, run: (*App).emergencyDocker},
+}
+
+func defineEmergencyCommand(fs *flag.FlagSet) func(args []string) error {
+	free := fs.String("free", "", "stop once this much space is freed, e.g. `10GB` (default: try everything)")
+	system := fs.Bool("system", false, "free space as the system service, with its quarantine")
+
+	return func(args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+		}
+		var target int64
+		if *free != "" {
+			var err error
+			if target, err = parseSize(*free); err != nil {
+				return fmt.Errorf("invalid --free: %w", err)
+			}
+		}
+
+		app, err := commandApp(*system)
+		if err != nil {
+			return err
+		}
+		return app.emergency(target)
+	}
+}
+
+// emergency frees space as fast as it safely can, without organizing
+// anything or keeping what it deletes in the quarantine, until target bytes
+// are free (0 for no target). Progress is measured as the growth of the free
+// space on the file systems involved, so it covers what docker and the
+// package manager free too.
+func (app *App) emergency(target int64) error {
+	config, err := app.loadConfig()
+	if err != nil {
+		log.Printf("Warning: %v; using the defaults", err)
+		config = defaultConfig()
+		// The policy's protected paths hold with the defaults too
+		if err := app.applyPolicy(&config); err != nil {
+			return err
+		}
+	}
+
+	unlock, err := app.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// What's deleted is journaled and audited like a run's deletions
+	app.cleaner = "emergency"
+	app.summary.RunID = newRunID()
+	defer app.closeAudit()
+	if err := app.recoverJournal(); err != nil {
+		app.logError("Failed to recover the interrupted run: %v", err)
+	}
+	if err := app.openJournal(); err != nil {
+		return err
+	}
+	defer app.closeJournal()
+
+	if target > 0 {
+		fmt.Println(T("emergency.start", formatSize(target)))
+	} else {
+		fmt.Println(T("emergency.start_all"))
+	}
+
+	paths := []string{app.homeDir, app.stateDir, "/var", "/"}
+	start := freeBytes(paths)
+	var freed int64
+	for _, step := range emergencySteps {
+		if step.modes&app.mode() == 0 {
+			continue
+		}
+		before := freeBytes(paths)
+		if err := step.run(app, config); err != nil {
+			app.logError("%s: %v", T(step.title), err)
+		}
+		// Other programs may write in the meantime
+		after := freeBytes(paths)
+		freed = max(after-start, 0)
+		stepFreed := max(after-before, 0)
+		if target > 0 {
+			fmt.Println(T("emergency.step_target", T(step.title), formatSize(stepFreed), formatSize(freed), formatSize(target)))
+		} else {
+			fmt.Println(T("emergency.step", T(step.title), formatSize(stepFreed), formatSize(freed)))
+		}
+		if target > 0 && freed >= target {
+			break
+		}
+	}
+
+	if target > 0 && freed < target {
+		return fmt.Errorf("freed %s, short of the %s asked for; a full run with --level aggressive may free more", formatSize(freed), formatSize(target))
+	}
+	fmt.Println(T("emergency.done", formatSize(freed)))
+	return nil
+}
+
+// freeBytes returns the space available to unprivileged users on the file
+// systems of paths, each counted once.
+func freeBytes(paths []string) int64 {
+	seen := make(map[uint64]bool)
+	var total int64
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok || seen[uint64(st.Dev)] {
+			continue
+		}
+		var fs syscall.Statfs_t
+		if err := syscall.Statfs(path, &fs); err != nil {
+			continue
+		}
+		seen[uint64(st.Dev)] = true
+		total += int64(fs.Bavail) * int64(fs.Bsize)
+	}
+	return total
+}
+
+// emptyTrash empties the user's desktop trashes: the home one and those at
+// the top of other disks.
+func (app *App) emptyTrash(cfg TrashConfig) error {
+	for _, trash := range app.trashDirs(cfg) {
+		for _, dir := range []string{"files", "info", "expunged"} {
+			if err := app.emptyDir(filepath.Join(trash, dir)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (app *App) emptyCaches() error {
+	for _, cache := range emergencyCaches {
+		if err := app.emptyDir(filepath.Join(app.homeDir, cache)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emptyDir removes everything in dir, keeping dir itself and anything the
+// policy protects; a missing dir is fine.
+func (app *App) emptyDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if err := app.removeAll(path); errors.Is(err, errProtected) {
+			app.skipItem("Failed to remove", path, err)
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeContents deletes everything in dir, keeping dir itself; a missing
+// dir is fine.
+func removeContents(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emergencyPackageCache cleans the package cache, which needs root or a
+// terminal to ask for it on.
+func (app *App) emergencyPackageCache(config Config) error {
+	pm, ok := detectPackageManager()
+	if !ok {
+		return nil
+	}
+	if os.Geteuid() != 0 && !app.isInteractive() {
+		log.Printf("Skipping package cache: %v", errNeedsRoot)
+		return nil
+	}
+	return app.runPrivileged(pm.cleanCache...)
+}
+
+// emergencyDocker removes dangling images and the build cache, sparing
+// anything with one of the configured keep labels.
+func (app *App) emergencyDocker(config Config) error {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return nil
+	}
+	var filters []string
+	for _, label := range config.Docker.KeepLabels {
+		filters = append(filters, "--filter", "label!="+label)
+	}
+	if _, err := dockerOutput(append([]string{"image", "prune", "--force"}, filters...)...); err != nil {
+		return err
+	}
+	_, err := dockerOutput(append([]string{"builder", "prune", "--force"}, filters...)...)
+	return err
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestEmptyCachesProtected empties the caches around a protected path,
+// checking the path is kept and what's removed is audited.
+func TestEmptyCachesProtected(t *testing.T) {
+	home := t.TempDir()
+	app := newHomeApp(home)
+	kept := filepath.Join(home, ".cache", "pip", "keep")
+	removed := filepath.Join(home, ".cache", "pip", "wheels")
+	app.protected = []string{kept}
+	for _, dir := range []string{kept, removed} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "data"), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := app.emptyCaches(); err != nil {
+		t.Fatalf("emptyCaches: %v", err)
+	}
+	app.closeAudit()
+	if _, err := os.Lstat(filepath.Join(kept, "data")); err != nil {
+		t.Errorf("the protected %s was removed: %v", kept, err)
+	}
+	if _, err := os.Lstat(removed); !os.IsNotExist(err) {
+		t.Errorf("%s wasn't removed: %v", removed, err)
+	}
+	audit, err := os.ReadFile(app.auditPath())
+	if err != nil {
+		t.Fatalf("nothing was audited: %v", err)
+	}
+	if !strings.Contains(string(audit), removed) {
+		t.Errorf("the audit log doesn't have %s:\n%s", removed, audit)
+	}
+}
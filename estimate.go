@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+const (
+	sizeCacheFileName = "size-cache.json"
+	// estimateSample is how many entries of a directory an estimate
+	// measures; the size of the rest is scaled up from them.
+	estimateSample = 64
+	// sizeCacheMaxAge is how long a measured tree size is reused while the
+	// tree's top directory is unchanged.
+	sizeCacheMaxAge = 7 * 24 * time.Hour
+)
+
+// packageCacheDirs are where the supported package managers keep
+// downloaded packages.
+var packageCacheDirs = []string{"/var/cache/apt/archives", "/var/cache/dnf", "/var/cache/pacman/pkg"}
+
+// sizeCacheEntry is a tree size measured by an earlier estimate.
+type sizeCacheEntry struct {
+	Bytes    int64     `json:"bytes"`
+	ModTime  time.Time `json:"mod_time"`
+	Measured time.Time `json:"measured"`
+}
+
+// estimateRow is what one cleaner could free.
+type estimateRow struct {
+	name     string
+	items    int
+	bytes    int64
+	measured bool
+}
+
+func defineEstimateCommand(fs *flag.FlagSet) func(args []string) error {
+	level := fs.String("level", "", "estimate for this `level` (default: the config's level)")
+	system := fs.Bool("system", false, "estimate for the system-wide configuration")
+
+	return func(args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("expected: estimate")
+		}
+		if *level != "" {
+			if _, err := findLevel(*level); err != nil {
+				return err
+			}
+		}
+		app, err := commandApp(*system)
+		if err != nil {
+			return err
+		}
+		app.levelFlag = *level
+		return app.estimate()
+	}
+}
+
+// estimate reports how much each enabled cleaner could free. It's a dry run
+// that, instead of measuring every tree a cleaner would remove, samples big
+// directories and reuses sizes measured recently, so it's quick enough to
+// decide whether a full run is worth it. As a dry run it changes nothing;
+// only the size cache is written.
+func (app *App) estimate() error {
+	config, err := app.loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	unlock, err := app.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	app.dryRun, app.summary.DryRun, app.estimating = true, true, true
+	app.pickLevel(config)
+	app.loadSizeCache()
+
+	var rows []estimateRow
+	mode := app.mode()
+	for _, c := range cleaners {
+		// Users' runs would each be a full dry run of their own
+		if c.modes&mode == 0 || !c.enabled(config) || !app.selected(c.name) || c.name == "users" || c.name == "maintenance" {
+			continue
+		}
+		bytes, items := app.summary.FreedBytes, app.foundCount()
+		if err := c.run(app, config); err != nil {
+			app.logError("Error estimating %s: %v", c.description, err)
+		}
+		row := estimateRow{name: c.name, items: app.foundCount() - items, bytes: app.summary.FreedBytes - bytes, measured: true}
+		switch c.name {
+		case "docker", "kube":
+			// Their dry runs only list what they'd remove
+			row.measured = false
+		case "packages":
+			row.measured = config.CleanPackageCache
+			if config.CleanPackageCache {
+				for _, dir := range packageCacheDirs {
+					row.bytes += app.estimateSize(dir)
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	if err := app.saveSizeCache(); err != nil {
+		app.logError("Failed to save the size cache: %v", err)
+	}
+	app.printEstimate(rows)
+	return nil
+}
+
+func (app *App) printEstimate(rows []estimateRow) {
+	fmt.Println(T("estimate.title", app.level.name))
+	names := []string{T("estimate.total")}
+	for _, row := range rows {
+		names = append(names, row.name)
+	}
+	width := columnWidth(14, names)
+	var total int64
+	for _, row := range rows {
+		size := formatSize(row.bytes)
+		if !row.measured {
+			size = "?"
+		}
+		fmt.Printf("   %s %10s   %s\n", padRight(row.name, width), size, T("estimate.items", row.items))
+		total += row.bytes
+	}
+	fmt.Printf("   %s %10s\n", padRight(T("estimate.total"), width), formatSize(total))
+	fmt.Println()
+	fmt.Println(T("estimate.note"))
+	for _, msg := range app.summary.Errors.Sample {
+		fmt.Println("❌ " + msg)
+	}
+}
+
+// estimateSize returns the disk usage of the tree at path: from the size
+// cache if its top directory hasn't changed since it was measured, or else
+// sampled.
+func (app *App) estimateSize(path string) int64 {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0
+	}
+	if entry, ok := app.sizeCache[path]; ok && entry.ModTime.Equal(info.ModTime()) && time.Since(entry.Measured) < sizeCacheMaxAge {
+		return entry.Bytes
+	}
+
+	root, err := os.OpenRoot(filepath.Dir(path))
+	if err != nil {
+		return 0
+	}
+	defer root.Close()
+	bytes := sampledSize(root, filepath.Base(path))
+	app.sizeCache[path] = sizeCacheEntry{Bytes: bytes, ModTime: info.ModTime(), Measured: time.Now()}
+	return bytes
+}
+
+// sampledSize estimates the disk usage of name in root. Of directories with
+// more than estimateSample entries, only evenly spread ones are measured and
+// their total is scaled up. Hard links are counted at every name.
+func sampledSize(root *os.Root, name string) int64 {
+	info, err := root.Lstat(name)
+	if err != nil {
+		return 0
+	}
+	var bytes int64
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		bytes = int64(st.Blocks) * 512
+	} else {
+		bytes = info.Size()
+	}
+	if !info.IsDir() {
+		return bytes
+	}
+
+	entries, err := fs.ReadDir(root.FS(), filepath.ToSlash(name))
+	if err != nil || len(entries) == 0 {
+		return bytes
+	}
+	step := max(len(entries)/estimateSample, 1)
+	var sampled int64
+	measured := 0
+	for i := 0; i < len(entries); i += step {
+		sampled += sampledSize(root, filepath.Join(name, entries[i].Name()))
+		measured++
+	}
+	return bytes + sampled*int64(len(entries))/int64(measured)
+}
+
+func (app *App) loadSizeCache() {
+	app.sizeCache = make(map[string]sizeCacheEntry)
+	data, err := os.ReadFile(filepath.Join(app.stateDir, sizeCacheFileName))
+	if err != nil {
+		return
+	}
+	// A corrupt cache only means measuring again
+	json.Unmarshal(data, &app.sizeCache)
+	for path, entry := range app.sizeCache {
+		if time.Since(entry.Measured) >= sizeCacheMaxAge {
+			delete(app.sizeCache, path)
+		}
+	}
+}
+
+func (app *App) saveSizeCache() error {
+	data, err := json.Marshal(app.sizeCache)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(app.stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return os.WriteFile(filepath.Join(app.stateDir, sizeCacheFileName), data, 0644)
+}
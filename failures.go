@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// Why an item was skipped, as counted in Summary.SkipReasons; each has a
+// "reason.<code>" message.
+const (
+	reasonPermission  = "permission"
+	reasonCrossDevice = "cross_device"
+	reasonBusy        = "busy"
+	reasonVanished    = "vanished"
+	reasonReadOnly    = "read_only"
+	reasonNoSpace     = "no_space"
+	reasonDiskGuard   = "disk_guard"
+	reasonOther       = "other"
+)
+
+// failureReason sorts an item's failure into one of the reasons above.
+func failureReason(err error) string {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return reasonPermission
+	case errors.Is(err, syscall.EXDEV):
+		return reasonCrossDevice
+	// Docker only says a volume is busy in its output
+	case errors.Is(err, syscall.EBUSY), errors.Is(err, syscall.ETXTBSY), strings.Contains(err.Error(), "in use"):
+		return reasonBusy
+	case errors.Is(err, fs.ErrNotExist):
+		return reasonVanished
+	case errors.Is(err, syscall.EROFS):
+		return reasonReadOnly
+	case errors.Is(err, syscall.ENOSPC):
+		return reasonNoSpace
+	case errors.Is(err, errDiskGuard):
+		return reasonDiskGuard
+	default:
+		return reasonOther
+	}
+}
+
+// skipItem logs that action failed on the item at path, and records it in
+// the summary's skipped items with the reason, so the run counts as partly
+// failed. Failures of a whole cleaner go through logError instead. Items
+// the system policy protects aren't failures; they're only listed.
+func (app *App) skipItem(action, path string, err error) {
+	if errors.Is(err, errProtected) {
+		app.addItem(&app.summary.ProtectedItems, app.displayPath(path))
+		return
+	}
+	log.Print(displayName(fmt.Sprintf("%s %s: %v", action, path, err)))
+	reason := failureReason(err)
+	if app.summary.SkipReasons == nil {
+		app.summary.SkipReasons = make(map[string]int)
+	}
+	app.summary.SkipReasons[reason]++
+	app.addItem(&app.summary.Skipped, fmt.Sprintf("%s (%s)", app.displayPath(path), T("reason."+reason)))
+}
+
+// failures counts the run's errors and skipped items.
+func (app *App) failures() int {
+	return app.summary.Errors.Count + app.summary.Skipped.Count
+}
+
+// skipReasons describes the skipped items' reasons, most common first, e.g.
+// "3 permission denied, 1 busy".
+func (app *App) skipReasons() string {
+	reasons := sortedKeys(app.summary.SkipReasons)
+	sort.SliceStable(reasons, func(i, j int) bool {
+		return app.summary.SkipReasons[reasons[i]] > app.summary.SkipReasons[reasons[j]]
+	})
+	var parts []string
+	for _, reason := range reasons {
+		parts = append(parts, fmt.Sprintf("%d %s", app.summary.SkipReasons[reason], T("reason."+reason)))
+	}
+	return strings.Join(parts, ", ")
+}
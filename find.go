@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Match qualities, best first.
+const (
+	matchExact = iota
+	matchSubstring
+	matchNormalized
+	matchFuzzy
+	noMatch
+)
+
+func defineFindCommand(fs *flag.FlagSet) func(args []string) error {
+	system := fs.Bool("system", false, "search the system service's audit log")
+
+	return func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("expected: find <pattern>")
+		}
+		app, err := commandApp(*system)
+		if err != nil {
+			return err
+		}
+		return app.findFiles(args[0])
+	}
+}
+
+// matchName rates how well a file name matches what the user remembers of
+// it: a glob, if pattern has wildcards, or else the name, part of it, the
+// name without its copy markers (see normalizeName), or a part of it a
+// typo or two away.
+func matchName(pattern, name string) int {
+	pattern, name = strings.ToLower(pattern), strings.ToLower(name)
+	if strings.ContainsAny(pattern, "*?[") {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return matchExact
+		}
+		return noMatch
+	}
+
+	switch {
+	case name == pattern:
+		return matchExact
+	case strings.Contains(name, pattern):
+		return matchSubstring
+	}
+	normalized, want := normalizeName(name), normalizeName(pattern)
+	if strings.TrimSuffix(want, filepath.Ext(want)) == "" {
+		return noMatch
+	}
+	if normalized == want || strings.Contains(normalized, want) {
+		return matchNormalized
+	}
+	if len(pattern) < 3 {
+		return noMatch
+	}
+	base, wantBase := strings.TrimSuffix(normalized, filepath.Ext(normalized)), strings.TrimSuffix(want, filepath.Ext(want))
+	if nearSubstring(wantBase, base, max(1, len([]rune(wantBase))/4)) {
+		return matchFuzzy
+	}
+	return noMatch
+}
+
+// nearSubstring reports whether some part of s is at most maxDist typos
+// away from want.
+func nearSubstring(want, s string, maxDist int) bool {
+	runes := []rune(s)
+	n := len([]rune(want))
+	for length := max(1, n-maxDist); length <= n+maxDist; length++ {
+		for start := 0; start+length <= len(runes); start++ {
+			if editDistance(want, string(runes[start:start+length])) <= maxDist {
+				return true
+			}
+		}
+		if length > len(runes) {
+			break
+		}
+	}
+	return false
+}
+
+// editDistance is the Levenshtein distance between a and b, in runes.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// fileHistory reads what runs did to files: the audit log, plus the
+// category folder indexes, which may go back further. An index entry the
+// audit log has too is only listed once.
+func (app *App) fileHistory() ([]auditRecord, error) {
+	var records []auditRecord
+	seen := make(map[string]bool)
+	f, err := os.Open(app.auditPath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var record auditRecord
+			if json.Unmarshal(scanner.Bytes(), &record) != nil {
+				continue
+			}
+			records = append(records, record)
+			seen[record.RunID+"\x00"+record.Dst] = true
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+	}
+
+	indexes, _ := filepath.Glob(filepath.Join(app.downloadsDir, "*", categoryIndexName+".*"))
+	for _, index := range indexes {
+		for _, entry := range readCategoryIndex(index) {
+			record := auditRecord{
+				Time:       entry.Moved,
+				RunID:      entry.RunID,
+				Cleaner:    "downloads",
+				Action:     opMove,
+				Src:        filepath.Join(app.downloadsDir, entry.OriginalName),
+				Dst:        filepath.Join(filepath.Dir(index), entry.Name),
+				Origin:     entry.Origin,
+				Downloaded: entry.Downloaded,
+			}
+			if !seen[record.RunID+"\x00"+record.Dst] {
+				records = append(records, record)
+			}
+		}
+	}
+	return records, nil
+}
+
+// readCategoryIndex reads a category folder's index, in either format,
+// skipping lines it can't parse.
+func readCategoryIndex(path string) []categoryIndexEntry {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var entries []categoryIndexEntry
+	if filepath.Ext(path) == ".jsonl" {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var entry categoryIndexEntry
+			if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+				entries = append(entries, entry)
+			}
+		}
+		return entries
+	}
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			continue
+		}
+		if len(row) < 6 || row[0] == "name" {
+			continue
+		}
+		entry := categoryIndexEntry{Name: row[0], OriginalName: row[1], Origin: row[3], RunID: row[4]}
+		entry.Downloaded, _ = time.Parse(time.RFC3339, row[2])
+		entry.Moved, _ = time.Parse(time.RFC3339, row[5])
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// findFiles tells where the files matching pattern went: each file a run
+// moved, quarantined, deleted or handed to a script, by its original path,
+// best matches first.
+func (app *App) findFiles(pattern string) error {
+	records, err := app.fileHistory()
+	if err != nil {
+		return err
+	}
+	quarantined := make(map[string][]quarantineRecord)
+	if items, err := app.loadQuarantine(); err == nil {
+		for _, item := range items {
+			quarantined[item.Path] = append(quarantined[item.Path], item)
+		}
+	}
+	snapshots := make(map[string][]runSnapshot)
+	if history, err := app.loadHistory(); err == nil {
+		for _, record := range history {
+			snapshots[record.RunID] = record.Snapshots
+		}
+	}
+
+	type match struct {
+		src     string
+		quality int
+		events  []auditRecord
+	}
+	matches := make(map[string]*match)
+	for _, record := range records {
+		quality := matchName(pattern, filepath.Base(record.Src))
+		if record.Dst != "" {
+			quality = min(quality, matchName(pattern, filepath.Base(record.Dst)))
+		}
+		if quality == noMatch {
+			continue
+		}
+		m, ok := matches[record.Src]
+		if !ok {
+			m = &match{src: record.Src, quality: quality}
+			matches[record.Src] = m
+		}
+		m.quality = min(m.quality, quality)
+		m.events = append(m.events, record)
+	}
+	if len(matches) == 0 {
+		fmt.Println(T("find.none", pattern))
+		return nil
+	}
+
+	found := make([]*match, 0, len(matches))
+	for _, m := range matches {
+		sort.Slice(m.events, func(i, j int) bool { return m.events[i].Time.Before(m.events[j].Time) })
+		found = append(found, m)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].quality != found[j].quality {
+			return found[i].quality < found[j].quality
+		}
+		return found[i].events[len(found[i].events)-1].Time.After(found[j].events[len(found[j].events)-1].Time)
+	})
+
+	for _, m := range found {
+		fmt.Println(T("find.file", displayName(app.displayPath(m.src))))
+		for _, record := range m.events {
+			fmt.Printf("   %s  %s  (%s)\n", record.Time.Local().Format("2006-01-02 15:04"), app.describeEvent(record, quarantined, snapshots[record.RunID]), record.RunID)
+		}
+		if origin := m.events[0]; origin.Origin != "" {
+			fmt.Println("   " + T("audit.origin", origin.Origin, origin.Downloaded.Local().Format("2006-01-02")))
+		}
+		fmt.Println()
+	}
+	fmt.Println(T("find.total", len(found)))
+	return nil
+}
+
+// describeEvent says what a run did to a file, and where it is now: a file
+// it removed may still be in a snapshot taken before it.
+func (app *App) describeEvent(record auditRecord, quarantined map[string][]quarantineRecord, snapshots []runSnapshot) string {
+	text := app.eventText(record, quarantined)
+	if record.Action != opMove && record.Action != opScript && record.Action != opRestore {
+		if kept := snapshotCopy(snapshots, record.Src); kept != "" {
+			text += ", " + T("find.snapshot", app.displayPath(kept))
+		}
+	}
+	return text
+}
+
+func (app *App) eventText(record auditRecord, quarantined map[string][]quarantineRecord) string {
+	switch record.Action {
+	case opMove:
+		text := T("find.moved", displayName(app.displayPath(record.Dst)))
+		if _, err := os.Lstat(record.Dst); err != nil {
+			text += " " + T("find.gone")
+		}
+		return text
+	case "quarantine":
+		// The quarantine item of this removal, if it hasn't expired
+		for _, item := range quarantined[record.Src] {
+			if d := item.Time.Sub(record.Time); d > -time.Minute && d < time.Minute {
+				return T("find.quarantined", item.ID)
+			}
+		}
+		return T("find.quarantine_expired")
+	case "trash":
+		return T("find.trashed")
+	case opRestore:
+		return T("find.restored")
+	case opScript:
+		return T("find.script", app.displayPath(record.Dst))
+	default:
+		return T("find.removed")
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	fontCacheMaxAge = 90 // days
+	fontCacheBudget = "50MB"
+)
+
+type FontCacheConfig struct {
+	// MaxAgeDays rebuilds a cache once its newest file is this many days
+	// old (default 90), or older than what it indexes.
+	MaxAgeDays int `json:"max_age_days"`
+	// Budget rebuilds the fontconfig cache once it outgrows this size, e.g.
+	// "50MB" (the default): it only ever gains files for fonts long gone.
+	Budget string `json:"budget"`
+}
+
+// fontCacheDirs are the fontconfig caches: the user's, or the system's.
+func (app *App) fontCacheDirs() []string {
+	if app.system {
+		return []string{"/var/cache/fontconfig"}
+	}
+	return []string{filepath.Join(app.homeDir, ".cache", "fontconfig")}
+}
+
+// fontDirs are the font folders the fontconfig caches index.
+func (app *App) fontDirs() []string {
+	if app.system {
+		return []string{"/usr/share/fonts", "/usr/local/share/fonts"}
+	}
+	return []string{filepath.Join(app.homeDir, ".local", "share", "fonts"), filepath.Join(app.homeDir, ".fonts")}
+}
+
+// fontsChangedSince reports whether a font folder has changed since t.
+func (app *App) fontsChangedSince(t time.Time) bool {
+	for _, dir := range app.fontDirs() {
+		if _, newest := treeStats(dir); newest.After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// iconThemeDirs are the icon themes whose icon-theme.cache is kept up to
+// date here.
+func (app *App) iconThemeDirs() []string {
+	root := filepath.Join(app.homeDir, ".local", "share", "icons")
+	if app.system {
+		root = "/usr/share/icons"
+	}
+	themes, _ := filepath.Glob(filepath.Join(root, "*", "index.theme"))
+	var dirs []string
+	for _, theme := range themes {
+		dirs = append(dirs, filepath.Dir(theme))
+	}
+	return dirs
+}
+
+// rebuildFontCaches clears the fontconfig and icon caches that are too old,
+// too big or out of date with what they index, and has fc-cache and
+// gtk-update-icon-cache rebuild them.
+func (app *App) rebuildFontCaches(cfg FontCacheConfig) error {
+	cutoff := app.ageCutoff(cfg.MaxAgeDays, fontCacheMaxAge)
+	if cfg.Budget == "" {
+		cfg.Budget = fontCacheBudget
+	}
+	budget, err := app.sizeBudget(cfg.Budget)
+	if err != nil {
+		return fmt.Errorf("invalid font_cache.budget: %w", err)
+	}
+
+	if commandExists("fc-cache") {
+		for _, dir := range app.fontCacheDirs() {
+			size, newest := treeStats(dir)
+			if size == 0 || (size <= budget && newest.After(cutoff) && !app.fontsChangedSince(newest)) {
+				continue
+			}
+			if err := app.rebuildCache(dir, removeContents, "fc-cache", "-f"); err != nil {
+				app.skipItem("Failed to rebuild font cache", dir, err)
+				continue
+			}
+			app.cacheRebuilt("fontconfig", dir, size)
+		}
+	}
+
+	if commandExists("gtk-update-icon-cache") {
+		for _, theme := range app.iconThemeDirs() {
+			cache := filepath.Join(theme, "icon-theme.cache")
+			info, err := os.Stat(cache)
+			if err != nil {
+				continue
+			}
+			// A theme changed since its cache was built has icons the cache
+			// doesn't know about
+			if _, newest := treeStats(theme); info.ModTime().After(cutoff) && !newest.After(info.ModTime()) {
+				continue
+			}
+			if err := app.rebuildCache(cache, os.Remove, "gtk-update-icon-cache", "-f", "-t", theme); err != nil {
+				app.skipItem("Failed to rebuild icon cache", cache, err)
+				continue
+			}
+			app.cacheRebuilt("icons", cache, info.Size())
+		}
+	}
+	return nil
+}
+
+// rebuildCache clears path with clear, then runs the command that rebuilds
+// it. The old cache isn't quarantined: the new one replaces it.
+func (app *App) rebuildCache(path string, clear func(string) error, command ...string) error {
+	if app.dryRun {
+		return nil
+	}
+	if err := clear(path); err != nil {
+		return err
+	}
+	if output, err := exec.Command(command[0], command[1:]...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", command[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// cacheRebuilt adds a rebuilt cache to the summary. What it freed is how
+// much smaller the new cache is, which a dry run can't know.
+func (app *App) cacheRebuilt(kind, path string, size int64) {
+	item := fmt.Sprintf("%s %s (%s)", kind, app.displayPath(path), formatSize(size))
+	if !app.dryRun {
+		rebuilt, _ := treeStats(path)
+		if rebuilt < size {
+			app.summary.FreedBytes += size - rebuilt
+		}
+		item = fmt.Sprintf("%s %s (%s -> %s)", kind, app.displayPath(path), formatSize(size), formatSize(rebuilt))
+	}
+	app.addItem(&app.summary.RebuiltCaches, item)
+}
+
+// treeStats returns the total size of the files under dir and the newest
+// modification time in it, dir included.
+func treeStats(dir string) (int64, time.Time) {
+	var size int64
+	var newest time.Time
+	walkTree(dir, func(rel string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		return nil
+	})
+	return size, newest
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFontsChangedSince(t *testing.T) {
+	home := t.TempDir()
+	app := newHomeApp(home)
+	cacheBuilt := time.Now().AddDate(0, 0, -10)
+	if app.fontsChangedSince(cacheBuilt) {
+		t.Error("fonts changed with no font folders")
+	}
+
+	font := filepath.Join(home, ".local", "share", "fonts", "Inter.ttf")
+	if err := os.MkdirAll(filepath.Dir(font), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(font, []byte("font"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := cacheBuilt.AddDate(0, 0, -1)
+	for _, p := range []string{font, filepath.Dir(font)} {
+		if err := os.Chtimes(p, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if app.fontsChangedSince(cacheBuilt) {
+		t.Error("fonts changed, with only fonts older than the cache")
+	}
+
+	if err := os.Chtimes(font, time.Now(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if !app.fontsChangedSince(cacheBuilt) {
+		t.Error("fonts didn't change, with a font newer than the cache")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"syscall"
+)
+
+// inodeKey identifies a file whatever the name it's reached by.
+type inodeKey struct {
+	dev, ino uint64
+}
+
+// diskFile is one inode of a tree about to be removed, with the space its
+// blocks take on disk.
+type diskFile struct {
+	key   inodeKey
+	path  string
+	links uint64
+	bytes int64
+}
+
+// freedSpace tracks the inodes counted as freed, so an inode is counted
+// once, and only when every one of its links has been removed.
+type freedSpace struct {
+	counted map[inodeKey]bool
+	// links are the removed names of hard-linked inodes not yet counted
+	links map[inodeKey]map[string]bool
+}
+
+// diskFiles lists the inodes under path (path itself if it isn't a
+// directory).
+func diskFiles(path string) []diskFile {
+	var files []diskFile
+	walkTree(path, func(rel string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return nil
+		}
+		file := diskFile{
+			key:   inodeKey{uint64(st.Dev), uint64(st.Ino)},
+			path:  filepath.Join(path, rel),
+			links: uint64(st.Nlink),
+			bytes: int64(st.Blocks) * 512,
+		}
+		// A directory's link count is its subdirectories, not other names
+		if d.IsDir() {
+			file.links = 1
+		}
+		files = append(files, file)
+		return nil
+	})
+	return files
+}
+
+// countFreed adds the space freed by removing files to the summary.
+func (app *App) countFreed(files []diskFile) {
+	// A real run measures every tree just before removing it, when the
+	// links already removed are gone from the link counts (and their inode
+	// numbers may have been reused), so each removal is counted on its own.
+	// A dry run removes nothing and has to track links across the run.
+	space := &freedSpace{}
+	if app.dryRun {
+		space = &app.freed
+	}
+	app.summary.FreedBytes += space.add(files)
+}
+
+// add returns the space of the files' inodes not counted yet whose links
+// have all been seen.
+func (s *freedSpace) add(files []diskFile) int64 {
+	if s.counted == nil {
+		s.counted = make(map[inodeKey]bool)
+		s.links = make(map[inodeKey]map[string]bool)
+	}
+
+	var total int64
+	for _, f := range files {
+		if s.counted[f.key] {
+			continue
+		}
+		if f.links > 1 {
+			seen := s.links[f.key]
+			if seen == nil {
+				seen = make(map[string]bool)
+				s.links[f.key] = seen
+			}
+			seen[f.path] = true
+			if uint64(len(seen)) < f.links {
+				continue
+			}
+			delete(s.links, f.key)
+		}
+		s.counted[f.key] = true
+		total += f.bytes
+	}
+	return total
+}
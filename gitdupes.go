@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// gitClone is a repository found with an origin, for spotting duplicates.
+type gitClone struct {
+	path         string
+	size         int64
+	lastActivity time.Time
+}
+
+// normalizeRemote reduces a remote URL to its host and path, so that the
+// HTTPS, SSH and scp-like forms of one repository compare equal.
+func normalizeRemote(url string) string {
+	url = strings.TrimSpace(url)
+	host, path := "", url
+	if _, rest, ok := strings.Cut(url, "://"); ok {
+		host, path, _ = strings.Cut(rest, "/")
+		// A port only says how the host is reached
+		host, _, _ = strings.Cut(host[strings.LastIndex(host, "@")+1:], ":")
+	} else if h, p, ok := strings.Cut(url, ":"); ok && !strings.Contains(h, "/") {
+		// The scp-like user@host:path
+		host, path = h[strings.LastIndex(h, "@")+1:], p
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	return strings.ToLower(host) + "/" + path
+}
+
+// lastActivity returns when a repository was last committed to or had git
+// update its index, whichever is later.
+func lastActivity(repo string) time.Time {
+	last := lastCommit(repo, "refs/heads", "refs/stash")
+	if info, err := os.Stat(filepath.Join(repo, ".git", "index")); err == nil && info.ModTime().After(last) {
+		last = info.ModTime()
+	}
+	return last
+}
+
+// hasLocalWork reports whether a clone has anything its origin doesn't:
+// uncommitted or untracked changes, stashes or commits not pushed.
+func hasLocalWork(repo string) bool {
+	if !isClean(repo) {
+		return true
+	}
+	stash, err := gitOutput(repo, "stash", "list")
+	if err != nil || stash != "" {
+		return true
+	}
+	unpushed, err := gitOutput(repo, "log", "--oneline", "-1", "--branches", "--not", "--remotes")
+	return err != nil || unpushed != ""
+}
+
+// reportDuplicateClones groups the repositories by their origin and reports
+// the ones cloned more than once, the most recently active first. Run on a
+// terminal it offers to remove the other copies, as long as they have no
+// work of their own that would be lost.
+func (app *App) reportDuplicateClones(repos []string, interactive bool) error {
+	clones := make(map[string][]gitClone)
+	for _, repo := range repos {
+		if _, err := os.Stat(repo); err != nil {
+			continue
+		}
+		origin, err := gitOutput(repo, "config", "--get", "remote.origin.url")
+		if err != nil || origin == "" {
+			continue
+		}
+		key := normalizeRemote(origin)
+		clones[key] = append(clones[key], gitClone{path: repo, size: dirSize(repo), lastActivity: lastActivity(repo)})
+	}
+
+	for _, key := range sortedKeys(clones) {
+		group := clones[key]
+		if len(group) < 2 {
+			continue
+		}
+		sort.SliceStable(group, func(i, j int) bool { return group[i].lastActivity.After(group[j].lastActivity) })
+		var copies []string
+		for _, c := range group {
+			copies = append(copies, fmt.Sprintf("%s (%s, %s)", app.displayPath(c.path), formatSize(c.size), c.lastActivity.Format("2006-01-02")))
+		}
+		app.addItem(&app.summary.DuplicateRepos, T("item.duplicate_repos", key, strings.Join(copies, ", ")))
+
+		if !interactive {
+			continue
+		}
+		keep := group[0]
+		for _, c := range group[1:] {
+			if hasLocalWork(c.path) {
+				continue
+			}
+			ok, err := app.confirm(T("confirm.remove_clone", app.displayPath(c.path), formatSize(c.size), app.displayPath(keep.path)))
+			if err != nil {
+				return fmt.Errorf("failed to read input: %w", err)
+			}
+			if !ok {
+				continue
+			}
+			if err := app.removeAll(c.path); err != nil {
+				app.skipItem("Failed to remove duplicate clone", c.path, err)
+				continue
+			}
+			app.addItem(&app.summary.RemovedClones, fmt.Sprintf("%s (%s)", app.displayPath(c.path), formatSize(c.size)))
+		}
+	}
+	return nil
+}
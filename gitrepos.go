@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	gitGCMaxAge        = 30 // days
+	gitLargeRatio      = 2
+	gitLargeMinSize    = 100 << 20
+	gitHousekeepingMax = 10 * time.Minute
+	gitGCStateFileName = "git-gc.json"
+)
+
+type GitReposConfig struct {
+	// Roots are the directories looked in, relative to the home directory
+	// unless absolute; empty looks everywhere in it.
+	Roots []string `json:"roots"`
+	// GCAfterDays tidies a repository once this many days have passed
+	// since saafsafai last did (default 30).
+	GCAfterDays int `json:"gc_after_days"`
+	// LargeRatio reports repositories whose .git is more than this many
+	// times the size of their files (default 2), from 100 MB up.
+	LargeRatio int `json:"large_ratio"`
+	// StaleMonths reports clones, worktrees and branches with no commits
+	// or uncommitted changes in this many months (default 6), offering to
+	// archive the clones into ArchiveDir (default ~/Archive) or remove the
+	// worktrees on the terminal.
+	StaleMonths int    `json:"stale_months"`
+	ArchiveDir  string `json:"archive_dir"`
+}
+
+// scanGitRepos is the repository matcher for the home directory scan,
+// unless the config has roots of its own to look in.
+func (app *App) scanGitRepos(config Config) homeMatcher {
+	if len(config.GitRepos.Roots) > 0 {
+		return nil
+	}
+	return app.matchGitRepos()
+}
+
+// matchGitRepos collects the working trees with a .git directory, without
+// descending into it, leaving saafsafai's own state alone. Worktrees and
+// submodules, whose .git is a file, are their main repository's.
+func (app *App) matchGitRepos() homeMatcher {
+	return func(path string, d fs.DirEntry) error {
+		if !d.IsDir() {
+			return nil
+		}
+		if path == app.stateDir {
+			return filepath.SkipDir
+		}
+		if d.Name() == ".git" {
+			app.scan.gitRepos = append(app.scan.gitRepos, filepath.Dir(path))
+			return filepath.SkipDir
+		}
+		return nil
+	}
+}
+
+// cleanGitRepos runs git's own housekeeping, expiring old reflog entries and
+// git gc --auto, in the repositories not tidied recently, and reports those
+// whose history far outweighs their files, or that are cloned twice. Only .git is ever changed, unless
+// the user agrees to archive a stale clone, remove a stale worktree or a
+// duplicate clone.
+func (app *App) cleanGitRepos(config Config) error {
+	cfg := config.GitRepos
+	if _, err := exec.LookPath("git"); err != nil {
+		log.Printf("Git is not installed, skipping repository housekeeping")
+		return nil
+	}
+	cutoff := app.ageCutoff(cfg.GCAfterDays, gitGCMaxAge)
+	ratio := int64(cfg.LargeRatio)
+	if ratio <= 0 {
+		ratio = gitLargeRatio
+	}
+
+	if err := app.scanRoots(config, cfg.Roots, app.matchGitRepos()); err != nil {
+		return fmt.Errorf("error scanning for git repositories: %w", err)
+	}
+
+	staleCutoff := app.ageCutoff(cfg.StaleMonths*30, gitStaleMonths*30)
+	interactive := app.isInteractive() && !app.dryRun
+
+	tidied := app.loadGitGCState()
+	for _, repo := range app.scan.gitRepos {
+		if err := app.reportStaleGit(repo, cfg, staleCutoff, interactive); err != nil {
+			return err
+		}
+		if _, err := os.Stat(repo); err != nil {
+			// Archived
+			continue
+		}
+
+		gitDir := filepath.Join(repo, ".git")
+		before := dirSize(gitDir)
+		after := before
+
+		if tidied[repo].Before(cutoff) {
+			if err := app.tidyGitRepo(gitDir); err != nil {
+				app.skipItem("Failed to tidy git repository", repo, err)
+			} else {
+				item := fmt.Sprintf("%s (.git %s)", app.displayPath(repo), formatSize(before))
+				if !app.dryRun {
+					after = dirSize(gitDir)
+					if after < before {
+						app.summary.FreedBytes += before - after
+					}
+					item = fmt.Sprintf("%s (.git %s -> %s)", app.displayPath(repo), formatSize(before), formatSize(after))
+					tidied[repo] = time.Now()
+				}
+				app.addItem(&app.summary.TidiedRepos, item)
+			}
+		}
+
+		if after >= gitLargeMinSize {
+			if files := dirSize(repo) - after; after > ratio*files {
+				app.addItem(&app.summary.LargeRepos, fmt.Sprintf("%s (.git %s, files %s)", app.displayPath(repo), formatSize(after), formatSize(files)))
+			}
+		}
+	}
+
+	if err := app.reportDuplicateClones(app.scan.gitRepos, interactive); err != nil {
+		return err
+	}
+
+	if !app.dryRun {
+		if err := app.saveGitGCState(tidied); err != nil {
+			log.Printf("Warning: failed to save when repositories were tidied: %v", err)
+		}
+	}
+	return nil
+}
+
+// tidyGitRepo expires the reflog entries older than the repository's
+// gc.reflogExpire settings and has git gc --auto repack it if it needs to.
+// A repository git is busy with is left for the next run.
+func (app *App) tidyGitRepo(gitDir string) error {
+	if app.isProtected(filepath.Dir(gitDir)) {
+		return errProtected
+	}
+	if _, err := os.Stat(filepath.Join(gitDir, "index.lock")); err == nil {
+		return fmt.Errorf("git is running in it")
+	}
+	if app.dryRun || app.estimating {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gitHousekeepingMax)
+	defer cancel()
+	for _, args := range [][]string{
+		{"reflog", "expire", "--all"},
+		{"gc", "--auto", "--quiet"},
+	} {
+		cmd := exec.CommandContext(ctx, "git", append([]string{"--git-dir", gitDir}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// loadGitGCState returns when each repository was last tidied.
+func (app *App) loadGitGCState() map[string]time.Time {
+	tidied := make(map[string]time.Time)
+	if data, err := os.ReadFile(filepath.Join(app.stateDir, gitGCStateFileName)); err == nil {
+		json.Unmarshal(data, &tidied)
+	}
+	return tidied
+}
+
+// saveGitGCState records when the repositories were last tidied, forgetting
+// those that are gone.
+func (app *App) saveGitGCState(tidied map[string]time.Time) error {
+	for repo := range tidied {
+		if _, err := os.Stat(filepath.Join(repo, ".git")); os.IsNotExist(err) {
+			delete(tidied, repo)
+		}
+	}
+	data, err := json.Marshal(tidied)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(app.stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return os.WriteFile(filepath.Join(app.stateDir, gitGCStateFileName), data, 0644)
+}
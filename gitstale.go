@@ -0,0 +1,265 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	gitStaleMonths    = 6
+	defaultArchiveDir = "Archive"
+)
+
+// gitOutput runs git in dir and returns its trimmed output. It takes no
+// optional locks, so it never writes the index of a repository it reads.
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"--no-optional-locks", "-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// lastCommit returns when HEAD, and the newest of the given refs if any, was
+// committed to, or the zero time if there are no commits.
+func lastCommit(dir string, refs ...string) time.Time {
+	var newest time.Time
+	lines := ""
+	if len(refs) > 0 {
+		lines, _ = gitOutput(dir, append([]string{"for-each-ref", "--format=%(committerdate:unix)"}, refs...)...)
+	}
+	head, _ := gitOutput(dir, "log", "-1", "--format=%ct", "HEAD")
+	for _, field := range strings.Fields(lines + " " + head) {
+		if unix, err := strconv.ParseInt(field, 10, 64); err == nil && time.Unix(unix, 0).After(newest) {
+			newest = time.Unix(unix, 0)
+		}
+	}
+	return newest
+}
+
+// isClean reports whether a working tree has no uncommitted changes and no
+// untracked files.
+func isClean(dir string) bool {
+	status, err := gitOutput(dir, "status", "--porcelain")
+	return err == nil && status == ""
+}
+
+// reportStaleGit finds what in repo has gone untouched since cutoff, with no
+// commits or uncommitted changes: the clone itself, its linked worktrees,
+// or else its branches. Interactive runs offer to archive the clone or
+// remove the worktrees; others only report them.
+func (app *App) reportStaleGit(repo string, cfg GitReposConfig, cutoff time.Time, interactive bool) error {
+	worktrees, _ := gitOutput(repo, "worktree", "list", "--porcelain")
+	var linked []string
+	// checkedOut are the branches of the worktrees, which are judged by them
+	checkedOut := map[string]bool{}
+	for _, line := range strings.Split(worktrees, "\n") {
+		if path, ok := strings.CutPrefix(line, "worktree "); ok && path != repo {
+			linked = append(linked, path)
+		}
+		if branch, ok := strings.CutPrefix(line, "branch refs/heads/"); ok {
+			checkedOut[branch] = true
+		}
+	}
+
+	// Stashes and the linked worktrees' branches are commits too
+	last := lastCommit(repo, "refs/heads", "refs/stash")
+	if !last.IsZero() && last.Before(cutoff) && isClean(repo) && len(linked) == 0 {
+		return app.staleClone(repo, cfg, last, interactive)
+	}
+
+	for _, wt := range linked {
+		if _, err := os.Stat(wt); err != nil {
+			// git gc prunes the worktrees that are gone
+			continue
+		}
+		wtLast := lastCommit(wt)
+		if wtLast.IsZero() || !wtLast.Before(cutoff) || !isClean(wt) {
+			continue
+		}
+		if err := app.staleWorktree(repo, wt, wtLast, interactive); err != nil {
+			return err
+		}
+	}
+
+	branches, _ := gitOutput(repo, "for-each-ref", "--format=%(refname:short)\t%(committerdate:unix)", "refs/heads")
+	var stale []string
+	for _, line := range strings.Split(branches, "\n") {
+		name, date, ok := strings.Cut(line, "\t")
+		unix, err := strconv.ParseInt(date, 10, 64)
+		if ok && err == nil && !checkedOut[name] && time.Unix(unix, 0).Before(cutoff) {
+			stale = append(stale, name)
+		}
+	}
+	if len(stale) > 0 {
+		app.addItem(&app.summary.StaleBranches, T("item.stale_branches", app.displayPath(repo), strings.Join(stale, ", ")))
+	}
+	return nil
+}
+
+// staleClone reports an untouched clone, or archives it if the user agrees.
+func (app *App) staleClone(repo string, cfg GitReposConfig, last time.Time, interactive bool) error {
+	size := dirSize(repo)
+	item := T("item.stale_repo", app.displayPath(repo), last.Format("2006-01-02"), formatSize(size))
+	if !interactive {
+		app.addItem(&app.summary.StaleRepos, item)
+		return nil
+	}
+	archive := filepath.Join(app.archiveDir(cfg), fmt.Sprintf("%s-%s.tar.gz", filepath.Base(repo), time.Now().Format("20060102")))
+	ok, err := app.confirm(T("confirm.archive_repo", app.displayPath(repo), last.Format("2006-01-02"), app.displayPath(archive)))
+	if err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	if !ok {
+		app.addItem(&app.summary.StaleRepos, item)
+		return nil
+	}
+	if app.isProtected(repo) {
+		app.skipItem("Failed to archive git repository", repo, errProtected)
+		return nil
+	}
+	// The archive is compressed, so the repository's size is as much as it
+	// can take
+	if err := os.MkdirAll(filepath.Dir(archive), 0755); err != nil {
+		app.skipItem("Failed to archive git repository", repo, err)
+		return nil
+	}
+	if err := app.guardWrite(filepath.Dir(archive), size); err != nil {
+		app.skipItem("Failed to archive git repository", repo, err)
+		return nil
+	}
+	if err := writeTarGz(archive, repo); err != nil {
+		app.skipItem("Failed to archive git repository", repo, err)
+		return nil
+	}
+	if err := app.removeAll(repo); err != nil {
+		app.skipItem("Failed to remove archived git repository", repo, err)
+		return nil
+	}
+	app.addItem(&app.summary.ArchivedRepos, fmt.Sprintf("%s -> %s", app.displayPath(repo), app.displayPath(archive)))
+	return nil
+}
+
+// staleWorktree reports an untouched linked worktree, or removes it with git
+// if the user agrees; its commits stay in the repository.
+func (app *App) staleWorktree(repo, wt string, last time.Time, interactive bool) error {
+	item := T("item.stale_worktree", app.displayPath(wt), app.displayPath(repo), last.Format("2006-01-02"))
+	if !interactive {
+		app.addItem(&app.summary.StaleRepos, item)
+		return nil
+	}
+	ok, err := app.confirm(T("confirm.remove_worktree", app.displayPath(wt), last.Format("2006-01-02"), app.displayPath(repo)))
+	if err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	if !ok {
+		app.addItem(&app.summary.StaleRepos, item)
+		return nil
+	}
+	if app.isProtected(wt) {
+		app.skipItem("Failed to remove git worktree", wt, errProtected)
+		return nil
+	}
+	size := dirSize(wt)
+	if _, err := gitOutput(repo, "worktree", "remove", wt); err != nil {
+		app.skipItem("Failed to remove git worktree", wt, err)
+		return nil
+	}
+	app.summary.FreedBytes += size
+	app.addItem(&app.summary.ArchivedRepos, app.displayPath(wt))
+	return nil
+}
+
+// archiveDir is where archived clones go, relative to the home directory
+// unless absolute.
+func (app *App) archiveDir(cfg GitReposConfig) string {
+	dir := cfg.ArchiveDir
+	if dir == "" {
+		dir = defaultArchiveDir
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(app.homeDir, dir)
+	}
+	return filepath.Clean(dir)
+}
+
+// writeTarGz archives the tree at dir into path, under dir's own name. The
+// archive is only put in place once it's complete.
+func writeTarGz(path, dir string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".archive-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	err = walkTree(dir, func(rel string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		p := filepath.Join(dir, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		} else if !info.Mode().IsRegular() && !info.IsDir() {
+			// Sockets and the like can't be archived, nor are they needed
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(filepath.Base(dir), rel))
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		in, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		_, err = io.Copy(tw, in)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
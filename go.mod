@@ -1,3 +1,5 @@
 module github.com/prabalesh/saafsafai
 
 go 1.24.3
+
+require golang.org/x/text v0.34.0
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Healthcheck events, appended to the ping URL the way healthchecks.io
+// expects: <url>/start when a run begins, <url> on success, <url>/fail on
+// failure.
+const (
+	healthcheckStart   = "/start"
+	healthcheckSuccess = ""
+	healthcheckFail    = "/fail"
+)
+
+var healthcheckClient = &http.Client{Timeout: 10 * time.Second}
+
+// pingHealthcheck reports a run event to the configured ping URL, with the
+// summary or error as the request body. Ping failures are only logged: a
+// monitoring outage shouldn't break the cleanup, and a missing ping is what
+// the monitor alerts on anyway.
+func (app *App) pingHealthcheck(url, event, body string) {
+	if url == "" || app.dryRun {
+		return
+	}
+
+	target := strings.TrimSuffix(url, "/") + event
+	for attempt := 1; attempt <= 3; attempt++ {
+		resp, err := healthcheckClient.Post(target, "text/plain; charset=utf-8", strings.NewReader(body))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 300 {
+				return
+			}
+			log.Printf("Healthcheck ping to %s returned %s", target, resp.Status)
+			return
+		}
+		if attempt == 3 {
+			log.Printf("Failed to ping healthcheck %s: %v", target, err)
+			return
+		}
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+}
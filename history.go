@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const historyFileName = "history.jsonl"
+
+// runRecord is one run in the history database, an append-only JSON lines
+// file in the state directory.
+type runRecord struct {
+	RunID string `json:"run_id"`
+	// Time and LastRuns are stored in UTC and read back in local time;
+	// Zone is the time zone the run was in, e.g. Europe/Berlin
+	Time       time.Time              `json:"time"`
+	Zone       string                 `json:"zone,omitempty"`
+	Items      int                    `json:"items"`
+	Errors     int                    `json:"errors"`
+	FreedBytes int64                  `json:"freed_bytes"`
+	Cleaners   map[string]int         `json:"cleaners"`
+	Categories map[string]int         `json:"categories"`
+	CacheSizes map[string]int64       `json:"cache_sizes"`
+	Extensions map[string]clutterStat `json:"extensions,omitempty"`
+	Domains    map[string]clutterStat `json:"domains,omitempty"`
+	// CleanerTimes and ActionTimes are in milliseconds, as in Summary
+	CleanerTimes map[string]int64 `json:"cleaner_ms"`
+	ActionTimes  map[string]int64 `json:"action_ms"`
+	// LastRuns is when each cleaner last ran without failing, in this run
+	// or an earlier one, so the latest record has them all.
+	LastRuns map[string]time.Time `json:"last_runs"`
+	// Snapshots were taken before the run, with snapshot.enabled
+	Snapshots []runSnapshot `json:"snapshots,omitempty"`
+	// DirSizes are the analytics' tracked directories, on the runs that
+	// measured them
+	DirSizes map[string]int64 `json:"dir_sizes,omitempty"`
+}
+
+func (r runRecord) MarshalJSON() ([]byte, error) {
+	type plain runRecord
+	r.Time = r.Time.UTC()
+	if r.LastRuns != nil {
+		lastRuns := make(map[string]time.Time, len(r.LastRuns))
+		for name, at := range r.LastRuns {
+			lastRuns[name] = at.UTC()
+		}
+		r.LastRuns = lastRuns
+	}
+	return json.Marshal(plain(r))
+}
+
+// UnmarshalJSON reads the times back in local time, so dates and ages in
+// days are counted on the local calendar whatever zone the run was in.
+func (r *runRecord) UnmarshalJSON(data []byte) error {
+	type plain runRecord
+	if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+		return err
+	}
+	r.Time = r.Time.Local()
+	for name, at := range r.LastRuns {
+		r.LastRuns[name] = at.Local()
+	}
+	return nil
+}
+
+// zoneName returns the name of the local time zone, e.g. Europe/Berlin, or
+// its abbreviation if the name can't be told.
+func zoneName() string {
+	if tz := strings.TrimPrefix(os.Getenv("TZ"), ":"); tz != "" && !filepath.IsAbs(tz) {
+		return tz
+	}
+	if target, err := filepath.EvalSymlinks("/etc/localtime"); err == nil {
+		if _, name, ok := strings.Cut(target, "zoneinfo/"); ok {
+			return name
+		}
+	}
+	if data, err := os.ReadFile("/etc/timezone"); err == nil {
+		if name := strings.TrimSpace(string(data)); name != "" {
+			return name
+		}
+	}
+	name, _ := time.Now().Zone()
+	return name
+}
+
+// cacheDirs are the caches whose size is recorded with every run, so their
+// growth shows up in the history.
+func (app *App) cacheDirs() []string {
+	if app.system {
+		return []string{"/var/cache/apt/archives", "/var/cache/dnf", "/var/cache/pacman/pkg"}
+	}
+	return []string{
+		filepath.Join(app.homeDir, ".cache"),
+		filepath.Join(app.homeDir, ".npm"),
+		filepath.Join(app.homeDir, ".cargo", "registry"),
+		filepath.Join(app.homeDir, "go", "pkg", "mod"),
+	}
+}
+
+func (app *App) recordHistory() error {
+	record := runRecord{
+		RunID:      app.summary.RunID,
+		Time:       time.Now(),
+		Zone:       zoneName(),
+		Items:      app.itemCount(),
+		Errors:     app.failures(),
+		FreedBytes: app.summary.FreedBytes,
+		Cleaners:   app.summary.Cleaners,
+		Categories: app.summary.Categories,
+		CacheSizes: make(map[string]int64),
+		Extensions: app.summary.Extensions,
+		Domains:    app.summary.Domains,
+
+		CleanerTimes: app.summary.CleanerTimes,
+		ActionTimes:  app.summary.ActionTimes,
+		Snapshots:    app.snapshots,
+		DirSizes:     app.dirSizes,
+	}
+	lastRuns, err := app.cleanerLastRuns()
+	if err != nil {
+		return err
+	}
+	for name, at := range app.cleanerRuns {
+		lastRuns[name] = at
+	}
+	record.LastRuns = lastRuns
+	if app.scan.done && app.scan.err == nil {
+		// Measured during the run's home directory scan
+		for dir, size := range app.scan.cacheSizes {
+			record.CacheSizes[app.displayPath(dir)] = size
+		}
+	} else {
+		for _, dir := range app.cacheDirs() {
+			if _, err := os.Stat(dir); err == nil {
+				record.CacheSizes[app.displayPath(dir)] = dirSize(dir)
+			}
+		}
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(app.stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(app.stateDir, historyFileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// cleanerLastRuns returns when each cleaner last ran without failing, from
+// the history.
+func (app *App) cleanerLastRuns() (map[string]time.Time, error) {
+	records, err := app.loadHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	last := make(map[string]time.Time)
+	for _, record := range records {
+		if record.LastRuns == nil {
+			// Recorded before LastRuns, when a run always ran every cleaner
+			for name := range record.Cleaners {
+				last[name] = record.Time
+			}
+			continue
+		}
+		for name, at := range record.LastRuns {
+			last[name] = at
+		}
+	}
+	return last, nil
+}
+
+// loadHistory returns the recorded runs, oldest first. Lines that fail to
+// parse (e.g. a write cut short by a crash) are skipped.
+func (app *App) loadHistory() ([]runRecord, error) {
+	f, err := os.Open(filepath.Join(app.stateDir, historyFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []runRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record runRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err == nil {
+			records = append(records, record)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// displayPath shortens paths under the home directory to ~/...
+func (app *App) displayPath(path string) string {
+	if app.homeDir != "" && strings.HasPrefix(path, app.homeDir+"/") {
+		return "~" + strings.TrimPrefix(path, app.homeDir)
+	}
+	return path
+}
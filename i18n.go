@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// User-facing output (reports, setup, help, notifications) goes through T so
+// it can be translated. Diagnostics written with log stay in English, since
+// they're meant for bug reports and searching.
+
+var catalogs = map[string]map[string]string{
+	"en": messagesEN,
+	"hi": messagesHI,
+}
+
+var messages = catalogs[detectLanguage()]
+
+// detectLanguage picks the catalog from the usual locale variables, in the
+// order the C library consults them, falling back to English.
+func detectLanguage() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		// e.g. "hi_IN.UTF-8" or "hi"
+		lang, _, _ := strings.Cut(value, "_")
+		lang, _, _ = strings.Cut(lang, ".")
+		if _, ok := catalogs[lang]; ok {
+			return lang
+		}
+		return "en"
+	}
+	return "en"
+}
+
+// T returns the message for key in the current language, formatted with args.
+// Keys missing from a translation fall back to English.
+func T(key string, args ...any) string {
+	format, ok := messages[key]
+	if !ok {
+		if format, ok = messagesEN[key]; !ok {
+			format = key
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// isYes reports whether an answer to a yes/no question means yes in the
+// current language. English answers are always understood.
+func isYes(answer string) bool {
+	for _, yes := range strings.Fields(T("answer.yes") + " " + messagesEN["answer.yes"]) {
+		if answer == yes {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const idlePollInterval = time.Minute
+
+// waitForIdle defers an unattended run until the system has been idle for
+// the given number of minutes, so the scan doesn't compete with the user
+// right after login. Runs from a terminal and dry runs never wait.
+func (app *App) waitForIdle(minutes int) {
+	if minutes <= 0 || app.dryRun || app.isInteractive() {
+		return
+	}
+
+	want := time.Duration(minutes) * time.Minute
+	waiting := false
+	for {
+		idle, ok := app.idleTime()
+		if !ok {
+			log.Printf("Can't tell whether the system is idle, running now")
+			return
+		}
+		if idle >= want {
+			return
+		}
+		if !waiting {
+			log.Printf("Waiting until the system has been idle for %d minutes", minutes)
+			waiting = true
+		}
+		time.Sleep(min(want-idle, idlePollInterval))
+	}
+}
+
+// idleTime returns how long the user (or, for the system service, every
+// session) has been idle: X's input idle time when available, otherwise
+// logind's IdleHint.
+func (app *App) idleTime() (time.Duration, bool) {
+	if !app.system && os.Getenv("DISPLAY") != "" {
+		if output, err := exec.Command("xprintidle").Output(); err == nil {
+			if ms, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64); err == nil {
+				return time.Duration(ms) * time.Millisecond, true
+			}
+		}
+	}
+
+	args := []string{"show", "--property=IdleHint", "--property=IdleSinceHint"}
+	if !app.system {
+		args = []string{"show-user", strconv.Itoa(os.Getuid()), "--property=IdleHint", "--property=IdleSinceHint"}
+	}
+	output, err := exec.Command("loginctl", args...).Output()
+	if err != nil {
+		return 0, false
+	}
+
+	var hint string
+	var since int64
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, _ := strings.Cut(line, "=")
+		switch key {
+		case "IdleHint":
+			hint = value
+		case "IdleSinceHint":
+			since, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+	switch {
+	case hint == "":
+		return 0, false
+	case hint != "yes":
+		return 0, true
+	}
+	// IdleSinceHint is in microseconds since the epoch
+	return time.Since(time.UnixMicro(since)), true
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	categoryIndexCSV  = "csv"
+	categoryIndexJSON = "json"
+
+	categoryIndexName = "saafsafai-index"
+)
+
+var categoryIndexFormats = []string{categoryIndexCSV, categoryIndexJSON}
+
+// categoryIndexEntry is a file moved into a category folder, as recorded in
+// the folder's index.
+type categoryIndexEntry struct {
+	Name         string    `json:"name"`
+	OriginalName string    `json:"original_name"`
+	Downloaded   time.Time `json:"downloaded"`
+	Origin       string    `json:"origin,omitempty"`
+	RunID        string    `json:"run_id"`
+	Moved        time.Time `json:"moved"`
+}
+
+// recordMove remembers a file a real run filed as dest, for its category
+// folder's index. Its download time is the browser's record of it, if
+// browser_history found one, or when the file was last modified.
+func (app *App) recordMove(src, dest string, info os.FileInfo) {
+	entry := categoryIndexEntry{
+		Name:         filepath.Base(dest),
+		OriginalName: filepath.Base(src),
+		Downloaded:   info.ModTime(),
+		RunID:        app.summary.RunID,
+		Moved:        time.Now(),
+	}
+	if origin, ok := app.origins[src]; ok {
+		entry.Origin = origin.URL
+		if !origin.Time.IsZero() {
+			entry.Downloaded = origin.Time
+		}
+	}
+	if app.filed == nil {
+		app.filed = make(map[string][]categoryIndexEntry)
+	}
+	dir := filepath.Dir(dest)
+	app.filed[dir] = append(app.filed[dir], entry)
+}
+
+// writeCategoryIndexes appends the files this run filed to the index in each
+// category folder, saafsafai-index.csv or saafsafai-index.jsonl, so where a
+// file went can be looked up there.
+func (app *App) writeCategoryIndexes(format string) {
+	dirs := make([]string, 0, len(app.filed))
+	for dir := range app.filed {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		if err := appendCategoryIndex(dir, format, app.filed[dir]); err != nil {
+			log.Printf("Warning: failed to update the index of %s: %v", app.displayPath(dir), err)
+		}
+	}
+	app.filed = nil
+}
+
+func appendCategoryIndex(dir, format string, entries []categoryIndexEntry) error {
+	name := categoryIndexName + ".csv"
+	if format == categoryIndexJSON {
+		name = categoryIndexName + ".jsonl"
+	}
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if format == categoryIndexJSON {
+		enc := json.NewEncoder(f)
+		for _, entry := range entries {
+			if err := enc.Encode(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	w := csv.NewWriter(f)
+	if info, err := f.Stat(); err == nil && info.Size() == 0 {
+		w.Write([]string{"name", "original_name", "downloaded", "origin", "run_id", "moved"})
+	}
+	for _, entry := range entries {
+		w.Write([]string{
+			entry.Name,
+			entry.OriginalName,
+			entry.Downloaded.Format(time.RFC3339),
+			entry.Origin,
+			entry.RunID,
+			entry.Moved.Format(time.RFC3339),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return nil
+}
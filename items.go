@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	// summarySampleSize is how many items of each summary list are kept in
+	// memory.
+	summarySampleSize  = 1000
+	itemStreamFileName = "run-items.jsonl"
+	// summaryMaxItems is how many items of each list, or of each group of
+	// one, the summary shows by default.
+	summaryMaxItems = 10
+)
+
+// SummaryConfig shapes the summary printed, logged and sent in
+// notifications; the run's report always lists every item.
+type SummaryConfig struct {
+	// MaxItems is how many items of each list the summary names, or of
+	// each group in the lists grouped by Downloads category or kind of
+	// file (default 10).
+	MaxItems int `json:"max_items"`
+}
+
+// itemList is one of the lists in a run's summary. Big cleanups can find
+// hundreds of thousands of items, so only the first summarySampleSize are
+// kept; Count counts them all, and a real run's report lists every one. It's
+// marshaled as the sample.
+type itemList struct {
+	Sample []string
+	Count  int
+	// groups are the items added with addGroupedItem, by group, each with
+	// the first summary.max_items of its items
+	groups map[string]*itemList
+}
+
+func (l itemList) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.Sample)
+}
+
+func (l itemList) truncated() bool {
+	return l.Count > len(l.Sample)
+}
+
+// addItem adds items to list. A real run also streams them to a file in
+// the state directory, from which the report gets the lists cut short.
+func (app *App) addItem(list *itemList, items ...string) {
+	for _, item := range items {
+		list.Count++
+		if len(list.Sample) < summarySampleSize {
+			list.Sample = append(list.Sample, item)
+		}
+		app.streamItem(list, item)
+	}
+}
+
+// addGroupedItem adds item to list, under group in the summary, e.g. the
+// category a Downloads file was filed into.
+func (app *App) addGroupedItem(list *itemList, group, item string) {
+	if list.groups == nil {
+		list.groups = make(map[string]*itemList)
+	}
+	g := list.groups[group]
+	if g == nil {
+		g = &itemList{}
+		list.groups[group] = g
+	}
+	g.Count++
+	if len(g.Sample) < app.summaryMax() {
+		g.Sample = append(g.Sample, item)
+	}
+	app.addItem(list, item)
+}
+
+// summaryMax returns how many items of a list or group the summary shows.
+func (app *App) summaryMax() int {
+	if app.summaryItems > 0 {
+		return app.summaryItems
+	}
+	return summaryMaxItems
+}
+
+// listLines returns the summary lines of list: its first items, or for a
+// grouped one each group with its count and first items, biggest first.
+// Those left out are counted, and listed in the run's report. The headings
+// are styled by p.
+func (app *App) listLines(p painter, list *itemList) []string {
+	if list.groups == nil {
+		var lines []string
+		shown := min(app.summaryMax(), len(list.Sample))
+		for _, item := range list.Sample[:shown] {
+			lines = append(lines, "   - "+displayName(item))
+		}
+		for _, line := range app.moreItems(list.Count-shown, "   ") {
+			lines = append(lines, p.paint(styleDim, line))
+		}
+		return lines
+	}
+
+	var lines []string
+	for _, name := range groupNames(list) {
+		g := list.groups[name]
+		lines = append(lines, "   "+p.paint(styleCyan, T("summary.group", name, g.Count)))
+		for _, item := range g.Sample {
+			lines = append(lines, "      - "+displayName(item))
+		}
+		if more := g.Count - len(g.Sample); more > 0 {
+			lines = append(lines, "      "+p.paint(styleDim, T("summary.more_items", more)))
+		}
+	}
+	if app.shownItems(list) < list.Count && app.summary.Report != "" && !app.itemStreamFailed {
+		lines = append(lines, "   "+p.paint(styleDim, T("summary.all_listed", list.Count, app.displayPath(app.summary.Report))))
+	}
+	return lines
+}
+
+// groupNames returns the names of list's groups, biggest first.
+func groupNames(list *itemList) []string {
+	names := sortedKeys(list.groups)
+	sort.SliceStable(names, func(i, j int) bool {
+		return list.groups[names[i]].Count > list.groups[names[j]].Count
+	})
+	return names
+}
+
+// shownItems returns how many of list's items the summary names.
+func (app *App) shownItems(list *itemList) int {
+	if list.groups == nil {
+		return min(app.summaryMax(), len(list.Sample))
+	}
+	shown := 0
+	for _, g := range list.groups {
+		shown += len(g.Sample)
+	}
+	return shown
+}
+
+// moreItems returns the summary line for the more items left out, if any,
+// indented by indent.
+func (app *App) moreItems(more int, indent string) []string {
+	if more <= 0 {
+		return nil
+	}
+	if app.summary.Report != "" && !app.itemStreamFailed {
+		return []string{indent + T("summary.more_items_report", more, app.displayPath(app.summary.Report))}
+	}
+	return []string{indent + T("summary.more_items", more)}
+}
+
+func (app *App) streamItem(list *itemList, item string) {
+	if app.dryRun || app.summary.Report == "" || app.itemStreamFailed {
+		return
+	}
+	if app.itemStream == nil {
+		f, err := app.openItemStream()
+		if err != nil {
+			log.Printf("Warning: failed to open the item stream, the report will only list the first %d items of each list: %v", summarySampleSize, err)
+			app.itemStreamFailed = true
+			return
+		}
+		app.itemStream = f
+	}
+	data, err := json.Marshal([2]string{app.summary.listKey(list), item})
+	if err == nil {
+		_, err = app.itemStream.Write(append(data, '\n'))
+	}
+	if err != nil {
+		log.Printf("Warning: failed to stream an item, the report will only list the first %d items of each list: %v", summarySampleSize, err)
+		app.itemStreamFailed = true
+	}
+}
+
+func (app *App) openItemStream() (*os.File, error) {
+	if err := os.MkdirAll(app.stateDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	// Left over only if a run crashed, when it's of no use
+	return os.OpenFile(filepath.Join(app.stateDir, itemStreamFileName), os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0600)
+}
+
+func (app *App) closeItemStream() {
+	if app.itemStream == nil {
+		return
+	}
+	app.itemStream.Close()
+	app.itemStream = nil
+	if err := os.Remove(filepath.Join(app.stateDir, itemStreamFileName)); err != nil {
+		log.Printf("Failed to remove item stream: %v", err)
+	}
+}
+
+// writeFullLists appends every item of the lists cut short to w, from the
+// item stream, reading it once per list so they stay out of memory.
+func (app *App) writeFullLists(w io.Writer) error {
+	if app.itemStream == nil || app.itemStreamFailed {
+		return nil
+	}
+	sections := append(app.summarySections(), app.reportSections()...)
+	sections = append(sections,
+		summarySection{T("summary.skipped", app.summary.Skipped.Count, app.skipReasons()), "", &app.summary.Skipped},
+		summarySection{T("summary.errors", app.summary.Errors.Count), "", &app.summary.Errors})
+
+	header := false
+	for _, section := range sections {
+		if app.shownItems(section.list) >= section.list.Count {
+			continue
+		}
+		if !header {
+			fmt.Fprintf(w, "\n%s\n", T("summary.full_lists"))
+			header = true
+		}
+		fmt.Fprintf(w, "\n%s\n", section.title)
+
+		key := app.summary.listKey(section.list)
+		if _, err := app.itemStream.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		scanner := bufio.NewScanner(app.itemStream)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var entry [2]string
+			if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry[0] == key {
+				fmt.Fprintf(w, "   - %s\n", displayName(entry[1]))
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+	if _, err := app.itemStream.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	return nil
+}
+
+// listKey returns the JSON key of the summary's list.
+func (s *Summary) listKey(list *itemList) string {
+	v := reflect.ValueOf(s).Elem()
+	for i := range v.NumField() {
+		if v.Field(i).Addr().Interface() == any(list) {
+			key, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+			return key
+		}
+	}
+	return ""
+}
+
+// markTruncated records in Truncated how many items the lists cut short
+// have, for the JSON summary.
+func (s *Summary) markTruncated() {
+	v := reflect.ValueOf(s).Elem()
+	for i := range v.NumField() {
+		list, ok := v.Field(i).Addr().Interface().(*itemList)
+		if !ok || !list.truncated() {
+			continue
+		}
+		if s.Truncated == nil {
+			s.Truncated = make(map[string]int)
+		}
+		key, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		s.Truncated[key] = list.Count
+	}
+}
+
+// markGroups records in Groups how many items each group of the grouped
+// lists has, for the JSON summary.
+func (s *Summary) markGroups() {
+	v := reflect.ValueOf(s).Elem()
+	for i := range v.NumField() {
+		list, ok := v.Field(i).Addr().Interface().(*itemList)
+		if !ok || list.groups == nil {
+			continue
+		}
+		if s.Groups == nil {
+			s.Groups = make(map[string]map[string]int)
+		}
+		counts := make(map[string]int)
+		for name, g := range list.groups {
+			counts[name] = g.Count
+		}
+		key, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		s.Groups[key] = counts
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+const journalFileName = "journal.jsonl"
+
+const (
+	opMove   = "move"
+	opRemove = "remove"
+)
+
+// journalEntry is one line of the write-ahead journal. Each action is
+// written (and synced) before it's carried out, then followed by a second
+// line with the same ID and its outcome, so a run that dies halfway can be
+// finished by the next one.
+type journalEntry struct {
+	ID     int    `json:"id"`
+	Op     string `json:"op,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Dest   string `json:"dest,omitempty"`
+	Status string `json:"status,omitempty"` // "done" or "failed"
+	// Dev, Ino and MTime identify what a removal removes, so recovery
+	// leaves alone whatever has taken its place since
+	Dev   uint64 `json:"dev,omitempty"`
+	Ino   uint64 `json:"ino,omitempty"`
+	MTime int64  `json:"mtime,omitempty"`
+}
+
+// identify records the identity of the file at the entry's path.
+func (entry *journalEntry) identify() {
+	info, err := os.Lstat(entry.Path)
+	if err != nil {
+		return
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		entry.Dev, entry.Ino = uint64(st.Dev), uint64(st.Ino)
+	}
+	entry.MTime = info.ModTime().UnixNano()
+}
+
+// sameFile reports whether info is of the file the entry identified. A
+// directory's own modification time changes as what's in it is removed,
+// so only its inode has to match.
+func (entry journalEntry) sameFile(info os.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || entry.Ino == 0 || uint64(st.Dev) != entry.Dev || uint64(st.Ino) != entry.Ino {
+		return false
+	}
+	return info.IsDir() || info.ModTime().UnixNano() == entry.MTime
+}
+
+type journal struct {
+	file   *os.File
+	nextID int
+}
+
+func (app *App) journalPath() string {
+	return filepath.Join(app.stateDir, journalFileName)
+}
+
+// openJournal starts the journal for this run.
+func (app *App) openJournal() error {
+	if err := os.MkdirAll(app.stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	f, err := os.OpenFile(app.journalPath(), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	app.journal = &journal{file: f}
+	return nil
+}
+
+// closeJournal ends the journal once every action has been accounted for in
+// the summary. It's done before the summary is written: a crash in between
+// loses the report rather than counting its items twice.
+func (app *App) closeJournal() {
+	if app.journal == nil {
+		return
+	}
+	app.journal.file.Close()
+	app.journal = nil
+	if err := os.Remove(app.journalPath()); err != nil {
+		log.Printf("Failed to remove journal: %v", err)
+	}
+}
+
+// journaled carries out an action, recording it in the journal first.
+func (app *App) journaled(entry journalEntry, action func() error) error {
+	if app.journal == nil {
+		return app.audited(entry, action)
+	}
+
+	app.journal.nextID++
+	entry.ID = app.journal.nextID
+	if entry.Op == opRemove {
+		entry.identify()
+	}
+	if err := app.journal.write(entry, true); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+
+	err := app.audited(entry, action)
+	outcome := journalEntry{ID: entry.ID, Status: "done"}
+	if err != nil {
+		outcome.Status = "failed"
+	}
+	// Not synced: if this line is lost, recovery checks the file system
+	if werr := app.journal.write(outcome, false); werr != nil {
+		log.Printf("Failed to write journal: %v", werr)
+	}
+	return err
+}
+
+func (j *journal) write(entry journalEntry, sync bool) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if sync {
+		return j.file.Sync()
+	}
+	return nil
+}
+
+// recoverJournal finishes what an interrupted run left behind: removals
+// that were under way are completed, if what's there is still what was
+// being removed, and moves (renames, so they either
+// happened or didn't) that didn't happen are rolled back. Everything the
+// interrupted run did is reported once, in this run's summary; actions that
+// didn't happen aren't, since this run will do and count them itself.
+func (app *App) recoverJournal() error {
+	f, err := os.Open(app.journalPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	var entries []journalEntry
+	status := make(map[int]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry journalEntry
+		// A line cut short by the crash is skipped
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if entry.Status != "" {
+			status[entry.ID] = entry.Status
+		} else {
+			entries = append(entries, entry)
+		}
+	}
+	f.Close()
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	log.Printf("Recovering %d actions from an interrupted run", len(entries))
+	app.cleaner = "recovery"
+	defer func() { app.cleaner = "" }()
+	for _, entry := range entries {
+		if status[entry.ID] == "failed" {
+			continue
+		}
+		switch entry.Op {
+		case opRemove:
+			if info, err := os.Lstat(entry.Path); err == nil {
+				// Recreated since, e.g. by a new download of the same name,
+				// which no check allowed removing
+				if !entry.sameFile(info) {
+					log.Printf("Not finishing the removal of %s, which has been replaced since", app.displayPath(entry.Path))
+					continue
+				}
+				if err := app.audited(entry, func() error { return app.discard(entry.Path, app.removeTree) }); err != nil {
+					app.skipItem("Failed to finish removing", entry.Path, err)
+					continue
+				}
+			}
+			app.addItem(&app.summary.Recovered, T("item.recovered_removal", app.displayPath(entry.Path)))
+		case opMove:
+			if _, err := os.Lstat(entry.Path); err == nil {
+				// Never happened; drop the category folder if it was
+				// created just for this file
+				os.Remove(filepath.Dir(entry.Dest))
+				continue
+			}
+			if info, err := os.Lstat(entry.Dest); err == nil {
+				// The interrupted run didn't get to audit it
+				record := auditRecord{Time: time.Now(), RunID: app.summary.RunID, Cleaner: app.cleaner, Action: opMove, Src: entry.Path, Dst: entry.Dest, Size: info.Size()}
+				if err := app.writeAudit(record); err != nil {
+					log.Printf("Failed to write audit log: %v", err)
+				}
+				app.addItem(&app.summary.Recovered, T("item.recovered_move", app.displayPath(entry.Path), app.displayPath(entry.Dest)))
+			}
+		}
+	}
+
+	return os.Remove(app.journalPath())
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// kernelsToKeep is how many of the newest installed kernels are always kept,
+// in addition to the running one.
+const kernelsToKeep = 2
+
+// debKernelPackages are the per-release Debian/Ubuntu packages removed
+// together with a kernel image, e.g. linux-modules-6.5.0-14-generic.
+var debKernelPackages = []string{"linux-image-", "linux-modules-", "linux-modules-extra-", "linux-headers-"}
+
+type installedKernel struct {
+	release  string   // as reported by uname -r
+	packages []string // packages to remove for this release
+}
+
+func (app *App) removeOldKernels(pm packageManager) error {
+	defer app.timeAction("old kernels", time.Now())
+
+	running, err := runningKernel()
+	if err != nil {
+		return fmt.Errorf("failed to determine the running kernel: %w", err)
+	}
+
+	var kernels []installedKernel
+	switch pm.name {
+	case "apt-get":
+		kernels, err = installedDebKernels()
+	case "dnf":
+		kernels, err = installedRPMKernels()
+	default:
+		log.Printf("Old kernel removal is not supported with %s, skipping", pm.name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list installed kernels: %w", err)
+	}
+
+	candidates, err := oldKernels(kernels, running)
+	if err != nil {
+		return err
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	var releases, packages []string
+	for _, k := range candidates {
+		releases = append(releases, k.release)
+		packages = append(packages, k.packages...)
+	}
+
+	if !app.dryRun {
+		if app.isInteractive() {
+			ok, err := app.confirm(T("confirm.remove_kernels", strings.Join(releases, ", ")))
+			if err != nil || !ok {
+				return err
+			}
+		}
+		if err := app.runPrivileged(append(pm.removeOrphans, packages...)...); err != nil {
+			return err
+		}
+	}
+
+	app.addItem(&app.summary.RemovedKernels, releases...)
+	return nil
+}
+
+// oldKernels returns the kernels that can be removed: everything but the
+// newest kernelsToKeep releases and the running kernel. As a safety check it
+// refuses to pick anything if the running kernel isn't one of the installed
+// packages, since that means we can't tell what the system boots from.
+func oldKernels(kernels []installedKernel, running string) ([]installedKernel, error) {
+	found := false
+	for _, k := range kernels {
+		if k.release == running {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("running kernel %s is not an installed package, refusing to remove kernels", running)
+	}
+
+	sort.Slice(kernels, func(i, j int) bool {
+		return compareVersions(kernels[i].release, kernels[j].release) > 0
+	})
+
+	var old []installedKernel
+	for i, k := range kernels {
+		if i < kernelsToKeep || k.release == running {
+			continue
+		}
+		old = append(old, k)
+	}
+	return old, nil
+}
+
+func runningKernel() (string, error) {
+	data, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return "", err
+	}
+	release := strings.TrimSpace(string(data))
+	if release == "" {
+		return "", fmt.Errorf("empty kernel release")
+	}
+	return release, nil
+}
+
+func installedDebKernels() ([]installedKernel, error) {
+	args := []string{"-W", "-f=${Package}\t${Status}\n"}
+	for _, prefix := range debKernelPackages {
+		args = append(args, prefix+"[0-9]*")
+	}
+
+	// dpkg-query exits non-zero when one of the patterns matches nothing
+	output, _ := exec.Command("dpkg-query", args...).Output()
+
+	byRelease := make(map[string]*installedKernel)
+	var images []string
+	for _, line := range strings.Split(string(output), "\n") {
+		name, status, ok := strings.Cut(line, "\t")
+		if !ok || !strings.HasSuffix(status, " installed") {
+			continue
+		}
+		for _, prefix := range debKernelPackages {
+			release, ok := strings.CutPrefix(name, prefix)
+			if !ok || release == "" || !unicode.IsDigit(rune(release[0])) {
+				continue
+			}
+			k, exists := byRelease[release]
+			if !exists {
+				k = &installedKernel{release: release}
+				byRelease[release] = k
+			}
+			k.packages = append(k.packages, name)
+			if prefix == "linux-image-" {
+				images = append(images, release)
+			}
+			break
+		}
+	}
+
+	// Only releases with an installed image count as kernels
+	var kernels []installedKernel
+	for _, release := range images {
+		kernels = append(kernels, *byRelease[release])
+	}
+	return kernels, nil
+}
+
+func installedRPMKernels() ([]installedKernel, error) {
+	output, err := exec.Command("rpm", "-q", "kernel-core", "--queryformat", "%{VERSION}-%{RELEASE}.%{ARCH}\n").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var kernels []installedKernel
+	for _, release := range strings.Fields(string(output)) {
+		// Removing kernel-core also removes the kernel and kernel-modules
+		// packages that depend on it
+		kernels = append(kernels, installedKernel{release: release, packages: []string{"kernel-core-" + release}})
+	}
+	return kernels, nil
+}
+
+// compareVersions compares two version strings by their numeric and
+// non-numeric runs, so that "6.10.0" sorts after "6.9.12".
+func compareVersions(a, b string) int {
+	for a != "" && b != "" {
+		var pa, pb string
+		pa, a = versionPart(a)
+		pb, b = versionPart(b)
+
+		if pa == pb {
+			continue
+		}
+
+		aNum, bNum := unicode.IsDigit(rune(pa[0])), unicode.IsDigit(rune(pb[0]))
+		switch {
+		case aNum && bNum:
+			pa, pb = strings.TrimLeft(pa, "0"), strings.TrimLeft(pb, "0")
+			if len(pa) != len(pb) {
+				return len(pa) - len(pb)
+			}
+			return strings.Compare(pa, pb)
+		case aNum:
+			return 1
+		case bNum:
+			return -1
+		default:
+			return strings.Compare(pa, pb)
+		}
+	}
+	return len(a) - len(b)
+}
+
+// versionPart splits off the leading run of digits or non-digits.
+func versionPart(s string) (string, string) {
+	digit := unicode.IsDigit(rune(s[0]))
+	i := 1
+	for i < len(s) && unicode.IsDigit(rune(s[i])) == digit {
+		i++
+	}
+	return s[:i], s[i:]
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const kubeClusterMaxAge = 30 // days
+
+type KubeConfig struct {
+	// ClusterMaxAgeDays removes clusters that have been stopped or untouched
+	// for this many days (default 30).
+	ClusterMaxAgeDays int `json:"cluster_max_age_days"`
+}
+
+// kubeNodeImages are the node images kind and k3d pull for their clusters;
+// once the clusters using them are gone they're usually the biggest leftover.
+var kubeNodeImages = map[string]string{
+	"kind": "kindest/node",
+	"k3d":  "rancher/k3s",
+}
+
+func (app *App) cleanKubeClusters(cfg KubeConfig) error {
+	cutoff := app.ageCutoff(cfg.ClusterMaxAgeDays, kubeClusterMaxAge)
+
+	if err := app.cleanKindClusters(cutoff); err != nil {
+		app.logError("Error cleaning kind clusters: %v", err)
+	}
+	if err := app.cleanK3dClusters(cutoff); err != nil {
+		app.logError("Error cleaning k3d clusters: %v", err)
+	}
+	if err := app.cleanMinikubeProfiles(cutoff); err != nil {
+		app.logError("Error cleaning minikube profiles: %v", err)
+	}
+
+	return nil
+}
+
+func (app *App) cleanKindClusters(cutoff time.Time) error {
+	defer app.timeAction("kind clusters", time.Now())
+
+	if _, err := exec.LookPath("kind"); err != nil {
+		return nil
+	}
+
+	output, err := exec.Command("kind", "get", "clusters").Output()
+	if err != nil {
+		return fmt.Errorf("failed to list kind clusters: %w", err)
+	}
+
+	removed := false
+	for _, name := range strings.Fields(string(output)) {
+		lastUsed, running, err := containerLastUsed(name + "-control-plane")
+		if err != nil || running || !lastUsed.Before(cutoff) {
+			continue
+		}
+		if app.removeKubeCluster("kind", name, lastUsed, "kind", "delete", "cluster", "--name", name) {
+			removed = true
+		}
+	}
+
+	if removed {
+		app.removeKubeNodeImages("kind")
+	}
+	return nil
+}
+
+func (app *App) cleanK3dClusters(cutoff time.Time) error {
+	defer app.timeAction("k3d clusters", time.Now())
+
+	if _, err := exec.LookPath("k3d"); err != nil {
+		return nil
+	}
+
+	output, err := exec.Command("k3d", "cluster", "list", "--output", "json").Output()
+	if err != nil {
+		return fmt.Errorf("failed to list k3d clusters: %w", err)
+	}
+
+	var clusters []struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(output, &clusters); err != nil {
+		return fmt.Errorf("failed to parse k3d cluster list: %w", err)
+	}
+
+	removed := false
+	for _, cluster := range clusters {
+		lastUsed, running, err := containerLastUsed("k3d-" + cluster.Name + "-server-0")
+		if err != nil || running || !lastUsed.Before(cutoff) {
+			continue
+		}
+		if app.removeKubeCluster("k3d", cluster.Name, lastUsed, "k3d", "cluster", "delete", cluster.Name) {
+			removed = true
+		}
+	}
+
+	if removed {
+		app.removeKubeNodeImages("k3d")
+	}
+	return nil
+}
+
+// cleanMinikubeProfiles removes minikube profiles whose files haven't changed
+// since the cutoff (minikube rewrites them on every start), then the cached
+// images and preload tarballs that no recent start has touched.
+func (app *App) cleanMinikubeProfiles(cutoff time.Time) error {
+	defer app.timeAction("minikube profiles", time.Now())
+
+	minikubeDir := filepath.Join(app.homeDir, ".minikube")
+	if _, err := exec.LookPath("minikube"); err != nil {
+		return nil
+	}
+
+	profiles, _ := filepath.Glob(filepath.Join(minikubeDir, "profiles", "*"))
+	for _, profile := range profiles {
+		lastUsed := newestModTime(profile)
+		if lastUsed.IsZero() || !lastUsed.Before(cutoff) {
+			continue
+		}
+		name := filepath.Base(profile)
+		// A cluster running since before the cutoff leaves its profile
+		// untouched, but is still in use
+		if running, err := minikubeRunning(name); err != nil || running {
+			continue
+		}
+		app.removeKubeCluster("minikube", name, lastUsed, "minikube", "delete", "--profile", name)
+	}
+
+	for _, cacheDir := range []string{"images", "preloaded-tarball"} {
+		root := filepath.Join(minikubeDir, "cache", cacheDir)
+		app.scanTree(root, nil, func(rel string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return nil
+			}
+			path := filepath.Join(root, rel)
+			info, err := d.Info()
+			if err != nil || !info.ModTime().Before(cutoff) {
+				return nil
+			}
+			if err := app.remove(path); err != nil {
+				app.skipItem("Failed to remove minikube cache file", path, err)
+				return nil
+			}
+			app.addItem(&app.summary.KubeItems, fmt.Sprintf("minikube cache %s (%s)", rel, formatSize(info.Size())))
+			return nil
+		})
+	}
+
+	return nil
+}
+
+func (app *App) removeKubeCluster(tool, name string, lastUsed time.Time, command ...string) bool {
+	if !app.dryRun {
+		if output, err := exec.Command(command[0], command[1:]...).CombinedOutput(); err != nil {
+			app.logError("Failed to delete %s cluster %s: %v: %s", tool, name, err, strings.TrimSpace(string(output)))
+			return false
+		}
+	}
+	app.addItem(&app.summary.KubeItems, fmt.Sprintf("%s cluster %s (last used %s)", tool, name, lastUsed.Format("2006-01-02")))
+	return true
+}
+
+// removeKubeNodeImages removes the tool's node images. Docker refuses to
+// remove images that a remaining cluster's containers still use.
+func (app *App) removeKubeNodeImages(tool string) {
+	output, err := dockerOutput("image", "ls", "--format", "{{.Repository}}:{{.Tag}}", kubeNodeImages[tool])
+	if err != nil {
+		return
+	}
+	for _, ref := range strings.Fields(output) {
+		if !app.dryRun {
+			if _, err := dockerOutput("image", "rm", ref); err != nil {
+				continue
+			}
+		}
+		app.addItem(&app.summary.KubeItems, "image "+ref)
+	}
+}
+
+// minikubeRunning reports whether the minikube profile's host is running,
+// or paused, which is still running. minikube status exits non-zero for a
+// stopped host, so its output is what tells.
+func minikubeRunning(name string) (bool, error) {
+	output, err := exec.Command("minikube", "status", "--profile", name, "--format", "{{.Host}}").Output()
+	state := strings.TrimSpace(string(output))
+	if state == "" {
+		if err == nil {
+			err = fmt.Errorf("no host state")
+		}
+		return false, fmt.Errorf("failed to get minikube status of %s: %w", name, err)
+	}
+	switch state {
+	case "Stopped", "Nonexistent":
+		return false, nil
+	}
+	// Running, Paused or anything else that can't be ruled out
+	return true, nil
+}
+
+// containerLastUsed returns when a container last stopped (or was created,
+// if it never ran) and whether it is running now.
+func containerLastUsed(name string) (time.Time, bool, error) {
+	output, err := dockerOutput("container", "inspect", "--format", "{{.State.Running}} {{.State.FinishedAt}} {{.Created}}", name)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	fields := strings.Fields(output)
+	if len(fields) != 3 {
+		return time.Time{}, false, fmt.Errorf("unexpected docker inspect output %q", output)
+	}
+	if fields[0] == "true" {
+		return time.Now(), true, nil
+	}
+
+	lastUsed, err := time.Parse(time.RFC3339Nano, fields[1])
+	if err != nil || lastUsed.Year() < 2000 {
+		lastUsed, err = time.Parse(time.RFC3339Nano, fields[2])
+	}
+	return lastUsed, false, err
+}
+
+// newestModTime returns the newest modification time of anything under dir.
+func newestModTime(dir string) time.Time {
+	var newest time.Time
+	walkTree(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		return nil
+	})
+	return newest
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+const defaultLevel = "normal"
+
+// cleanLevel is a --level profile: how eagerly a run cleans, relative to
+// the thresholds in the config.
+type cleanLevel struct {
+	name string
+	// ageScale multiplies the ages after which things count as unused, and
+	// sizeScale the size budgets caches are pruned down to; 0 means 1
+	ageScale, sizeScale float64
+	// cleaners, if set, are the only cleaners the level runs
+	cleaners []string
+}
+
+var cleanLevels = []cleanLevel{
+	// Only cleaners that touch the user's own files, and twice as patient:
+	// cheap and unsurprising, e.g. for the run at boot
+	{name: "light", ageScale: 2, cleaners: []string{"downloads", "node_modules", "appimages", "maintenance"}},
+	{name: "normal"},
+	// Half the ages and budgets, for when space is needed now
+	{name: "aggressive", ageScale: 0.5, sizeScale: 0.5},
+}
+
+func init() {
+	flagValues["level"] = levelNames
+}
+
+func levelNames() []string {
+	var names []string
+	for _, l := range cleanLevels {
+		names = append(names, l.name)
+	}
+	return names
+}
+
+// findLevel returns the level called name; "" is the default.
+func findLevel(name string) (cleanLevel, error) {
+	if name == "" {
+		name = defaultLevel
+	}
+	for _, l := range cleanLevels {
+		if l.name == name {
+			return l, nil
+		}
+	}
+	return cleanLevel{}, fmt.Errorf("invalid level %q, expected one of: %s", name, strings.Join(levelNames(), ", "))
+}
+
+func (l cleanLevel) runs(name string) bool {
+	return l.cleaners == nil || slices.Contains(l.cleaners, name)
+}
+
+// pickLevel sets the run's level from --level, or else the config.
+func (app *App) pickLevel(config Config) {
+	name := config.Level
+	if app.levelFlag != "" {
+		name = app.levelFlag
+	}
+	level, err := findLevel(name)
+	if err != nil {
+		log.Printf("Warning: %v; using %s", err, defaultLevel)
+		level, _ = findLevel(defaultLevel)
+	}
+	app.level = level
+}
+
+func scale(n, factor float64) float64 {
+	if factor == 0 {
+		return n
+	}
+	return n * factor
+}
+
+// ageCutoff returns the time before which things untouched count as unused,
+// for an age of days (or def, if days isn't set) scaled by the run's level.
+func (app *App) ageCutoff(days, def int) time.Time {
+	if days <= 0 {
+		days = def
+	}
+	return daysAgo(time.Now(), scale(float64(days), app.level.ageScale))
+}
+
+// daysAgo returns the time days before now on the local calendar: whole
+// days go back to the same time of day, so a file isn't a day younger or
+// older for a daylight saving change in between.
+func daysAgo(now time.Time, days float64) time.Time {
+	whole := math.Floor(days)
+	return now.AddDate(0, 0, -int(whole)).Add(-time.Duration((days - whole) * 24 * float64(time.Hour)))
+}
+
+// sizeBudget parses a size budget and scales it by the run's level.
+func (app *App) sizeBudget(budget string) (int64, error) {
+	size, err := parseSize(budget)
+	if err != nil {
+		return 0, err
+	}
+	return int64(scale(float64(size), app.level.sizeScale)), nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultLimitsKey holds the limits of every cleaner without its own.
+	defaultLimitsKey = "default"
+	// defaultMaxWorkers caps the automatic worker count on local disks.
+	defaultMaxWorkers = 4
+)
+
+// CleanerLimits bound how hard a cleaner works the disk. They apply where
+// it reads file contents: hashing photos, and moving and checksumming what
+// goes into the quarantine. 0 is automatic: the workers saafsafai bench
+// found fastest on the disk, if it's been run there, or else as many as
+// CPUs (up to 4) on local disks and a single worker, with a single open
+// file, on network file systems; reads are never throttled.
+type CleanerLimits struct {
+	MaxWorkers     int     `json:"max_workers"`
+	MaxOpenFiles   int     `json:"max_open_files"`
+	IOThrottleMBps float64 `json:"io_throttle_mbps"`
+}
+
+// validateLimits checks the config's limits, keyed by cleaner name or
+// "default".
+func validateLimits(limits map[string]CleanerLimits) error {
+	names := cleanerNames()
+	for _, key := range sortedKeys(limits) {
+		if key != defaultLimitsKey && !slices.Contains(names, key) {
+			return fmt.Errorf("invalid limits key %q, expected %s or a cleaner name", key, defaultLimitsKey)
+		}
+		l := limits[key]
+		if l.MaxWorkers < 0 || l.MaxOpenFiles < 0 || l.IOThrottleMBps < 0 {
+			return fmt.Errorf("invalid limits.%s, expected 0 (automatic) or more", key)
+		}
+	}
+	return nil
+}
+
+// readLimiter applies a cleaner's limits to the files it reads. A nil one
+// doesn't limit anything.
+type readLimiter struct {
+	workers int
+	// files holds a token for each file open, if they're capped
+	files chan struct{}
+
+	mu sync.Mutex
+	// rate is the bytes a second reads are throttled to, 0 for none; next
+	// is when the bytes read so far are allowed up to
+	rate float64
+	next time.Time
+}
+
+// limiter returns the limiter of the running cleaner, made the first time
+// from the config's limits for it.
+func (app *App) limiter() *readLimiter {
+	if lim, ok := app.limiters[app.cleaner]; ok {
+		return lim
+	}
+	l := app.limits[defaultLimitsKey]
+	if own, ok := app.limits[app.cleaner]; ok {
+		if own.MaxWorkers > 0 {
+			l.MaxWorkers = own.MaxWorkers
+		}
+		if own.MaxOpenFiles > 0 {
+			l.MaxOpenFiles = own.MaxOpenFiles
+		}
+		if own.IOThrottleMBps > 0 {
+			l.IOThrottleMBps = own.IOThrottleMBps
+		}
+	}
+	benched := false
+	if l.MaxWorkers == 0 {
+		l.MaxWorkers = app.benchWorkers()
+		benched = l.MaxWorkers > 0
+	}
+	if app.onNetworkFS() {
+		if l.MaxWorkers == 0 {
+			l.MaxWorkers = 1
+		}
+		if l.MaxOpenFiles == 0 && !benched {
+			l.MaxOpenFiles = 1
+		}
+	}
+	if l.MaxWorkers == 0 {
+		l.MaxWorkers = min(runtime.NumCPU(), defaultMaxWorkers)
+	}
+
+	lim := &readLimiter{workers: l.MaxWorkers, rate: l.IOThrottleMBps * 1e6}
+	if l.MaxOpenFiles > 0 {
+		lim.files = make(chan struct{}, l.MaxOpenFiles)
+	}
+	if app.limiters == nil {
+		app.limiters = make(map[string]*readLimiter)
+	}
+	app.limiters[app.cleaner] = lim
+	return lim
+}
+
+// onNetworkFS reports whether any directory the running cleaner works in
+// is on a network file system.
+func (app *App) onNetworkFS() bool {
+	if paths, ok := cleanerPaths[app.cleaner]; ok {
+		for _, path := range paths(app) {
+			if isNetworkFS(path) {
+				return true
+			}
+		}
+	}
+	return isNetworkFS(app.homeDir)
+}
+
+// each calls fn for 0 to n-1 on the limiter's workers, and returns once all
+// the calls have.
+func (lim *readLimiter) each(n int, fn func(i int)) {
+	workers := 1
+	if lim != nil {
+		workers = max(min(lim.workers, n), 1)
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := range n {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// open opens path for reading, waiting for a free open file if they're
+// capped; closing the file frees it.
+func (lim *readLimiter) open(path string) (*limitedFile, error) {
+	if lim != nil && lim.files != nil {
+		lim.files <- struct{}{}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		lim.release()
+		return nil, err
+	}
+	return &limitedFile{file: f, lim: lim}, nil
+}
+
+func (lim *readLimiter) release() {
+	if lim != nil && lim.files != nil {
+		<-lim.files
+	}
+}
+
+// throttled reports whether reads are throttled, so copies have to go
+// through the limiter rather than be left to the kernel.
+func (lim *readLimiter) throttled() bool {
+	return lim != nil && lim.rate > 0
+}
+
+// wait blocks until reading n more bytes keeps within the throttle.
+func (lim *readLimiter) wait(n int) {
+	if !lim.throttled() || n <= 0 {
+		return
+	}
+	lim.mu.Lock()
+	now := time.Now()
+	if lim.next.Before(now) {
+		lim.next = now
+	}
+	lim.next = lim.next.Add(time.Duration(float64(n) / lim.rate * float64(time.Second)))
+	delay := lim.next.Sub(now)
+	lim.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// reader returns r with its reads throttled.
+func (lim *readLimiter) reader(r io.Reader) io.Reader {
+	if !lim.throttled() {
+		return r
+	}
+	return &limitedFile{reader: r, lim: lim}
+}
+
+// limitedFile is a file read through a limiter. It's an io.Reader and not
+// an *os.File, so copies can't bypass the throttle for the kernel's.
+type limitedFile struct {
+	file   *os.File
+	reader io.Reader
+	lim    *readLimiter
+	closed bool
+}
+
+func (f *limitedFile) Read(p []byte) (int, error) {
+	r := f.reader
+	if r == nil {
+		r = f.file
+	}
+	n, err := r.Read(p)
+	f.lim.wait(n)
+	return n, err
+}
+
+func (f *limitedFile) Stat() (os.FileInfo, error) {
+	return f.file.Stat()
+}
+
+func (f *limitedFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	err := f.file.Close()
+	f.lim.release()
+	return err
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+var errLocked = errors.New("another saafsafai run is in progress")
+
+// lock takes an exclusive lock so that a manual run and the scheduled one
+// can't clean the same directories at the same time. The kernel drops the
+// lock if the process dies, so a crash never leaves a stale lock behind.
+func (app *App) lock() (func(), error) {
+	if err := os.MkdirAll(app.stateDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(app.stateDir, "saafsafai.lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, errLocked
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", f.Name(), err)
+	}
+
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	runLogDirName = "runs"
+	// latestLogName links to the last run's log
+	latestLogName = "latest.log"
+
+	// The log_names: a log a day, or one per run
+	logNamesDaily = "daily"
+	logNamesRun   = "run"
+)
+
+var logNameFormats = []string{logNamesDaily, logNamesRun}
+
+// newRunID returns an ID for a run: its start time, then random digits in
+// case two runs start in the same second.
+func newRunID() string {
+	suffix := make([]byte, 2)
+	rand.Read(suffix)
+	// In UTC, so they sort in the order of the runs across daylight saving
+	// changes and time zones
+	return time.Now().UTC().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// runLogPath is where the report of the run with id is kept.
+func (app *App) runLogPath(id string) string {
+	return filepath.Join(app.logDir, runLogDirName, id+".log")
+}
+
+// logFilePath is where the log of a run at now goes: the day's log, or with
+// log_names "run" its own, named after its local time and ID so they sort
+// in the order of the runs, e.g. 2024-06-01T07-30-12_run-20240601-053012-3f2a.log.
+func (app *App) logFilePath(now time.Time) string {
+	if app.logNames == logNamesRun && app.summary.RunID != "" {
+		return filepath.Join(app.logDir, now.Format("2006-01-02T15-04-05")+"_run-"+app.summary.RunID+".log")
+	}
+	return filepath.Join(app.logDir, now.Format("2006-01-02")+".log")
+}
+
+// linkLatestLog points latest.log at the log just written, replacing the
+// link through a temporary one so it's never missing.
+func (app *App) linkLatestLog(logFile string) {
+	latest := filepath.Join(app.logDir, latestLogName)
+	tmp := latest + ".tmp"
+	os.Remove(tmp)
+	err := os.Symlink(filepath.Base(logFile), tmp)
+	if err == nil {
+		err = os.Rename(tmp, latest)
+	}
+	if err != nil {
+		log.Printf("Warning: failed to link %s: %v", latest, err)
+	}
+}
+
+// logDay returns the day of a log, from the date its name starts with.
+func logDay(file string) (time.Time, bool) {
+	name := filepath.Base(file)
+	if len(name) < len("2006-01-02") {
+		return time.Time{}, false
+	}
+	day, err := time.ParseInLocation("2006-01-02", name[:len("2006-01-02")], time.Local)
+	return day, err == nil
+}
+
+func defineLogsCommand(fs *flag.FlagSet) func(args []string) error {
+	last := fs.Int("last", 1, "show the last `N` run logs")
+	follow := fs.Bool("follow", false, "keep printing new runs as they are logged")
+	date := fs.String("date", "", "show the logs of the runs on `YYYY-MM-DD`")
+	run := fs.String("run", "", "show the report of the run with this `ID`")
+	file := fs.String("file", "", "show what runs did to the file or folder at `PATH`, from the audit log")
+	system := fs.Bool("system", false, "show the system service's logs")
+
+	return func(args []string) error {
+		app, err := commandApp(*system)
+		if err != nil {
+			return err
+		}
+		if *run != "" {
+			return app.showRunLog(*run)
+		}
+		if *file != "" {
+			return app.showAudit(*file)
+		}
+		return app.showLogs(*last, *follow, *date)
+	}
+}
+
+// showRunLog prints the report of one run, by the ID in its summary.
+func (app *App) showRunLog(id string) error {
+	if id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return fmt.Errorf("invalid run ID %q", id)
+	}
+	path := app.runLogPath(id)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return fmt.Errorf("no report for run %s (reports are kept as long as the daily logs)", id)
+	}
+	return printFile(path)
+}
+
+func (app *App) showLogs(last int, follow bool, date string) error {
+	if date != "" {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+		}
+	}
+
+	files, err := app.logFiles()
+	if err != nil {
+		return err
+	}
+	if date != "" {
+		// The day's log, or each of its runs' with log_names "run"
+		var day []string
+		for _, file := range files {
+			if strings.HasPrefix(filepath.Base(file), date) {
+				day = append(day, file)
+			}
+		}
+		if len(day) == 0 {
+			return fmt.Errorf("no log for %s", date)
+		}
+		files, last, follow = day, len(day), false
+	}
+	if len(files) == 0 {
+		// Nothing logged to files yet; the journal may still have output
+		return app.showJournal(last, follow)
+	}
+
+	if last < 1 {
+		last = 1
+	}
+	if last > len(files) {
+		last = len(files)
+	}
+	for i, file := range files[len(files)-last:] {
+		if last > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("==> %s <==\n", filepath.Base(file))
+		}
+		if err := printFile(file); err != nil {
+			return err
+		}
+	}
+
+	if follow {
+		return app.followLogs(files[len(files)-1])
+	}
+	return nil
+}
+
+// logFiles returns the run logs, daily or one per run, oldest first.
+func (app *App) logFiles() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(app.logDir, "*.log"))
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, file := range matches {
+		if _, ok := logDay(file); ok {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// followLogs polls the log directory and prints each run's report as it is
+// written, until interrupted.
+func (app *App) followLogs(current string) error {
+	info, _ := os.Stat(current)
+	for {
+		time.Sleep(time.Second)
+
+		files, err := app.logFiles()
+		if err != nil || len(files) == 0 {
+			continue
+		}
+		newest := files[len(files)-1]
+
+		newInfo, err := os.Stat(newest)
+		if err != nil {
+			continue
+		}
+		if newest == current && info != nil && newInfo.ModTime().Equal(info.ModTime()) && newInfo.Size() == info.Size() {
+			continue
+		}
+
+		// Each run rewrites the day's file (or writes its own), so print the
+		// new report whole
+		fmt.Printf("\n==> %s <==\n", filepath.Base(newest))
+		if err := printFile(newest); err != nil {
+			return err
+		}
+		current, info = newest, newInfo
+	}
+}
+
+func (app *App) showJournal(last int, follow bool) error {
+	if _, err := exec.LookPath("journalctl"); err != nil {
+		return fmt.Errorf("no logs found in %s", app.logDir)
+	}
+
+	args := []string{"--unit", serviceName, "--no-pager"}
+	if !app.system {
+		args = append([]string{"--user"}, args...)
+	}
+	if follow {
+		args = append(args, "--follow")
+	} else {
+		// journalctl has no notion of runs, so show the last few lines per run
+		args = append(args, "--lines", fmt.Sprint(last*50))
+	}
+
+	cmd := exec.Command("journalctl", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func printFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no log for %s", filepath.Base(path))
+		}
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(os.Stdout, f)
+	return err
+}
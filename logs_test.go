@@ -0,0 +1,14 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShowRunLogMissing(t *testing.T) {
+	app := newHomeApp(t.TempDir())
+	err := app.showRunLog("20260101-120000-abcd")
+	if err == nil || !strings.Contains(err.Error(), "no report for run 20260101-120000-abcd") {
+		t.Errorf("showRunLog of a run with no report = %v, want the run's own message", err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+const mailAttachmentMaxAge = 14 // days
+
+type MailConfig struct {
+	// MaxAgeDays removes cached and temporarily opened attachments older
+	// than this many days (default 14).
+	MaxAgeDays int `json:"max_age_days"`
+}
+
+// mailAttachmentDirs are where mail clients leave attachments: Thunderbird
+// and Evolution save the ones opened from a message in the temp directory,
+// and Evolution keeps IMAP message parts, which it downloads again when
+// needed, in its cache.
+func (app *App) mailAttachmentDirs() []string {
+	tmp := os.TempDir()
+	dirs := []string{filepath.Join(app.homeDir, ".cache", "evolution", "mail")}
+	for _, pattern := range []string{"mozilla_*", "evolution-*", "thunderbird_*"} {
+		matches, _ := filepath.Glob(filepath.Join(tmp, pattern))
+		for _, match := range matches {
+			if ownedByMe(match) {
+				dirs = append(dirs, match)
+			}
+		}
+	}
+	return dirs
+}
+
+// ownedByMe reports whether the directory at path belongs to the user
+// running saafsafai, so other users' temp files are left alone.
+func ownedByMe(path string) bool {
+	info, err := os.Lstat(path)
+	if err != nil || !info.IsDir() {
+		return false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(st.Uid) == os.Getuid()
+}
+
+// cleanMailAttachments removes the mail clients' attachment files untouched
+// for longer than the configured age.
+func (app *App) cleanMailAttachments(cfg MailConfig) error {
+	cutoff := app.ageCutoff(cfg.MaxAgeDays, mailAttachmentMaxAge)
+
+	seen := make(map[inodeKey]bool)
+	for _, dir := range app.mailAttachmentDirs() {
+		var old []string
+		app.scanTree(dir, seen, func(rel string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err == nil && info.ModTime().Before(cutoff) && accessTime(info).Before(cutoff) {
+				old = append(old, filepath.Join(dir, rel))
+			}
+			return nil
+		})
+
+		for _, path := range old {
+			info, err := os.Lstat(path)
+			if err != nil {
+				continue
+			}
+			if err := app.remove(path); err != nil {
+				app.skipItem("Failed to remove mail attachment", path, err)
+				continue
+			}
+			app.addItem(&app.summary.MailAttachments, fmt.Sprintf("%s (%s, %s)", app.displayPath(path), formatSize(info.Size()), info.ModTime().Format("2006-01-02")))
+		}
+	}
+	return nil
+}
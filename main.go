@@ -3,12 +3,16 @@ package main
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -18,26 +22,305 @@ const (
 	serviceName       = "saafsafai.service"
 	binaryName        = "saafsafai"
 	nodeModulesMaxAge = 30 // days
+	winePrefixMaxAge  = 90 // days
+)
+
+// Exit codes, so wrappers and monitoring can tell runs apart.
+const (
+	exitOK          = 0 // cleaned something without errors
+	exitFatal       = 1 // the run couldn't complete
+	exitPartial     = 2 // completed, but some items failed
+	exitNothingToDo = 3 // completed and there was nothing to clean
+	exitLocked      = 4 // another run holds the lock
+	exitUsage       = 5 // invalid command line
 )
 
 type Config struct {
-	CleanDownloads    bool `json:"clean_downloads"`
-	DeleteNodeModules bool `json:"delete_node_modules"`
+	// Version is the schema version the config was written for; older
+	// configs are migrated when loaded.
+	Version int `json:"version"`
+	// RemoteConfig is a centrally managed config this one's keys are
+	// applied over, fetched at the start of every run.
+	RemoteConfig RemoteConfig `json:"remote_config"`
+
+	CleanDownloads bool `json:"clean_downloads"`
+	// DownloadsDir is the folder the Downloads cleaner organizes, relative
+	// to the home directory (or --root); empty for ~/Downloads.
+	DownloadsDir string `json:"downloads_dir"`
+	// BrowserHistory looks up where Downloads files came from in the
+	// browsers' download history, for the audit log.
+	BrowserHistory bool `json:"browser_history"`
+	// RenameDocuments names generically named PDFs, such as
+	// "document(3).pdf", after the date and title on their first page.
+	RenameDocuments bool `json:"rename_documents"`
+	// CategoryIndex, "csv" or "json", keeps an index of the files moved
+	// into each category folder in it; empty keeps none.
+	CategoryIndex string `json:"category_index"`
+	// Categories files Downloads files of some extensions into another
+	// category folder than the built-in one, or a new one, e.g.
+	// {".ics": "Calendar"}.
+	Categories map[string]string `json:"categories"`
+	// CategoryNames names the folders of categories, built-in or not, in
+	// another language or however one likes, e.g. {"Documents":
+	// "Dokumente"}; rules keep naming the category.
+	CategoryNames map[string]string `json:"category_names"`
+	// DeleteAfterDays removes Downloads files of some extensions, filed
+	// or not, once they're this many days old, e.g. {".iso": 30}.
+	DeleteAfterDays map[string]int `json:"delete_after_days"`
+	// DownloadActions maps file extensions to scripts that are run on
+	// Downloads files of that type instead of moving them into a category.
+	DownloadActions map[string]string `json:"download_actions"`
+	// Strict leaves Downloads files no categories, delete_after_days or
+	// download_actions rule names in place, only listing them, instead of
+	// going by the built-in categories and temporary file extensions.
+	Strict bool `json:"strict"`
+	// NoExtension is what's done with Downloads files without an
+	// extension: "sniff" (the default) files them by what their content
+	// looks like, "leave" leaves them, "review" moves them into Review and
+	// "others" files them into Others. Scripts and programs stay put unless
+	// it's "others".
+	NoExtension string `json:"no_extension"`
+	// CompoundExtensions are extensions of more than one part, such as
+	// ".tar.gz", that rules and categories match whole, on top of the
+	// built-in ones and those rules name.
+	CompoundExtensions []string `json:"compound_extensions"`
+
+	DeleteNodeModules    bool `json:"delete_node_modules"`
+	CleanWinePrefixes    bool `json:"clean_wine_prefixes"`
+	CleanOldAppImages    bool `json:"clean_old_appimages"`
+	CleanPackageCache    bool `json:"clean_package_cache"`
+	RemoveOrphanPackages bool `json:"remove_orphan_packages"`
+	RemoveOldKernels     bool `json:"remove_old_kernels"`
+	CleanUserHomes       bool `json:"clean_user_homes"`
+
+	CleanMailAttachments bool       `json:"clean_mail_attachments"`
+	Mail                 MailConfig `json:"mail"`
+
+	CleanSyncConflicts bool               `json:"clean_sync_conflicts"`
+	SyncConflicts      SyncConflictConfig `json:"sync_conflicts"`
+
+	CleanBackupFiles bool              `json:"clean_backup_files"`
+	BackupFiles      BackupFilesConfig `json:"backup_files"`
+
+	CleanMetadataFiles bool                `json:"clean_metadata_files"`
+	MetadataFiles      MetadataFilesConfig `json:"metadata_files"`
+
+	CleanGitRepos bool           `json:"clean_git_repos"`
+	GitRepos      GitReposConfig `json:"git_repos"`
+
+	FindDuplicatePhotos bool         `json:"find_duplicate_photos"`
+	Photos              PhotosConfig `json:"photos"`
+
+	CleanFontCaches bool            `json:"clean_font_caches"`
+	FontCache       FontCacheConfig `json:"font_cache"`
+
+	CleanDocker bool         `json:"clean_docker"`
+	Docker      DockerConfig `json:"docker"`
+
+	CleanVMImages bool     `json:"clean_vm_images"`
+	VM            VMConfig `json:"vm"`
+
+	CleanKubeClusters bool       `json:"clean_kube_clusters"`
+	Kube              KubeConfig `json:"kube"`
+
+	// Removable are the profiles of removable media, cleaned when mounted.
+	Removable []RemovableProfile `json:"removable"`
+
+	// PostCleanHooks trims the file systems runs free space on, and runs
+	// the other post_clean hooks. fstrim and btrfs need root, so setup only
+	// offers it for the system service.
+	PostCleanHooks bool            `json:"post_clean_hooks"`
+	PostClean      PostCleanConfig `json:"post_clean"`
+
+	Notify NotifyConfig `json:"notify"`
+	// Summary shapes the run summary, which the notifications and
+	// healthcheck pings carry too.
+	Summary SummaryConfig `json:"summary"`
+
+	// RunOn is "login" (boot, for the system service), "timer" or "both";
+	// Schedule is the timer's systemd calendar expression, e.g. "daily".
+	RunOn    string      `json:"run_on"`
+	Schedule string      `json:"schedule"`
+	Timer    TimerConfig `json:"timer"`
+
+	Quarantine QuarantineConfig `json:"quarantine"`
+	// Trash moves what's deleted into the desktop trash of its disk instead.
+	Trash TrashConfig `json:"trash"`
+	// DiskGuard checks the disks big copies are written to first.
+	DiskGuard DiskGuardConfig `json:"disk_guard"`
+	// Snapshot snapshots the btrfs subvolumes and ZFS datasets runs clean
+	// in before they start, for one more way back.
+	Snapshot  SnapshotConfig  `json:"snapshot"`
+	Retention RetentionConfig `json:"retention"`
+	// LogNames is how the logs are named: "daily" (the default), one file
+	// a day that each run replaces, or "run", one per run, named after its
+	// time and ID.
+	LogNames string `json:"log_names"`
+	// Analytics records the size of the home directory's folders over time,
+	// locally, for stats trends.
+	Analytics AnalyticsConfig `json:"analytics"`
+
+	// Level is the --level of runs that don't give one: "light", "normal"
+	// (the default) or "aggressive".
+	Level string `json:"level"`
+	// Limits bound the workers, open files and read rate of cleaners, by
+	// cleaner name, or "default" for the rest, e.g. for homes on NFS.
+	Limits map[string]CleanerLimits `json:"limits"`
+	// AgeBy is what file ages are counted from, by cleaner name, or
+	// "default" for the rest: "mtime" (the default), "atime" for the last
+	// time a file was read or changed, or "birthtime" for when it was
+	// created, where the file system records it.
+	AgeBy map[string]string `json:"age_by"`
+	// MaxScanDepth and MaxEntriesPerDir bound the walks for things to clean:
+	// directories more than MaxScanDepth below where a walk starts (default
+	// 64) aren't looked in, nor past the first MaxEntriesPerDir entries of a
+	// directory (default 100000). Directories looping back to one they're
+	// in, as bind mounts can, never are.
+	MaxScanDepth     int `json:"max_scan_depth"`
+	MaxEntriesPerDir int `json:"max_entries_per_dir"`
+
+	// IdleMinutes defers unattended runs until the system has been idle
+	// this long; 0 runs right away.
+	IdleMinutes int `json:"idle_minutes"`
+
+	// HealthcheckURL is pinged at the start and end of every run
+	// (healthchecks.io style), so a monitor can alert when runs stop.
+	HealthcheckURL string `json:"healthcheck_url"`
 }
 
 type Summary struct {
-	DeletedFiles   []string `json:"deleted_files"`
-	MovedFiles     []string `json:"moved_files"`
-	RemovedModules []string `json:"removed_modules"`
+	// RunID identifies the run in notifications, the history and the
+	// run's own report, whose path is Report (none for dry runs).
+	RunID  string `json:"run_id"`
+	Report string `json:"report,omitempty"`
+
+	DryRun               bool     `json:"dry_run"`
+	DeletedFiles         itemList `json:"deleted_files"`
+	MovedFiles           itemList `json:"moved_files"`
+	RenamedCategories    itemList `json:"renamed_categories"`
+	QuarantinedDownloads itemList `json:"quarantined_downloads"`
+	ExpiredFiles         itemList `json:"expired_files"`
+	ScriptActions        itemList `json:"script_actions"`
+	RemovedModules       itemList `json:"removed_modules"`
+	RemovedWinePrefixes  itemList `json:"removed_wine_prefixes"`
+	RemovedAppImages     itemList `json:"removed_appimages"`
+	MailAttachments      itemList `json:"mail_attachments"`
+	QuarantinedConflicts itemList `json:"quarantined_conflicts"`
+	SyncConflicts        itemList `json:"sync_conflicts"`
+	RemovedBackups       itemList `json:"removed_backups"`
+	RemovedMetadata      itemList `json:"removed_metadata"`
+	TidiedRepos          itemList `json:"tidied_repos"`
+	ArchivedRepos        itemList `json:"archived_repos"`
+	RemovedClones        itemList `json:"removed_clones"`
+	RemovedPhotos        itemList `json:"removed_photos"`
+	DuplicatePhotos      itemList `json:"duplicate_photos"`
+	RebuiltCaches        itemList `json:"rebuilt_caches"`
+	PackageCaches        itemList `json:"package_caches"`
+	RemovedPackages      itemList `json:"removed_packages"`
+	RemovedKernels       itemList `json:"removed_kernels"`
+	CleanedUsers         itemList `json:"cleaned_users"`
+	DockerItems          itemList `json:"docker_items"`
+	RemovedVMImages      itemList `json:"removed_vm_images"`
+	VMImageCandidates    itemList `json:"vm_image_candidates"`
+	UntouchedFiles       itemList `json:"untouched_files"`
+	LeftFiles            itemList `json:"left_files"`
+	LargeRepos           itemList `json:"large_repos"`
+	StaleRepos           itemList `json:"stale_repos"`
+	StaleBranches        itemList `json:"stale_branches"`
+	DuplicateRepos       itemList `json:"duplicate_repos"`
+	ProtectedItems       itemList `json:"protected_items"`
+	KubeItems            itemList `json:"kube_items"`
+	MediaItems           itemList `json:"media_items"`
+	PostCleanHooks       itemList `json:"post_clean_hooks"`
+	Snapshots            itemList `json:"snapshots"`
+	Recovered            itemList `json:"recovered"`
+	Errors               itemList `json:"errors"`
+	// Skipped are the items a cleaner failed on, with why; SkipReasons
+	// counts them by reason.
+	Skipped     itemList       `json:"skipped"`
+	SkipReasons map[string]int `json:"skip_reasons,omitempty"`
+	// Truncated maps the lists above cut short to their full length; a real
+	// run's report still has every item.
+	Truncated map[string]int `json:"truncated,omitempty"`
+	// Groups counts the items of the grouped lists by group, e.g.
+	// moved_files by Downloads category.
+	Groups map[string]map[string]int `json:"groups,omitempty"`
+
+	// FreedBytes is the disk space the removals freed (or would free): the
+	// blocks of each removed inode, once, with hard links counted only when
+	// none is left. Items moved into the quarantine don't free anything.
+	FreedBytes int64 `json:"freed_bytes"`
+
+	// Cleaners maps each cleaner that ran to the number of items it found,
+	// and Categories counts the files moved into each Downloads category.
+	Cleaners   map[string]int `json:"cleaners"`
+	Categories map[string]int `json:"categories"`
+	// Extensions and Domains count the Downloads files the run handled by
+	// extension and by the site they came from (with browser_history).
+	Extensions map[string]clutterStat `json:"extensions,omitempty"`
+	Domains    map[string]clutterStat `json:"domains,omitempty"`
+
+	// CleanerTimes and ActionTimes are how long each cleaner and each
+	// expensive step within one took, in milliseconds.
+	CleanerTimes map[string]int64 `json:"cleaner_ms"`
+	ActionTimes  map[string]int64 `json:"action_ms"`
 }
 
 type App struct {
-	homeDir        string
-	downloadsDir   string
-	configPath     string
-	systemdUnitDir string
-	logDir         string
-	summary        Summary
+	homeDir           string
+	downloadsDir      string
+	configPath        string
+	systemdUnitDir    string
+	binDir            string
+	stateDir          string
+	logDir            string
+	system            bool
+	dryRun            bool
+	verbose           bool
+	jsonOutput        bool
+	color             painter // how the summary printed to the terminal is styled
+	stdin             *bufio.Reader
+	journal           *journal
+	quarantine        bool
+	unattended        bool            // the daemon's runs never prompt, even from a terminal
+	only              map[string]bool // if set, the only cleaners to run
+	skip              map[string]bool // cleaners not to run
+	levelFlag         string          // --level, if given
+	noSystemd         bool            // --no-systemd, or --root
+	downloadsFlag     bool            // --downloads, which the config doesn't override
+	scanDirs          []string        // --scan, if given
+	estimating        bool            // sample tree sizes rather than measure them
+	sizeCache         map[string]sizeCacheEntry
+	itemStream        *os.File // every summary item of a real run, for its report
+	auditFile         *os.File
+	cleaner           string // the cleaner running, for the audit log
+	origins           map[string]downloadOrigin
+	filed             map[string][]categoryIndexEntry // by category folder, for its index
+	protected         []string                        // paths the system policy protects
+	limits            map[string]CleanerLimits        // the config's, by cleaner
+	ageByConfig       map[string]string               // the config's age_by
+	logNames          string                          // the config's log_names
+	summaryItems      int                             // the config's summary.max_items
+	categoryNames     map[string]string               // the config's category_names
+	dirNames          map[string]map[string]bool      // composed entry names by directory, for freeName
+	limiters          map[string]*readLimiter
+	scanLimits        walkLimits // the config's, for scanTree
+	itemStreamFailed  bool
+	remoteDropped     bool // the remote config keys that run commands were warned about
+	scan              homeScan
+	level             cleanLevel           // the level the run cleans at
+	cleanerRuns       map[string]time.Time // when each cleaner that didn't fail ran
+	freed             freedSpace
+	media             map[string]bool  // if set, the only removable media to clean, by device
+	freeBefore        map[string]int64 // free space by file system when the cleaners started
+	diskGuard         DiskGuardConfig
+	diskHealthChecked map[string]error // by disk, once a run
+	snapshots         []runSnapshot    // taken before the run
+	dirSizes          map[string]int64 // the tracked directories, if measured
+	trashConfig       TrashConfig
+	undoable          int               // items the run moved, quarantined or trashed
+	undoNotice        *undoNotification // the run's notification, if it has an Undo button
+	summary           Summary
 }
 
 func NewApp() (*App, error) {
@@ -45,44 +328,162 @@ func NewApp() (*App, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get home directory: %w", err)
 	}
+	return newHomeApp(homeDir), nil
+}
 
+// newHomeApp returns an App for the home directory homeDir, laid out as
+// the user's own would be.
+func newHomeApp(homeDir string) *App {
 	app := &App{
 		homeDir:        homeDir,
 		downloadsDir:   filepath.Join(homeDir, "Downloads"),
 		configPath:     filepath.Join(homeDir, ".config", configFileName),
 		systemdUnitDir: filepath.Join(homeDir, ".config", "systemd", "user"),
+		binDir:         filepath.Join(homeDir, ".local", "bin"),
+		stateDir:       filepath.Join(homeDir, ".local", "share", "saafsafai"),
 		logDir:         filepath.Join(homeDir, ".local", "share", "saafsafai", "logs"),
 		summary:        Summary{},
 	}
 
-	return app, nil
+	return app
 }
 
 func main() {
-	app, err := NewApp()
-	if err != nil {
-		log.Fatalf("Failed to initialize application: %v", err)
+	if len(os.Args) > 1 {
+		if cmd, ok := findCommand(os.Args[1]); ok {
+			os.Exit(runCommand(cmd, os.Args[2:]))
+		}
 	}
 
-	args := os.Args[1:]
+	opts := defineRootFlags(flag.CommandLine)
+	flag.Usage = func() { (&App{}).printHelp() }
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		os.Exit(exitUsage)
+	}
 
+	if *opts.help {
+		flag.Usage()
+		return
+	}
+	if *opts.version {
+		printVersion(readBuildInfo())
+		return
+	}
+
+	var app *App
+	var err error
 	switch {
-	case len(args) > 0 && args[0] == "--setup":
-		if err := app.runSetup(); err != nil {
+	case *opts.system && (*opts.root != "" || *opts.home != ""), *opts.root != "" && *opts.home != "":
+		log.Print("only one of --system, --root and --home can be given")
+		os.Exit(exitUsage)
+	case *opts.system:
+		app = NewSystemApp()
+	case *opts.root != "":
+		app, err = NewRootApp(*opts.root)
+	case *opts.home != "":
+		app, err = NewHomeApp(*opts.home)
+	default:
+		app, err = NewApp()
+	}
+	if err != nil {
+		log.Fatalf("Failed to initialize application: %v", err)
+	}
+	if *opts.noSystemd {
+		app.noSystemd = true
+	}
+	if err := app.usePaths(opts.paths()); err != nil {
+		log.Print(err)
+		os.Exit(exitUsage)
+	}
+
+	if *opts.setup {
+		if err := app.runSetup(triggerFlags{}); err != nil {
 			log.Fatalf("Setup failed: %v", err)
 		}
 		return
-	case len(args) > 0 && args[0] == "--help":
-		app.printHelp()
-		return
-	case len(args) > 0 && args[0] == "--version":
-		fmt.Println("saafsafai v1.0.0")
-		return
 	}
 
-	if err := app.run(); err != nil {
+	app.dryRun = *opts.dryRun
+	app.summary.DryRun = *opts.dryRun
+	app.verbose = *opts.verbose
+	app.jsonOutput = *opts.json
+	app.color = colorOutput(os.Stdout, *opts.noColor)
+	if app.only, err = parseCleanerList("only", *opts.only); err == nil {
+		app.skip, err = parseCleanerList("skip", *opts.skip)
+	}
+	if err == nil && *opts.level != "" {
+		app.levelFlag = *opts.level
+		_, err = findLevel(*opts.level)
+	}
+	if err != nil {
+		log.Print(err)
+		os.Exit(exitUsage)
+	}
+
+	run := app.run
+	if *opts.catchUp {
+		run = app.catchUp
+	}
+	if err := run(); err != nil {
+		if errors.Is(err, errLocked) {
+			log.Printf("Cleanup skipped: %v", err)
+			os.Exit(exitLocked)
+		}
 		log.Fatalf("Cleanup failed: %v", err)
 	}
+	app.waitForUndo()
+	os.Exit(app.exitCode())
+}
+
+type rootOptions struct {
+	setup, system, noSystemd, dryRun, catchUp, verbose, json, noColor, help, version *bool
+	only, skip, level, root, home                                                    *string
+	config, downloads, scan, stateDir, logDir                                        *string
+}
+
+func (o rootOptions) paths() pathFlags {
+	return pathFlags{config: *o.config, downloads: *o.downloads, scan: *o.scan, stateDir: *o.stateDir, logDir: *o.logDir}
+}
+
+// defineRootFlags registers the flags of a plain "saafsafai" run.
+func defineRootFlags(fs *flag.FlagSet) rootOptions {
+	return rootOptions{
+		setup:     fs.Bool("setup", false, "run interactive setup"),
+		system:    fs.Bool("system", false, "use the system-wide (root) configuration and service"),
+		root:      fs.String("root", "", "treat `dir` as the home directory, with the config and state in it, e.g. a container volume (implies --no-systemd)"),
+		home:      fs.String("home", "", "clean `dir` as a home directory laid out like your own, e.g. another user's or a mounted backup"),
+		config:    fs.String("config", "", "read the config from `file`"),
+		downloads: fs.String("downloads", "", "organize `dir` as the Downloads folder, whatever the config's downloads_dir"),
+		scan:      fs.String("scan", "", "look for node_modules, sync conflicts, backup and metadata files and git repositories in these `dirs` (comma-separated) instead of the home directory"),
+		stateDir:  fs.String("state-dir", "", "keep the history, audit log, quarantine and other state in `dir`"),
+		logDir:    fs.String("log-dir", "", "write the logs and run reports to `dir`"),
+		noSystemd: fs.Bool("no-systemd", false, "don't install or touch systemd units; setup only writes the config"),
+		dryRun:    fs.Bool("dry-run", false, "report what would be cleaned without changing anything"),
+		catchUp:   fs.Bool("catch-up", false, "run the cleaners a scheduled run missed while the machine was off, if any, after a random delay"),
+		verbose:   fs.Bool("verbose", false, "include how long each cleaner took in the report"),
+		json:      fs.Bool("json", false, "print the summary as JSON"),
+		noColor:   fs.Bool("no-color", false, "print the summary without colors, even to a terminal"),
+		help:      fs.Bool("help", false, "show help"),
+		version:   fs.Bool("version", false, "show version information"),
+		only:      fs.String("only", "", "run only these configured cleaners, e.g. `downloads,node_modules`"),
+		skip:      fs.String("skip", "", "don't run these cleaners, e.g. `packages`"),
+		level:     fs.String("level", "", "how eagerly to clean: `light`, normal or aggressive (default: the config's level)"),
+	}
+}
+
+func (app *App) exitCode() int {
+	switch {
+	case app.failures() > 0:
+		return exitPartial
+	case app.itemCount() == 0:
+		return exitNothingToDo
+	default:
+		return exitOK
+	}
 }
 
 func (app *App) run() error {
@@ -91,122 +492,417 @@ func (app *App) run() error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	if config.CleanDownloads {
-		if err := app.cleanDownloads(); err != nil {
-			log.Printf("Error cleaning downloads: %v", err)
-		}
+	unlock, err := app.lock()
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
-	if config.DeleteNodeModules {
-		if err := app.cleanOldNodeModules(); err != nil {
-			log.Printf("Error cleaning node_modules: %v", err)
+	if config.RemoteConfig.enabled() {
+		if err := app.fetchRemoteConfig(config.RemoteConfig); err != nil {
+			log.Printf("Warning: %v; using the last one fetched, if any", err)
+		}
+		if config, err = app.loadConfig(); err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
 		}
 	}
 
-	return app.printSummary()
-}
+	app.waitForIdle(config.IdleMinutes)
+	app.pickLevel(config)
 
-func (app *App) printHelp() {
-	fmt.Println(`saafsafai - A system cleanup utility
+	app.summary.RunID = newRunID()
+	if !app.dryRun {
+		app.summary.Report = app.runLogPath(app.summary.RunID)
+		defer app.closeItemStream()
+		defer app.closeAudit()
+	}
 
-Usage:
-  saafsafai           Run cleanup based on configuration
-  saafsafai --setup   Run interactive setup
-  saafsafai --help    Show this help message
-  saafsafai --version Show version information
+	app.quarantine = config.Quarantine.Enabled
+	app.trashConfig = config.Trash
+	if !app.dryRun {
+		if err := app.recoverJournal(); err != nil {
+			app.logError("Failed to recover the interrupted run: %v", err)
+		}
+		if err := app.openJournal(); err != nil {
+			return err
+		}
+	}
 
-Configuration file location: ~/.config/saafsafai.json
-Logs location: ~/.local/share/saafsafai/logs/`)
-}
+	app.pingHealthcheck(config.HealthcheckURL, healthcheckStart, "")
 
-func (app *App) runSetup() error {
-	reader := bufio.NewReader(os.Stdin)
-	var config Config
+	if config.Snapshot.Enabled {
+		app.takeSnapshots(config)
+	}
+	if config.PostCleanHooks && !app.dryRun {
+		app.snapshotFreeSpace()
+	}
+	app.runCleaners(config)
+	if config.Analytics.Enabled && !app.dryRun {
+		app.sampleUsage(config.Analytics)
+	}
 
-	fmt.Println("⚙️  Welcome to saafsafai setup!")
-	fmt.Println()
+	return app.finish(config)
+}
 
-	cleanDownloads, err := app.askYesNo(reader, "Do you want to clean the Downloads folder?")
-	if err != nil {
-		return fmt.Errorf("failed to read input: %w", err)
+// finish writes the summary, sends the configured notifications and reports
+// the outcome to the healthcheck.
+func (app *App) finish(config Config) error {
+	app.closeJournal()
+
+	if err := app.printSummary(); err != nil {
+		app.pingHealthcheck(config.HealthcheckURL, healthcheckFail, err.Error())
+		return err
 	}
-	config.CleanDownloads = cleanDownloads
+	app.notify(config.Notify)
 
-	deleteNodeModules, err := app.askYesNo(reader, "Do you want to delete unused node_modules folders (30+ days old)?")
-	if err != nil {
-		return fmt.Errorf("failed to read input: %w", err)
+	if !app.dryRun {
+		if err := app.recordHistory(); err != nil {
+			log.Printf("Failed to record run history: %v", err)
+		}
 	}
-	config.DeleteNodeModules = deleteNodeModules
 
-	if err := app.saveConfig(config); err != nil {
-		return fmt.Errorf("failed to save config: %w", err)
+	event := healthcheckSuccess
+	if app.failures() > 0 {
+		event = healthcheckFail
 	}
+	app.pingHealthcheck(config.HealthcheckURL, event, app.summaryText())
+	return nil
+}
+
+func (app *App) printHelp() {
+	fmt.Println(T("help"))
+}
 
-	if err := app.installSystemdService(); err != nil {
-		return fmt.Errorf("failed to install systemd service: %w", err)
+// runSetup runs the interactive setup. Trigger settings given as flags
+// replace the question about when to run.
+func (app *App) runSetup(triggers triggerFlags) error {
+	if app.system {
+		return app.runSystemSetup(triggers)
 	}
 
+	reader := bufio.NewReader(os.Stdin)
+
+	fmt.Println(T("setup.welcome"))
 	fmt.Println()
-	fmt.Println("✅ Setup complete! saafsafai will run at each boot.")
-	fmt.Println("📁 Config saved to:", app.configPath)
-	fmt.Println("🔧 To manually run: saafsafai")
-	fmt.Println("📋 To see logs: ls", app.logDir)
 
-	return nil
+	config, err := app.askConfig(reader, userMode)
+	if err != nil {
+		return err
+	}
+
+	if !app.noSystemd {
+		if err := app.askTriggers(reader, &config, triggers); err != nil {
+			return err
+		}
+	}
+	return app.provision(config)
 }
 
 func (app *App) askYesNo(reader *bufio.Reader, question string) (bool, error) {
-	fmt.Printf("%s (y/n): ", question)
+	fmt.Print(T("prompt.yes_no", question))
 	input, err := reader.ReadString('\n')
 	if err != nil {
 		return false, err
 	}
 
 	response := strings.TrimSpace(strings.ToLower(input))
-	return response == "y" || response == "yes", nil
+	return isYes(response), nil
+}
+
+// askChoice asks for one of choices, returning current on an empty answer.
+func (app *App) askChoice(reader *bufio.Reader, question string, choices []string, current string) (string, error) {
+	for {
+		answer, err := app.askString(reader, fmt.Sprintf("%s (%s)", question, strings.Join(choices, "/")), current)
+		if err != nil {
+			return "", err
+		}
+		if slices.Contains(choices, answer) {
+			return answer, nil
+		}
+	}
+}
+
+// askString asks for free text, returning current on an empty answer.
+func (app *App) askString(reader *bufio.Reader, question, current string) (string, error) {
+	fmt.Printf("%s [%s]: ", question, current)
+	input, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if answer := strings.TrimSpace(input); answer != "" {
+		return answer, nil
+	}
+	return current, nil
+}
+
+// confirm asks a yes/no question on the terminal, sharing one reader so
+// buffered input isn't lost between questions.
+func (app *App) confirm(question string) (bool, error) {
+	if app.stdin == nil {
+		app.stdin = bufio.NewReader(os.Stdin)
+	}
+	return app.askYesNo(app.stdin, question)
 }
 
 func (app *App) loadConfig() (Config, error) {
 	var config Config
 
-	if _, err := os.Stat(app.configPath); os.IsNotExist(err) {
-		return config, fmt.Errorf("config file not found at %s. Run 'saafsafai --setup' to configure", app.configPath)
+	configPath := app.configPath
+	if _, err := os.Stat(configPath); os.IsNotExist(err) && !app.system {
+		// Fall back to the default an administrator provided for all users
+		if _, err := os.Stat(systemDefaultUserConfig); err == nil {
+			configPath = systemDefaultUserConfig
+		}
 	}
 
-	data, err := os.ReadFile(app.configPath)
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		setupCmd := "saafsafai --setup"
+		if app.system {
+			setupCmd = "sudo saafsafai --setup --system"
+		}
+		return config, fmt.Errorf("config file not found at %s. Run '%s' to configure", app.configPath, setupCmd)
+	}
+
+	data, err := os.ReadFile(configPath)
 	if err != nil {
 		return config, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	// A config over a remote one only holds the keys it overrides, which
+	// migrating would fill in with their defaults
+	var local struct {
+		RemoteConfig RemoteConfig `json:"remote_config"`
+	}
+	if json.Unmarshal(data, &local) == nil && local.RemoteConfig.enabled() {
+		base, err := app.remoteBase()
+		if err != nil {
+			return config, err
+		}
+		if base != nil {
+			if err := json.Unmarshal(base, &config); err != nil {
+				return config, fmt.Errorf("failed to parse the cached remote config: %w", err)
+			}
+		}
+	} else if data, err = app.migrateConfig(configPath, data); err != nil {
+		return config, err
+	}
 	if err := json.Unmarshal(data, &config); err != nil {
 		return config, fmt.Errorf("failed to parse config JSON: %w", err)
 	}
+	if err := app.applyPolicy(&config); err != nil {
+		return config, err
+	}
+	app.useDownloadsDir(config.DownloadsDir)
+	app.limits, app.limiters = config.Limits, nil
+	app.ageByConfig = config.AgeBy
+	app.logNames = config.LogNames
+	app.summaryItems = config.Summary.MaxItems
+	app.categoryNames = config.CategoryNames
+	app.scanLimits = scanLimitsFor(config)
+	app.diskGuard = config.DiskGuard
 
 	return config, nil
 }
 
 func (app *App) saveConfig(cfg Config) error {
+	if cfg.RemoteConfig.enabled() {
+		data, err := app.localOverrides(cfg)
+		if err != nil {
+			return err
+		}
+		if err := writeConfigFile(app.configPath, data, cfg); err != nil {
+			return fmt.Errorf("failed to write config file: %w", err)
+		}
+		return nil
+	}
+	return writeConfig(app.configPath, cfg)
+}
+
+// configPerm is the mode a config file is written with: readable by its
+// owner alone if it holds a secret, such as an SMTP password.
+func configPerm(cfg Config) os.FileMode {
+	if cfg.Notify.Email.Password != "" {
+		return 0600
+	}
+	return 0644
+}
+
+// writeConfigFile writes the config file data of cfg to path, with
+// configPerm's mode whether the file is new or not.
+func writeConfigFile(path string, data []byte, cfg Config) error {
+	if err := os.WriteFile(path, data, configPerm(cfg)); err != nil {
+		return err
+	}
+	return os.Chmod(path, configPerm(cfg))
+}
+
+func writeConfig(path string, cfg Config) error {
+	cfg.Version = configVersion
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	if err := os.MkdirAll(filepath.Dir(app.configPath), 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	if err := os.WriteFile(app.configPath, data, 0644); err != nil {
+	if err := writeConfigFile(path, data, cfg); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
 	return nil
 }
 
-func (app *App) cleanDownloads() error {
+// validate checks the settings a run would otherwise only warn about, or
+// quietly replace with their defaults.
+func (c Config) validate() error {
+	if c.Version > configVersion {
+		return fmt.Errorf("invalid version %d, this saafsafai knows up to %d", c.Version, configVersion)
+	}
+	if err := c.RemoteConfig.validate(); err != nil {
+		return err
+	}
+	if err := validateRemovable(c.Removable); err != nil {
+		return err
+	}
+	if err := validateLimits(c.Limits); err != nil {
+		return err
+	}
+	if err := validateAgeBy(c.AgeBy); err != nil {
+		return err
+	}
+	if c.RunOn != "" && !slices.Contains(runOnNames, c.RunOn) {
+		return fmt.Errorf("invalid run_on %q, expected one of: %s", c.RunOn, strings.Join(runOnNames, ", "))
+	}
+	if c.Level != "" {
+		if _, err := findLevel(c.Level); err != nil {
+			return err
+		}
+	}
+	switch c.Notify.Mode {
+	case "", notifyNever, notifyAlways, notifyErrorsOnly:
+	default:
+		return fmt.Errorf("invalid notify.mode %q, expected one of: %s, %s, %s", c.Notify.Mode, notifyNever, notifyAlways, notifyErrorsOnly)
+	}
+	if c.LogNames != "" && !slices.Contains(logNameFormats, c.LogNames) {
+		return fmt.Errorf("invalid log_names %q, expected %s or %s", c.LogNames, logNamesDaily, logNamesRun)
+	}
+	if c.CategoryIndex != "" && !slices.Contains(categoryIndexFormats, c.CategoryIndex) {
+		return fmt.Errorf("invalid category_index %q, expected %s or %s", c.CategoryIndex, categoryIndexCSV, categoryIndexJSON)
+	}
+	switch c.SyncConflicts.Action {
+	case "", conflictActionReview, conflictActionQuarantine:
+	default:
+		return fmt.Errorf("invalid sync_conflicts.action %q, expected %s or %s", c.SyncConflicts.Action, conflictActionReview, conflictActionQuarantine)
+	}
+	for ext, category := range c.Categories {
+		if strings.Trim(ext, ".") == "" || !validCategory(category) {
+			return fmt.Errorf("invalid categories entry %q: %q, expected an extension and a folder name", ext, category)
+		}
+	}
+	if err := validateCategoryNames(c); err != nil {
+		return err
+	}
+	if err := validateNoExtension(c.NoExtension); err != nil {
+		return err
+	}
+	for _, ext := range c.CompoundExtensions {
+		if parts := strings.Split(strings.TrimPrefix(ext, "."), "."); len(parts) < 2 || slices.Contains(parts, "") || strings.ContainsAny(ext, `/\`) {
+			return fmt.Errorf("invalid compound_extensions entry %q, expected an extension of more than one part, such as .tar.gz", ext)
+		}
+	}
+	for ext, days := range c.DeleteAfterDays {
+		if strings.Trim(ext, ".") == "" || days <= 0 {
+			return fmt.Errorf("invalid delete_after_days entry %q: %d, expected an extension and a number of days", ext, days)
+		}
+	}
+	for ext, script := range c.DownloadActions {
+		if strings.Trim(ext, ".") == "" || script == "" {
+			return fmt.Errorf("invalid download_actions entry %q: %q, expected an extension and a script", ext, script)
+		}
+	}
+	if c.Quarantine.Enabled && c.Trash.Enabled {
+		return fmt.Errorf("quarantine.enabled and trash.enabled can't both be on, pick where deleted items go")
+	}
+	if c.DiskGuard.MaxUsedPercent > 100 {
+		return fmt.Errorf("invalid disk_guard.max_used_percent %d, expected a percentage", c.DiskGuard.MaxUsedPercent)
+	}
+	if usage := c.PostClean.BtrfsBalanceUsage; usage < 0 || usage > 100 {
+		return fmt.Errorf("invalid post_clean.btrfs_balance_usage %d, expected a percentage", usage)
+	}
+	if port := c.Notify.Email.SMTPPort; port < 0 || port > 65535 {
+		return fmt.Errorf("invalid notify.email.smtp_port %d", port)
+	}
+	if file := c.Notify.Email.PasswordFile; file != "" && !filepath.IsAbs(file) {
+		return fmt.Errorf("invalid notify.email.password_file %q, expected an absolute path", file)
+	}
+
+	sizes := map[string]string{
+		"quarantine.budget":           c.Quarantine.Budget,
+		"docker.builder_cache_budget": c.Docker.BuilderCacheBudget,
+		"font_cache.budget":           c.FontCache.Budget,
+		"post_clean.min_freed":        c.PostClean.MinFreed,
+	}
+	for key, size := range sizes {
+		if size == "" {
+			continue
+		}
+		if _, err := parseSize(size); err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+
+	counts := map[string]int{
+		"idle_minutes":                c.IdleMinutes,
+		"max_scan_depth":              c.MaxScanDepth,
+		"max_entries_per_dir":         c.MaxEntriesPerDir,
+		"quarantine.max_age_days":     c.Quarantine.MaxAgeDays,
+		"retention.log_days":          c.Retention.LogDays,
+		"retention.history_days":      c.Retention.HistoryDays,
+		"docker.volume_max_age_days":  c.Docker.VolumeMaxAgeDays,
+		"docker.image_max_age_days":   c.Docker.ImageMaxAgeDays,
+		"vm.vagrant_box_max_age_days": c.VM.VagrantBoxMaxAgeDays,
+		"photos.max_distance":         c.Photos.MaxDistance,
+		"sync_conflicts.max_age_days": c.SyncConflicts.MaxAgeDays,
+		"backup_files.max_age_days":   c.BackupFiles.MaxAgeDays,
+		"git_repos.gc_after_days":     c.GitRepos.GCAfterDays,
+		"git_repos.large_ratio":       c.GitRepos.LargeRatio,
+		"git_repos.stale_months":      c.GitRepos.StaleMonths,
+		"mail.max_age_days":           c.Mail.MaxAgeDays,
+		"font_cache.max_age_days":     c.FontCache.MaxAgeDays,
+		"kube.cluster_max_age_days":   c.Kube.ClusterMaxAgeDays,
+		"post_clean.btrfs_scrub_days": c.PostClean.BtrfsScrubDays,
+		"disk_guard.max_used_percent": c.DiskGuard.MaxUsedPercent,
+		"snapshot.keep":               c.Snapshot.Keep,
+		"analytics.interval_days":     c.Analytics.IntervalDays,
+		"summary.max_items":           c.Summary.MaxItems,
+	}
+	for key, n := range counts {
+		if n < 0 {
+			return fmt.Errorf("invalid %s %d, expected 0 (the default) or more", key, n)
+		}
+	}
+
+	if c.HealthcheckURL != "" {
+		u, err := url.Parse(c.HealthcheckURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid healthcheck_url %q, expected an http(s) URL", c.HealthcheckURL)
+		}
+	}
+	return nil
+}
+
+func (app *App) cleanDownloads(config Config) error {
 	if _, err := os.Stat(app.downloadsDir); os.IsNotExist(err) {
 		log.Printf("Downloads directory does not exist: %s", app.downloadsDir)
 		return nil
 	}
+	if config.BrowserHistory {
+		app.origins = app.downloadOrigins()
+	}
+
+	app.renameCategoryFolders(config)
 
 	entries, err := os.ReadDir(app.downloadsDir)
 	if err != nil {
@@ -219,26 +915,96 @@ func (app *App) cleanDownloads() error {
 		}
 
 		filePath := filepath.Join(app.downloadsDir, entry.Name())
-		ext := strings.ToLower(filepath.Ext(entry.Name()))
-
-		// Delete temporary files
-		if app.isTempFile(ext) {
-			if err := os.Remove(filePath); err != nil {
-				log.Printf("Failed to delete temp file %s: %v", entry.Name(), err)
-				continue
-			}
-			app.summary.DeletedFiles = append(app.summary.DeletedFiles, entry.Name())
-		} else {
-			// Move to category folder
-			if err := app.moveToCategory(filePath, ext); err != nil {
-				log.Printf("Failed to move file %s: %v", entry.Name(), err)
-			}
+		info, err := entry.Info()
+		if err != nil {
+			continue
 		}
+		app.countSource(filePath, fileExt(config, entry.Name()), info)
+		kind, category := app.downloadKind(config, filePath, info)
+		app.handleDownload(config, filePath, kind, category)
+	}
+	app.expireCategories(config)
+
+	if config.CategoryIndex != "" {
+		app.writeCategoryIndexes(config.CategoryIndex)
 	}
 
 	return nil
 }
 
+// downloadKind is what a run does with a Downloads file.
+type downloadKind int
+
+const (
+	// downloadUntouched is a file strict mode leaves, as no rule names it
+	downloadUntouched downloadKind = iota
+	// downloadLeft is a file without an extension no_extension leaves
+	downloadLeft
+	downloadTemp
+	downloadExpired
+	downloadScripted
+	downloadFiled
+)
+
+// downloadKind returns what a run does with the Downloads file at path,
+// and the category of a file it files.
+func (app *App) downloadKind(config Config, path string, info os.FileInfo) (downloadKind, string) {
+	ext := fileExt(config, path)
+	switch {
+	// In strict mode, only files a rule names are touched
+	case config.Strict && !hasRule(config, ext):
+		return downloadUntouched, ""
+	case app.isTempFile(ext):
+		return downloadTemp, ""
+	case app.expired(config, ext, path, info):
+		return downloadExpired, ""
+	}
+	if _, ok := extLookup(config.DownloadActions, ext); ok {
+		return downloadScripted, ""
+	}
+	if category := downloadCategory(config, path, ext, info); category != "" {
+		return downloadFiled, category
+	}
+	return downloadLeft, ""
+}
+
+// handleDownload does with the Downloads file at path what kind says,
+// filing it into category.
+func (app *App) handleDownload(config Config, path string, kind downloadKind, category string) {
+	name := filepath.Base(path)
+	ext := fileExt(config, name)
+	switch kind {
+	case downloadUntouched:
+		app.addItem(&app.summary.UntouchedFiles, name)
+	case downloadLeft:
+		app.addItem(&app.summary.LeftFiles, name)
+	case downloadTemp:
+		if err := app.remove(path); err != nil {
+			app.skipItem("Failed to delete temp file", path, err)
+			return
+		}
+		app.addGroupedItem(&app.summary.DeletedFiles, "*"+ext, name)
+	case downloadExpired:
+		app.expire(path)
+	case downloadScripted:
+		script, _ := extLookup(config.DownloadActions, ext)
+		if err := app.runAction(script, path); err != nil {
+			app.skipItem("Failed to run action", path, err)
+		}
+	case downloadFiled:
+		// Moved to its category folder, named after its content if it's a
+		// PDF with a name that says nothing
+		if config.RenameDocuments && ext == ".pdf" {
+			if renamed := documentName(path); renamed != "" {
+				name = renamed
+			}
+		}
+		if err := app.moveToCategory(path, category, name, fileExt(config, name)); err != nil {
+			app.skipItem("Failed to move file", path, err)
+		}
+	}
+}
+
 func (app *App) isTempFile(ext string) bool {
 	tempExts := []string{".tmp", ".part", ".crdownload", ".download"}
 	for _, tempExt := range tempExts {
@@ -249,123 +1015,133 @@ func (app *App) isTempFile(ext string) bool {
 	return false
 }
 
-func (app *App) moveToCategory(filePath, ext string) error {
-	categories := map[string][]string{
-		"Documents":  {".pdf", ".txt", ".docx", ".doc", ".rtf", ".odt", ".pages"},
-		"Images":     {".png", ".jpg", ".jpeg", ".gif", ".bmp", ".svg", ".webp", ".tiff"},
-		"Videos":     {".mp4", ".mkv", ".avi", ".mov", ".wmv", ".flv", ".webm", ".m4v"},
-		"Audio":      {".mp3", ".wav", ".flac", ".aac", ".ogg", ".wma", ".m4a"},
-		"Archives":   {".zip", ".tar", ".gz", ".rar", ".7z", ".bz2", ".xz", ".tar.gz"},
-		"Installers": {".deb", ".rpm", ".dmg", ".exe", ".msi", ".appimage", ".sh", ".pkg"},
-		"Code":       {".py", ".js", ".go", ".java", ".cpp", ".c", ".html", ".css", ".json"},
+// moveToCategory files a Downloads file into the category folder, under
+// fileName, which is its own name unless it's being renamed, with the
+// extension ext.
+func (app *App) moveToCategory(filePath, category, fileName, ext string) error {
+	if app.isProtected(filePath) {
+		return errProtected
 	}
-
-	category := "Others"
-	for cat, exts := range categories {
-		for _, x := range exts {
-			if x == ext {
-				category = cat
-				break
-			}
-		}
-		if category != "Others" {
-			break
-		}
+	item := filepath.Base(filePath)
+	if fileName != item {
+		item += " → " + fileName
+	}
+	folder := categoryFolder(app.categoryNames, category)
+	destDir := filepath.Join(app.downloadsDir, folder)
+	if app.dryRun {
+		app.addGroupedItem(&app.summary.MovedFiles, folder, item+" → "+folder)
+		app.countCategory(category)
+		return nil
 	}
 
-	destDir := filepath.Join(app.downloadsDir, category)
 	if err := os.MkdirAll(destDir, 0755); err != nil {
 		return fmt.Errorf("failed to create category directory: %w", err)
 	}
 
-	fileName := filepath.Base(filePath)
-	dest := filepath.Join(destDir, fileName)
-
-	// Handle duplicate filenames
-	counter := 1
-	for {
-		if _, err := os.Stat(dest); os.IsNotExist(err) {
-			break
-		}
+	// Handle duplicate filenames, including the same name composed
+	// differently (see composeName)
+	dest := filepath.Join(destDir, app.freeName(destDir, fileName, ext))
 
-		base := strings.TrimSuffix(fileName, filepath.Ext(fileName))
-		ext := filepath.Ext(fileName)
-		dest = filepath.Join(destDir, fmt.Sprintf("%s_%d%s", base, counter, ext))
-		counter++
+	info, err := os.Lstat(filePath)
+	if err != nil {
+		return err
 	}
-
-	if err := os.Rename(filePath, dest); err != nil {
+	move := journalEntry{Op: opMove, Path: filePath, Dest: dest}
+	if err := app.journaled(move, func() error { return os.Rename(filePath, dest) }); err != nil {
 		return fmt.Errorf("failed to move file: %w", err)
 	}
+	app.recordMove(filePath, dest, info)
 
-	app.summary.MovedFiles = append(app.summary.MovedFiles, fileName)
+	app.addGroupedItem(&app.summary.MovedFiles, folder, item)
+	app.countCategory(category)
 	return nil
 }
 
-func (app *App) cleanOldNodeModules() error {
-	cutoff := time.Now().AddDate(0, 0, -nodeModulesMaxAge)
-
-	err := filepath.WalkDir(app.homeDir, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			// Skip directories we can't read
-			return nil
-		}
+func (app *App) countCategory(category string) {
+	if app.summary.Categories == nil {
+		app.summary.Categories = make(map[string]int)
+	}
+	app.summary.Categories[category]++
+}
 
-		if d.IsDir() && d.Name() == "node_modules" {
-			info, err := os.Stat(path)
-			if err != nil {
-				return nil
-			}
+func (app *App) cleanOldNodeModules(config Config) error {
+	cutoff := app.ageCutoff(0, nodeModulesMaxAge)
 
-			if info.ModTime().Before(cutoff) {
-				if err := os.RemoveAll(path); err != nil {
-					log.Printf("Failed to remove node_modules at %s: %v", path, err)
-				} else {
-					app.summary.RemovedModules = append(app.summary.RemovedModules, path)
-				}
-			}
+	if err := app.scanHome(config); err != nil {
+		return fmt.Errorf("error scanning for node_modules: %w", err)
+	}
 
-			return filepath.SkipDir // Don't descend into node_modules
+	for _, path := range app.scan.nodeModules {
+		info, err := os.Stat(path)
+		if err != nil || !app.fileTime(path, info).Before(cutoff) {
+			continue
+		}
+		if err := app.removeAll(path); err != nil {
+			app.skipItem("Failed to remove node_modules at", path, err)
+		} else {
+			app.addItem(&app.summary.RemovedModules, path)
 		}
-
-		return nil
-	})
-
-	if err != nil {
-		return fmt.Errorf("error scanning for node_modules: %w", err)
 	}
 
 	return nil
 }
 
-func (app *App) installSystemdService() error {
+func (app *App) installSystemdService(config Config) error {
 	if err := os.MkdirAll(app.systemdUnitDir, 0755); err != nil {
 		return fmt.Errorf("failed to create systemd directory: %w", err)
 	}
 
-	// Install binary to ~/.local/bin/saafsafai
-	localBinDir := filepath.Join(app.homeDir, ".local", "bin")
-	if err := os.MkdirAll(localBinDir, 0755); err != nil {
-		return fmt.Errorf("failed to create local bin directory: %w", err)
-	}
-
-	execPath, err := os.Executable()
+	targetPath, packaged, err := app.binaryPath()
 	if err != nil {
-		return fmt.Errorf("failed to get executable path: %w", err)
+		return err
+	}
+	if !packaged {
+		// Install binary to ~/.local/bin/saafsafai (system: /usr/local/bin)
+		if err := os.MkdirAll(app.binDir, 0755); err != nil {
+			return fmt.Errorf("failed to create local bin directory: %w", err)
+		}
+		execPath, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to get executable path: %w", err)
+		}
+		if execPath != targetPath {
+			if err := app.copyFile(execPath, targetPath); err != nil {
+				return fmt.Errorf("failed to install binary: %w", err)
+			}
+			fmt.Println(T("setup.binary_installed", targetPath))
+		}
 	}
 
-	targetPath := filepath.Join(localBinDir, binaryName)
-
-	if execPath != targetPath {
-		if err := app.copyFile(execPath, targetPath); err != nil {
-			return fmt.Errorf("failed to install binary: %w", err)
+	// The sandbox only lets runs write inside directories that exist when
+	// the service starts
+	for _, dir := range []string{app.stateDir, app.logDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
 		}
-		fmt.Printf("✅ Installed binary to: %s\n", targetPath)
 	}
 
 	// Create systemd service file
 	serviceFile := filepath.Join(app.systemdUnitDir, serviceName)
-	serviceContent := fmt.Sprintf(`[Unit]
+	serviceContent := app.serviceContent(targetPath, config)
+
+	if err := os.WriteFile(serviceFile, []byte(serviceContent), 0644); err != nil {
+		return fmt.Errorf("failed to write systemd service file: %w", err)
+	}
+
+	if err := app.installSchedule(targetPath, config); err != nil {
+		return err
+	}
+
+	fmt.Println(T("setup.service_installed", serviceFile))
+	return nil
+}
+
+func (app *App) serviceContent(targetPath string, config Config) string {
+	if app.system {
+		return systemServiceContent(targetPath, app.sandbox(config))
+	}
+
+	return fmt.Sprintf(`[Unit]
 Description=Saafsafai Cleanup Service
 After=default.target
 
@@ -373,29 +1149,40 @@ After=default.target
 Type=oneshot
 ExecStart=%s
 Environment=HOME=%s
-
+SuccessExitStatus=3
+%s
 [Install]
 WantedBy=default.target
-`, targetPath, app.homeDir)
+`, targetPath, app.homeDir, app.sandbox(config))
+}
 
-	if err := os.WriteFile(serviceFile, []byte(serviceContent), 0644); err != nil {
-		return fmt.Errorf("failed to write systemd service file: %w", err)
+// sandbox returns the unit's sandboxing directives: the file system is
+// read-only except for the directories the enabled cleaners work in, so a
+// bug in one can't damage anything outside its scope. Missing directories
+// are allowed ("-") since most cleaners' directories are optional.
+func (app *App) sandbox(config Config) string {
+	var paths []string
+	for _, path := range app.writablePaths(config) {
+		entry := "-" + path
+		if strings.ContainsAny(path, " \t\"") {
+			entry = strconv.Quote(entry)
+		}
+		paths = append(paths, entry)
 	}
 
-	// Enable and start the service
-	commands := [][]string{
-		{"systemctl", "--user", "daemon-reload"},
-		{"systemctl", "--user", "enable", serviceName},
-	}
+	return fmt.Sprintf(`NoNewPrivileges=yes
+PrivateTmp=yes
+ProtectSystem=strict
+ReadWritePaths=%s
+`, strings.Join(paths, " "))
+}
 
-	for _, cmd := range commands {
-		if err := exec.Command(cmd[0], cmd[1:]...).Run(); err != nil {
-			log.Printf("Warning: Failed to run %v: %v", cmd, err)
-		}
+// systemctl builds a systemctl command line for the user or system manager.
+func (app *App) systemctl(args ...string) []string {
+	if app.system {
+		return append([]string{"systemctl"}, args...)
 	}
-
-	fmt.Printf("✅ Systemd service installed: %s\n", serviceFile)
-	return nil
+	return append([]string{"systemctl", "--user"}, args...)
 }
 
 func (app *App) copyFile(src, dst string) error {
@@ -419,65 +1206,265 @@ func (app *App) copyFile(src, dst string) error {
 	return os.Chmod(dst, 0755)
 }
 
-func (app *App) printSummary() error {
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	var lines []string
+// logError logs a per-item failure and records it in the summary, so a run
+// that partly failed can be reported as such.
+func (app *App) logError(format string, args ...any) {
+	msg := displayName(fmt.Sprintf(format, args...))
+	log.Print(msg)
+	app.addItem(&app.summary.Errors, msg)
+}
 
-	lines = append(lines, fmt.Sprintf("🧹 Saafsafai Cleanup Report — %s", timestamp))
-	lines = append(lines, "")
+// remove deletes a single file unless the app is in dry-run mode.
+func (app *App) remove(path string) error {
+	return app.removeWith(path, os.Remove)
+}
+
+// removeAll deletes a directory tree unless the app is in dry-run mode.
+func (app *App) removeAll(path string) error {
+	return app.removeWith(path, app.removeTree)
+}
 
-	if len(app.summary.DeletedFiles) > 0 {
-		lines = append(lines, "🗑️ Deleted temp files:")
-		for _, f := range app.summary.DeletedFiles {
-			lines = append(lines, "   - "+f)
+// removeWith removes path with remove, journaled, and counts the space it
+// frees.
+func (app *App) removeWith(path string, remove func(string) error) error {
+	if app.isProtected(path) {
+		return errProtected
+	}
+	if app.estimating {
+		app.summary.FreedBytes += app.estimateSize(path)
+		return nil
+	}
+	var files []diskFile
+	if !app.quarantine && !app.trashConfig.Enabled {
+		files = diskFiles(path)
+	}
+	if !app.dryRun {
+		if err := app.journaled(journalEntry{Op: opRemove, Path: path}, func() error { return app.discard(path, remove) }); err != nil {
+			return err
 		}
-		lines = append(lines, "")
 	}
+	app.countFreed(files)
+	return nil
+}
 
-	if len(app.summary.MovedFiles) > 0 {
-		lines = append(lines, "📁 Moved files to category folders:")
-		for _, f := range app.summary.MovedFiles {
-			lines = append(lines, "   - "+f)
-		}
-		lines = append(lines, "")
+type summarySection struct {
+	title      string
+	dryRunText string
+	list       *itemList
+}
+
+func (app *App) summarySections() []summarySection {
+	return []summarySection{
+		{T("section.deleted_files"), T("section.deleted_files.dry_run"), &app.summary.DeletedFiles},
+		{T("section.renamed_categories"), T("section.renamed_categories.dry_run"), &app.summary.RenamedCategories},
+		{T("section.moved_files"), T("section.moved_files.dry_run"), &app.summary.MovedFiles},
+		{T("section.unclaimed"), T("section.unclaimed.dry_run"), &app.summary.QuarantinedDownloads},
+		{T("section.expired"), T("section.expired.dry_run"), &app.summary.ExpiredFiles},
+		{T("section.script_actions"), T("section.script_actions.dry_run"), &app.summary.ScriptActions},
+		{T("section.removed_modules"), T("section.removed_modules.dry_run"), &app.summary.RemovedModules},
+		{T("section.wine_prefixes"), T("section.wine_prefixes.dry_run"), &app.summary.RemovedWinePrefixes},
+		{T("section.appimages"), T("section.appimages.dry_run"), &app.summary.RemovedAppImages},
+		{T("section.mail"), T("section.mail.dry_run"), &app.summary.MailAttachments},
+		{T("section.sync_conflicts"), T("section.sync_conflicts.dry_run"), &app.summary.QuarantinedConflicts},
+		{T("section.backup_files"), T("section.backup_files.dry_run"), &app.summary.RemovedBackups},
+		{T("section.metadata_files"), T("section.metadata_files.dry_run"), &app.summary.RemovedMetadata},
+		{T("section.git_repos"), T("section.git_repos.dry_run"), &app.summary.TidiedRepos},
+		{T("section.archived_repos"), T("section.archived_repos"), &app.summary.ArchivedRepos},
+		{T("section.removed_clones"), T("section.removed_clones"), &app.summary.RemovedClones},
+		{T("section.photos"), T("section.photos.dry_run"), &app.summary.RemovedPhotos},
+		{T("section.font_caches"), T("section.font_caches.dry_run"), &app.summary.RebuiltCaches},
+		{T("section.package_caches"), T("section.package_caches.dry_run"), &app.summary.PackageCaches},
+		{T("section.removed_packages"), T("section.removed_packages.dry_run"), &app.summary.RemovedPackages},
+		{T("section.removed_kernels"), T("section.removed_kernels.dry_run"), &app.summary.RemovedKernels},
+		{T("section.docker"), T("section.docker.dry_run"), &app.summary.DockerItems},
+		{T("section.vm_images"), T("section.vm_images.dry_run"), &app.summary.RemovedVMImages},
+		{T("section.kube"), T("section.kube.dry_run"), &app.summary.KubeItems},
+		{T("section.removable"), T("section.removable.dry_run"), &app.summary.MediaItems},
+		{T("section.users"), T("section.users.dry_run"), &app.summary.CleanedUsers},
+		{T("section.recovered"), T("section.recovered"), &app.summary.Recovered},
 	}
+}
+
+// reportSections lists findings that were reported but not acted on; they
+// don't count as cleaned items.
+func (app *App) reportSections() []summarySection {
+	return []summarySection{
+		{T("section.snapshots"), T("section.snapshots"), &app.summary.Snapshots},
+		{T("section.vm_image_candidates"), T("section.vm_image_candidates.dry_run"), &app.summary.VMImageCandidates},
+		{T("section.untouched"), T("section.untouched"), &app.summary.UntouchedFiles},
+		{T("section.left"), T("section.left"), &app.summary.LeftFiles},
+		{T("section.large_repos"), T("section.large_repos"), &app.summary.LargeRepos},
+		{T("section.stale_repos"), T("section.stale_repos"), &app.summary.StaleRepos},
+		{T("section.stale_branches"), T("section.stale_branches"), &app.summary.StaleBranches},
+		{T("section.duplicate_repos"), T("section.duplicate_repos"), &app.summary.DuplicateRepos},
+		{T("section.protected"), T("section.protected"), &app.summary.ProtectedItems},
+		{T("section.duplicate_photos"), T("section.duplicate_photos"), &app.summary.DuplicatePhotos},
+		{T("section.sync_conflict_review"), T("section.sync_conflict_review"), &app.summary.SyncConflicts},
+		{T("section.post_clean"), T("section.post_clean"), &app.summary.PostCleanHooks},
+	}
+}
+
+// itemCount returns how many items the run cleaned (or would clean).
+func (app *App) itemCount() int {
+	return countItems(app.summarySections())
+}
+
+// foundCount is itemCount plus the findings that were only reported.
+func (app *App) foundCount() int {
+	return app.itemCount() + countItems(app.reportSections())
+}
+
+func countItems(sections []summarySection) int {
+	total := 0
+	for _, section := range sections {
+		total += section.list.Count
+	}
+	return total
+}
+
+// summaryText is the run's summary as the logs, reports and notifications
+// get it.
+func (app *App) summaryText() string {
+	return app.renderSummary(plain)
+}
+
+// renderSummary writes out the run's summary, styled by p.
+func (app *App) renderSummary(p painter) string {
+	timestamp := time.Now().Format("2006-01-02 15:04:05 -0700")
+	var lines []string
 
-	if len(app.summary.RemovedModules) > 0 {
-		lines = append(lines, "📦 Deleted old node_modules folders:")
-		for _, m := range app.summary.RemovedModules {
-			lines = append(lines, "   - "+m)
+	title := T("summary.title", timestamp)
+	if app.dryRun {
+		title += T("summary.dry_run_suffix")
+	}
+	lines = append(lines, p.paint(styleBold, title))
+	if app.summary.RunID != "" {
+		lines = append(lines, T("summary.run_id", app.summary.RunID))
+	}
+	if app.summary.Report != "" {
+		lines = append(lines, T("summary.report", app.displayPath(app.summary.Report)))
+	}
+	lines = append(lines, "")
+
+	for _, section := range append(app.summarySections(), app.reportSections()...) {
+		if section.list.Count == 0 {
+			continue
 		}
+		if app.dryRun {
+			lines = append(lines, p.paint(styleBold, section.dryRunText))
+		} else {
+			lines = append(lines, p.paint(styleBold, section.title))
+		}
+		lines = append(lines, app.listLines(p, section.list)...)
 		lines = append(lines, "")
 	}
 
-	totalItems := len(app.summary.DeletedFiles) + len(app.summary.MovedFiles) + len(app.summary.RemovedModules)
+	if app.verbose {
+		lines = append(lines, app.timingLines()...)
+	}
+
+	totalItems := app.itemCount()
+
 	if totalItems == 0 {
-		lines = append(lines, "📭 Nothing to clean today.")
+		lines = append(lines, T("summary.nothing"))
+	} else if app.dryRun {
+		lines = append(lines, p.paint(styleGreen, T("summary.found_dry_run", totalItems)))
 	} else {
-		lines = append(lines, fmt.Sprintf("✨ Cleaned up %d items total.", totalItems))
+		lines = append(lines, p.paint(styleGreen, T("summary.cleaned", totalItems)))
+	}
+	if app.summary.FreedBytes > 0 {
+		if app.dryRun {
+			lines = append(lines, p.paint(styleGreen, T("summary.freed_dry_run", formatSize(app.summary.FreedBytes))))
+		} else {
+			lines = append(lines, p.paint(styleGreen, T("summary.freed", formatSize(app.summary.FreedBytes))))
+		}
 	}
 
-	logText := strings.Join(lines, "\n")
+	if app.summary.Skipped.Count > 0 {
+		lines = append(lines, "")
+		lines = append(lines, p.paint(styleBold, T("summary.skipped", app.summary.Skipped.Count, app.skipReasons())))
+		lines = append(lines, app.listLines(p, &app.summary.Skipped)...)
+	}
 
-	// Ensure log directory exists
-	if err := os.MkdirAll(app.logDir, 0755); err != nil {
-		return fmt.Errorf("failed to create log directory: %w", err)
+	if app.summary.Errors.Count > 0 {
+		lines = append(lines, "")
+		lines = append(lines, p.paint(styleRed, T("summary.errors", app.summary.Errors.Count)))
+		lines = append(lines, app.listLines(p, &app.summary.Errors)...)
 	}
 
-	// Write to daily log file
-	logFile := filepath.Join(app.logDir, time.Now().Format("2006-01-02")+".log")
-	if err := os.WriteFile(logFile, []byte(logText+"\n"), 0644); err != nil {
-		return fmt.Errorf("failed to write log file: %w", err)
+	return strings.Join(lines, "\n")
+}
+
+func (app *App) printSummary() error {
+	logText := app.summaryText()
+
+	// A dry run never overwrites the real daily log
+	if !app.dryRun {
+		// Ensure log directory exists
+		if err := os.MkdirAll(app.logDir, 0755); err != nil {
+			return fmt.Errorf("failed to create log directory: %w", err)
+		}
+
+		logFile := app.logFilePath(time.Now())
+		if err := os.WriteFile(logFile, []byte(logText+"\n"), 0644); err != nil {
+			return fmt.Errorf("failed to write log file: %w", err)
+		}
+		app.linkLatestLog(logFile)
+
+		// The day's log is replaced by each run; the run's own stays
+		if app.summary.Report != "" {
+			if err := os.MkdirAll(filepath.Dir(app.summary.Report), 0755); err != nil {
+				return fmt.Errorf("failed to create log directory: %w", err)
+			}
+			if err := app.writeReport(logText); err != nil {
+				return fmt.Errorf("failed to write run log: %w", err)
+			}
+		}
 	}
 
-	// Print to stdout if running interactively
-	if app.isInteractive() {
+	switch {
+	case app.jsonOutput:
+		app.summary.markTruncated()
+		app.summary.markGroups()
+		data, err := json.MarshalIndent(app.summary, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal summary: %w", err)
+		}
+		fmt.Println(string(data))
+	case app.dryRun || app.isInteractive():
+		// Print to stdout if running interactively
+		if app.color {
+			logText = app.renderSummary(app.color)
+		}
 		fmt.Println(logText)
 	}
 
 	return nil
 }
 
+// writeReport writes the run's own log: the summary, followed by every item
+// of the lists the summary cut short.
+func (app *App) writeReport(logText string) error {
+	f, err := os.Create(app.summary.Report)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, logText)
+	if err := app.writeFullLists(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// isInteractive reports whether someone is at a terminal to answer prompts
+// and read the summary: whether stdin and stdout both are one, which they
+// aren't under cron, systemd or a pipe.
 func (app *App) isInteractive() bool {
-	return os.Getenv("TERM") != "" && (os.Getenv("DISPLAY") != "" || os.Getenv("SSH_CLIENT") != "")
+	return !app.unattended && isTerminal(os.Stdin) && isTerminal(os.Stdout)
 }
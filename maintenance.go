@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	logRetentionDays     = 90
+	historyRetentionDays = 365
+)
+
+type RetentionConfig struct {
+	// LogDays keeps the daily logs this many days (default 90).
+	LogDays int `json:"log_days"`
+	// HistoryDays keeps history database entries this many days (default
+	// 365); older runs drop out of "stats diff".
+	HistoryDays int `json:"history_days"`
+}
+
+// retentionCutoff returns the time before which data kept for days (or def,
+// if days isn't set) is pruned.
+func retentionCutoff(days, def int) time.Time {
+	if days <= 0 {
+		days = def
+	}
+	return time.Now().AddDate(0, 0, -days)
+}
+
+// maintain prunes saafsafai's own logs, history and quarantine past their
+// retention, and the quarantine past its budget. It runs after every other cleaner, whatever the config.
+func (app *App) maintain(config Config) error {
+	if app.dryRun {
+		return nil
+	}
+
+	if err := app.pruneLogs(retentionCutoff(config.Retention.LogDays, logRetentionDays)); err != nil {
+		app.logError("Failed to prune old logs: %v", err)
+	}
+	if err := app.pruneHistory(retentionCutoff(config.Retention.HistoryDays, historyRetentionDays)); err != nil {
+		app.logError("Failed to prune the history database: %v", err)
+	}
+	budgetSize := config.Quarantine.Budget
+	if budgetSize == "" {
+		budgetSize = quarantineBudget
+	}
+	budget, err := app.sizeBudget(budgetSize)
+	if err != nil {
+		app.logError("Invalid quarantine budget: %v", err)
+		budget = math.MaxInt64
+	}
+	if err := app.pruneQuarantine(retentionCutoff(config.Quarantine.MaxAgeDays, quarantineMaxAge), budget); err != nil {
+		app.logError("Failed to prune the quarantine: %v", err)
+	}
+	return nil
+}
+
+// pruneLogs removes the daily and run logs from before cutoff.
+func (app *App) pruneLogs(cutoff time.Time) error {
+	files, err := app.logFiles()
+	if err != nil {
+		return err
+	}
+
+	pruned := 0
+	for _, file := range files {
+		day, ok := logDay(file)
+		if !ok || !day.Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(file); err != nil {
+			app.skipItem("Failed to remove old log", file, err)
+			continue
+		}
+		pruned++
+	}
+
+	// Each run's own report, kept as long as the day's log
+	reports, err := filepath.Glob(filepath.Join(app.logDir, runLogDirName, "*.log"))
+	if err != nil {
+		return err
+	}
+	for _, report := range reports {
+		info, err := os.Stat(report)
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(report); err != nil {
+			app.skipItem("Failed to remove old log", report, err)
+			continue
+		}
+		pruned++
+	}
+
+	if pruned > 0 {
+		log.Printf("Pruned %d old logs", pruned)
+	}
+	return nil
+}
+
+// pruneHistory drops the runs recorded before cutoff from the history
+// database.
+func (app *App) pruneHistory(cutoff time.Time) error {
+	records, err := app.loadHistory()
+	if err != nil {
+		return err
+	}
+
+	var keep []runRecord
+	for _, record := range records {
+		if !record.Time.Before(cutoff) {
+			keep = append(keep, record)
+		}
+	}
+	if len(keep) == len(records) {
+		return nil
+	}
+
+	if err := writeJSONLines(filepath.Join(app.stateDir, historyFileName), keep, 0644); err != nil {
+		return err
+	}
+	log.Printf("Pruned %d old runs from the history database", len(records)-len(keep))
+	return nil
+}
+
+// writeJSONLines replaces path with one JSON line per record, through a
+// temporary file so a crash never leaves it half written.
+func writeJSONLines[T any](path string, records []T, perm os.FileMode) error {
+	var data []byte
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	if err := os.WriteFile(path+".tmp", data, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	return os.Rename(path+".tmp", path)
+}
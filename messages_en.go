@@ -0,0 +1,447 @@
+package main
+
+var messagesEN = map[string]string{
+	"answer.yes":    "y yes",
+	"prompt.yes_no": "%s (y/n): ",
+
+	"summary.title":             "🧹 Saafsafai Cleanup Report — %s",
+	"summary.dry_run_suffix":    " (dry run)",
+	"summary.run_id":            "🔖 Run ID: %s",
+	"summary.report":            "📄 Full report: %s",
+	"summary.nothing":           "📭 Nothing to clean today.",
+	"summary.found_dry_run":     "🔍 Found %d items to clean (dry run, nothing changed).",
+	"summary.cleaned":           "✨ Cleaned up %d items total.",
+	"summary.freed":             "💾 Freed %s of disk space.",
+	"summary.freed_dry_run":     "💾 Would free %s of disk space.",
+	"summary.timings":           "⏱️ Timings:",
+	"summary.more_items":        "… and %d more",
+	"summary.merged":            "(merged)",
+	"summary.group":             "%s (%d):",
+	"summary.all_listed":        "All %d are listed in %s",
+	"summary.more_items_report": "… and %d more, all listed in %s",
+	"summary.full_lists":        "📋 Full lists:",
+	"summary.skipped":           "⏭️ Skipped %d items (%s), see the log for details:",
+	"summary.skipped_item":      "Skipped %s",
+	"summary.errors":            "⚠️ %d errors:",
+
+	"section.deleted_files":               "🗑️ Deleted temp files:",
+	"section.deleted_files.dry_run":       "🗑️ Would delete temp files:",
+	"section.renamed_categories":          "🏷️ Renamed category folders:",
+	"section.renamed_categories.dry_run":  "🏷️ Would rename category folders:",
+	"section.unclaimed":                   "🔒 Quarantined downloads no category claims:",
+	"section.unclaimed.dry_run":           "🔒 Would quarantine downloads no category claims:",
+	"section.moved_files":                 "📁 Moved files to category folders:",
+	"section.moved_files.dry_run":         "📁 Would move files to category folders:",
+	"section.expired":                     "⌛ Removed expired downloads:",
+	"section.expired.dry_run":             "⌛ Would remove expired downloads:",
+	"section.script_actions":              "⚙️ Ran download actions:",
+	"section.script_actions.dry_run":      "⚙️ Would run download actions:",
+	"section.removed_modules":             "📦 Deleted old node_modules folders:",
+	"section.removed_modules.dry_run":     "📦 Would delete old node_modules folders:",
+	"section.wine_prefixes":               "🍷 Removed unused Wine prefixes:",
+	"section.wine_prefixes.dry_run":       "🍷 Would remove unused Wine prefixes:",
+	"section.appimages":                   "💿 Removed old AppImage versions:",
+	"section.appimages.dry_run":           "💿 Would remove old AppImage versions:",
+	"section.mail":                        "📎 Removed old mail attachments:",
+	"section.mail.dry_run":                "📎 Would remove old mail attachments:",
+	"section.sync_conflicts":              "🔀 Quarantined old sync conflict copies:",
+	"section.sync_conflicts.dry_run":      "🔀 Would quarantine old sync conflict copies:",
+	"section.backup_files":                "🩹 Removed old backup and swap files:",
+	"section.backup_files.dry_run":        "🩹 Would remove old backup and swap files:",
+	"section.metadata_files":              "🍂 Removed .DS_Store, Thumbs.db and other metadata files:",
+	"section.metadata_files.dry_run":      "🍂 Would remove .DS_Store, Thumbs.db and other metadata files:",
+	"section.git_repos":                   "🌿 Tidied git repositories:",
+	"section.git_repos.dry_run":           "🌿 Would tidy git repositories:",
+	"section.snapshots":                   "📸 Snapshots taken before the run:",
+	"section.post_clean":                  "🪄 Post-clean hooks run:",
+	"section.large_repos":                 "🐘 Git repositories whose history is much bigger than their files:",
+	"section.archived_repos":              "🗄️ Archived git clones and removed worktrees:",
+	"section.stale_repos":                 "🗄️ Git clones and worktrees untouched for months, candidates for archival:",
+	"section.stale_branches":              "🪵 Git branches without commits for months:",
+	"section.removed_clones":              "👯 Removed duplicate git clones:",
+	"section.duplicate_repos":             "👯 Git repositories cloned more than once:",
+	"section.photos":                      "🖼️ Removed duplicate photos:",
+	"section.photos.dry_run":              "🖼️ Would offer to remove duplicate photos:",
+	"section.font_caches":                 "🔤 Rebuilt font and icon caches:",
+	"section.font_caches.dry_run":         "🔤 Would rebuild font and icon caches:",
+	"section.package_caches":              "🧰 Cleaned package caches:",
+	"section.package_caches.dry_run":      "🧰 Would clean package caches:",
+	"section.removed_packages":            "📤 Removed orphaned packages:",
+	"section.removed_packages.dry_run":    "📤 Would remove orphaned packages:",
+	"section.removed_kernels":             "🐧 Removed old kernels:",
+	"section.removed_kernels.dry_run":     "🐧 Would remove old kernels:",
+	"section.docker":                      "🐳 Cleaned Docker resources:",
+	"section.docker.dry_run":              "🐳 Would clean Docker resources:",
+	"section.vm_images":                   "💽 Removed VM disk images:",
+	"section.vm_images.dry_run":           "💽 Would offer to remove VM disk images:",
+	"section.kube":                        "☸️ Removed stale local Kubernetes clusters:",
+	"section.kube.dry_run":                "☸️ Would remove stale local Kubernetes clusters:",
+	"section.removable":                   "💽 Tidied removable media:",
+	"section.removable.dry_run":           "💽 Would tidy removable media:",
+	"section.users":                       "👥 Ran cleanup for users:",
+	"section.users.dry_run":               "👥 Would run cleanup for users:",
+	"section.recovered":                   "♻️ Finished from an interrupted run:",
+	"section.vm_image_candidates":         "💽 Unused VM disk images (run saafsafai from a terminal to remove):",
+	"section.vm_image_candidates.dry_run": "💽 Unused VM disk images:",
+	"section.untouched":                   "🔒 Left in Downloads (strict mode, no rule names them):",
+	"section.left":                        "🔒 Left in Downloads (no extension: scripts, programs, or all with no_extension leave):",
+	"section.protected":                   "🛡️ Left alone (protected by the system policy):",
+	"section.duplicate_photos":            "🖼️ Duplicate photos (run saafsafai from a terminal to review):",
+	"section.sync_conflict_review":        "🔀 Old sync conflict copies to review:",
+
+	"item.recovered_removal": "removed %s",
+	"item.recovered_move":    "moved %s → %s",
+	"item.duplicate_photos":  "keep %s; duplicates: %s",
+	"item.stale_repo":        "%s (last commit %s, %s)",
+	"item.stale_worktree":    "%s (worktree of %s, last commit %s)",
+	"item.stale_branches":    "%s: %s",
+	"item.duplicate_repos":   "%s: %s",
+
+	"confirm.remove":            "Remove %s?",
+	"confirm.remove_kernels":    "Remove old kernels %s?",
+	"confirm.remove_duplicates": "Remove %s, duplicates of %s?",
+	"confirm.archive_repo":      "Archive %s, untouched since %s, into %s and remove it?",
+	"confirm.remove_worktree":   "Remove the worktree %s, untouched since %s? Its commits stay in %s",
+	"confirm.remove_clone":      "Remove %s (%s), a clone of the same repository as %s?",
+
+	"setup.welcome":             "⚙️  Welcome to saafsafai setup!",
+	"setup.clean_downloads":     "Do you want to clean the Downloads folder?",
+	"setup.delete_node_modules": "Do you want to delete unused node_modules folders (30+ days old)?",
+	"setup.wine_prefixes":       "Do you want to remove unused Wine prefixes (90+ days without use)?",
+	"setup.appimages":           "Do you want to remove older versions of duplicate AppImages?",
+	"setup.mail_attachments":    "Do you want to remove old Thunderbird and Evolution attachment files?",
+	"setup.sync_conflicts":      "Do you want to gather old Syncthing, Dropbox and Nextcloud conflict copies for review?",
+	"setup.backup_files":        "Do you want to remove old editor backup files (file~, .bak, .orig, .rej) and stale swap files?",
+	"setup.metadata_files":      "Do you want to remove macOS and Windows metadata files (.DS_Store, ._*, Thumbs.db, desktop.ini)?",
+	"setup.git_repos":           "Do you want to run git's housekeeping (reflog expiry, git gc --auto) in your repositories now and then?",
+	"setup.duplicate_photos":    "Do you want to look for duplicate photos in Downloads and Pictures (removal always asks first)?",
+	"setup.font_caches":         "Do you want to rebuild stale or oversized font and icon caches?",
+	"setup.package_cache_sudo":  "Do you want to clean system package caches when run from a terminal (asks for sudo)?",
+	"setup.docker":              "Do you want to remove dangling Docker images and unused anonymous volumes (30+ days old)?",
+	"setup.kube":                "Do you want to delete kind/minikube/k3d clusters untouched for 30+ days?",
+	"setup.vm_images":           "Do you want to look for unused VM disk images and Vagrant boxes (removal always asks first)?",
+	"setup.complete":            "✅ Setup complete! saafsafai will run at each boot.",
+	"setup.complete_timer":      "✅ Setup complete! saafsafai will run on schedule: %s",
+	"setup.complete_both":       "✅ Setup complete! saafsafai will run at each boot and on schedule: %s",
+	"setup.gui_title":           "saafsafai setup",
+	"setup.gui_cleaner":         "What to clean",
+	"setup.run_on":              "When should saafsafai run: at login, from a timer, or both?",
+	"setup.schedule":            "Timer schedule (systemd calendar expression)",
+	"schedule.after_boot":       "%s after boot",
+	"setup.complete_no_systemd": "✅ Setup complete! No service was installed; run saafsafai from cron or a container scheduler.",
+	"setup.config_saved":        "📁 Config saved to: %s",
+	"setup.manual_run":          "🔧 To manually run: %s",
+	"setup.see_logs":            "📋 To see logs: ls %s",
+	"setup.binary_installed":    "✅ Installed binary to: %s",
+	"setup.service_installed":   "✅ Systemd service installed: %s",
+
+	"system_setup.welcome":         "⚙️  Welcome to saafsafai system setup!",
+	"system_setup.package_cache":   "Do you want to clean the package manager cache (apt/dnf/pacman)?",
+	"system_setup.orphans":         "Do you want to remove orphaned packages that nothing depends on?",
+	"system_setup.kernels":         "Do you want to remove old kernels (the newest two and the running one are always kept)?",
+	"system_setup.user_homes":      "Do you want to run each user's cleanup from this service (shared machines)?",
+	"system_setup.post_clean":      "Do you want to run fstrim after runs that free 1 GB or more, so SSDs and thin-provisioned VM disks get the space back?",
+	"system_setup.font_caches":     "Do you want to rebuild the system's stale or oversized font and icon caches?",
+	"system_setup.default_config":  "Do you want to set a default config for users who haven't run setup?",
+	"system_setup.default_heading": "Default configuration for users:",
+	"system_setup.default_saved":   "📁 Default user config saved to: %s",
+	"system_setup.complete_timer":  "✅ System setup complete! saafsafai will run as root on schedule: %s",
+	"system_setup.complete_both":   "✅ System setup complete! saafsafai will run as root at each boot and on schedule: %s",
+	"system_setup.complete":        "✅ System setup complete! saafsafai will run at each boot as root.",
+
+	"notify.subject":        "🧹 saafsafai cleanup report for %s",
+	"notify.errors_subject": "⚠️ saafsafai: %d errors on %s",
+	"notify.errors_intro":   "saafsafai hit %d errors on %s:",
+	"notify.full_report":    "Full report:",
+	"notify.undo":           "Undo",
+
+	"undo.subject":     "saafsafai undo",
+	"undo.done_notify": "Put back what run %s moved, quarantined or trashed.",
+	"undo.restored":    "↩️ Put back %s",
+	"undo.failed":      "❌ Couldn't put back %s: %v",
+	"undo.deleted":     "🗑️ %s was deleted for good",
+	"undo.total":       "%d items of run %s put back.",
+
+	"audit.none":              "No run has touched %s.",
+	"audit.origin":            "downloaded from %s on %s",
+	"similar.none":            "No similarly named files in Downloads.",
+	"similar.cluster":         "📑 %s: %d files, %s",
+	"similar.total":           "%d groups of similarly named files; newest first in each.",
+	"triage.none":             "The Others folder is empty: every file found a category.",
+	"triage.title":            "📦 %d files in Others, of %d types:",
+	"triage.unknown":          "unknown type",
+	"triage.sniffed":          "looks like %s",
+	"triage.from_terminal":    "Run saafsafai suggest --others from a terminal to pick their categories, or add the rules above to the config by hand.",
+	"triage.assign":           "File %s (%d files) into a category?",
+	"triage.move":             "Move the %d files already in Others into their new folders?",
+	"organize.planning":       "📋 Planning how to organize %s…",
+	"organize.nothing":        "Nothing to organize: there are no files in Downloads.",
+	"organize.plan":           "📋 Plan for %d files, %s (planned in %s):",
+	"organize.row_quarantine": "quarantine (no category)",
+	"organize.row_temp":       "deleted (unfinished downloads)",
+	"organize.row_expired":    "deleted (delete_after_days)",
+	"organize.row_scripted":   "download_actions scripts",
+	"organize.row_untouched":  "left in place (strict)",
+	"organize.row_left":       "left in place (no extension)",
+	"organize.quarantined":    "Files no category claims go into the quarantine: saafsafai restore brings any of them back, for %d days.",
+	"organize.over_budget":    "⚠️ They come to %s, more than the quarantine's budget of %s, so the oldest would be deleted early; raise quarantine.budget first to keep them all.",
+	"organize.confirm_hint":   "Nothing changed. Run with --yes, or from a terminal, to carry out the plan.",
+	"organize.confirm":        "Carry out the plan for %d files?",
+	"organize.progress":       "⏳ %d of %d files done, %s",
+	"organize.done":           "Organized %d items. To put everything back: saafsafai undo %s",
+	"organize.no_extension":   "no extension",
+	"find.none":               "No file matching %q was moved or removed by a run.",
+	"find.file":               "🔎 %s",
+	"find.moved":              "moved to %s",
+	"find.gone":               "(no longer there)",
+	"find.removed":            "deleted",
+	"find.trashed":            "moved to the trash",
+	"find.restored":           "put back by saafsafai undo",
+	"find.snapshot":           "a copy is in the snapshot at %s",
+	"find.quarantined":        "quarantined, restore with: saafsafai restore %s",
+	"find.quarantine_expired": "quarantined, since expired",
+	"find.script":             "handed to %s",
+	"find.total":              "%d files matched; best matches first.",
+	"suggest.too_few":         "Only %d runs recorded so far; suggestions need at least %d.",
+	"suggest.none":            "No suggestions: the rules fit what the runs have been filing.",
+	"suggest.deleted":         "You deleted %d of the %d %s files filed into %s. Delete them from Downloads automatically after %d days?",
+	"suggest.others":          "%d %s files ended up in Others. File them into %s instead?",
+	"suggest.accept":          "Add this rule?",
+	"suggest.folder":          "Category folder",
+	"suggest.days":            "Days",
+	"suggest.invalid_folder":  "%q can't be a category folder name, skipped.",
+	"suggest.invalid_days":    "%q isn't a number of days, skipped.",
+	"suggest.from_terminal":   "Run saafsafai suggest from a terminal to add them, or add them to the config by hand.",
+	"suggest.saved":           "✅ Added %d rules to %s.",
+
+	"reason.permission":   "permission denied",
+	"reason.cross_device": "cross-device move",
+	"reason.busy":         "in use",
+	"reason.vanished":     "already gone",
+	"reason.read_only":    "read-only file system",
+	"reason.no_space":     "no space left",
+	"reason.disk_guard":   "held back by disk_guard",
+	"reason.other":        "failed",
+
+	"service.no_drift":       "✅ The installed binary and units match the current config.",
+	"service.drift":          "🔧 Drift from what setup would install today:",
+	"service.binary_differs": "%s differs from this binary",
+	"service.unit_missing":   "%s is missing",
+	"service.unit_unused":    "%s is installed but not used by the schedule",
+	"service.unit_changed":   "%s has changed:",
+	"service.repaired":       "✅ Service repaired.",
+
+	"doctor.heading":             "🩺 Checking saafsafai's setup...",
+	"doctor.healthy":             "✅ No problems found.",
+	"doctor.config_ok":           "Config %s parses and validates",
+	"doctor.config_missing":      "No config at %s",
+	"doctor.config_invalid":      "The config is invalid: %v",
+	"doctor.fix_setup":           "Run %s to write one",
+	"doctor.fix_config":          "Fix %s; saafsafai config schema describes every key",
+	"doctor.config_secret":       "Config %s holds the SMTP password but others can read it",
+	"doctor.fix_secret":          "Move it into notify.email.password_file, or chmod 600 %s",
+	"doctor.downloads_ok":        "Downloads folder %s is there",
+	"doctor.downloads_missing":   "The Downloads folder %s doesn't exist, so clean_downloads does nothing",
+	"doctor.downloads_not_dir":   "The Downloads folder %s isn't a folder",
+	"doctor.fix_downloads":       "Create it (mkdir -p %s), or point downloads_dir at the right folder",
+	"doctor.not_writable":        "%s can't be written to (it's owned by %s)",
+	"doctor.noatime":             "%s is mounted noatime, so access times never change and the cleaners age_by sets to atime go by the modification time alone",
+	"doctor.fix_noatime":         "Remount %s with relatime (the default), or set those cleaners back to mtime",
+	"doctor.service_ok":          "%s installed and enabled",
+	"doctor.unit_missing":        "%s isn't installed, so nothing runs saafsafai",
+	"doctor.unit_disabled":       "%s is %s, so it won't run",
+	"doctor.fix_service":         "Run %s",
+	"doctor.systemd_unreachable": "Couldn't ask systemd about the service: %v",
+	"doctor.unit_failed":         "%s failed the last time it ran",
+	"doctor.fix_failed":          "See why with %s, then run %s",
+	"doctor.lock_ok":             "No run holds the lock",
+	"doctor.lock_held":           "A run holds the lock %s, so others are skipped",
+	"doctor.fix_lock_held":       "If no run should be going, find the stuck one with fuser -v %s and stop it",
+	"doctor.lock_denied":         "The lock %s can't be opened (it's owned by %s), so every run fails",
+	"doctor.journal_left":        "An interrupted run left its journal at %s",
+	"doctor.fix_journal":         "Run %s once; it finishes or rolls back what the interrupted run was doing",
+	"doctor.fix_chown":           "Probably left by a run with sudo: %s",
+	"doctor.permissions_ok":      "State and log folders under %s are writable",
+	"doctor.foreign_files":       "%d entries in %s belong to another user, so they can't be pruned",
+	"doctor.clock_ok":            "No sign of the clock being off",
+	"doctor.clock_behind":        "Run %s is recorded at %s, in the future: the clock has gone back, so ages are off",
+	"doctor.ntp_unsynced":        "The clock isn't synchronized with NTP, so ages may be off",
+	"doctor.fix_clock":           "Set the clock and keep it in sync: sudo timedatectl set-ntp true",
+	"doctor.future_files":        "%d files in %s are dated more than a day ahead, so they'll look new for a long time",
+	"doctor.fix_future_files":    "Check the clock of the machine or camera they came from, or touch them to date them now",
+
+	"selftest.heading":     "🧪 Checking the cleaners against a fixture home in %s...",
+	"selftest.passed":      "✅ All %d fixtures came out as documented.",
+	"selftest.kept_home":   "The fixture home is kept at %s",
+	"selftest.dry_run":     "--dry-run changes nothing",
+	"selftest.changed":     "It changed %s",
+	"selftest.run":         "A run on the fixture home succeeds",
+	"selftest.removes":     "%s removes %s",
+	"selftest.keeps":       "%s keeps %s",
+	"selftest.moves":       "%s moves %s to %s",
+	"selftest.lists":       "%s lists %s, leaving it in place",
+	"selftest.undo":        "undo puts back the %d files the run moved",
+	"selftest.still_there": "It's still there",
+	"selftest.gone":        "It's gone",
+	"selftest.not_at":      "There's nothing at %s",
+	"selftest.not_listed":  "The run's summary doesn't name it",
+	"selftest.not_back":    "%s weren't put back",
+
+	"restore.empty":    "The quarantine is empty.",
+	"restore.restored": "♻️ Restored %s",
+	"verify.unchecked": "❔ %s  %s: quarantined before checksums were kept",
+	"verify.corrupted": "❌ %s  %s: %v",
+	"verify.summary":   "✅ %d intact, %d corrupted, %d without checksums",
+
+	"bench.disk":      "💽 %s",
+	"bench.walk":      "   Walk: %.0f entries/s",
+	"bench.hash":      "   Hash, by workers: %s",
+	"bench.copy":      "   Copy: %.0f MB/s",
+	"bench.no_files":  "   No files of %s or more to time hashing and copying with",
+	"bench.recommend": "   💡 Recommended limits: \"max_workers\": %d, and \"io_throttle_mbps\": %d to leave half the disk to other work",
+	"bench.saved":     "Results saved to %s; cleaners with automatic max_workers now use the fastest worker count of their disk.",
+
+	"tray.not_running":     "The saafsafai daemon isn't running",
+	"tray.no_runs":         "No runs yet",
+	"tray.last_run":        "Last run %s: %d items, %d errors, %s freed",
+	"tray.last_run_failed": "Last run %s failed: %s",
+	"tray.running":         "Cleaning up…",
+	"tray.paused":          "(scheduled runs paused)",
+	"tray.clean_now":       "Clean now",
+	"tray.pause":           "Pause scheduled runs",
+	"tray.quit":            "Quit",
+
+	"stats.comparing":        "📊 Comparing runs %s → %s",
+	"stats.per_cleaner":      "Items found per cleaner:",
+	"stats.new_categories":   "🆕 New categories of clutter:",
+	"stats.category_files":   "%s (%d files)",
+	"stats.cache_sizes":      "💾 Cache sizes:",
+	"stats.timings":          "⏱️ Time per cleaner:",
+	"stats.misconfiguration": "⚠️ Possible misconfiguration:",
+	"stats.found_nothing":    "%s found nothing, but %d items the run before",
+	"stats.errors":           "Errors: %d → %d",
+	"stats.sources":          "📊 Where the Downloads clutter came from, over %d runs from %s to %s:",
+	"stats.sources_none":     "No Downloads files recorded in the history yet.",
+	"stats.by_extension":     "By file type:",
+	"stats.by_domain":        "By site:",
+	"stats.no_domains":       "Turn on browser_history to also see which sites the files came from.",
+	"stats.recent_files":     "(%d in the last 30 days)",
+	"stats.more_sources":     "… and %d more",
+	"stats.trends":           "📈 Disk usage from %s to %s:",
+	"stats.per_month":        "%s/month",
+	"stats.keeping_up":       "✅ Cleanup is keeping up: runs freed %s, and the folders shrank by %s overall.",
+	"stats.outgrowing":       "⚠️ Clutter is outgrowing cleanup: the folders grew %s overall, while runs freed %s.",
+
+	"emergency.start":         "🚨 Emergency cleanup: freeing %s, nothing is organized or kept in the quarantine",
+	"emergency.start_all":     "🚨 Emergency cleanup: freeing what can be freed fast, nothing is organized or kept in the quarantine",
+	"emergency.trash":         "Trash",
+	"emergency.quarantine":    "Quarantine",
+	"emergency.caches":        "Caches",
+	"emergency.package_cache": "Package cache",
+	"emergency.docker":        "Docker",
+	"emergency.step":          "✅ %s: %s freed (%s so far)",
+	"emergency.step_target":   "✅ %s: %s freed (%s of %s)",
+	"emergency.done":          "🎉 Freed %s",
+
+	"audit_report.valid": "✅ The signature is valid, made with the key %s.",
+	"estimate.title":     "📏 Space each cleaner could free (level %s):",
+	"estimate.items":     "%d found",
+	"estimate.total":     "Total",
+	"estimate.note":      "Big folders are sampled and sizes measured in the last week reused, so these are estimates; \"?\" marks cleaners that can't measure theirs. Run saafsafai --dry-run for the exact list.",
+
+	"help": `saafsafai - A system cleanup utility
+
+Usage:
+  saafsafai           Run cleanup based on configuration
+  saafsafai --dry-run Report what would be cleaned without changing anything
+  saafsafai --catch-up
+                      Do the scheduled run missed while the machine was off, if any
+  saafsafai --only downloads,node_modules
+                      Run only these cleaners, if enabled in the config
+  saafsafai --skip packages
+                      Don't run these cleaners
+  saafsafai --level light|normal|aggressive
+                      light: only your own files, twice the ages; aggressive: half the ages and budgets
+  saafsafai --verbose Include how long each cleaner took in the report
+  saafsafai --json    Print the summary as JSON
+  saafsafai --no-color
+                      Print the summary without colors, even to a terminal (or set NO_COLOR)
+  saafsafai --setup   Run interactive setup
+  saafsafai --root DIR
+                      Clean DIR as if it were the home directory, with the config and state in it (for containers)
+  saafsafai --home DIR
+                      Clean DIR as a home directory laid out like yours, e.g. another user's or a backup
+  saafsafai --config FILE --downloads DIR --scan DIR,... --state-dir DIR --log-dir DIR
+                      Give the config, Downloads folder, scanned folders, state or logs one by one
+  saafsafai --no-systemd
+                      Never install or touch systemd units; with --setup, only write the config
+  saafsafai --help    Show this help message
+  saafsafai --version Show version information
+
+Commands:
+  saafsafai setup [--yes|--gui] [--run-on login|timer|both] [--schedule daily] [--clean-downloads=true ...]
+                      Configure saafsafai; with --yes, from the flags alone
+  saafsafai service install|repair [--system]
+                      Install the units for the config, or reinstall them and the binary, reporting any drift
+  saafsafai doctor [--system]
+                      Diagnose common problems and suggest fixes
+  saafsafai selftest [--keep]
+                      Check the cleaners against a fixture home in a temporary folder, changing nothing else
+  saafsafai restore [<path|id>] [--to DIR] [--force] [--system]
+                      List quarantined items, or restore one
+  saafsafai verify [--system]
+                      Check quarantined items against their checksums
+  saafsafai daemon [--interval 24h] [--listen ADDR] [--system]
+                      Clean up on an interval, controlled over D-Bus
+  saafsafai tray [--system]
+                      Show the daemon in the system tray
+  saafsafai find PATTERN [--system]
+                      Tell where runs moved, quarantined or deleted the files matching PATTERN
+  saafsafai suggest
+                      Suggest Downloads rules from what the runs filed, and add the ones accepted
+  saafsafai suggest --others
+                      Go through the file types piling up in Others, and file the ones given a category there from now on
+  saafsafai organize [--initial] [--dry-run] [--yes]
+                      Organize Downloads from a plan of what goes where, with progress; --initial quarantines what no category claims
+  saafsafai similar
+                      Group similarly named files in Downloads, such as report(1).pdf and report-final.pdf
+  saafsafai audit [--output FILE] [--key FILE] [--level LEVEL] [--system]
+                      Report the space every cleaner could free as signed JSON, changing nothing
+  saafsafai audit --verify FILE
+                      Check an audit report's signature
+  saafsafai remote [--plan-only] [--level LEVEL] [--copy] [--system] [user@]HOST
+                      Run a cleanup (or with --plan-only, a dry run) over SSH and print its JSON summary
+  saafsafai estimate [--level LEVEL] [--system]
+                      Estimate how much space each cleaner could free
+  saafsafai config schema
+                      Print the config file's JSON Schema
+  saafsafai emergency [--free SIZE] [--system]
+                      Free space fast (trash, quarantine, caches, docker) until SIZE is free
+  saafsafai logs [--last N] [--follow] [--date YYYY-MM-DD] [--run ID] [--file PATH] [--system]
+                      Show recent run logs
+  saafsafai stats diff [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--system]
+                      Compare the last two runs (or the runs on two dates)
+  saafsafai stats sources [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--system]
+                      Show which file types and sites the Downloads clutter comes from
+  saafsafai stats trends [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--system]
+                      Show how the tracked folders grew, and whether cleanup keeps up (needs analytics)
+  saafsafai version [--json]
+                      Show the version, commit, Go version, build tags and cleaners built in
+  saafsafai completion bash|zsh|fish
+                      Print a shell completion script
+
+System-wide mode (run as root):
+  saafsafai --setup --system  Configure and install the system service
+  saafsafai --system          Run the privileged cleaners and, if enabled, each user's cleanup
+
+Exit codes:
+  0  Cleaned up without errors
+  1  Fatal error
+  2  Completed, but some items failed
+  3  Nothing to clean
+  4  Another run is in progress
+  5  Invalid command line
+
+Configuration file location: ~/.config/saafsafai.json (system: /etc/saafsafai/saafsafai.json)
+Logs location: ~/.local/share/saafsafai/logs/ (system: /var/log/saafsafai/)`,
+}
@@ -0,0 +1,447 @@
+package main
+
+var messagesHI = map[string]string{
+	"answer.yes":    "h haan हाँ हां",
+	"prompt.yes_no": "%s (हाँ=h/नहीं=n): ",
+
+	"summary.title":             "🧹 साफ़सफ़ाई रिपोर्ट — %s",
+	"summary.dry_run_suffix":    " (ड्राई रन)",
+	"summary.run_id":            "🔖 रन ID: %s",
+	"summary.report":            "📄 पूरी रिपोर्ट: %s",
+	"summary.nothing":           "📭 आज साफ़ करने के लिए कुछ नहीं है।",
+	"summary.found_dry_run":     "🔍 साफ़ करने के लिए %d आइटम मिले (ड्राई रन, कुछ नहीं बदला गया)।",
+	"summary.cleaned":           "✨ कुल %d आइटम साफ़ किए गए।",
+	"summary.freed":             "💾 %s डिस्क स्थान खाली हुआ।",
+	"summary.freed_dry_run":     "💾 %s डिस्क स्थान खाली होगा।",
+	"summary.timings":           "⏱️ समय:",
+	"summary.more_items":        "… और %d",
+	"summary.merged":            "(मिलाया गया)",
+	"summary.group":             "%s (%d):",
+	"summary.all_listed":        "सभी %[1]d %[2]s में सूचीबद्ध हैं",
+	"summary.more_items_report": "… और %d, सभी %s में",
+	"summary.full_lists":        "📋 पूरी सूचियाँ:",
+	"summary.skipped":           "⏭️ %d आइटम छोड़े गए (%s), विवरण लॉग में देखें:",
+	"summary.skipped_item":      "छोड़ा गया: %s",
+	"summary.errors":            "⚠️ %d त्रुटियाँ:",
+
+	"section.deleted_files":               "🗑️ हटाई गई अस्थायी फ़ाइलें:",
+	"section.deleted_files.dry_run":       "🗑️ ये अस्थायी फ़ाइलें हटाई जाएँगी:",
+	"section.renamed_categories":          "🏷️ नाम बदले गए श्रेणी फ़ोल्डर:",
+	"section.renamed_categories.dry_run":  "🏷️ इन श्रेणी फ़ोल्डरों के नाम बदले जाएँगे:",
+	"section.unclaimed":                   "🔒 क्वारंटीन किए गए डाउनलोड जिनकी कोई श्रेणी नहीं:",
+	"section.unclaimed.dry_run":           "🔒 ये डाउनलोड, जिनकी कोई श्रेणी नहीं, क्वारंटीन किए जाएँगे:",
+	"section.moved_files":                 "📁 श्रेणी फ़ोल्डरों में ले जाई गई फ़ाइलें:",
+	"section.moved_files.dry_run":         "📁 ये फ़ाइलें श्रेणी फ़ोल्डरों में ले जाई जाएँगी:",
+	"section.expired":                     "⌛ हटाए गए पुराने हो चुके डाउनलोड:",
+	"section.expired.dry_run":             "⌛ ये पुराने हो चुके डाउनलोड हटाए जाएँगे:",
+	"section.script_actions":              "⚙️ चलाए गए डाउनलोड एक्शन:",
+	"section.script_actions.dry_run":      "⚙️ ये डाउनलोड एक्शन चलाए जाएँगे:",
+	"section.removed_modules":             "📦 हटाए गए पुराने node_modules फ़ोल्डर:",
+	"section.removed_modules.dry_run":     "📦 ये पुराने node_modules फ़ोल्डर हटाए जाएँगे:",
+	"section.wine_prefixes":               "🍷 हटाए गए अप्रयुक्त Wine प्रीफ़िक्स:",
+	"section.wine_prefixes.dry_run":       "🍷 ये अप्रयुक्त Wine प्रीफ़िक्स हटाए जाएँगे:",
+	"section.appimages":                   "💿 हटाए गए पुराने AppImage संस्करण:",
+	"section.appimages.dry_run":           "💿 ये पुराने AppImage संस्करण हटाए जाएँगे:",
+	"section.mail":                        "📎 हटाए गए पुराने मेल अटैचमेंट:",
+	"section.mail.dry_run":                "📎 ये पुराने मेल अटैचमेंट हटाए जाएँगे:",
+	"section.sync_conflicts":              "🔀 क्वारंटीन की गई पुरानी सिंक कॉन्फ़्लिक्ट प्रतियाँ:",
+	"section.sync_conflicts.dry_run":      "🔀 ये पुरानी सिंक कॉन्फ़्लिक्ट प्रतियाँ क्वारंटीन की जाएँगी:",
+	"section.backup_files":                "🩹 हटाई गई पुरानी बैकअप और स्वैप फ़ाइलें:",
+	"section.backup_files.dry_run":        "🩹 ये पुरानी बैकअप और स्वैप फ़ाइलें हटाई जाएँगी:",
+	"section.metadata_files":              "🍂 हटाई गई .DS_Store, Thumbs.db और दूसरी मेटाडेटा फ़ाइलें:",
+	"section.metadata_files.dry_run":      "🍂 ये .DS_Store, Thumbs.db और दूसरी मेटाडेटा फ़ाइलें हटाई जाएँगी:",
+	"section.git_repos":                   "🌿 व्यवस्थित की गई git रिपॉज़िटरी:",
+	"section.git_repos.dry_run":           "🌿 ये git रिपॉज़िटरी व्यवस्थित की जाएँगी:",
+	"section.snapshots":                   "📸 सफ़ाई से पहले लिए गए स्नैपशॉट:",
+	"section.post_clean":                  "🪄 चलाए गए सफ़ाई-बाद हुक:",
+	"section.large_repos":                 "🐘 git रिपॉज़िटरी जिनका इतिहास उनकी फ़ाइलों से बहुत बड़ा है:",
+	"section.archived_repos":              "🗄️ आर्काइव किए गए git क्लोन और हटाए गए वर्कट्री:",
+	"section.stale_repos":                 "🗄️ महीनों से अछूते git क्लोन और वर्कट्री, आर्काइव करने लायक:",
+	"section.stale_branches":              "🪵 git ब्रांच जिनमें महीनों से कोई कमिट नहीं हुआ:",
+	"section.removed_clones":              "👯 हटाए गए डुप्लिकेट git क्लोन:",
+	"section.duplicate_repos":             "👯 एक से ज़्यादा बार क्लोन की गई git रिपॉज़िटरी:",
+	"section.photos":                      "🖼️ हटाई गई डुप्लिकेट तस्वीरें:",
+	"section.photos.dry_run":              "🖼️ इन डुप्लिकेट तस्वीरों को हटाने के लिए पूछा जाएगा:",
+	"section.font_caches":                 "🔤 दोबारा बनाए गए फ़ॉन्ट और आइकन कैश:",
+	"section.font_caches.dry_run":         "🔤 ये फ़ॉन्ट और आइकन कैश दोबारा बनाए जाएँगे:",
+	"section.package_caches":              "🧰 साफ़ किए गए पैकेज कैश:",
+	"section.package_caches.dry_run":      "🧰 ये पैकेज कैश साफ़ किए जाएँगे:",
+	"section.removed_packages":            "📤 हटाए गए अनाथ पैकेज:",
+	"section.removed_packages.dry_run":    "📤 ये अनाथ पैकेज हटाए जाएँगे:",
+	"section.removed_kernels":             "🐧 हटाए गए पुराने कर्नेल:",
+	"section.removed_kernels.dry_run":     "🐧 ये पुराने कर्नेल हटाए जाएँगे:",
+	"section.docker":                      "🐳 साफ़ किए गए Docker संसाधन:",
+	"section.docker.dry_run":              "🐳 ये Docker संसाधन साफ़ किए जाएँगे:",
+	"section.vm_images":                   "💽 हटाई गई VM डिस्क इमेज:",
+	"section.vm_images.dry_run":           "💽 इन VM डिस्क इमेज को हटाने के लिए पूछा जाएगा:",
+	"section.kube":                        "☸️ हटाए गए पुराने स्थानीय Kubernetes क्लस्टर:",
+	"section.kube.dry_run":                "☸️ ये पुराने स्थानीय Kubernetes क्लस्टर हटाए जाएँगे:",
+	"section.removable":                   "💽 साफ़ किए गए हटाने योग्य मीडिया:",
+	"section.removable.dry_run":           "💽 ये हटाने योग्य मीडिया साफ़ किए जाएँगे:",
+	"section.users":                       "👥 इन उपयोगकर्ताओं के लिए सफ़ाई चलाई गई:",
+	"section.users.dry_run":               "👥 इन उपयोगकर्ताओं के लिए सफ़ाई चलाई जाएगी:",
+	"section.recovered":                   "♻️ बाधित रन से पूरे किए गए:",
+	"section.vm_image_candidates":         "💽 अप्रयुक्त VM डिस्क इमेज (हटाने के लिए टर्मिनल से saafsafai चलाएँ):",
+	"section.vm_image_candidates.dry_run": "💽 अप्रयुक्त VM डिस्क इमेज:",
+	"section.untouched":                   "🔒 डाउनलोड्स में छोड़ी गई फ़ाइलें (स्ट्रिक्ट मोड, किसी नियम में नहीं):",
+	"section.left":                        "🔒 डाउनलोड्स में छोड़ी गई फ़ाइलें (बिना एक्सटेंशन: स्क्रिप्ट, प्रोग्राम, या no_extension leave पर सभी):",
+	"section.protected":                   "🛡️ छोड़ दिए गए (सिस्टम नीति से सुरक्षित):",
+	"section.duplicate_photos":            "🖼️ डुप्लिकेट तस्वीरें (जाँचने के लिए टर्मिनल से saafsafai चलाएँ):",
+	"section.sync_conflict_review":        "🔀 जाँचने के लिए पुरानी सिंक कॉन्फ़्लिक्ट प्रतियाँ:",
+
+	"item.recovered_removal": "%s हटाया गया",
+	"item.recovered_move":    "%s → %s ले जाया गया",
+	"item.duplicate_photos":  "%s रखें; डुप्लिकेट: %s",
+	"item.stale_repo":        "%s (आख़िरी कमिट %s, %s)",
+	"item.stale_worktree":    "%s (%s का वर्कट्री, आख़िरी कमिट %s)",
+	"item.stale_branches":    "%s: %s",
+	"item.duplicate_repos":   "%s: %s",
+
+	"confirm.remove":            "%s हटाएँ?",
+	"confirm.remove_kernels":    "पुराने कर्नेल %s हटाएँ?",
+	"confirm.remove_duplicates": "%s हटाएँ, जो %s के डुप्लिकेट हैं?",
+	"confirm.archive_repo":      "%s को, जो %s से अछूता है, %s में आर्काइव करके हटाएँ?",
+	"confirm.remove_worktree":   "वर्कट्री %s हटाएँ, जो %s से अछूता है? इसके कमिट %s में रहेंगे",
+	"confirm.remove_clone":      "%s (%s) हटाएँ, जो %s वाली रिपॉज़िटरी का ही क्लोन है?",
+
+	"setup.welcome":             "⚙️  saafsafai सेटअप में आपका स्वागत है!",
+	"setup.clean_downloads":     "क्या आप Downloads फ़ोल्डर साफ़ करना चाहते हैं?",
+	"setup.delete_node_modules": "क्या आप अप्रयुक्त node_modules फ़ोल्डर (30+ दिन पुराने) हटाना चाहते हैं?",
+	"setup.wine_prefixes":       "क्या आप अप्रयुक्त Wine प्रीफ़िक्स (90+ दिन से उपयोग नहीं) हटाना चाहते हैं?",
+	"setup.appimages":           "क्या आप डुप्लिकेट AppImage के पुराने संस्करण हटाना चाहते हैं?",
+	"setup.mail_attachments":    "क्या आप पुरानी Thunderbird और Evolution अटैचमेंट फ़ाइलें हटाना चाहते हैं?",
+	"setup.sync_conflicts":      "क्या आप पुरानी Syncthing, Dropbox और Nextcloud कॉन्फ़्लिक्ट प्रतियाँ जाँच के लिए इकट्ठा करना चाहते हैं?",
+	"setup.backup_files":        "क्या आप पुरानी एडिटर बैकअप फ़ाइलें (file~, .bak, .orig, .rej) और बेकार स्वैप फ़ाइलें हटाना चाहते हैं?",
+	"setup.metadata_files":      "क्या आप macOS और Windows की मेटाडेटा फ़ाइलें (.DS_Store, ._*, Thumbs.db, desktop.ini) हटाना चाहते हैं?",
+	"setup.git_repos":           "क्या आप समय-समय पर अपनी रिपॉज़िटरी में git की साफ़-सफ़ाई (reflog expiry, git gc --auto) चलाना चाहते हैं?",
+	"setup.duplicate_photos":    "क्या आप Downloads और Pictures में डुप्लिकेट तस्वीरें खोजना चाहते हैं (हटाने से पहले हमेशा पूछा जाएगा)?",
+	"setup.font_caches":         "क्या आप पुराने या बहुत बड़े फ़ॉन्ट और आइकन कैश दोबारा बनाना चाहते हैं?",
+	"setup.package_cache_sudo":  "क्या आप टर्मिनल से चलाने पर सिस्टम पैकेज कैश साफ़ करना चाहते हैं (sudo माँगेगा)?",
+	"setup.docker":              "क्या आप लटकती Docker इमेज और अप्रयुक्त अनाम वॉल्यूम (30+ दिन पुराने) हटाना चाहते हैं?",
+	"setup.kube":                "क्या आप 30+ दिन से अछूते kind/minikube/k3d क्लस्टर हटाना चाहते हैं?",
+	"setup.vm_images":           "क्या आप अप्रयुक्त VM डिस्क इमेज और Vagrant बॉक्स खोजना चाहते हैं (हटाने से पहले हमेशा पूछा जाएगा)?",
+	"setup.complete":            "✅ सेटअप पूरा हुआ! saafsafai हर बूट पर चलेगा।",
+	"setup.complete_timer":      "✅ सेटअप पूरा हुआ! saafsafai इस समय-सारणी पर चलेगा: %s",
+	"setup.complete_both":       "✅ सेटअप पूरा हुआ! saafsafai हर बूट पर और इस समय-सारणी पर चलेगा: %s",
+	"setup.gui_title":           "saafsafai सेटअप",
+	"setup.gui_cleaner":         "क्या साफ़ करें",
+	"setup.run_on":              "saafsafai कब चले: लॉगिन पर, टाइमर से, या दोनों?",
+	"setup.schedule":            "टाइमर की समय-सारणी (systemd कैलेंडर व्यंजक)",
+	"schedule.after_boot":       "बूट के %s बाद",
+	"setup.complete_no_systemd": "✅ सेटअप पूरा हुआ! कोई सेवा इंस्टॉल नहीं की गई; saafsafai को cron या कंटेनर शेड्यूलर से चलाएँ।",
+	"setup.config_saved":        "📁 कॉन्फ़िग यहाँ सहेजा गया: %s",
+	"setup.manual_run":          "🔧 स्वयं चलाने के लिए: %s",
+	"setup.see_logs":            "📋 लॉग देखने के लिए: ls %s",
+	"setup.binary_installed":    "✅ बाइनरी यहाँ इंस्टॉल की गई: %s",
+	"setup.service_installed":   "✅ Systemd सेवा इंस्टॉल की गई: %s",
+
+	"system_setup.welcome":         "⚙️  saafsafai सिस्टम सेटअप में आपका स्वागत है!",
+	"system_setup.package_cache":   "क्या आप पैकेज मैनेजर कैश (apt/dnf/pacman) साफ़ करना चाहते हैं?",
+	"system_setup.orphans":         "क्या आप ऐसे अनाथ पैकेज हटाना चाहते हैं जिन पर कुछ भी निर्भर नहीं है?",
+	"system_setup.kernels":         "क्या आप पुराने कर्नेल हटाना चाहते हैं (सबसे नए दो और चालू कर्नेल हमेशा रखे जाते हैं)?",
+	"system_setup.post_clean":      "क्या आप 1 GB या उससे ज़्यादा जगह खाली करने वाले रन के बाद fstrim चलाना चाहते हैं, ताकि SSD और thin-provisioned VM डिस्क को जगह वापस मिले?",
+	"system_setup.user_homes":      "क्या आप इस सेवा से हर उपयोगकर्ता की सफ़ाई चलाना चाहते हैं (साझा मशीनें)?",
+	"system_setup.font_caches":     "क्या आप सिस्टम के पुराने या बहुत बड़े फ़ॉन्ट और आइकन कैश दोबारा बनाना चाहते हैं?",
+	"system_setup.default_config":  "क्या आप उन उपयोगकर्ताओं के लिए डिफ़ॉल्ट कॉन्फ़िग सेट करना चाहते हैं जिन्होंने सेटअप नहीं चलाया?",
+	"system_setup.default_heading": "उपयोगकर्ताओं के लिए डिफ़ॉल्ट कॉन्फ़िगरेशन:",
+	"system_setup.default_saved":   "📁 डिफ़ॉल्ट उपयोगकर्ता कॉन्फ़िग यहाँ सहेजा गया: %s",
+	"system_setup.complete_timer":  "✅ सिस्टम सेटअप पूरा हुआ! saafsafai root के रूप में इस समय-सारणी पर चलेगा: %s",
+	"system_setup.complete_both":   "✅ सिस्टम सेटअप पूरा हुआ! saafsafai root के रूप में हर बूट पर और इस समय-सारणी पर चलेगा: %s",
+	"system_setup.complete":        "✅ सिस्टम सेटअप पूरा हुआ! saafsafai हर बूट पर root के रूप में चलेगा।",
+
+	"notify.subject":        "🧹 %s के लिए saafsafai सफ़ाई रिपोर्ट",
+	"notify.errors_subject": "⚠️ saafsafai: %d त्रुटियाँ, %s पर",
+	"notify.errors_intro":   "saafsafai को %d त्रुटियाँ मिलीं, %s पर:",
+	"notify.full_report":    "पूरी रिपोर्ट:",
+	"notify.undo":           "पूर्ववत करें",
+
+	"undo.subject":     "saafsafai undo",
+	"undo.done_notify": "रन %s ने जो खिसकाया, क्वारंटाइन किया या रद्दी में डाला, वह वापस रख दिया गया।",
+	"undo.restored":    "↩️ वापस रखी गई: %s",
+	"undo.failed":      "❌ %s वापस नहीं रखी जा सकी: %v",
+	"undo.deleted":     "🗑️ %s हमेशा के लिए हटा दी गई थी",
+	"undo.total":       "रन %[2]s की %[1]d चीज़ें वापस रखी गईं।",
+
+	"audit.none":              "किसी रन ने %s को नहीं छुआ।",
+	"audit.origin":            "%s से %s को डाउनलोड किया गया",
+	"similar.none":            "Downloads में मिलते-जुलते नाम वाली कोई फ़ाइल नहीं।",
+	"similar.cluster":         "📑 %s: %d फ़ाइलें, %s",
+	"similar.total":           "मिलते-जुलते नाम वाली फ़ाइलों के %d समूह; हर समूह में नई पहले।",
+	"triage.none":             "Others फ़ोल्डर ख़ाली है: हर फ़ाइल को श्रेणी मिल गई।",
+	"triage.title":            "📦 Others में %[1]d फ़ाइलें, %[2]d प्रकारों की:",
+	"triage.unknown":          "अज्ञात प्रकार",
+	"triage.sniffed":          "%s जैसी लगती है",
+	"triage.from_terminal":    "इनकी श्रेणियाँ चुनने के लिए टर्मिनल से saafsafai suggest --others चलाएँ, या ऊपर के नियम कॉन्फ़िग में खुद जोड़ें।",
+	"triage.assign":           "क्या %[1]s (%[2]d फ़ाइलें) किसी श्रेणी में रखें?",
+	"triage.move":             "क्या Others में पहले से रखी %d फ़ाइलें उनके नए फ़ोल्डरों में ले जाएँ?",
+	"organize.planning":       "📋 %s को व्यवस्थित करने की योजना बन रही है…",
+	"organize.nothing":        "व्यवस्थित करने को कुछ नहीं: Downloads में कोई फ़ाइल नहीं है।",
+	"organize.plan":           "📋 %d फ़ाइलों, %s की योजना (%s में बनी):",
+	"organize.row_quarantine": "क्वारंटीन (कोई श्रेणी नहीं)",
+	"organize.row_temp":       "हटाई जाएँगी (अधूरे डाउनलोड)",
+	"organize.row_expired":    "हटाई जाएँगी (delete_after_days)",
+	"organize.row_scripted":   "download_actions स्क्रिप्ट",
+	"organize.row_untouched":  "जहाँ हैं वहीं (strict)",
+	"organize.row_left":       "जहाँ हैं वहीं (बिना एक्सटेंशन)",
+	"organize.quarantined":    "जिन फ़ाइलों की कोई श्रेणी नहीं, वे क्वारंटीन में जाएँगी: saafsafai restore उनमें से किसी को भी %d दिन तक वापस ला सकता है।",
+	"organize.over_budget":    "⚠️ इनका कुल आकार %s है, क्वारंटीन के बजट %s से ज़्यादा, इसलिए सबसे पुरानी जल्दी हटा दी जाएँगी; सब रखने के लिए पहले quarantine.budget बढ़ाएँ।",
+	"organize.confirm_hint":   "कुछ नहीं बदला। योजना पूरी करने के लिए --yes के साथ, या टर्मिनल से चलाएँ।",
+	"organize.confirm":        "क्या %d फ़ाइलों की योजना पूरी करें?",
+	"organize.progress":       "⏳ %[2]d में से %[1]d फ़ाइलें हो गईं, %[3]s",
+	"organize.done":           "%d चीज़ें व्यवस्थित की गईं। सब वापस रखने के लिए: saafsafai undo %s",
+	"organize.no_extension":   "बिना एक्सटेंशन",
+	"find.none":               "%q से मेल खाती कोई फ़ाइल किसी रन ने न हटाई न खिसकाई।",
+	"find.file":               "🔎 %s",
+	"find.moved":              "%s में ले जाई गई",
+	"find.gone":               "(अब वहाँ नहीं है)",
+	"find.removed":            "हटाई गई",
+	"find.trashed":            "रद्दी में डाली गई",
+	"find.restored":           "saafsafai undo ने वापस रखी",
+	"find.snapshot":           "इसकी एक प्रति स्नैपशॉट में है: %s",
+	"find.quarantined":        "क्वारंटाइन की गई, वापस लाने के लिए: saafsafai restore %s",
+	"find.quarantine_expired": "क्वारंटाइन की गई, जिसकी अवधि बीत चुकी है",
+	"find.script":             "%s को सौंपी गई",
+	"find.total":              "%d फ़ाइलें मिलीं; सबसे अच्छे मेल पहले।",
+	"suggest.too_few":         "अभी तक केवल %d रन दर्ज हैं; सुझावों के लिए कम से कम %d चाहिए।",
+	"suggest.none":            "कोई सुझाव नहीं: नियम रनों द्वारा रखी जा रही फ़ाइलों से मेल खाते हैं।",
+	"suggest.deleted":         "आपने %[4]s में रखी गई %[2]d %[3]s फ़ाइलों में से %[1]d हटा दीं। क्या उन्हें %[5]d दिन बाद Downloads से अपने आप हटाया जाए?",
+	"suggest.others":          "%d %s फ़ाइलें Others में पहुँचीं। क्या उन्हें इसके बजाय %s में रखा जाए?",
+	"suggest.accept":          "क्या यह नियम जोड़ें?",
+	"suggest.folder":          "श्रेणी फ़ोल्डर",
+	"suggest.days":            "दिन",
+	"suggest.invalid_folder":  "%q श्रेणी फ़ोल्डर का नाम नहीं हो सकता, छोड़ दिया गया।",
+	"suggest.invalid_days":    "%q दिनों की संख्या नहीं है, छोड़ दिया गया।",
+	"suggest.from_terminal":   "इन्हें जोड़ने के लिए टर्मिनल से saafsafai suggest चलाएँ, या कॉन्फ़िग में खुद जोड़ें।",
+	"suggest.saved":           "✅ %[2]s में %[1]d नियम जोड़े गए।",
+
+	"reason.permission":   "अनुमति नहीं",
+	"reason.cross_device": "दूसरे डिवाइस पर ले जाना",
+	"reason.busy":         "उपयोग में",
+	"reason.vanished":     "पहले ही हट चुका",
+	"reason.read_only":    "केवल-पठन फ़ाइल सिस्टम",
+	"reason.no_space":     "जगह नहीं बची",
+	"reason.disk_guard":   "disk_guard ने रोका",
+	"reason.other":        "विफल",
+
+	"service.no_drift":       "✅ इंस्टॉल की गई बाइनरी और यूनिट मौजूदा कॉन्फ़िग से मेल खाती हैं।",
+	"service.drift":          "🔧 आज सेटअप जो इंस्टॉल करता, उससे अंतर:",
+	"service.binary_differs": "%s इस बाइनरी से अलग है",
+	"service.unit_missing":   "%s मौजूद नहीं है",
+	"service.unit_unused":    "%s इंस्टॉल है पर समय-सारणी में उपयोग नहीं होता",
+	"service.unit_changed":   "%s बदल गया है:",
+	"service.repaired":       "✅ सेवा ठीक कर दी गई।",
+
+	"doctor.heading":             "🩺 saafsafai के सेटअप की जाँच हो रही है...",
+	"doctor.healthy":             "✅ कोई समस्या नहीं मिली।",
+	"doctor.config_ok":           "कॉन्फ़िग %s पार्स और मान्य होता है",
+	"doctor.config_missing":      "%s पर कोई कॉन्फ़िग नहीं है",
+	"doctor.config_invalid":      "कॉन्फ़िग अमान्य है: %v",
+	"doctor.fix_setup":           "एक लिखने के लिए %s चलाएँ",
+	"doctor.fix_config":          "%s ठीक करें; saafsafai config schema हर कुंजी बताता है",
+	"doctor.config_secret":       "कॉन्फ़िग %s में SMTP पासवर्ड है, पर दूसरे इसे पढ़ सकते हैं",
+	"doctor.fix_secret":          "इसे notify.email.password_file में रखें, या %s पर chmod 600 करें",
+	"doctor.downloads_ok":        "Downloads फ़ोल्डर %s मौजूद है",
+	"doctor.downloads_missing":   "Downloads फ़ोल्डर %s मौजूद नहीं है, इसलिए clean_downloads कुछ नहीं करता",
+	"doctor.downloads_not_dir":   "Downloads फ़ोल्डर %s फ़ोल्डर नहीं है",
+	"doctor.fix_downloads":       "इसे बनाएँ (mkdir -p %s), या downloads_dir को सही फ़ोल्डर पर सेट करें",
+	"doctor.not_writable":        "%s में लिखा नहीं जा सकता (इसका स्वामी %s है)",
+	"doctor.noatime":             "%s noatime के साथ माउंट है, इसलिए एक्सेस समय कभी नहीं बदलते और age_by में atime वाले क्लीनर केवल बदलाव के समय से चलते हैं",
+	"doctor.fix_noatime":         "%s को relatime (डिफ़ॉल्ट) के साथ फिर से माउंट करें, या उन क्लीनरों को वापस mtime पर करें",
+	"doctor.service_ok":          "%s इंस्टॉल और सक्षम है",
+	"doctor.unit_missing":        "%s इंस्टॉल नहीं है, इसलिए saafsafai को कुछ नहीं चलाता",
+	"doctor.unit_disabled":       "%s %s है, इसलिए यह नहीं चलेगा",
+	"doctor.fix_service":         "%s चलाएँ",
+	"doctor.systemd_unreachable": "systemd से सेवा के बारे में पूछा नहीं जा सका: %v",
+	"doctor.unit_failed":         "%s पिछली बार चलने पर विफल रहा",
+	"doctor.fix_failed":          "%s से कारण देखें, फिर %s चलाएँ",
+	"doctor.lock_ok":             "किसी रन के पास लॉक नहीं है",
+	"doctor.lock_held":           "एक रन के पास लॉक %s है, इसलिए दूसरे छोड़ दिए जाते हैं",
+	"doctor.fix_lock_held":       "अगर कोई रन नहीं चलना चाहिए, तो fuser -v %s से अटका रन ढूँढकर रोकें",
+	"doctor.lock_denied":         "लॉक %s खोला नहीं जा सकता (इसका स्वामी %s है), इसलिए हर रन विफल होता है",
+	"doctor.journal_left":        "एक बाधित रन ने अपना जर्नल %s पर छोड़ा है",
+	"doctor.fix_journal":         "%s एक बार चलाएँ; यह बाधित रन का काम पूरा करता है या वापस लेता है",
+	"doctor.fix_chown":           "शायद sudo वाले रन ने छोड़ा है: %s",
+	"doctor.permissions_ok":      "%s के अंतर्गत स्थिति और लॉग फ़ोल्डर लिखने योग्य हैं",
+	"doctor.foreign_files":       "%[2]s की %[1]d प्रविष्टियाँ किसी दूसरे उपयोगकर्ता की हैं, इसलिए उन्हें हटाया नहीं जा सकता",
+	"doctor.clock_ok":            "घड़ी के गलत होने का कोई संकेत नहीं",
+	"doctor.clock_behind":        "रन %s %s पर दर्ज है, भविष्य में: घड़ी पीछे गई है, इसलिए उम्र गलत हैं",
+	"doctor.ntp_unsynced":        "घड़ी NTP से सिंक नहीं है, इसलिए उम्र गलत हो सकती हैं",
+	"doctor.fix_clock":           "घड़ी सेट करें और सिंक रखें: sudo timedatectl set-ntp true",
+	"doctor.future_files":        "%[2]s की %[1]d फ़ाइलें एक दिन से ज़्यादा आगे की तारीख की हैं, इसलिए वे लंबे समय तक नई दिखेंगी",
+	"doctor.fix_future_files":    "जिस मशीन या कैमरे से वे आईं उसकी घड़ी जाँचें, या उन्हें अभी की तारीख देने के लिए touch करें",
+
+	"selftest.heading":     "🧪 %s में एक नमूना होम पर क्लीनरों की जाँच हो रही है...",
+	"selftest.passed":      "✅ सभी %d नमूने दस्तावेज़ के अनुसार निकले।",
+	"selftest.kept_home":   "नमूना होम %s पर रखा गया है",
+	"selftest.dry_run":     "--dry-run कुछ नहीं बदलता",
+	"selftest.changed":     "इसने %s बदल दिया",
+	"selftest.run":         "नमूना होम पर रन सफल होता है",
+	"selftest.removes":     "%s %s हटाता है",
+	"selftest.keeps":       "%s %s रखता है",
+	"selftest.moves":       "%s %s को %s में ले जाता है",
+	"selftest.lists":       "%s %s को सूची में दिखाता है, उसे वहीं छोड़कर",
+	"selftest.undo":        "undo रन द्वारा ले जाई गई %d फ़ाइलें वापस रखता है",
+	"selftest.still_there": "यह अब भी वहीं है",
+	"selftest.gone":        "यह गायब है",
+	"selftest.not_at":      "%s पर कुछ नहीं है",
+	"selftest.not_listed":  "रन के सारांश में इसका नाम नहीं है",
+	"selftest.not_back":    "%s वापस नहीं रखे गए",
+
+	"restore.empty":    "क्वारंटीन खाली है।",
+	"restore.restored": "♻️ %s वापस लाया गया",
+	"verify.unchecked": "❔ %s  %s: चेकसम रखे जाने से पहले क्वारंटीन किया गया",
+	"verify.corrupted": "❌ %s  %s: %v",
+	"verify.summary":   "✅ %d सही, %d खराब, %d बिना चेकसम",
+
+	"bench.disk":      "💽 %s",
+	"bench.walk":      "   वॉक: %.0f प्रविष्टियाँ/सेकंड",
+	"bench.hash":      "   हैश, वर्कर के अनुसार: %s",
+	"bench.copy":      "   कॉपी: %.0f MB/s",
+	"bench.no_files":  "   हैशिंग और कॉपी का समय मापने के लिए %s या बड़ी कोई फ़ाइल नहीं",
+	"bench.recommend": "   💡 सुझाई गई सीमाएँ: \"max_workers\": %d, और बाकी काम के लिए आधी डिस्क छोड़ने को \"io_throttle_mbps\": %d",
+	"bench.saved":     "नतीजे %s में सहेजे गए; स्वचालित max_workers वाले क्लीनर अब अपनी डिस्क की सबसे तेज़ वर्कर संख्या इस्तेमाल करेंगे।",
+
+	"tray.not_running":     "saafsafai डेमन नहीं चल रहा है",
+	"tray.no_runs":         "अभी तक कोई रन नहीं",
+	"tray.last_run":        "पिछला रन %s: %d चीज़ें, %d त्रुटियाँ, %s खाली हुआ",
+	"tray.last_run_failed": "पिछला रन %s विफल रहा: %s",
+	"tray.running":         "सफ़ाई हो रही है…",
+	"tray.paused":          "(निर्धारित रन रुके हुए हैं)",
+	"tray.clean_now":       "अभी साफ़ करें",
+	"tray.pause":           "निर्धारित रन रोकें",
+	"tray.quit":            "बंद करें",
+
+	"stats.comparing":        "📊 रन की तुलना %s → %s",
+	"stats.per_cleaner":      "हर क्लीनर को मिले आइटम:",
+	"stats.new_categories":   "🆕 अव्यवस्था की नई श्रेणियाँ:",
+	"stats.category_files":   "%s (%d फ़ाइलें)",
+	"stats.cache_sizes":      "💾 कैश आकार:",
+	"stats.timings":          "⏱️ हर क्लीनर का समय:",
+	"stats.misconfiguration": "⚠️ संभावित गलत कॉन्फ़िगरेशन:",
+	"stats.found_nothing":    "%s को कुछ नहीं मिला, जबकि पिछले रन में %d आइटम थे",
+	"stats.errors":           "त्रुटियाँ: %d → %d",
+	"stats.sources":          "📊 Downloads की अव्यवस्था कहाँ से आई, %d रनों में, %s से %s तक:",
+	"stats.sources_none":     "इतिहास में अभी तक Downloads की कोई फ़ाइल दर्ज नहीं है।",
+	"stats.by_extension":     "फ़ाइल प्रकार के अनुसार:",
+	"stats.by_domain":        "साइट के अनुसार:",
+	"stats.no_domains":       "फ़ाइलें किन साइटों से आईं, यह भी देखने के लिए browser_history चालू करें।",
+	"stats.recent_files":     "(पिछले 30 दिनों में %d)",
+	"stats.more_sources":     "… और %d",
+	"stats.trends":           "📈 डिस्क उपयोग %s से %s तक:",
+	"stats.per_month":        "%s/माह",
+	"stats.keeping_up":       "✅ सफ़ाई बराबर चल रही है: रनों ने %s खाली किया, और फ़ोल्डर कुल %s घटे।",
+	"stats.outgrowing":       "⚠️ अव्यवस्था सफ़ाई से तेज़ बढ़ रही है: फ़ोल्डर कुल %s बढ़े, जबकि रनों ने %s खाली किया।",
+
+	"emergency.start":         "🚨 आपात सफ़ाई: %s खाली किया जा रहा है, कुछ भी व्यवस्थित या क्वारंटीन में नहीं रखा जाएगा",
+	"emergency.start_all":     "🚨 आपात सफ़ाई: जो जल्दी खाली हो सके वह खाली किया जा रहा है, कुछ भी व्यवस्थित या क्वारंटीन में नहीं रखा जाएगा",
+	"emergency.trash":         "रद्दी",
+	"emergency.quarantine":    "क्वारंटीन",
+	"emergency.caches":        "कैश",
+	"emergency.package_cache": "पैकेज कैश",
+	"emergency.docker":        "Docker",
+	"emergency.step":          "✅ %s: %s खाली हुआ (अब तक %s)",
+	"emergency.step_target":   "✅ %s: %s खाली हुआ (%s / %s)",
+	"emergency.done":          "🎉 %s खाली हुआ",
+
+	"audit_report.valid": "✅ हस्ताक्षर सही है, कुंजी %s से बनाया गया।",
+	"estimate.title":     "📏 हर क्लीनर कितनी जगह खाली कर सकता है (स्तर %s):",
+	"estimate.items":     "%d मिले",
+	"estimate.total":     "कुल",
+	"estimate.note":      "बड़े फ़ोल्डरों का नमूना लिया जाता है और पिछले हफ़्ते मापे गए आकार दोबारा इस्तेमाल होते हैं, इसलिए ये अनुमान हैं; \"?\" उन क्लीनरों को दिखाता है जो अपना आकार नहीं माप सकते। सटीक सूची के लिए saafsafai --dry-run चलाएँ।",
+
+	"help": `saafsafai - सिस्टम सफ़ाई उपयोगिता
+
+उपयोग:
+  saafsafai           कॉन्फ़िगरेशन के अनुसार सफ़ाई चलाएँ
+  saafsafai --dry-run बिना कुछ बदले बताएँ कि क्या साफ़ होगा
+  saafsafai --catch-up
+                      मशीन बंद रहते छूटा निर्धारित रन करें, अगर कोई छूटा हो
+  saafsafai --only downloads,node_modules
+                      सिर्फ़ ये क्लीनर चलाएँ, अगर कॉन्फ़िग में सक्षम हों
+  saafsafai --skip packages
+                      ये क्लीनर न चलाएँ
+  saafsafai --level light|normal|aggressive
+                      light: सिर्फ़ आपकी अपनी फ़ाइलें, दोगुनी उम्र; aggressive: आधी उम्र और बजट
+  saafsafai --verbose रिपोर्ट में हर क्लीनर का लिया समय शामिल करें
+  saafsafai --json    सारांश JSON के रूप में छापें
+  saafsafai --no-color
+                      टर्मिनल पर भी सारांश बिना रंगों के छापें (या NO_COLOR सेट करें)
+  saafsafai --setup   इंटरैक्टिव सेटअप चलाएँ
+  saafsafai --root DIR
+                      DIR को होम डायरेक्टरी मानकर साफ़ करें, कॉन्फ़िग और स्थिति उसी में (कंटेनरों के लिए)
+  saafsafai --home DIR
+                      DIR को आपकी जैसी बनावट वाली होम डायरेक्टरी मानकर साफ़ करें, जैसे किसी और उपयोगकर्ता की या बैकअप
+  saafsafai --config FILE --downloads DIR --scan DIR,... --state-dir DIR --log-dir DIR
+                      कॉन्फ़िग, डाउनलोड्स फ़ोल्डर, स्कैन किए जाने वाले फ़ोल्डर, स्थिति या लॉग अलग-अलग दें
+  saafsafai --no-systemd
+                      systemd यूनिट कभी इंस्टॉल न करें या न छुएँ; --setup के साथ केवल कॉन्फ़िग लिखें
+  saafsafai --help    यह सहायता संदेश दिखाएँ
+  saafsafai --version संस्करण जानकारी दिखाएँ
+
+कमांड:
+  saafsafai setup [--yes|--gui] [--run-on login|timer|both] [--schedule daily] [--clean-downloads=true ...]
+                      saafsafai कॉन्फ़िगर करें; --yes के साथ, केवल फ़्लैग से
+  saafsafai service install|repair [--system]
+                      कॉन्फ़िग के लिए यूनिट इंस्टॉल करें, या उन्हें और बाइनरी फिर से इंस्टॉल करें, अंतर बताते हुए
+  saafsafai doctor [--system]
+                      आम समस्याओं का निदान करें और समाधान सुझाएँ
+  saafsafai selftest [--keep]
+                      अस्थायी फ़ोल्डर में एक नमूना होम पर क्लीनरों की जाँच करें, और कुछ बदले बिना
+  saafsafai restore [<path|id>] [--to DIR] [--force] [--system]
+                      क्वारंटीन की गई चीज़ें दिखाएँ, या एक वापस लाएँ
+  saafsafai verify [--system]
+                      क्वारंटीन की गई चीज़ों को उनके चेकसम से जाँचें
+  saafsafai daemon [--interval 24h] [--listen ADDR] [--system]
+                      तय अंतराल पर सफ़ाई करें, D-Bus से नियंत्रित
+  saafsafai tray [--system]
+                      डेमन को सिस्टम ट्रे में दिखाएँ
+  saafsafai find PATTERN [--system]
+                      बताएँ कि PATTERN से मेल खाती फ़ाइलों को रनों ने कहाँ ले जाया, क्वारंटाइन किया या हटाया
+  saafsafai suggest
+                      रनों द्वारा रखी गई फ़ाइलों से Downloads के नियम सुझाएँ, और स्वीकार किए गए जोड़ें
+  saafsafai suggest --others
+                      Others में जमा हो रहे फ़ाइल प्रकार देखें, और जिन्हें श्रेणी दी जाए उन्हें आगे से वहीं रखें
+  saafsafai organize [--initial] [--dry-run] [--yes]
+                      क्या कहाँ जाएगा इसकी योजना से Downloads व्यवस्थित करें, प्रगति के साथ; --initial बिना श्रेणी वाली फ़ाइलें क्वारंटीन करता है
+  saafsafai similar
+                      Downloads में मिलते-जुलते नाम वाली फ़ाइलें समूहों में दिखाएँ, जैसे report(1).pdf और report-final.pdf
+  saafsafai audit [--output FILE] [--key FILE] [--level LEVEL] [--system]
+                      हर क्लीनर कितनी जगह खाली कर सकता है, बिना कुछ बदले, हस्ताक्षरित JSON में बताएँ
+  saafsafai audit --verify FILE
+                      ऑडिट रिपोर्ट का हस्ताक्षर जाँचें
+  saafsafai remote [--plan-only] [--level LEVEL] [--copy] [--system] [user@]HOST
+                      SSH से दूसरी मशीन पर सफ़ाई (--plan-only के साथ ड्राई रन) चलाएँ और उसका JSON सारांश दिखाएँ
+  saafsafai estimate [--level LEVEL] [--system]
+                      अनुमान लगाएँ कि हर क्लीनर कितनी जगह खाली कर सकता है
+  saafsafai config schema
+                      कॉन्फ़िग फ़ाइल का JSON Schema छापें
+  saafsafai emergency [--free SIZE] [--system]
+                      जल्दी जगह खाली करें (रद्दी, क्वारंटीन, कैश, docker), SIZE खाली होने तक
+  saafsafai logs [--last N] [--follow] [--date YYYY-MM-DD] [--run ID] [--file PATH] [--system]
+                      हाल के रन के लॉग दिखाएँ
+  saafsafai stats diff [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--system]
+                      पिछले दो रन (या दो तारीखों के रन) की तुलना करें
+  saafsafai stats sources [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--system]
+                      दिखाएँ कि Downloads की अव्यवस्था किन फ़ाइल प्रकारों और साइटों से आती है
+  saafsafai stats trends [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--system]
+                      दिखाएँ कि ट्रैक किए गए फ़ोल्डर कितने बढ़े, और क्या सफ़ाई बराबर चल रही है (analytics चाहिए)
+  saafsafai version [--json]
+                      संस्करण, कमिट, Go संस्करण, बिल्ड टैग और शामिल क्लीनर दिखाएँ
+  saafsafai completion bash|zsh|fish
+                      शेल कम्प्लीशन स्क्रिप्ट छापें
+
+सिस्टम-व्यापी मोड (root के रूप में चलाएँ):
+  saafsafai --setup --system  सिस्टम सेवा कॉन्फ़िगर और इंस्टॉल करें
+  saafsafai --system          विशेषाधिकार वाले क्लीनर और, यदि सक्षम हो, हर उपयोगकर्ता की सफ़ाई चलाएँ
+
+निकास कोड:
+  0  बिना त्रुटि के सफ़ाई हुई
+  1  गंभीर त्रुटि
+  2  पूरा हुआ, पर कुछ आइटम विफल रहे
+  3  साफ़ करने के लिए कुछ नहीं
+  4  एक और रन चल रहा है
+  5  अमान्य कमांड लाइन
+
+कॉन्फ़िगरेशन फ़ाइल: ~/.config/saafsafai.json (सिस्टम: /etc/saafsafai/saafsafai.json)
+लॉग: ~/.local/share/saafsafai/logs/ (सिस्टम: /var/log/saafsafai/)`,
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// appleDoubleMagic starts the ._ files macOS writes on file systems that
+// can't hold its extended attributes.
+const appleDoubleMagic = 0x00051607
+
+// metadataNames are the files Finder and Explorer leave in the folders they
+// show, lowercase as Windows' may be in any case.
+var metadataNames = []string{".ds_store", "thumbs.db", "ehthumbs.db", "desktop.ini"}
+
+type MetadataFilesConfig struct {
+	// Roots are the directories looked in, relative to the home directory
+	// unless absolute; empty looks everywhere in it.
+	Roots []string `json:"roots"`
+}
+
+// scanMetadataFiles is the metadata file matcher for the home directory
+// scan, unless the config has roots of its own to look in.
+func (app *App) scanMetadataFiles(config Config) homeMatcher {
+	if len(config.MetadataFiles.Roots) > 0 {
+		return nil
+	}
+	return app.matchMetadataFiles()
+}
+
+// matchMetadataFiles collects the .DS_Store, AppleDouble, Thumbs.db and
+// desktop.ini files, leaving saafsafai's own state alone.
+func (app *App) matchMetadataFiles() homeMatcher {
+	return func(path string, d fs.DirEntry) error {
+		if d.IsDir() {
+			if path == app.stateDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && isMetadataFile(path, d.Name()) {
+			app.scan.metadataFiles = append(app.scan.metadataFiles, path)
+		}
+		return nil
+	}
+}
+
+func isMetadataFile(path, name string) bool {
+	for _, n := range metadataNames {
+		if strings.EqualFold(name, n) {
+			return true
+		}
+	}
+	// Only ._ files that really are AppleDouble, not just named like one
+	return strings.HasPrefix(name, "._") && len(name) > 2 && isAppleDouble(path)
+}
+
+// metadataKind returns the kind of metadata file name is, for the summary:
+// its name, or ._* for AppleDouble files.
+func metadataKind(name string) string {
+	if strings.HasPrefix(name, "._") {
+		return "._*"
+	}
+	return name
+}
+
+func isAppleDouble(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	var magic [4]byte
+	if _, err := io.ReadFull(f, magic[:]); err != nil {
+		return false
+	}
+	return binary.BigEndian.Uint32(magic[:]) == appleDoubleMagic
+}
+
+// cleanMetadataFiles removes the files macOS and Windows file managers leave
+// behind, in the home directory or the configured roots. They're rebuilt
+// when needed, so there's no age to wait for.
+func (app *App) cleanMetadataFiles(config Config) error {
+	if err := app.scanRoots(config, config.MetadataFiles.Roots, app.matchMetadataFiles()); err != nil {
+		return fmt.Errorf("error scanning for metadata files: %w", err)
+	}
+
+	for _, path := range app.scan.metadataFiles {
+		info, err := os.Lstat(path)
+		if err != nil {
+			continue
+		}
+		if err := app.remove(path); err != nil {
+			app.skipItem("Failed to remove metadata file", path, err)
+			continue
+		}
+		app.addGroupedItem(&app.summary.RemovedMetadata, metadataKind(filepath.Base(path)), fmt.Sprintf("%s (%s)", app.displayPath(path), formatSize(info.Size())))
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"slices"
+)
+
+// configVersion is the version of the config schema this saafsafai writes.
+// Configs from before versioning count as version 1.
+const configVersion = 2
+
+// configMigrations[i] migrates a version i+1 config, as decoded JSON, to
+// version i+2.
+var configMigrations = []func(raw map[string]any){
+	// 2: run_on replaced inferring the triggers from schedule, which could
+	// be "boot" for no timer
+	func(raw map[string]any) {
+		if _, ok := raw["run_on"]; ok {
+			return
+		}
+		switch schedule, _ := raw["schedule"].(string); schedule {
+		case scheduleBoot:
+			raw["run_on"] = runOnLogin
+			delete(raw, "schedule")
+		case "":
+			raw["run_on"] = runOnLogin
+		default:
+			raw["run_on"] = runOnTimer
+		}
+	},
+}
+
+// migrateConfig brings the config in data, read from path, up to the
+// current version: keys are renamed and settings added since get their
+// defaults. The original is backed up and the file rewritten, except in dry
+// runs or when path isn't the App's own config. Keys this version doesn't
+// know are kept, so nothing the user set is silently dropped.
+func (app *App) migrateConfig(path string, data []byte) ([]byte, error) {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config JSON: %w", err)
+	}
+
+	version := 1
+	if v, ok := raw["version"]; ok {
+		n, ok := v.(float64)
+		if !ok || n < 1 || n != math.Trunc(n) {
+			return nil, fmt.Errorf("invalid config version %v", v)
+		}
+		version = int(n)
+	}
+	switch {
+	case version == configVersion:
+		return data, nil
+	case version > configVersion:
+		log.Printf("Warning: %s is from a newer saafsafai (config version %d, this one knows %d); settings it doesn't know are ignored",
+			path, version, configVersion)
+		return data, nil
+	}
+
+	for v := version; v < configVersion; v++ {
+		configMigrations[v-1](raw)
+	}
+
+	config := defaultConfig()
+	intermediate, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(intermediate, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config JSON: %w", err)
+	}
+	config.Version = configVersion
+
+	migrated, err := mergeUnknownKeys(config, raw)
+	if err != nil {
+		return nil, err
+	}
+	if app.dryRun || path != app.configPath {
+		return migrated, nil
+	}
+
+	backup := fmt.Sprintf("%s.v%d.bak", path, version)
+	if _, err := os.Stat(backup); os.IsNotExist(err) {
+		if err := os.WriteFile(backup, data, 0600); err != nil {
+			return nil, fmt.Errorf("failed to back up the config: %w", err)
+		}
+	}
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	if err := os.WriteFile(path+".tmp", migrated, perm); err != nil {
+		return nil, fmt.Errorf("failed to write migrated config: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return nil, fmt.Errorf("failed to write migrated config: %w", err)
+	}
+	log.Printf("Migrated %s from config version %d to %d; the original is in %s", path, version, configVersion, backup)
+	return migrated, nil
+}
+
+// mergeUnknownKeys returns config as JSON with the keys of raw that Config
+// doesn't have added back, at any depth.
+func mergeUnknownKeys(config Config, raw map[string]any) ([]byte, error) {
+	known, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	var merged map[string]any
+	if err := json.Unmarshal(known, &merged); err != nil {
+		return nil, err
+	}
+	unknown := addUnknownKeys(merged, raw, "")
+	if len(unknown) == 0 {
+		return known, nil
+	}
+	slices.Sort(unknown)
+	for _, key := range unknown {
+		log.Printf("Warning: config key %q isn't known to this version; kept as is", key)
+	}
+	return json.MarshalIndent(merged, "", "  ")
+}
+
+// addUnknownKeys copies the keys of raw missing from known into it and
+// returns their paths.
+func addUnknownKeys(known, raw map[string]any, prefix string) []string {
+	var unknown []string
+	for key, value := range raw {
+		existing, ok := known[key]
+		if !ok {
+			known[key] = value
+			unknown = append(unknown, prefix+key)
+			continue
+		}
+		if knownObject, ok := existing.(map[string]any); ok {
+			if rawObject, ok := value.(map[string]any); ok {
+				unknown = append(unknown, addUnknownKeys(knownObject, rawObject, prefix+key+".")...)
+			}
+		}
+	}
+	return unknown
+}
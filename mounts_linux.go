@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// mountedFilesystems lists the file systems on block devices, each once
+// under the first mount point it has (a btrfs mounted for several
+// subvolumes is one file system).
+func mountedFilesystems() []filesystem {
+	var filesystems []filesystem
+	seen := make(map[string]bool)
+	for _, fs := range mounts() {
+		if !strings.HasPrefix(fs.source, "/dev/") || seen[fs.source] {
+			continue
+		}
+		seen[fs.source] = true
+		filesystems = append(filesystems, fs)
+	}
+	return filesystems
+}
+
+// mountOf returns the mount path is under, whatever its source (a ZFS
+// dataset has its name for one).
+func mountOf(path string) (filesystem, bool) {
+	var found filesystem
+	ok := false
+	for _, fs := range mounts() {
+		// A later mount on the same point hides the earlier one
+		if (path == fs.mountPoint || isUnder(path, fs.mountPoint) || fs.mountPoint == "/") && (!ok || len(fs.mountPoint) >= len(found.mountPoint)) {
+			found, ok = fs, true
+		}
+	}
+	return found, ok
+}
+
+// mounts lists every mount, in the order they were made.
+func mounts() []filesystem {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var mounts []filesystem
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw
+		fields, rest, ok := strings.Cut(scanner.Text(), " - ")
+		if !ok {
+			continue
+		}
+		mount, after := strings.Fields(fields), strings.Fields(rest)
+		if len(mount) < 5 || len(after) < 2 {
+			continue
+		}
+		mounts = append(mounts, filesystem{
+			source:     unescapeMount(after[1]),
+			mountPoint: unescapeMount(mount[4]),
+			fsType:     after[0],
+			noAtime:    slices.Contains(strings.Split(mount[5], ","), "noatime"),
+		})
+	}
+	return mounts
+}
+
+// unescapeMount undoes mountinfo's octal escapes of spaces, tabs, newlines
+// and backslashes in paths.
+func unescapeMount(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+// mountedFilesystems lists no file systems; post-clean hooks need Linux's
+// mountinfo to find them.
+func mountedFilesystems() []filesystem {
+	return nil
+}
+
+// mountOf finds no mount, for the same reason.
+func mountOf(path string) (filesystem, bool) {
+	return filesystem{}, false
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// composeName composes name to Unicode NFC, so two spellings of the same
+// name compare equal: macOS (and files synced from it) write names
+// decomposed, so "é" can be "e" followed by U+0301. Names that aren't valid
+// UTF-8 are left alone.
+func composeName(name string) string {
+	if isASCII(name) || !utf8.ValidString(name) {
+		return name
+	}
+	return norm.NFC.String(name)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// displayName makes s safe to print on one line: control characters such as
+// newlines are escaped (\n, \t, \x1b), as are the bytes of s that aren't
+// valid UTF-8, instead of being dropped, mangled or breaking up the report.
+func displayName(s string) string {
+	if utf8.ValidString(s) && strings.IndexFunc(s, unicode.IsControl) < 0 {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(&b, `\x%02x`, s[i])
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < utf8.RuneSelf && unicode.IsControl(r):
+			fmt.Fprintf(&b, `\x%02x`, r)
+		case unicode.IsControl(r):
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// freeName returns name, or name with a counter before its extension ext
+// ("photo_1.jpg", "backup_1.tar.gz") if dir has it already (see nameTaken).
+// Without ext, name's extension is one of the built-in ones. The name
+// returned is added to the names read of dir, as the caller is about to use
+// it.
+func (app *App) freeName(dir, name, ext string) string {
+	if ext == "" {
+		ext = fileExt(Config{}, name)
+	}
+	// ext is in lower case, the name's own may not be
+	stem, suffix := name, ""
+	if len(ext) < len(name) && strings.EqualFold(name[len(name)-len(ext):], ext) {
+		stem, suffix = name[:len(name)-len(ext)], name[len(name)-len(ext):]
+	}
+	free := name
+	for counter := 1; app.nameTaken(dir, free); counter++ {
+		free = fmt.Sprintf("%s_%d%s", stem, counter, suffix)
+	}
+	if names, ok := app.dirNames[dir]; ok {
+		names[composeName(free)] = true
+	}
+	return free
+}
+
+// nameTaken reports whether dir has an entry called name, or the same name
+// composed differently.
+func (app *App) nameTaken(dir, name string) bool {
+	if _, err := os.Lstat(filepath.Join(dir, name)); err == nil {
+		return true
+	}
+	// An ASCII name has no other spelling
+	if isASCII(name) {
+		return false
+	}
+	return app.composedNames(dir)[composeName(name)]
+}
+
+// composedNames returns the composed names of dir's entries, read once a
+// run, rather than for every file filed into it.
+func (app *App) composedNames(dir string) map[string]bool {
+	if names, ok := app.dirNames[dir]; ok {
+		return names
+	}
+	names := make(map[string]bool)
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		names[composeName(entry.Name())] = true
+	}
+	if app.dirNames == nil {
+		app.dirNames = make(map[string]map[string]bool)
+	}
+	app.dirNames[dir] = names
+	return names
+}
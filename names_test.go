@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+func TestComposeName(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"ASCII", "report.pdf", "report.pdf"},
+		{"one mark", "cafe\u0301.txt", "caf\u00e9.txt"},
+		{"already composed", "caf\u00e9.txt", "caf\u00e9.txt"},
+		// Vietnamese ệ is e with a dot below and a circumflex
+		{"two marks", "Vie\u0323\u0302t.txt", "Vi\u1ec7t.txt"},
+		{"two marks out of order", "Vie\u0302\u0323t.txt", "Vi\u1ec7t.txt"},
+		{"composed letter and a mark", "Vi\u00ea\u0323t.txt", "Vi\u1ec7t.txt"},
+		{"kana", "\u304b\u3099.txt", "\u304c.txt"},
+		{"Hangul", "\u1112\u1161\u11ab.txt", "\ud55c.txt"},
+		{"invalid UTF-8", "bad\xffe\u0301", "bad\xffe\u0301"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := composeName(tt.in); got != tt.want {
+				t.Errorf("composeName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFreeName(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"photo.jpg", "Vi\u1ec7t.txt", "backup.tar.gz", "Backup.TAR.XZ", "old.tar.lzo"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := &App{}
+	tests := []struct {
+		name, in, ext, want string
+	}{
+		{"free", "notes.txt", "", "notes.txt"},
+		{"taken", "photo.jpg", "", "photo_1.jpg"},
+		{"taken by another spelling", "Vie\u0323\u0302t.txt", "", "Vie\u0323\u0302t_1.txt"},
+		// Added to the names read, though no file was created with it
+		{"given before", "Vie\u0323\u0302t_1.txt", "", "Vie\u0323\u0302t_1_1.txt"},
+		{"built-in compound extension", "backup.tar.gz", "", "backup_1.tar.gz"},
+		{"compound extension in upper case", "Backup.TAR.XZ", ".tar.xz", "Backup_1.TAR.XZ"},
+		{"configured compound extension", "old.tar.lzo", ".tar.lzo", "old_1.tar.lzo"},
+		{"unconfigured compound extension", "old.tar.lzo", "", "old.tar_1.lzo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := app.freeName(dir, tt.in, tt.ext); got != tt.want {
+				t.Errorf("freeName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"plain", "report.pdf", "report.pdf"},
+		{"newline", "a\nb.pdf", `a\nb.pdf`},
+		{"tab and carriage return", "a\tb\r.pdf", `a\tb\r.pdf`},
+		{"escape sequence", "red\x1b[31m.txt", `red\x1b[31m.txt`},
+		{"C1 control", "csi\u009b.txt", `csi\u009b.txt`},
+		{"invalid UTF-8", "bad\xff\xfe.pdf", `bad\xff\xfe.pdf`},
+		{"leading dash", "-rf.pdf", "-rf.pdf"},
+		{"non-ASCII", "Vi\u1ec7t.txt", "Vi\u1ec7t.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := displayName(tt.in); got != tt.want {
+				t.Errorf("displayName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestOddNames files and removes Downloads files with names a shell or a
+// terminal would trip over, checking that they're left as they were on disk
+// and escaped in the report.
+func TestOddNames(t *testing.T) {
+	home := t.TempDir()
+	app := newHomeApp(home)
+	if err := os.MkdirAll(app.downloadsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := defaultConfig()
+	filed := []string{"a\nb.pdf", "-rf.pdf", "bad\xff.pdf", "red\x1b[31m.txt", "csi\u009b.txt", "tab\there.txt"}
+	removed := []string{"-\n.tmp", "bad\xfe.part"}
+	for _, name := range append(filed, removed...) {
+		if err := os.WriteFile(filepath.Join(app.downloadsDir, name), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := app.cleanDownloads(config); err != nil {
+		t.Fatalf("cleanDownloads: %v", err)
+	}
+
+	for _, name := range filed {
+		folder := categoryFolder(app.categoryNames, categoryFor(config, fileExt(config, name)))
+		if _, err := os.Lstat(filepath.Join(app.downloadsDir, folder, name)); err != nil {
+			t.Errorf("%q wasn't filed into %s: %v", name, folder, err)
+		}
+	}
+	for _, name := range removed {
+		if _, err := os.Lstat(filepath.Join(app.downloadsDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%q wasn't removed: %v", name, err)
+		}
+	}
+
+	report := app.summaryText()
+	for _, name := range append(filed, removed...) {
+		if !strings.Contains(report, displayName(name)) {
+			t.Errorf("the report doesn't name %q as %q", name, displayName(name))
+		}
+	}
+	for line := range strings.SplitSeq(report, "\n") {
+		if strings.ContainsFunc(line, unicode.IsControl) || !utf8.ValidString(line) {
+			t.Errorf("the report has a line with a raw control character or invalid UTF-8: %q", line)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import "syscall"
+
+// networkFSMagic are the statfs types of network file systems: NFS, SMB and
+// CIFS, AFS, Ceph, 9P and FUSE, which sshfs and most remote mounts use.
+var networkFSMagic = map[int64]bool{
+	0x6969:     true,
+	0x517b:     true,
+	0xff534d42: true,
+	0xfe534d42: true,
+	0x5346414f: true,
+	0x00c36400: true,
+	0x01021997: true,
+	0x65735546: true,
+}
+
+// isNetworkFS reports whether path is on a network file system.
+func isNetworkFS(path string) bool {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return false
+	}
+	return networkFSMagic[int64(fs.Type)]
+}
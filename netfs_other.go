@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+// isNetworkFS reports whether path is on a network file system; outside
+// Linux it isn't told, and local defaults apply.
+func isNetworkFS(path string) bool {
+	return false
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// What a run does with Downloads files without an extension, by the
+// config's no_extension.
+const (
+	// noExtensionSniff files them by what their content looks like, the
+	// default
+	noExtensionSniff = "sniff"
+	// noExtensionLeave leaves them all where they are
+	noExtensionLeave = "leave"
+	// noExtensionReview moves them into the Review folder, to look at
+	noExtensionReview = "review"
+	// noExtensionOthers files them into Others, like unknown extensions
+	noExtensionOthers = "others"
+)
+
+var noExtensionModes = []string{noExtensionSniff, noExtensionLeave, noExtensionReview, noExtensionOthers}
+
+// reviewCategory is where no_extension "review" moves the Downloads files
+// without an extension.
+const reviewCategory = "Review"
+
+// executableMagic are how executable binaries start: ELF, Mach-O (either
+// byte order, and universal) and Windows PE.
+var executableMagic = [][]byte{
+	[]byte("\x7fELF"),
+	{0xfe, 0xed, 0xfa, 0xce}, {0xfe, 0xed, 0xfa, 0xcf},
+	{0xce, 0xfa, 0xed, 0xfe}, {0xcf, 0xfa, 0xed, 0xfe},
+	{0xca, 0xfe, 0xba, 0xbe},
+	[]byte("MZ"),
+}
+
+func validateNoExtension(mode string) error {
+	if mode != "" && !slices.Contains(noExtensionModes, mode) {
+		return fmt.Errorf("invalid no_extension %q, expected one of: %s", mode, strings.Join(noExtensionModes, ", "))
+	}
+	return nil
+}
+
+// downloadCategory returns the category the Downloads file at path is
+// filed into, or "" if it stays where it is. Only files without an
+// extension are looked into; scripts and programs among them stay put
+// unless no_extension is "others", as moving one can break what runs it.
+func downloadCategory(config Config, path, ext string, info os.FileInfo) string {
+	if ext != "" || config.NoExtension == noExtensionOthers {
+		return categoryFor(config, ext)
+	}
+	if config.NoExtension == noExtensionLeave {
+		return ""
+	}
+	head := fileHead(path)
+	if info.Mode()&0111 != 0 || bytes.HasPrefix(head, []byte("#!")) {
+		return ""
+	}
+	for _, magic := range executableMagic {
+		if bytes.HasPrefix(head, magic) {
+			return ""
+		}
+	}
+	if config.NoExtension == noExtensionReview {
+		return reviewCategory
+	}
+	if category := typeCategory(sniffedType(head)); category != "" {
+		return category
+	}
+	return otherCategory
+}
+
+// fileHead returns the first 512 bytes of the file at path, as many as
+// http.DetectContentType looks at.
+func fileHead(path string) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil
+	}
+	return head[:n]
+}
+
+// sniffedType returns the MIME type content starting with head looks like,
+// without its parameters, or nothing if it looks like no type in
+// particular.
+func sniffedType(head []byte) string {
+	if len(head) == 0 {
+		return ""
+	}
+	mimeType, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	if mimeType == "application/octet-stream" {
+		return ""
+	}
+	return mimeType
+}
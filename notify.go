@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	notifyNever      = "never"
+	notifyAlways     = "always"
+	notifyErrorsOnly = "errors_only"
+)
+
+type NotifyConfig struct {
+	// Mode is "never" (the default), "always" or "errors_only". In
+	// errors_only mode clean runs stay silent and only runs where something
+	// failed are reported.
+	Mode    string      `json:"mode"`
+	Desktop bool        `json:"desktop"`
+	Email   EmailConfig `json:"email"`
+}
+
+// smtpPasswordEnv names the environment variable the SMTP password can be
+// given in, instead of a file or the config.
+const smtpPasswordEnv = "SAAFSAFAI_SMTP_PASSWORD"
+
+type EmailConfig struct {
+	SMTPHost string `json:"smtp_host"`
+	SMTPPort int    `json:"smtp_port"`
+	Username string `json:"username"`
+	// PasswordFile is the absolute path of a file holding the SMTP
+	// password, which only its owner may read, kept out of the config.
+	PasswordFile string `json:"password_file"`
+	// Password is the SMTP password itself, which makes the config a
+	// secret, written for its owner's eyes only
+	Password string   `json:"password"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// password returns the SMTP password: from the SAAFSAFAI_SMTP_PASSWORD
+// environment variable, the password file or the config, in that order.
+func (cfg EmailConfig) password() (string, error) {
+	if password := os.Getenv(smtpPasswordEnv); password != "" {
+		return password, nil
+	}
+	if cfg.PasswordFile == "" {
+		return cfg.Password, nil
+	}
+	info, err := os.Stat(cfg.PasswordFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the password file: %w", err)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("the password file %s can be read by others, chmod 600 it", cfg.PasswordFile)
+	}
+	data, err := os.ReadFile(cfg.PasswordFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the password file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func (app *App) notify(cfg NotifyConfig) {
+	if app.dryRun {
+		return
+	}
+
+	switch cfg.Mode {
+	case notifyAlways:
+	case notifyErrorsOnly:
+		if app.failures() == 0 {
+			return
+		}
+	case "", notifyNever:
+		return
+	default:
+		log.Printf("Unknown notify mode %q, not sending notifications", cfg.Mode)
+		return
+	}
+
+	subject, body := app.notificationText()
+
+	if cfg.Desktop && app.undoable > 0 {
+		// With an Undo button, if the notification server has buttons
+		n, err := showUndoNotification(subject, body)
+		if err == nil {
+			app.undoNotice = n
+			cfg.Desktop = false
+		}
+	}
+	if cfg.Desktop {
+		if err := sendDesktopNotification(subject, body); err != nil {
+			log.Printf("Failed to send desktop notification: %v", err)
+		}
+	}
+
+	if cfg.Email.SMTPHost != "" {
+		if err := sendEmail(cfg.Email, subject, body); err != nil {
+			log.Printf("Failed to send email notification: %v", err)
+		}
+	}
+}
+
+func (app *App) notificationText() (string, string) {
+	host, _ := os.Hostname()
+
+	if app.failures() == 0 {
+		return T("notify.subject", host), app.summaryText()
+	}
+
+	var body strings.Builder
+	body.WriteString(T("notify.errors_intro", app.failures(), host) + "\n\n")
+	// The first of each; the summary below counts the rest
+	errs, skipped := app.summary.Errors.Sample, app.summary.Skipped.Sample
+	for _, e := range errs[:min(app.summaryMax(), len(errs))] {
+		fmt.Fprintf(&body, "  - %s\n", e)
+	}
+	for _, item := range skipped[:min(app.summaryMax(), len(skipped))] {
+		fmt.Fprintf(&body, "  - %s\n", T("summary.skipped_item", item))
+	}
+	body.WriteString("\n" + T("notify.full_report") + "\n\n")
+	body.WriteString(app.summaryText())
+
+	return T("notify.errors_subject", app.failures(), host), body.String()
+}
+
+func sendDesktopNotification(subject, body string) error {
+	if _, err := exec.LookPath("notify-send"); err != nil {
+		return fmt.Errorf("notify-send is not installed")
+	}
+	return exec.Command("notify-send", "--app-name=saafsafai", "--", subject, body).Run()
+}
+
+func sendEmail(cfg EmailConfig, subject, body string) error {
+	if cfg.From == "" || len(cfg.To) == 0 {
+		return fmt.Errorf("email notifications need both from and to addresses")
+	}
+
+	port := cfg.SMTPPort
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(port))
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		password, err := cfg.password()
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", cfg.Username, password, cfg.SMTPHost)
+	}
+
+	// Port 465 speaks TLS from the start; everything else upgrades with
+	// STARTTLS when the server offers it
+	if port != 465 {
+		return smtp.SendMail(addr, auth, cfg.From, cfg.To, []byte(msg.String()))
+	}
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: cfg.SMTPHost})
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, cfg.SMTPHost)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(cfg.From); err != nil {
+		return err
+	}
+	for _, to := range cfg.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(msg.String())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
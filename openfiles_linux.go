@@ -0,0 +1,19 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// openFiles returns the files the processes we can see have open, going by
+// their /proc/PID/fd links.
+func openFiles() map[string]bool {
+	open := make(map[string]bool)
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	for _, fd := range fds {
+		if target, err := os.Readlink(fd); err == nil && filepath.IsAbs(target) {
+			open[target] = true
+		}
+	}
+	return open
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// organizeBatch is how many Downloads entries organize reads at a time, and
+// how many files it handles between progress lines.
+const organizeBatch = 1000
+
+// organizeStep is what organize plans to do with one Downloads file.
+type organizeStep struct {
+	path string
+	size int64
+	kind downloadKind
+	// category is the file's category, if it's filed
+	category string
+	// quarantined is a file no rule claims that --initial moves into the
+	// quarantine instead of Others
+	quarantined bool
+}
+
+func defineOrganizeCommand(fs *flag.FlagSet) func(args []string) error {
+	initial := fs.Bool("initial", false, "tune for the first run on a years-old Downloads folder: quarantine the files no category claims instead of filing them into Others")
+	dryRun := fs.Bool("dry-run", false, "print the plan without changing anything")
+	yes := fs.Bool("yes", false, "carry out the plan without asking")
+
+	return func(args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+		}
+		app, err := NewApp()
+		if err != nil {
+			return err
+		}
+		app.dryRun = *dryRun
+		app.summary.DryRun = *dryRun
+		return app.organize(*initial, *yes)
+	}
+}
+
+// organize organizes the Downloads folder as a run's downloads cleaner
+// would, planned in full first: how many files go where and how much space
+// that is. From a terminal the plan is carried out once confirmed, or with
+// yes; it goes a batch at a time, with progress. With initial, the files no
+// category claims are moved into the quarantine, to be restored or left to
+// expire, rather than filed into Others. The run is recorded like any
+// other, so saafsafai undo puts everything back.
+func (app *App) organize(initial, yes bool) error {
+	config, err := app.loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := config.validate(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(app.downloadsDir); err != nil {
+		return fmt.Errorf("failed to read downloads directory: %w", err)
+	}
+
+	unlock, err := app.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	app.pickLevel(config)
+	app.cleaner = "downloads"
+	app.summary.RunID = newRunID()
+	app.quarantine = config.Quarantine.Enabled
+	app.trashConfig = config.Trash
+
+	fmt.Println(T("organize.planning", app.displayPath(app.downloadsDir)))
+	start := time.Now()
+	plan, err := app.organizePlan(config, initial)
+	if err != nil {
+		return err
+	}
+	if len(plan) == 0 {
+		fmt.Println(T("organize.nothing"))
+		return nil
+	}
+	app.printOrganizePlan(config, plan, time.Since(start))
+
+	if app.dryRun {
+		return nil
+	}
+	if !yes {
+		if !app.isInteractive() {
+			fmt.Println(T("organize.confirm_hint"))
+			return nil
+		}
+		ok, err := app.askYesNo(bufio.NewReader(os.Stdin), T("organize.confirm", len(plan)))
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	finish, err := app.beginDownloadsRun()
+	if err != nil {
+		return err
+	}
+	defer finish()
+	if config.BrowserHistory {
+		app.origins = app.downloadOrigins()
+	}
+	app.renameCategoryFolders(config)
+	app.runOrganizePlan(config, plan)
+	app.endDownloadsRun(config, start)
+	fmt.Println(T("organize.done", app.itemCount(), app.summary.RunID))
+	return nil
+}
+
+// beginDownloadsRun starts a run of the downloads cleaner outside the
+// scheduled ones, recorded the same way: with its report, audit and journal.
+// The returned function closes them.
+func (app *App) beginDownloadsRun() (func(), error) {
+	app.summary.Report = app.runLogPath(app.summary.RunID)
+	finish := func() {
+		app.closeItemStream()
+		app.closeAudit()
+	}
+	if err := app.recoverJournal(); err != nil {
+		app.logError("Failed to recover the interrupted run: %v", err)
+	}
+	if err := app.openJournal(); err != nil {
+		finish()
+		return nil, err
+	}
+	return finish, nil
+}
+
+// endDownloadsRun writes the category indexes and summary of a run
+// beginDownloadsRun started at start, and records it in the history.
+func (app *App) endDownloadsRun(config Config, start time.Time) {
+	if config.CategoryIndex != "" {
+		app.writeCategoryIndexes(config.CategoryIndex)
+	}
+	app.closeJournal()
+	app.summary.Cleaners = map[string]int{app.cleaner: app.foundCount()}
+	app.summary.CleanerTimes = map[string]int64{app.cleaner: time.Since(start).Milliseconds()}
+	if err := app.printSummary(); err != nil {
+		app.logError("Failed to write the summary: %v", err)
+	}
+	if err := app.recordHistory(); err != nil {
+		app.logError("Failed to record run history: %v", err)
+	}
+}
+
+// organizePlan reads the Downloads folder a batch of entries at a time and
+// plans what to do with each file in it.
+func (app *App) organizePlan(config Config, initial bool) ([]organizeStep, error) {
+	dir, err := os.Open(app.downloadsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read downloads directory: %w", err)
+	}
+	defer dir.Close()
+
+	var plan []organizeStep
+	for {
+		entries, err := dir.ReadDir(organizeBatch)
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			path := filepath.Join(app.downloadsDir, entry.Name())
+			step := organizeStep{path: path, size: info.Size()}
+			step.kind, step.category = app.downloadKind(config, path, info)
+			step.quarantined = initial && step.category == otherCategory
+			plan = append(plan, step)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read downloads directory: %w", err)
+		}
+	}
+	// In name order, as the folder is listed
+	sort.SliceStable(plan, func(i, j int) bool { return plan[i].path < plan[j].path })
+	return plan, nil
+}
+
+// printOrganizePlan prints how many files of the plan go where, with their
+// sizes.
+func (app *App) printOrganizePlan(config Config, plan []organizeStep, took time.Duration) {
+	type row struct {
+		files int
+		bytes int64
+	}
+	rows := make(map[string]*row)
+	add := func(name string, size int64) {
+		if rows[name] == nil {
+			rows[name] = &row{}
+		}
+		rows[name].files++
+		rows[name].bytes += size
+	}
+	var total, quarantined int64
+	for _, step := range plan {
+		total += step.size
+		switch {
+		case step.quarantined:
+			add(T("organize.row_quarantine"), step.size)
+			quarantined += step.size
+		case step.kind == downloadFiled:
+			add(categoryFolder(config.CategoryNames, step.category)+"/", step.size)
+		case step.kind == downloadTemp:
+			add(T("organize.row_temp"), step.size)
+		case step.kind == downloadExpired:
+			add(T("organize.row_expired"), step.size)
+		case step.kind == downloadScripted:
+			add(T("organize.row_scripted"), step.size)
+		case step.kind == downloadLeft:
+			add(T("organize.row_left"), step.size)
+		default:
+			add(T("organize.row_untouched"), step.size)
+		}
+	}
+
+	names := sortedKeys(rows)
+	sort.SliceStable(names, func(i, j int) bool { return rows[names[i]].bytes > rows[names[j]].bytes })
+	width := columnWidth(14, names)
+	fmt.Println(T("organize.plan", len(plan), formatSize(total), took.Round(time.Millisecond)))
+	for _, name := range names {
+		fmt.Printf("   %s %7d %10s\n", padRight(name, width), rows[name].files, formatSize(rows[name].bytes))
+	}
+	fmt.Println()
+
+	if quarantined > 0 {
+		days := config.Quarantine.MaxAgeDays
+		if days == 0 {
+			days = quarantineMaxAge
+		}
+		fmt.Println(T("organize.quarantined", days))
+		budgetSize := config.Quarantine.Budget
+		if budgetSize == "" {
+			budgetSize = quarantineBudget
+		}
+		if budget, err := app.sizeBudget(budgetSize); err == nil && quarantined > budget {
+			fmt.Println(T("organize.over_budget", formatSize(quarantined), formatSize(budget)))
+		}
+	}
+}
+
+// runOrganizePlan carries out the plan, printing its progress every batch
+// of files.
+func (app *App) runOrganizePlan(config Config, plan []organizeStep) {
+	tty := isTerminal(os.Stdout)
+	var done int64
+	for i, step := range plan {
+		switch {
+		case step.quarantined:
+			app.quarantineDownload(config, step.path)
+		default:
+			app.handleDownload(config, step.path, step.kind, step.category)
+		}
+		done += step.size
+
+		if n := i + 1; n%organizeBatch == 0 || n == len(plan) {
+			progress := T("organize.progress", n, len(plan), formatSize(done))
+			if tty {
+				// Overwritten in place, the last one left standing
+				fmt.Print("\r" + progress)
+				if n == len(plan) {
+					fmt.Println()
+				}
+			} else {
+				fmt.Println(progress)
+			}
+		}
+	}
+}
+
+// quarantineDownload moves a Downloads file no category claims into the
+// quarantine, whatever the quarantine setting, for it to be restored or
+// expire.
+func (app *App) quarantineDownload(config Config, path string) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return
+	}
+	quarantine := app.quarantine
+	app.quarantine = true
+	err = app.remove(path)
+	app.quarantine = quarantine
+	if err != nil {
+		app.skipItem("Failed to quarantine download", path, err)
+		return
+	}
+	item := fmt.Sprintf("%s (%s)", filepath.Base(path), formatSize(info.Size()))
+	app.addGroupedItem(&app.summary.QuarantinedDownloads, extGroup(fileExt(config, path)), item)
+}
+
+// extGroup is the summary group of files with extension ext.
+func extGroup(ext string) string {
+	if ext != "" {
+		return "*" + ext
+	}
+	return T("organize.no_extension")
+}
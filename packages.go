@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+var errNeedsRoot = errors.New("root privileges required; run from a terminal or install the system service with 'sudo saafsafai --setup --system'")
+
+type packageManager struct {
+	name          string
+	cleanCache    []string
+	listOrphans   []string
+	parseOrphans  func(output string) []string
+	removeOrphans []string // the orphan package names are appended
+}
+
+var packageManagers = []packageManager{
+	{
+		name:          "apt-get",
+		cleanCache:    []string{"apt-get", "clean"},
+		listOrphans:   []string{"apt-get", "--simulate", "autoremove"},
+		parseOrphans:  parseAptOrphans,
+		removeOrphans: []string{"apt-get", "purge", "-y"},
+	},
+	{
+		name:          "dnf",
+		cleanCache:    []string{"dnf", "clean", "packages"},
+		listOrphans:   []string{"dnf", "repoquery", "--unneeded", "--quiet", "--queryformat", "%{name}\n"},
+		parseOrphans:  strings.Fields,
+		removeOrphans: []string{"dnf", "remove", "-y"},
+	},
+	{
+		name:          "pacman",
+		cleanCache:    []string{"pacman", "-Sc", "--noconfirm"},
+		listOrphans:   []string{"pacman", "-Qdtq"},
+		parseOrphans:  strings.Fields,
+		removeOrphans: []string{"pacman", "-Rns", "--noconfirm"},
+	},
+}
+
+func detectPackageManager() (packageManager, bool) {
+	for _, pm := range packageManagers {
+		if _, err := exec.LookPath(pm.name); err == nil {
+			return pm, true
+		}
+	}
+	return packageManager{}, false
+}
+
+// runPrivilegedCleaners runs the cleaners that need root. They run directly
+// in system mode, via sudo/pkexec when a user runs saafsafai from a terminal,
+// and are skipped for unattended user runs.
+func (app *App) runPrivilegedCleaners(config Config) {
+	if !config.CleanPackageCache && !config.RemoveOrphanPackages && !config.RemoveOldKernels {
+		return
+	}
+
+	pm, ok := detectPackageManager()
+	if !ok {
+		log.Printf("No supported package manager found (apt, dnf, pacman), skipping package cleanup")
+		return
+	}
+
+	if !app.dryRun && os.Geteuid() != 0 && !app.isInteractive() {
+		log.Printf("Skipping package cleanup: %v", errNeedsRoot)
+		return
+	}
+
+	if config.CleanPackageCache {
+		if err := app.cleanPackageCache(pm); err != nil {
+			app.logError("Error cleaning package cache: %v", err)
+		}
+	}
+
+	if config.RemoveOrphanPackages {
+		if err := app.removeOrphanPackages(pm); err != nil {
+			app.logError("Error removing orphaned packages: %v", err)
+		}
+	}
+
+	if config.RemoveOldKernels {
+		if err := app.removeOldKernels(pm); err != nil {
+			app.logError("Error removing old kernels: %v", err)
+		}
+	}
+}
+
+func (app *App) cleanPackageCache(pm packageManager) error {
+	defer app.timeAction("package cache", time.Now())
+
+	if !app.dryRun {
+		if err := app.runPrivileged(pm.cleanCache...); err != nil {
+			return err
+		}
+	}
+	app.addItem(&app.summary.PackageCaches, strings.Join(pm.cleanCache, " "))
+	return nil
+}
+
+func (app *App) removeOrphanPackages(pm packageManager) error {
+	defer app.timeAction("orphaned packages", time.Now())
+
+	output, err := exec.Command(pm.listOrphans[0], pm.listOrphans[1:]...).Output()
+	if err != nil {
+		// pacman -Qdtq exits with 1 when there are no orphans
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || len(output) > 0 {
+			return fmt.Errorf("failed to list orphaned packages: %w", err)
+		}
+	}
+
+	orphans := pm.parseOrphans(string(output))
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	if !app.dryRun {
+		if err := app.runPrivileged(append(pm.removeOrphans, orphans...)...); err != nil {
+			return err
+		}
+	}
+	app.addItem(&app.summary.RemovedPackages, orphans...)
+	return nil
+}
+
+// runPrivileged runs a command as root, escalating with sudo (or polkit's
+// pkexec) when saafsafai itself isn't running as root.
+func (app *App) runPrivileged(args ...string) error {
+	if os.Geteuid() != 0 {
+		if !app.isInteractive() {
+			return errNeedsRoot
+		}
+		escalate := "sudo"
+		if _, err := exec.LookPath(escalate); err != nil {
+			escalate = "pkexec"
+		}
+		args = append([]string{escalate}, args...)
+	}
+
+	var output bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// parseAptOrphans extracts the package names from the "Remv <pkg> [...]"
+// lines of a simulated apt-get autoremove.
+func parseAptOrphans(output string) []string {
+	var orphans []string
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "Remv" {
+			orphans = append(orphans, fields[1])
+		}
+	}
+	return orphans
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+	"unicode"
+	"unicode/utf16"
+)
+
+// pdfSizeLimit is the largest PDF read for its title and date; bigger ones
+// are rarely receipts, and would take too long to read.
+const pdfSizeLimit = 32 << 20
+
+var (
+	pdfObjectStart = regexp.MustCompile(`(\d+)\s+\d+\s+obj\b`)
+	pdfRootRef     = regexp.MustCompile(`/Root\s+(\d+)\s+\d+\s+R`)
+	pdfInfoRef     = regexp.MustCompile(`/Info\s+(\d+)\s+\d+\s+R`)
+	pdfPagesRef    = regexp.MustCompile(`/Pages\s+(\d+)\s+\d+\s+R`)
+	pdfFirstKid    = regexp.MustCompile(`/Kids\s*\[\s*(\d+)\s+\d+\s+R`)
+	pdfContentsRef = regexp.MustCompile(`/Contents\s+(\d+)\s+\d+\s+R`)
+	pdfContentsArr = regexp.MustCompile(`/Contents\s*\[([^\]]*)\]`)
+	pdfRef         = regexp.MustCompile(`(\d+)\s+\d+\s+R`)
+	pdfLength      = regexp.MustCompile(`/Length\s+(\d+)(\s+\d+\s+R)?`)
+	pdfObjStmN     = regexp.MustCompile(`/N\s+(\d+)`)
+	pdfObjStmFirst = regexp.MustCompile(`/First\s+(\d+)`)
+	pdfDate        = regexp.MustCompile(`^(D:)?(\d{4})(\d{2})(\d{2})`)
+	pdfFlate       = regexp.MustCompile(`/Filter\s*(/FlateDecode|\[\s*/FlateDecode\s*\])`)
+)
+
+// pdfDocument is just enough of a PDF to read the text of its first page and
+// its document info: its objects by number, found by scanning the file
+// rather than through the cross-reference table, which works on the damaged
+// files browsers sometimes leave too.
+type pdfDocument struct {
+	data    []byte
+	objects map[int][]byte
+}
+
+// pdfLine is a line of text on a page and the size it's set in.
+type pdfLine struct {
+	text string
+	size float64
+}
+
+func openPDF(path string) (*pdfDocument, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, pdfSizeLimit))
+	if err != nil {
+		return nil, err
+	}
+	doc := &pdfDocument{data: data, objects: make(map[int][]byte)}
+	doc.scanObjects()
+	return doc, nil
+}
+
+// encrypted reports whether the document's strings are encrypted, in which
+// case there's nothing to read.
+func (doc *pdfDocument) encrypted() bool {
+	return bytes.Contains(doc.data, []byte("/Encrypt"))
+}
+
+// scanObjects finds every "N G obj ... endobj", skipping over streams by
+// their length, then the objects packed into object streams. A later
+// definition of an object replaces an earlier one, as with incremental
+// updates.
+func (doc *pdfDocument) scanObjects() {
+	var objStms []int
+	for pos := 0; pos < len(doc.data); {
+		loc := pdfObjectStart.FindSubmatchIndex(doc.data[pos:])
+		if loc == nil {
+			break
+		}
+		num, _ := strconv.Atoi(string(doc.data[pos+loc[2] : pos+loc[3]]))
+		start := pos + loc[1]
+		end := start + bytes.Index(doc.data[start:], []byte("endobj"))
+		if end < start {
+			end = len(doc.data)
+		}
+		if s := bytes.Index(doc.data[start:end], []byte("stream")); s >= 0 {
+			// The stream may hold "endobj" itself: skip it whole if the
+			// length is given
+			if m := pdfLength.FindSubmatch(doc.data[start : start+s]); m != nil && m[2] == nil {
+				n, _ := strconv.Atoi(string(m[1]))
+				if streamEnd := start + s + len("stream") + n; streamEnd < len(doc.data) {
+					if e := bytes.Index(doc.data[streamEnd:], []byte("endobj")); e >= 0 {
+						end = streamEnd + e
+					}
+				}
+			}
+			if bytes.Contains(doc.data[start:start+s], []byte("/ObjStm")) {
+				objStms = append(objStms, num)
+			}
+		}
+		doc.objects[num] = doc.data[start:end]
+		pos = end + len("endobj")
+		if end == len(doc.data) {
+			break
+		}
+	}
+
+	for _, num := range objStms {
+		obj := doc.objects[num]
+		content := doc.stream(obj)
+		n, first := pdfObjStmN.FindSubmatch(obj), pdfObjStmFirst.FindSubmatch(obj)
+		if content == nil || n == nil || first == nil {
+			continue
+		}
+		count, _ := strconv.Atoi(string(n[1]))
+		offset, _ := strconv.Atoi(string(first[1]))
+		if offset > len(content) {
+			continue
+		}
+		header := bytes.Fields(content[:offset])
+		for i := 0; i+1 < len(header) && i/2 < count; i += 2 {
+			objNum, err1 := strconv.Atoi(string(header[i]))
+			start, err2 := strconv.Atoi(string(header[i+1]))
+			if err1 != nil || err2 != nil || offset+start > len(content) {
+				break
+			}
+			end := len(content)
+			if i+3 < len(header) {
+				if next, err := strconv.Atoi(string(header[i+3])); err == nil && offset+next <= len(content) && next >= start {
+					end = offset + next
+				}
+			}
+			if _, ok := doc.objects[objNum]; !ok {
+				doc.objects[objNum] = content[offset+start : end]
+			}
+		}
+	}
+}
+
+// pdfDict returns the part of an object before its stream, if any.
+func pdfDict(obj []byte) []byte {
+	if i := bytes.Index(obj, []byte("stream")); i >= 0 {
+		return obj[:i]
+	}
+	return obj
+}
+
+// stream returns an object's stream data, inflated if it's compressed with
+// FlateDecode, or nil if it has none or another filter. A damaged stream
+// gives what could be inflated of it.
+func (doc *pdfDocument) stream(obj []byte) []byte {
+	i := bytes.Index(obj, []byte("stream"))
+	if i < 0 {
+		return nil
+	}
+	dict := obj[:i]
+	data := obj[i+len("stream"):]
+	data = bytes.TrimPrefix(data, []byte("\r"))
+	data = bytes.TrimPrefix(data, []byte("\n"))
+	if e := bytes.LastIndex(data, []byte("endstream")); e >= 0 {
+		data = data[:e]
+	}
+
+	if !bytes.Contains(dict, []byte("/Filter")) {
+		return data
+	}
+	if !pdfFlate.Match(dict) {
+		return nil
+	}
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	inflated, _ := io.ReadAll(io.LimitReader(r, pdfSizeLimit))
+	return inflated
+}
+
+// trailerRef finds the object a trailer (or cross-reference stream) key
+// points to, preferring the last, newest trailer.
+func (doc *pdfDocument) trailerRef(re *regexp.Regexp) ([]byte, bool) {
+	matches := re.FindAllSubmatch(doc.data, -1)
+	for i := len(matches) - 1; i >= 0; i-- {
+		num, _ := strconv.Atoi(string(matches[i][1]))
+		if obj, ok := doc.objects[num]; ok {
+			return obj, true
+		}
+	}
+	return nil, false
+}
+
+func (doc *pdfDocument) ref(re *regexp.Regexp, obj []byte) ([]byte, bool) {
+	m := re.FindSubmatch(pdfDict(obj))
+	if m == nil {
+		return nil, false
+	}
+	num, _ := strconv.Atoi(string(m[1]))
+	target, ok := doc.objects[num]
+	return target, ok
+}
+
+// firstPage returns the content of the document's first page.
+func (doc *pdfDocument) firstPage() []byte {
+	root, ok := doc.trailerRef(pdfRootRef)
+	if !ok {
+		return nil
+	}
+	node, ok := doc.ref(pdfPagesRef, root)
+	for depth := 0; ok && depth < 32; depth++ {
+		kid, isTree := doc.ref(pdfFirstKid, node)
+		if !isTree {
+			break
+		}
+		node = kid
+	}
+	if !ok {
+		return nil
+	}
+
+	var objects [][]byte
+	if contents, ok := doc.ref(pdfContentsRef, node); ok {
+		// Either the stream, or an array of them
+		if bytes.Contains(contents, []byte("stream")) {
+			objects = append(objects, contents)
+		} else {
+			objects = append(objects, doc.refs(contents)...)
+		}
+	} else if m := pdfContentsArr.FindSubmatch(pdfDict(node)); m != nil {
+		objects = doc.refs(m[1])
+	}
+
+	var content []byte
+	for _, obj := range objects {
+		content = append(content, doc.stream(obj)...)
+		content = append(content, '\n')
+	}
+	return content
+}
+
+func (doc *pdfDocument) refs(list []byte) [][]byte {
+	var objects [][]byte
+	for _, m := range pdfRef.FindAllSubmatch(list, -1) {
+		num, _ := strconv.Atoi(string(m[1]))
+		if obj, ok := doc.objects[num]; ok {
+			objects = append(objects, obj)
+		}
+	}
+	return objects
+}
+
+// info returns the document info's title and creation date, if set.
+func (doc *pdfDocument) info() (string, time.Time) {
+	obj, ok := doc.trailerRef(pdfInfoRef)
+	if !ok {
+		return "", time.Time{}
+	}
+	var title string
+	var created time.Time
+	if i := bytes.Index(obj, []byte("/Title")); i >= 0 {
+		if s, ok := pdfString(bytes.TrimLeft(obj[i+len("/Title"):], " \t\r\n")); ok {
+			title = s
+		}
+	}
+	if i := bytes.Index(obj, []byte("/CreationDate")); i >= 0 {
+		if s, ok := pdfString(bytes.TrimLeft(obj[i+len("/CreationDate"):], " \t\r\n")); ok {
+			if m := pdfDate.FindStringSubmatch(s); m != nil {
+				created, _ = time.Parse("20060102", m[2]+m[3]+m[4])
+			}
+		}
+	}
+	return title, created
+}
+
+// pdfString decodes the literal or hex string at the start of b.
+func pdfString(b []byte) (string, bool) {
+	if len(b) == 0 {
+		return "", false
+	}
+	var raw []byte
+	switch b[0] {
+	case '(':
+		raw, _ = pdfLiteral(b)
+	case '<':
+		raw, _ = pdfHex(b)
+	default:
+		return "", false
+	}
+	return pdfText(raw), true
+}
+
+// pdfLiteral parses a "(...)" string, returning its bytes and its length in
+// b.
+func pdfLiteral(b []byte) ([]byte, int) {
+	var out []byte
+	depth := 0
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case c == '\\' && i+1 < len(b):
+			i++
+			switch e := b[i]; e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r', '\n':
+				// A line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					n := 0
+					for j := 0; j < 3 && i < len(b) && b[i] >= '0' && b[i] <= '7'; j++ {
+						n = n*8 + int(b[i]-'0')
+						i++
+					}
+					i--
+					out = append(out, byte(n))
+				} else {
+					out = append(out, e)
+				}
+			}
+		case c == '(':
+			if depth > 0 {
+				out = append(out, c)
+			}
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return out, i + 1
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out, len(b)
+}
+
+// pdfHex parses a "<...>" string, returning its bytes and its length in b.
+func pdfHex(b []byte) ([]byte, int) {
+	end := bytes.IndexByte(b, '>')
+	if end < 0 {
+		end = len(b)
+	}
+	var digits []byte
+	for _, c := range b[1:end] {
+		if unicode.Is(unicode.ASCII_Hex_Digit, rune(c)) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		n, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(n)
+	}
+	return out, min(end+1, len(b))
+}
+
+// pdfText decodes a string's bytes: UTF-16 with a byte order mark, or else
+// taken as Latin-1, which is close to the encodings of PDFs' simple fonts.
+func pdfText(raw []byte) string {
+	if len(raw) >= 2 && raw[0] == 0xfe && raw[1] == 0xff {
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	runes := make([]rune, len(raw))
+	for i, c := range raw {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// pdfTextLines extracts the lines of text a page's content shows, with the
+// size of the largest text in each. Text in fonts with two-byte codes comes
+// out as noise, which the callers drop.
+func pdfTextLines(content []byte) []pdfLine {
+	var lines []pdfLine
+	var line []byte
+	var lineSize float64
+	var operands [][]byte
+	var fontSize, scale, y float64 = 0, 1, math.NaN()
+
+	newLine := func() {
+		if text := string(bytes.TrimSpace(line)); text != "" {
+			lines = append(lines, pdfLine{text, lineSize})
+		}
+		line, lineSize = nil, 0
+	}
+	show := func(s []byte) {
+		line = append(line, s...)
+		lineSize = max(lineSize, fontSize*scale)
+	}
+	number := func(i int) float64 {
+		if i < 0 || i >= len(operands) {
+			return 0
+		}
+		f, _ := strconv.ParseFloat(string(operands[i]), 64)
+		return f
+	}
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			s, n := pdfLiteral(content[i:])
+			operands = append(operands, append([]byte{'('}, s...))
+			i += n
+		case c == '<' && i+1 < len(content) && content[i+1] == '<':
+			i += 2
+		case c == '>' && i+1 < len(content) && content[i+1] == '>':
+			i += 2
+		case c == '<':
+			s, n := pdfHex(content[i:])
+			operands = append(operands, append([]byte{'('}, s...))
+			i += n
+		case c == '[' || c == ']':
+			operands = append(operands, []byte{c})
+			i++
+		case c == '/' || c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(content) && !pdfDelimiter(content[j]) {
+				j++
+			}
+			operands = append(operands, content[i:j])
+			i = j
+		case pdfDelimiter(c):
+			i++
+		default:
+			j := i + 1
+			for j < len(content) && !pdfDelimiter(content[j]) {
+				j++
+			}
+			op := string(content[i:j])
+			i = j
+			switch op {
+			case "BT":
+				scale = 1
+			case "Tf":
+				fontSize = math.Abs(number(len(operands) - 1))
+			case "Tm":
+				// Only a move to another line starts a new one
+				scale = math.Max(math.Abs(number(len(operands)-3)), math.Abs(number(len(operands)-6)))
+				if ty := number(len(operands) - 1); ty != y {
+					newLine()
+					y = ty
+				} else {
+					line = append(line, ' ')
+				}
+			case "Td", "TD":
+				if number(len(operands)-1) != 0 {
+					newLine()
+				} else {
+					line = append(line, ' ')
+				}
+			case "T*":
+				newLine()
+			case "Tj", "'", "\"":
+				if op != "Tj" {
+					newLine()
+				}
+				if n := len(operands); n > 0 && len(operands[n-1]) > 0 && operands[n-1][0] == '(' {
+					show(operands[n-1][1:])
+				}
+			case "TJ":
+				for _, operand := range operands {
+					if len(operand) > 0 && operand[0] == '(' {
+						show(operand[1:])
+					} else if f, err := strconv.ParseFloat(string(operand), 64); err == nil && f < -200 {
+						// A gap this wide is a space between words
+						line = append(line, ' ')
+					}
+				}
+			case "BI":
+				// Skip an inline image's data
+				if e := bytes.Index(content[i:], []byte("EI")); e >= 0 {
+					i += e + 2
+				}
+			}
+			operands = operands[:0]
+		}
+	}
+	newLine()
+
+	for i := range lines {
+		lines[i].text = pdfText([]byte(lines[i].text))
+	}
+	return lines
+}
+
+func pdfDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0, '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
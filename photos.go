@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/fs"
+	"math/bits"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const photoMaxDistance = 6 // differing bits of 64
+
+var photoExts = []string{".jpg", ".jpeg", ".png", ".gif"}
+
+type PhotosConfig struct {
+	// MaxDistance is how many of the 64 bits of two photos' perceptual
+	// hashes may differ for them to count as the same photo (default 6).
+	MaxDistance int `json:"max_distance"`
+}
+
+type photo struct {
+	path   string
+	size   int64
+	pixels int
+	hash   uint64
+}
+
+// photoDirs are where duplicate photos are looked for.
+func (app *App) photoDirs() []string {
+	return []string{app.downloadsDir, filepath.Join(app.homeDir, "Pictures")}
+}
+
+// findDuplicatePhotos groups photos in Downloads and Pictures that look the
+// same, including resized or re-encoded copies, by a perceptual hash. In
+// each group the largest is kept; the others are only ever removed after
+// asking on the terminal, and unattended runs just report the groups.
+func (app *App) findDuplicatePhotos(cfg PhotosConfig) error {
+	maxDistance := cfg.MaxDistance
+	if maxDistance <= 0 {
+		maxDistance = photoMaxDistance
+	}
+
+	interactive := app.isInteractive() && !app.dryRun
+	for _, group := range groupPhotos(app.scanPhotos(), maxDistance) {
+		keep, others := group[0], group[1:]
+		var names []string
+		for _, p := range others {
+			names = append(names, app.displayPath(p.path))
+		}
+		item := T("item.duplicate_photos", app.displayPath(keep.path), strings.Join(names, ", "))
+
+		if !interactive {
+			if app.dryRun {
+				app.addItem(&app.summary.RemovedPhotos, item)
+				for _, p := range others {
+					app.countFreed(diskFiles(p.path))
+				}
+			} else {
+				app.addItem(&app.summary.DuplicatePhotos, item)
+			}
+			continue
+		}
+
+		ok, err := app.confirm(T("confirm.remove_duplicates", strings.Join(names, ", "), app.displayPath(keep.path)))
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+		if !ok {
+			continue
+		}
+		removed := false
+		for _, p := range others {
+			if err := app.remove(p.path); err != nil {
+				app.skipItem("Failed to remove duplicate photo", p.path, err)
+				continue
+			}
+			removed = true
+		}
+		if removed {
+			app.addItem(&app.summary.RemovedPhotos, item)
+		}
+	}
+	return nil
+}
+
+// scanPhotos hashes the photos in the photo directories, on the cleaner's
+// workers; files that don't decode are left out.
+func (app *App) scanPhotos() []photo {
+	defer app.timeAction("photo hashes", time.Now())
+
+	var paths []string
+	seen := make(map[inodeKey]bool)
+	for _, dir := range app.photoDirs() {
+		app.scanTree(dir, seen, func(rel string, d fs.DirEntry, err error) error {
+			if err == nil && d.Type().IsRegular() && hasPhotoExt(d.Name()) {
+				paths = append(paths, filepath.Join(dir, rel))
+			}
+			return nil
+		})
+	}
+
+	lim := app.limiter()
+	hashed := make([]photo, len(paths))
+	ok := make([]bool, len(paths))
+	lim.each(len(paths), func(i int) {
+		p, err := hashPhoto(paths[i], lim)
+		hashed[i], ok[i] = p, err == nil
+	})
+	var photos []photo
+	for i, p := range hashed {
+		if ok[i] {
+			photos = append(photos, p)
+		}
+	}
+	return photos
+}
+
+func hasPhotoExt(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range photoExts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+func hashPhoto(path string, lim *readLimiter) (photo, error) {
+	f, err := lim.open(path)
+	if err != nil {
+		return photo{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return photo{}, err
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return photo{}, err
+	}
+	b := img.Bounds()
+	return photo{path: path, size: info.Size(), pixels: b.Dx() * b.Dy(), hash: dHash(img)}, nil
+}
+
+// dHash is the difference hash of img: it's shrunk to 9×8 grey cells, and
+// each bit says whether a cell is brighter than the one to its right. Scaling
+// and re-encoding barely change it.
+func dHash(img image.Image) uint64 {
+	b := img.Bounds()
+	var cells [8][9]float64
+	for y := range 8 {
+		for x := range 9 {
+			cells[y][x] = averageGrey(img, image.Rect(
+				b.Min.X+x*b.Dx()/9, b.Min.Y+y*b.Dy()/8,
+				b.Min.X+(x+1)*b.Dx()/9, b.Min.Y+(y+1)*b.Dy()/8,
+			))
+		}
+	}
+	var hash uint64
+	for y := range 8 {
+		for x := range 8 {
+			hash <<= 1
+			if cells[y][x] > cells[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// averageGrey returns the mean luminance of r, from at most 8×8 samples of
+// it so big photos hash quickly.
+func averageGrey(img image.Image, r image.Rectangle) float64 {
+	if r.Empty() {
+		return 0
+	}
+	stepX, stepY := max(r.Dx()/8, 1), max(r.Dy()/8, 1)
+	var total float64
+	n := 0
+	for y := r.Min.Y; y < r.Max.Y; y += stepY {
+		for x := r.Min.X; x < r.Max.X; x += stepX {
+			cr, cg, cb, _ := img.At(x, y).RGBA()
+			total += 0.299*float64(cr) + 0.587*float64(cg) + 0.114*float64(cb)
+			n++
+		}
+	}
+	return total / float64(n)
+}
+
+// groupPhotos returns the groups of photos within maxDistance of each other
+// (directly or through another photo of the group), largest first in each.
+func groupPhotos(photos []photo, maxDistance int) [][]photo {
+	parent := make([]int, len(photos))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range photos {
+		for j := i + 1; j < len(photos); j++ {
+			if bits.OnesCount64(photos[i].hash^photos[j].hash) <= maxDistance {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	byRoot := make(map[int][]photo)
+	var roots []int
+	for i, p := range photos {
+		root := find(i)
+		if byRoot[root] == nil {
+			roots = append(roots, root)
+		}
+		byRoot[root] = append(byRoot[root], p)
+	}
+
+	var groups [][]photo
+	for _, root := range roots {
+		group := byRoot[root]
+		if len(group) < 2 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool {
+			if group[i].pixels != group[j].pixels {
+				return group[i].pixels > group[j].pixels
+			}
+			return group[i].size > group[j].size
+		})
+		groups = append(groups, group)
+	}
+	return groups
+}
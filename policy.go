@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// systemPolicy is the administrator's policy, applied over every config,
+// the user's own or the system's.
+var systemPolicy = filepath.Join(systemConfigDir, "policy.json")
+
+var errProtected = errors.New("protected by the system policy")
+
+// Policy is what an administrator mandates on a managed machine, whatever
+// the configs say. Keys are config keys, nested ones with dots, e.g.
+// "quarantine.max_age_days".
+type Policy struct {
+	// Force sets config keys to these values, e.g. enabling or disabling a
+	// cleaner.
+	Force map[string]any `json:"force"`
+	// Bounds keeps numeric config keys within a range; a key left at 0 is
+	// bounded as its default.
+	Bounds map[string]PolicyBound `json:"bounds"`
+	// ProtectedPaths are never removed, moved or handed to a script, nor
+	// anything in them. They may have globs and start with ~/ for the home
+	// of the user whose config is applied.
+	ProtectedPaths []string `json:"protected_paths"`
+	// RemoteCommands lets a remote config set the keys that run commands,
+	// which it otherwise can't.
+	RemoteCommands bool `json:"remote_commands"`
+}
+
+// PolicyBound is the least and most a config key may be; either may be
+// left out.
+type PolicyBound struct {
+	Min *float64 `json:"min"`
+	Max *float64 `json:"max"`
+}
+
+// loadPolicy reads the system policy, if there is one. A policy that can't
+// be read is an error rather than ignored, as running without it could do
+// what it forbids.
+func loadPolicy() (*Policy, error) {
+	data, err := os.ReadFile(systemPolicy)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the system policy: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var policy Policy
+	if err := dec.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("failed to parse the system policy %s: %w", systemPolicy, err)
+	}
+	return &policy, nil
+}
+
+// applyPolicy forces and bounds the keys of config the policy names, and
+// remembers its protected paths for the run.
+func (app *App) applyPolicy(config *Config) error {
+	policy, err := loadPolicy()
+	if err != nil || policy == nil {
+		return err
+	}
+	app.protected = nil
+	for _, pattern := range policy.ProtectedPaths {
+		if rest, ok := strings.CutPrefix(pattern, "~/"); ok {
+			if app.homeDir == "" {
+				continue
+			}
+			pattern = filepath.Join(app.homeDir, rest)
+		}
+		app.protected = append(app.protected, filepath.Clean(pattern))
+	}
+	if len(policy.Force) == 0 && len(policy.Bounds) == 0 {
+		return nil
+	}
+
+	values, err := configMap(*config)
+	if err != nil {
+		return err
+	}
+	defaults, err := configMap(defaultConfig())
+	if err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(policy.Force) {
+		if _, ok := lookupKey(values, key); !ok {
+			return fmt.Errorf("the system policy forces an unknown config key %q", key)
+		}
+		setKey(values, key, policy.Force[key])
+	}
+	for _, key := range sortedKeys(policy.Bounds) {
+		value, ok := lookupKey(values, key)
+		n, numeric := value.(float64)
+		if !ok || !numeric {
+			return fmt.Errorf("the system policy bounds %q, which isn't a numeric config key", key)
+		}
+		if n == 0 {
+			value, _ = lookupKey(defaults, key)
+			n, _ = value.(float64)
+		}
+		bound := policy.Bounds[key]
+		if bound.Min != nil && n < *bound.Min {
+			n = *bound.Min
+		}
+		if bound.Max != nil && n > *bound.Max {
+			n = *bound.Max
+		}
+		setKey(values, key, n)
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	var forced Config
+	if err := json.Unmarshal(data, &forced); err != nil {
+		return fmt.Errorf("the system policy doesn't fit the config: %w", err)
+	}
+	*config = forced
+	return nil
+}
+
+// lookupKey returns the value at a dotted key in decoded config JSON.
+func lookupKey(values map[string]any, key string) (any, bool) {
+	parent, last, ok := keyParent(values, key)
+	if !ok {
+		return nil, false
+	}
+	value, ok := parent[last]
+	return value, ok
+}
+
+// setKey sets the value at a dotted key of decoded config JSON that
+// lookupKey finds.
+func setKey(values map[string]any, key string, value any) {
+	if parent, last, ok := keyParent(values, key); ok {
+		parent[last] = value
+	}
+}
+
+func keyParent(values map[string]any, key string) (map[string]any, string, bool) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := values[part].(map[string]any)
+		if !ok {
+			return nil, "", false
+		}
+		values = next
+	}
+	return values, parts[len(parts)-1], true
+}
+
+// isProtected reports whether the policy protects path: it's a protected
+// path, is in one, or holds one.
+func (app *App) isProtected(path string) bool {
+	path = filepath.Clean(path)
+	for _, pattern := range app.protected {
+		if !strings.ContainsAny(pattern, "*?[") && strings.HasPrefix(pattern, path+string(filepath.Separator)) {
+			return true
+		}
+		for p := path; ; p = filepath.Dir(p) {
+			if ok, _ := filepath.Match(pattern, p); ok {
+				return true
+			}
+			if p == filepath.Dir(p) {
+				break
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	postCleanMinFreed      = "1GB"
+	postCleanTimeout       = 30 * time.Minute
+	btrfsScrubStateFile    = "btrfs-scrub.json"
+	trimNotSupportedOutput = "not supported"
+)
+
+type PostCleanConfig struct {
+	// MinFreed is how much a run has to free on a file system, e.g. "5GB"
+	// (default 1GB), for the hooks to run on it. What's freed is measured
+	// as the growth of its free space over the run.
+	MinFreed string `json:"min_freed"`
+	// Fstrim trims the file system, so SSDs and thin-provisioned virtual
+	// disks get the freed blocks back.
+	Fstrim bool `json:"fstrim"`
+	// BtrfsBalanceUsage balances btrfs file systems' data and metadata
+	// chunks used less than this percent (0 for none), returning them to
+	// the free space.
+	BtrfsBalanceUsage int `json:"btrfs_balance_usage"`
+	// BtrfsScrubDays starts a btrfs scrub, which checks every block in the
+	// background, when the last one saafsafai started is this many days
+	// old (0 for none), whatever the run freed.
+	BtrfsScrubDays int `json:"btrfs_scrub_days"`
+	// Hooks are shell commands run for each file system, with its mount
+	// point, type, device and the bytes freed on it in SAAFSAFAI_MOUNT_POINT,
+	// SAAFSAFAI_FS_TYPE, SAAFSAFAI_DEVICE and SAAFSAFAI_FREED_BYTES.
+	Hooks []string `json:"hooks"`
+}
+
+// filesystem is a mounted file system on a block device.
+type filesystem struct {
+	source     string
+	mountPoint string
+	fsType     string
+	// noAtime is set if it's mounted noatime, so access times never change
+	noAtime bool
+}
+
+// snapshotFreeSpace records the space free on each file system before the
+// cleaners run, for runPostClean to tell what they freed on it, including
+// what the users' runs and tools like Docker freed.
+func (app *App) snapshotFreeSpace() {
+	app.freeBefore = make(map[string]int64)
+	for _, fs := range mountedFilesystems() {
+		app.freeBefore[fs.source] = freeBytes([]string{fs.mountPoint})
+	}
+}
+
+// runPostClean trims, balances and runs the configured hooks on the file
+// systems the run freed enough on, and starts the btrfs scrubs that are
+// due. Trimming and btrfs need root; user runs only run the hooks.
+func (app *App) runPostClean(cfg PostCleanConfig) error {
+	if app.dryRun || app.freeBefore == nil {
+		// Dry runs free nothing
+		return nil
+	}
+	minFreedSize := cfg.MinFreed
+	if minFreedSize == "" {
+		minFreedSize = postCleanMinFreed
+	}
+	minFreed, err := parseSize(minFreedSize)
+	if err != nil {
+		return fmt.Errorf("invalid post_clean.min_freed: %w", err)
+	}
+	root := os.Geteuid() == 0
+	if !root && (cfg.Fstrim || cfg.BtrfsBalanceUsage > 0 || cfg.BtrfsScrubDays > 0) {
+		log.Printf("Warning: fstrim and btrfs need root, skipping them; enable post_clean_hooks in the system config for them")
+	}
+
+	scrubbed := app.loadScrubState()
+	scrubCutoff := app.ageCutoff(cfg.BtrfsScrubDays, cfg.BtrfsScrubDays)
+	for _, fs := range mountedFilesystems() {
+		before, ok := app.freeBefore[fs.source]
+		if !ok {
+			// Mounted during the run
+			continue
+		}
+		if freed := freeBytes([]string{fs.mountPoint}) - before; freed >= minFreed {
+			if cfg.Fstrim && root {
+				app.trim(fs)
+			}
+			if cfg.BtrfsBalanceUsage > 0 && fs.fsType == "btrfs" && root {
+				usage := strconv.Itoa(cfg.BtrfsBalanceUsage)
+				app.postCleanCommand(fs, "btrfs balance", "btrfs", "balance", "start", "-dusage="+usage, "-musage="+usage, fs.mountPoint)
+			}
+			for _, hook := range cfg.Hooks {
+				app.runPostCleanHook(hook, fs, freed)
+			}
+		}
+		if cfg.BtrfsScrubDays > 0 && fs.fsType == "btrfs" && root && scrubbed[fs.source].Before(scrubCutoff) {
+			if app.postCleanCommand(fs, "btrfs scrub", "btrfs", "scrub", "start", fs.mountPoint) {
+				scrubbed[fs.source] = time.Now()
+			}
+		}
+	}
+	if cfg.BtrfsScrubDays > 0 && root {
+		if err := app.saveScrubState(scrubbed); err != nil {
+			log.Printf("Warning: failed to save when btrfs scrubs were started: %v", err)
+		}
+	}
+	return nil
+}
+
+// trim runs fstrim on the file system, leaving those that can't discard,
+// like spinning disks, alone.
+func (app *App) trim(fs filesystem) {
+	ctx, cancel := context.WithTimeout(context.Background(), postCleanTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "fstrim", "--verbose", fs.mountPoint).CombinedOutput()
+	if err != nil && strings.Contains(string(output), trimNotSupportedOutput) {
+		log.Printf("%s can't be trimmed, skipping it", fs.mountPoint)
+		return
+	}
+	if err != nil {
+		app.skipItem("Failed to trim", fs.mountPoint, fmt.Errorf("%w: %s", err, lastLine(output)))
+		return
+	}
+	app.addItem(&app.summary.PostCleanHooks, "fstrim "+lastLine(output))
+}
+
+// postCleanCommand runs a command for the file system, reporting whether it
+// succeeded.
+func (app *App) postCleanCommand(fs filesystem, what, name string, args ...string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), postCleanTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		app.skipItem("Failed to run "+what+" on", fs.mountPoint, fmt.Errorf("%w: %s", err, lastLine(output)))
+		return false
+	}
+	item := fmt.Sprintf("%s %s", what, fs.mountPoint)
+	if result := lastLine(output); result != "" {
+		item += ": " + result
+	}
+	app.addItem(&app.summary.PostCleanHooks, item)
+	return true
+}
+
+// runPostCleanHook runs a configured hook for the file system; the last line
+// it prints goes in the summary.
+func (app *App) runPostCleanHook(hook string, fs filesystem, freed int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), actionTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook)
+	cmd.Env = append(os.Environ(),
+		"SAAFSAFAI_MOUNT_POINT="+fs.mountPoint,
+		"SAAFSAFAI_FS_TYPE="+fs.fsType,
+		"SAAFSAFAI_DEVICE="+fs.source,
+		"SAAFSAFAI_FREED_BYTES="+strconv.FormatInt(freed, 10),
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		app.skipItem(fmt.Sprintf("Post-clean hook %q failed on", hook), fs.mountPoint, fmt.Errorf("%w: %s", err, lastLine(output)))
+		return
+	}
+	item := fmt.Sprintf("%s on %s (%s freed)", hook, fs.mountPoint, formatSize(freed))
+	if result := lastLine(output); result != "" {
+		item += ": " + result
+	}
+	app.addItem(&app.summary.PostCleanHooks, item)
+}
+
+// loadScrubState returns when saafsafai last started a scrub of each btrfs
+// file system, by device.
+func (app *App) loadScrubState() map[string]time.Time {
+	scrubbed := make(map[string]time.Time)
+	if data, err := os.ReadFile(filepath.Join(app.stateDir, btrfsScrubStateFile)); err == nil {
+		json.Unmarshal(data, &scrubbed)
+	}
+	return scrubbed
+}
+
+func (app *App) saveScrubState(scrubbed map[string]time.Time) error {
+	data, err := json.Marshal(scrubbed)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(app.stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return os.WriteFile(filepath.Join(app.stateDir, btrfsScrubStateFile), data, 0644)
+}
+
+// btrfsMountPoints are the mount points of the btrfs file systems, which a
+// balance needs to be able to write to.
+func btrfsMountPoints() []string {
+	var paths []string
+	for _, fs := range mountedFilesystems() {
+		if fs.fsType == "btrfs" {
+			paths = append(paths, fs.mountPoint)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	quarantineDirName   = "quarantine"
+	quarantineIndexName = "index.jsonl"
+	quarantineMaxAge    = 30 // days
+	quarantineBudget    = "5GB"
+)
+
+type QuarantineConfig struct {
+	// Enabled moves removed files and folders into the quarantine instead
+	// of deleting them, so "saafsafai restore" can bring them back.
+	Enabled bool `json:"enabled"`
+	// MaxAgeDays is how long items are kept before they're deleted for good
+	// (default 30).
+	MaxAgeDays int `json:"max_age_days"`
+	// Budget caps the quarantine's size, e.g. "5GB" (the default); past it
+	// the oldest items are deleted early.
+	Budget string `json:"budget"`
+}
+
+// quarantineRecord describes one quarantined item. Items are stored under
+// their content ID, so the same content quarantined twice is kept once.
+type quarantineRecord struct {
+	ID         string      `json:"id"`
+	Path       string      `json:"path"`
+	Time       time.Time   `json:"time"`
+	Size       int64       `json:"size"`
+	Mode       fs.FileMode `json:"mode"`
+	UID        int         `json:"uid"`
+	GID        int         `json:"gid"`
+	ModTime    time.Time   `json:"mod_time"`
+	AccessTime time.Time   `json:"access_time"`
+	// Checksums are the SHA-256 of each file, by path within the item ("."
+	// for a file), so a restore can tell the content is intact.
+	Checksums map[string]string `json:"checksums,omitempty"`
+}
+
+func (app *App) quarantineDir() string {
+	return filepath.Join(app.stateDir, quarantineDirName)
+}
+
+func (app *App) quarantineObject(id string) string {
+	return filepath.Join(app.quarantineDir(), "objects", id)
+}
+
+// discard removes path with remove, or moves it into the quarantine or the
+// trash when that's enabled.
+func (app *App) discard(path string, remove func(string) error) error {
+	if app.quarantine {
+		return app.quarantineItem(path)
+	}
+	if app.trashConfig.Enabled {
+		return app.trashItem(path)
+	}
+	return remove(path)
+}
+
+// quarantineItem moves path into the quarantine and records where it came
+// from, with its ownership and timestamps.
+func (app *App) quarantineItem(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	lim := app.limiter()
+	checksums, err := quarantineManifest(path, lim)
+	if err != nil {
+		return fmt.Errorf("failed to checksum %s: %w", path, err)
+	}
+	id, err := contentID(path, info, checksums)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+
+	record := quarantineRecord{
+		ID:         id,
+		Path:       path,
+		Time:       time.Now(),
+		Size:       info.Size(),
+		Mode:       info.Mode(),
+		ModTime:    info.ModTime(),
+		AccessTime: accessTime(info),
+		Checksums:  checksums,
+	}
+	if info.IsDir() {
+		record.Size = dirSize(path)
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		record.UID, record.GID = int(st.Uid), int(st.Gid)
+	}
+
+	object := app.quarantineObject(id)
+	if err := os.MkdirAll(filepath.Dir(object), 0700); err != nil {
+		return fmt.Errorf("failed to create quarantine: %w", err)
+	}
+	if _, err := os.Lstat(object); err == nil {
+		// Already quarantined with the same content, unless the short ID
+		// collided: then path is kept rather than lost
+		if err := app.verifyObject(record); err != nil {
+			return fmt.Errorf("%s has the ID of a different quarantined item, keeping it: %w", path, err)
+		}
+		if err := app.removeTree(path); err != nil {
+			return err
+		}
+	} else if err := app.guardMove(path, filepath.Dir(object), record.Size); err != nil {
+		return fmt.Errorf("failed to move %s into the quarantine: %w", path, err)
+	} else if err := moveTree(path, object, lim); err != nil {
+		return fmt.Errorf("failed to move %s into the quarantine: %w", path, err)
+	}
+
+	return app.appendQuarantineRecord(record)
+}
+
+// contentID hashes a file's content, or a directory's listing (names,
+// modes, sizes and modification times) and the checksums of its files, as
+// quarantineManifest gives them, into a short ID.
+func contentID(path string, info fs.FileInfo, checksums map[string]string) (string, error) {
+	if info.Mode().IsRegular() {
+		hash, ok := checksums["."]
+		if !ok {
+			return "", fmt.Errorf("no checksum of %s", path)
+		}
+		return hash[:12], nil
+	}
+	h := sha256.New()
+	if info.Mode()&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "symlink %s", target)
+		return hex.EncodeToString(h.Sum(nil))[:12], nil
+	}
+	err := walkTree(path, func(rel string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%v\x00%d\x00%d\x00%s\n", rel, fi.Mode(), fi.Size(), fi.ModTime().UnixNano(), checksums[filepath.ToSlash(rel)])
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12], nil
+}
+
+// moveTree renames src to dst, copying across file systems, with the copy's
+// reads limited by lim.
+func moveTree(src, dst string, lim *readLimiter) error {
+	err := os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	tmp := dst + ".partial"
+	if err := copyTree(src, tmp, lim); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return err
+	}
+	return removeTree(src, nil)
+}
+
+// copyTree copies files, directories and symlinks, keeping their modes and
+// modification times.
+func copyTree(src, dst string, lim *readLimiter) error {
+	var dirs []string
+	err := walkTree(src, func(rel string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		p := filepath.Join(src, rel)
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			dirs = append(dirs, rel)
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			if err := copyRegularFile(p, target, info, lim); err != nil {
+				return err
+			}
+			return os.Chtimes(target, accessTime(info), info.ModTime())
+		}
+		return nil // sockets, devices and the like aren't worth keeping
+	})
+	if err != nil {
+		return err
+	}
+
+	// Directory times last, since filling them in changes them
+	for i := len(dirs) - 1; i >= 0; i-- {
+		info, err := os.Lstat(filepath.Join(src, dirs[i]))
+		if err != nil {
+			continue
+		}
+		target := filepath.Join(dst, dirs[i])
+		os.Chmod(target, info.Mode().Perm())
+		os.Chtimes(target, accessTime(info), info.ModTime())
+	}
+	return nil
+}
+
+func copyRegularFile(src, dst string, info fs.FileInfo, lim *readLimiter) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	copyData := copyFileData
+	if lim.throttled() {
+		// Clones and sparse copies would bypass the throttle
+		copyData = func(out, in *os.File, _ fs.FileInfo) error {
+			_, err := io.Copy(out, lim.reader(in))
+			return err
+		}
+	}
+	if err := copyData(out, in, info); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (app *App) appendQuarantineRecord(record quarantineRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(app.quarantineDir(), quarantineIndexName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open quarantine index: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// loadQuarantine returns the quarantined items, oldest first.
+func (app *App) loadQuarantine() ([]quarantineRecord, error) {
+	f, err := os.Open(filepath.Join(app.quarantineDir(), quarantineIndexName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []quarantineRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record quarantineRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err == nil {
+			records = append(records, record)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// saveQuarantine rewrites the index with records.
+func (app *App) saveQuarantine(records []quarantineRecord) error {
+	return writeJSONLines(filepath.Join(app.quarantineDir(), quarantineIndexName), records, 0600)
+}
+
+// pruneQuarantine drops the items quarantined before cutoff, then the oldest
+// of the rest until it fits in budget bytes, and any object no record refers
+// to, such as the leftover of a move cut short.
+func (app *App) pruneQuarantine(cutoff time.Time, budget int64) error {
+	records, err := app.loadQuarantine()
+	if err != nil {
+		return err
+	}
+
+	var keep []quarantineRecord
+	for _, record := range records {
+		if !record.Time.Before(cutoff) {
+			keep = append(keep, record)
+		}
+	}
+
+	// Records sharing an ID share the object, so it's counted once and only
+	// freed with the last of them
+	refs := make(map[string]int)
+	var total int64
+	for _, record := range keep {
+		if refs[record.ID] == 0 {
+			total += record.Size
+		}
+		refs[record.ID]++
+	}
+	for len(keep) > 0 && total > budget {
+		refs[keep[0].ID]--
+		if refs[keep[0].ID] == 0 {
+			total -= keep[0].Size
+		}
+		keep = keep[1:]
+	}
+
+	if len(keep) < len(records) {
+		// Index first, so it never refers to a missing object
+		if err := app.saveQuarantine(keep); err != nil {
+			return err
+		}
+		log.Printf("Pruned %d items from the quarantine", len(records)-len(keep))
+	}
+
+	objects, err := os.ReadDir(filepath.Join(app.quarantineDir(), "objects"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, object := range objects {
+		if refs[object.Name()] > 0 {
+			continue
+		}
+		path := app.quarantineObject(object.Name())
+		files := diskFiles(path)
+		if err := app.removeTree(path); err != nil {
+			app.skipItem("Failed to remove quarantined", path, err)
+			continue
+		}
+		app.countFreed(files)
+	}
+	return nil
+}
+
+func defineRestoreCommand(fs *flag.FlagSet) func(args []string) error {
+	to := fs.String("to", "", "restore into this directory instead of the original location")
+	force := fs.Bool("force", false, "restore even if the item no longer matches its checksums")
+	system := fs.Bool("system", false, "use the system-wide quarantine")
+
+	return func(args []string) error {
+		app, err := commandApp(*system)
+		if err != nil {
+			return err
+		}
+
+		switch len(args) {
+		case 0:
+			return app.listQuarantine()
+		case 1:
+			return app.restore(args[0], *to, *force)
+		default:
+			return fmt.Errorf("expected one path or ID to restore")
+		}
+	}
+}
+
+func (app *App) listQuarantine() error {
+	records, err := app.loadQuarantine()
+	if err != nil {
+		return fmt.Errorf("failed to read quarantine index: %w", err)
+	}
+	if len(records) == 0 {
+		fmt.Println(T("restore.empty"))
+		return nil
+	}
+	for _, r := range records {
+		fmt.Printf("%s  %s  %8s  %s\n", r.ID, r.Time.Local().Format("2006-01-02 15:04"), formatSize(r.Size), displayName(app.displayPath(r.Path)))
+	}
+	return nil
+}
+
+// restore brings back the newest quarantined item whose ID starts with, or
+// whose original path is, target.
+func (app *App) restore(target, toDir string, force bool) error {
+	records, err := app.loadQuarantine()
+	if err != nil {
+		return fmt.Errorf("failed to read quarantine index: %w", err)
+	}
+
+	path := target
+	if strings.HasPrefix(path, "~/") {
+		path = filepath.Join(app.homeDir, path[2:])
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	index := -1
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Path == path || strings.HasPrefix(records[i].ID, target) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("nothing quarantined matches %s", target)
+	}
+
+	dest, err := app.restoreRecord(records, index, toDir, force)
+	if err != nil {
+		return err
+	}
+	fmt.Println(T("restore.restored", displayName(app.displayPath(dest))))
+	return nil
+}
+
+// restoreRecord brings back records[index], into toDir if it's set, drops
+// it from the index and returns where it was restored to. Unless force is
+// set, content that doesn't match its checksums isn't restored.
+func (app *App) restoreRecord(records []quarantineRecord, index int, toDir string, force bool) (string, error) {
+	record := records[index]
+	if !force {
+		if err := app.verifyObject(record); err != nil {
+			return "", fmt.Errorf("refusing to restore %s: %w (--force restores it anyway)", record.Path, err)
+		}
+	}
+
+	dest := record.Path
+	if toDir != "" {
+		dest = filepath.Join(toDir, filepath.Base(record.Path))
+	}
+	if _, err := os.Lstat(dest); err == nil {
+		return "", fmt.Errorf("%s already exists", dest)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", err
+	}
+
+	// Content shared with other records stays in the quarantine for them
+	shared := false
+	for i, r := range records {
+		if i != index && r.ID == record.ID {
+			shared = true
+		}
+	}
+	object := app.quarantineObject(record.ID)
+	move := moveTree
+	if shared {
+		move = copyTree
+	}
+	if err := move(object, dest, app.limiter()); err != nil {
+		return "", fmt.Errorf("failed to restore %s: %w", dest, err)
+	}
+
+	if err := os.Lchown(dest, record.UID, record.GID); err != nil && !errors.Is(err, fs.ErrPermission) {
+		return "", fmt.Errorf("failed to restore ownership of %s: %w", dest, err)
+	}
+	if record.Mode&fs.ModeSymlink == 0 {
+		os.Chmod(dest, record.Mode.Perm())
+		os.Chtimes(dest, record.AccessTime, record.ModTime)
+	}
+
+	if err := app.saveQuarantine(append(records[:index:index], records[index+1:]...)); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
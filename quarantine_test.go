@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestQuarantineSameListing quarantines two folders alike in everything but
+// their files' content, checking that both are kept and come back intact.
+func TestQuarantineSameListing(t *testing.T) {
+	home := t.TempDir()
+	app := newHomeApp(home)
+	app.quarantine = true
+
+	when := time.Now().AddDate(0, 0, -30)
+	dirs := map[string]string{
+		filepath.Join(home, "a", "node_modules"): "aaaa\n",
+		filepath.Join(home, "b", "node_modules"): "bbbb\n",
+	}
+	for dir, data := range dirs {
+		file := filepath.Join(dir, "index.js")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range []string{file, dir} {
+			if err := os.Chtimes(p, when, when); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for dir := range dirs {
+		if err := app.removeAll(dir); err != nil {
+			t.Fatalf("removeAll %s: %v", dir, err)
+		}
+	}
+	for dir, data := range dirs {
+		if err := app.restore(dir, "", false); err != nil {
+			t.Errorf("restore %s: %v", dir, err)
+			continue
+		}
+		got, err := os.ReadFile(filepath.Join(dir, "index.js"))
+		if err != nil || string(got) != data {
+			t.Errorf("%s was restored with %q (%v), want %q", dir, got, err, data)
+		}
+	}
+}
+
+// TestQuarantineMismatchedObject quarantines a file whose ID is taken by an
+// object with other content, checking that the file is kept.
+func TestQuarantineMismatchedObject(t *testing.T) {
+	home := t.TempDir()
+	app := newHomeApp(home)
+	app.quarantine = true
+
+	path := filepath.Join(home, "notes.txt~")
+	if err := os.WriteFile(path, []byte("notes\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksums, err := quarantineManifest(path, app.limiter())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := contentID(path, info, checksums)
+	if err != nil {
+		t.Fatal(err)
+	}
+	object := app.quarantineObject(id)
+	if err := os.MkdirAll(filepath.Dir(object), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(object, []byte("something else\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.remove(path); err == nil {
+		t.Error("remove succeeded, dropping the file as a duplicate of another")
+	}
+	if _, err := os.Lstat(path); err != nil {
+		t.Errorf("%s is gone: %v", path, err)
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	remoteConfigCache = "remote-config.json"
+	remoteConfigMeta  = "remote-config.meta.json"
+	remoteConfigRepo  = "remote-config.git"
+	// remoteConfigMaxSize caps what's read from a config URL.
+	remoteConfigMaxSize = 1 << 20
+	remoteConfigTimeout = 2 * time.Minute
+	defaultRemotePath   = configFileName
+)
+
+var remoteClient = &http.Client{Timeout: 30 * time.Second}
+
+// RemoteConfig is where a centrally managed config is fetched from at the
+// start of every run: an HTTPS URL, or a file in a git repository. The local
+// config's own keys override it.
+type RemoteConfig struct {
+	URL string `json:"url"`
+	Git string `json:"git"`
+	// Ref is the branch or tag to check out, the repository's default
+	// branch if empty; Path is the file in it, saafsafai.json if empty.
+	Ref  string `json:"ref"`
+	Path string `json:"path"`
+}
+
+func (r RemoteConfig) enabled() bool {
+	return r.URL != "" || r.Git != ""
+}
+
+// source identifies the remote config, to tell when the cache is of another.
+func (r RemoteConfig) source() string {
+	if r.URL != "" {
+		return r.URL
+	}
+	return r.Git + "#" + r.Ref + ":" + r.path()
+}
+
+func (r RemoteConfig) path() string {
+	if r.Path == "" {
+		return defaultRemotePath
+	}
+	return r.Path
+}
+
+func (r RemoteConfig) validate() error {
+	switch {
+	case r.URL != "" && r.Git != "":
+		return fmt.Errorf("remote_config can have url or git, not both")
+	case r.URL != "":
+		if u, err := url.Parse(r.URL); err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("invalid remote_config.url %q, expected an https:// URL", r.URL)
+		}
+	case r.Git != "":
+		if strings.HasPrefix(r.Git, "-") {
+			return fmt.Errorf("invalid remote_config.git %q", r.Git)
+		}
+		if clean := filepath.Clean(r.path()); filepath.IsAbs(clean) || strings.HasPrefix(clean, "..") {
+			return fmt.Errorf("invalid remote_config.path %q, expected a path inside the repository", r.Path)
+		}
+	}
+	return nil
+}
+
+// remoteMeta is what's known about the cached remote config.
+type remoteMeta struct {
+	Source  string    `json:"source"`
+	ETag    string    `json:"etag,omitempty"`
+	Fetched time.Time `json:"fetched"`
+}
+
+// remoteConfigPath is the cached copy of the remote config, the one runs
+// use.
+func (app *App) remoteConfigPath() string {
+	return filepath.Join(app.stateDir, remoteConfigCache)
+}
+
+// fetchRemoteConfig updates the cached remote config, revalidating it with
+// its ETag, or pulling the repository. A config that doesn't parse or
+// validate isn't cached; runs keep the last good one, if any.
+func (app *App) fetchRemoteConfig(remote RemoteConfig) error {
+	if err := remote.validate(); err != nil {
+		return err
+	}
+	var meta remoteMeta
+	if data, err := os.ReadFile(filepath.Join(app.stateDir, remoteConfigMeta)); err == nil {
+		json.Unmarshal(data, &meta)
+	}
+	if meta.Source != remote.source() {
+		// The cache is of another remote config
+		meta = remoteMeta{Source: remote.source()}
+		if err := os.Remove(app.remoteConfigPath()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	var data []byte
+	var err error
+	if remote.URL != "" {
+		data, err = app.downloadConfig(remote.URL, &meta)
+	} else {
+		data, err = app.pullConfig(remote)
+	}
+	if err != nil {
+		return err
+	}
+	meta.Fetched = time.Now()
+
+	if data != nil {
+		var config Config
+		if err := json.Unmarshal(data, &config); err != nil {
+			return fmt.Errorf("failed to parse the remote config: %w", err)
+		}
+		if err := config.validate(); err != nil {
+			return fmt.Errorf("invalid remote config: %w", err)
+		}
+		if err := os.MkdirAll(app.stateDir, 0755); err != nil {
+			return fmt.Errorf("failed to create state directory: %w", err)
+		}
+		if err := writeConfigFile(app.remoteConfigPath()+".tmp", data, config); err != nil {
+			return fmt.Errorf("failed to cache the remote config: %w", err)
+		}
+		if err := os.Rename(app.remoteConfigPath()+".tmp", app.remoteConfigPath()); err != nil {
+			return fmt.Errorf("failed to cache the remote config: %w", err)
+		}
+	}
+	metaData, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(app.stateDir, remoteConfigMeta), metaData, 0644)
+}
+
+// downloadConfig fetches the config at rawURL, or returns nil if it hasn't
+// changed since the cached copy, going by its ETag.
+func (app *App) downloadConfig(rawURL string, meta *remoteMeta) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(app.remoteConfigPath()); err == nil && meta.ETag != "" {
+		req.Header.Set("If-None-Match", meta.ETag)
+	}
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the remote config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("failed to fetch the remote config: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, remoteConfigMaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the remote config: %w", err)
+	}
+	if len(data) > remoteConfigMaxSize {
+		return nil, fmt.Errorf("the remote config is bigger than %s", formatSize(remoteConfigMaxSize))
+	}
+	meta.ETag = resp.Header.Get("ETag")
+	return data, nil
+}
+
+// pullConfig brings a shallow clone of the repository in the state
+// directory up to date and reads the config file from it.
+func (app *App) pullConfig(remote RemoteConfig) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteConfigTimeout)
+	defer cancel()
+	git := func(args ...string) error {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+
+	repo := filepath.Join(app.stateDir, remoteConfigRepo)
+	origin, err := exec.CommandContext(ctx, "git", "-C", repo, "remote", "get-url", "origin").Output()
+	if err != nil || strings.TrimSpace(string(origin)) != remote.Git {
+		if err := os.RemoveAll(repo); err != nil {
+			return nil, err
+		}
+		args := []string{"clone", "--quiet", "--depth", "1"}
+		if remote.Ref != "" {
+			args = append(args, "--branch", remote.Ref)
+		}
+		if err := git(append(args, "--", remote.Git, repo)...); err != nil {
+			return nil, err
+		}
+	} else {
+		ref := remote.Ref
+		if ref == "" {
+			ref = "HEAD"
+		}
+		if err := git("-C", repo, "fetch", "--quiet", "--depth", "1", "origin", ref); err != nil {
+			return nil, err
+		}
+		if err := git("-C", repo, "reset", "--quiet", "--hard", "FETCH_HEAD"); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(repo, filepath.Clean(remote.path())))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the remote config: %w", err)
+	}
+	return data, nil
+}
+
+// remoteCommandKeys are the config keys that run commands. Whoever
+// controls a remote config could otherwise run anything on every machine
+// following it, as root for the system service.
+var remoteCommandKeys = []string{"download_actions", "post_clean.hooks", "snapshot.command"}
+
+// remoteBase returns the cached remote config, as JSON migrated to this
+// version, or nil if none has been fetched yet.
+func (app *App) remoteBase() ([]byte, error) {
+	data, err := os.ReadFile(app.remoteConfigPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the cached remote config: %w", err)
+	}
+	data, err = app.migrateConfig(app.remoteConfigPath(), data)
+	if err != nil {
+		return nil, err
+	}
+	return app.withoutRemoteCommands(data)
+}
+
+// withoutRemoteCommands drops the keys that run commands from remote config
+// JSON, unless the system policy's remote_commands allows them.
+func (app *App) withoutRemoteCommands(data []byte) ([]byte, error) {
+	policy, err := loadPolicy()
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil && policy.RemoteCommands {
+		return data, nil
+	}
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse the cached remote config: %w", err)
+	}
+	var dropped []string
+	for _, key := range remoteCommandKeys {
+		parent, last, ok := keyParent(values, key)
+		if !ok {
+			continue
+		}
+		switch value := parent[last].(type) {
+		case nil:
+		case string:
+			if value != "" {
+				dropped = append(dropped, key)
+			}
+		case []any:
+			if len(value) > 0 {
+				dropped = append(dropped, key)
+			}
+		case map[string]any:
+			if len(value) > 0 {
+				dropped = append(dropped, key)
+			}
+		default:
+			dropped = append(dropped, key)
+		}
+		delete(parent, last)
+	}
+	// Once, though the config is loaded more than once
+	if len(dropped) > 0 && !app.remoteDropped {
+		log.Printf("Warning: ignoring %s from the remote config, as they run commands (the system policy's remote_commands would allow them)", strings.Join(dropped, ", "))
+		app.remoteDropped = true
+	}
+	return json.Marshal(values)
+}
+
+// localOverrides returns the keys of cfg that differ from the remote config,
+// so saving a config loaded over one keeps only the user's own settings.
+func (app *App) localOverrides(cfg Config) ([]byte, error) {
+	var base Config
+	data, err := app.remoteBase()
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		if err := json.Unmarshal(data, &base); err != nil {
+			return nil, fmt.Errorf("failed to parse the cached remote config: %w", err)
+		}
+	}
+
+	merged, err := configMap(cfg)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := configMap(base)
+	if err != nil {
+		return nil, err
+	}
+	overrides := map[string]any{"version": configVersion, "remote_config": merged["remote_config"]}
+	for key, value := range merged {
+		if !reflect.DeepEqual(value, remote[key]) {
+			overrides[key] = value
+		}
+	}
+	return json.MarshalIndent(overrides, "", "  ")
+}
+
+// configMap returns cfg as decoded JSON.
+func configMap(cfg Config) (map[string]any, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
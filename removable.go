@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	removableMaxAge = 90
+	removableLayout = "2006-01"
+	// mountPollInterval is how often the daemon looks for media mounted.
+	mountPollInterval = 5 * time.Second
+)
+
+// RemovableProfile is what to do on a removable disk or card whenever it's
+// mounted, picked by its file system's label or UUID as lsblk -o
+// NAME,LABEL,UUID shows them.
+type RemovableProfile struct {
+	Name     string          `json:"name"`
+	Label    string          `json:"label"`
+	UUID     string          `json:"uuid"`
+	Organize []MediaOrganize `json:"organize"`
+	Prune    []MediaPrune    `json:"prune"`
+}
+
+// MediaOrganize sorts the files under Dir, a folder relative to the top of
+// the media such as "DCIM", into folders of To (Dir by default) named by
+// Layout, a Go time layout (default "2006-01") of their modification time,
+// which cameras set to when the photo was taken.
+type MediaOrganize struct {
+	Dir    string `json:"dir"`
+	To     string `json:"to"`
+	Layout string `json:"layout"`
+}
+
+// MediaPrune removes the files under Dir, a folder relative to the top of
+// the media, matching Pattern (a glob of names, default all) once they're
+// MaxAgeDays old (default 90), except the KeepNewest newest of them.
+type MediaPrune struct {
+	Dir        string `json:"dir"`
+	Pattern    string `json:"pattern"`
+	MaxAgeDays int    `json:"max_age_days"`
+	KeepNewest int    `json:"keep_newest"`
+}
+
+// mountedProfile is a profile's media, mounted.
+type mountedProfile struct {
+	profile RemovableProfile
+	fs      filesystem
+}
+
+// validateRemovable checks the removable media profiles.
+func validateRemovable(profiles []RemovableProfile) error {
+	for i, p := range profiles {
+		key := fmt.Sprintf("removable[%d]", i)
+		if p.Label == "" && p.UUID == "" {
+			return fmt.Errorf("invalid %s, expected a label or uuid", key)
+		}
+		for j, rule := range p.Organize {
+			if !filepath.IsLocal(rule.Dir) || (rule.To != "" && !filepath.IsLocal(rule.To)) {
+				return fmt.Errorf("invalid %s.organize[%d], expected dir and to inside the media", key, j)
+			}
+		}
+		for j, rule := range p.Prune {
+			if !filepath.IsLocal(rule.Dir) {
+				return fmt.Errorf("invalid %s.prune[%d].dir %q, expected a folder inside the media", key, j, rule.Dir)
+			}
+			if _, err := filepath.Match(rule.Pattern, ""); err != nil {
+				return fmt.Errorf("invalid %s.prune[%d].pattern %q: %w", key, j, rule.Pattern, err)
+			}
+			if rule.MaxAgeDays < 0 || rule.KeepNewest < 0 {
+				return fmt.Errorf("invalid %s.prune[%d], expected max_age_days and keep_newest of 0 or more", key, j)
+			}
+		}
+	}
+	return nil
+}
+
+// mountedProfiles returns the profiles whose media are mounted, with where.
+func mountedProfiles(profiles []RemovableProfile) []mountedProfile {
+	var mounted []mountedProfile
+	for _, fs := range mountedFilesystems() {
+		device, err := filepath.EvalSymlinks(fs.source)
+		if err != nil {
+			device = fs.source
+		}
+		for _, p := range profiles {
+			if (p.Label != "" && diskLink("by-label", p.Label) == device) || (p.UUID != "" && diskLink("by-uuid", p.UUID) == device) {
+				mounted = append(mounted, mountedProfile{profile: p, fs: fs})
+				break
+			}
+		}
+	}
+	return mounted
+}
+
+// diskLink returns the device udev's /dev/disk/<kind>/<name> link points
+// to, or "" if there's none. udev escapes a name's spaces and slashes.
+func diskLink(kind, name string) string {
+	name = strings.NewReplacer(" ", `\x20`, "/", `\x2f`).Replace(name)
+	device, err := filepath.EvalSymlinks(filepath.Join("/dev/disk", kind, name))
+	if err != nil {
+		return ""
+	}
+	return device
+}
+
+// cleanRemovable runs the profiles of the media mounted (only those in
+// app.media, when the daemon runs it for media just mounted).
+func (app *App) cleanRemovable(config Config) error {
+	// Loading a config doesn't validate it, and a folder outside the media
+	// would be pruned or organized like one inside it
+	if err := validateRemovable(config.Removable); err != nil {
+		return err
+	}
+	for _, m := range mountedProfiles(config.Removable) {
+		if app.media != nil && !app.media[m.fs.source] {
+			continue
+		}
+		name := m.profile.Name
+		if name == "" {
+			name = m.fs.mountPoint
+		}
+		log.Printf("Cleaning %s, mounted at %s", name, m.fs.mountPoint)
+		for _, rule := range m.profile.Organize {
+			app.organizeMedia(name, m.fs.mountPoint, rule)
+		}
+		for _, rule := range m.profile.Prune {
+			app.pruneMedia(name, m.fs.mountPoint, rule)
+		}
+	}
+	return nil
+}
+
+// mediaFiles lists the regular files under dir, leaving out hidden ones.
+func (app *App) mediaFiles(dir string) []string {
+	var files []string
+	app.scanTree(dir, nil, func(rel string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if rel != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			files = append(files, filepath.Join(dir, rel))
+		}
+		return nil
+	})
+	return files
+}
+
+// organizeMedia sorts a folder of the media into dated folders.
+func (app *App) organizeMedia(name, top string, rule MediaOrganize) {
+	dir := filepath.Join(top, rule.Dir)
+	to := dir
+	if rule.To != "" {
+		to = filepath.Join(top, rule.To)
+	}
+	layout := rule.Layout
+	if layout == "" {
+		layout = removableLayout
+	}
+	for _, path := range app.mediaFiles(dir) {
+		info, err := os.Lstat(path)
+		if err != nil {
+			continue
+		}
+		destDir := filepath.Join(to, info.ModTime().Format(layout))
+		if filepath.Dir(path) == destDir {
+			continue
+		}
+		if app.isProtected(path) {
+			app.skipItem("Failed to organize", path, errProtected)
+			continue
+		}
+		item := fmt.Sprintf("%s: %s → %s", name, app.displayPath(path), app.displayPath(destDir))
+		if app.dryRun {
+			app.addItem(&app.summary.MediaItems, item)
+			continue
+		}
+		if err := os.MkdirAll(destDir, 0755); err != nil {
+			app.skipItem("Failed to organize", path, err)
+			continue
+		}
+		dest := filepath.Join(destDir, app.freeName(destDir, filepath.Base(path), ""))
+		move := journalEntry{Op: opMove, Path: path, Dest: dest}
+		if err := app.journaled(move, func() error { return os.Rename(path, dest) }); err != nil {
+			app.skipItem("Failed to organize", path, err)
+			continue
+		}
+		app.addItem(&app.summary.MediaItems, item)
+	}
+}
+
+// pruneMedia removes a folder's old files from the media.
+func (app *App) pruneMedia(name, top string, rule MediaPrune) {
+	type file struct {
+		path    string
+		modTime time.Time
+	}
+	var files []file
+	for _, path := range app.mediaFiles(filepath.Join(top, rule.Dir)) {
+		if rule.Pattern != "" {
+			if ok, _ := filepath.Match(rule.Pattern, filepath.Base(path)); !ok {
+				continue
+			}
+		}
+		if info, err := os.Lstat(path); err == nil {
+			files = append(files, file{path, info.ModTime()})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+
+	cutoff := app.ageCutoff(rule.MaxAgeDays, removableMaxAge)
+	for i, f := range files {
+		if i < rule.KeepNewest || !f.modTime.Before(cutoff) {
+			continue
+		}
+		if err := app.removeAll(f.path); err != nil {
+			app.skipItem("Failed to remove", f.path, err)
+			continue
+		}
+		app.addItem(&app.summary.MediaItems, fmt.Sprintf("%s: %s", name, app.displayPath(f.path)))
+	}
+}
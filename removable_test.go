@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestCleanRemovableOutsideMedia(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile RemovableProfile
+	}{
+		{"prune dir", RemovableProfile{Label: "CAMERA", Prune: []MediaPrune{{Dir: "../home/x"}}}},
+		{"organize dir", RemovableProfile{Label: "CAMERA", Organize: []MediaOrganize{{Dir: "/home/x"}}}},
+		{"organize to", RemovableProfile{Label: "CAMERA", Organize: []MediaOrganize{{Dir: "DCIM", To: "../../home/x"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newHomeApp(t.TempDir())
+			if err := app.cleanRemovable(Config{Removable: []RemovableProfile{tt.profile}}); err == nil {
+				t.Error("cleanRemovable ran a rule for a folder outside the media")
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// documentTitleMax is the most characters of a title put in a file name.
+const documentTitleMax = 60
+
+var (
+	// genericDocumentName matches the names a download gets when the site
+	// doesn't give a better one, once the copy markers are dropped (see
+	// normalizeName): "document", "download", "scan 12", or a string of
+	// digits and hex such as an ID or a timestamp.
+	genericDocumentName = regexp.MustCompile(`^((document|download|file|scan|scanned|untitled|print|receipt|invoice|statement|attachment|doc|pdf)( ?\d+)*|[0-9a-f ]*\d[0-9a-f ]*)$`)
+
+	documentISODate   = regexp.MustCompile(`\b((?:19|20)\d\d)-(\d\d)-(\d\d)\b`)
+	documentDigitDate = regexp.MustCompile(`\b(\d{1,2})[./](\d{1,2})[./]((?:19|20)\d\d)\b`)
+	documentDayMonth  = regexp.MustCompile(`(?i)\b(\d{1,2})(?:st|nd|rd|th)?[ -]([a-z]{3})[a-z]*\.?[ ,-]*((?:19|20)\d\d)\b`)
+	documentMonthDay  = regexp.MustCompile(`(?i)\b([a-z]{3})[a-z]*\.? (\d{1,2})(?:st|nd|rd|th)?,? ((?:19|20)\d\d)\b`)
+
+	// documentTitleBoilerplate is what office suites put before the file
+	// name in a PDF's title.
+	documentTitleBoilerplate = regexp.MustCompile(`(?i)^(microsoft (word|excel|powerpoint) - )`)
+)
+
+var documentMonths = map[string]time.Month{
+	"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April,
+	"may": time.May, "jun": time.June, "jul": time.July, "aug": time.August,
+	"sep": time.September, "oct": time.October, "nov": time.November, "dec": time.December,
+}
+
+// isGenericDocumentName reports whether a file name says nothing about the
+// document, so naming it after its content loses nothing.
+func isGenericDocumentName(name string) bool {
+	key := normalizeName(name)
+	return genericDocumentName.MatchString(strings.TrimSuffix(key, filepath.Ext(key)))
+}
+
+// documentName returns a better name for a generically named PDF, from the
+// date and title on its first page (or, failing that, its document info):
+// "document(3).pdf" may become "2024-05-12 Electricity Bill.pdf". It returns
+// "" when the name is fine as it is or nothing better could be read.
+func documentName(path string) string {
+	if !isGenericDocumentName(filepath.Base(path)) {
+		return ""
+	}
+	doc, err := openPDF(path)
+	if err != nil || doc.encrypted() {
+		return ""
+	}
+
+	lines := pdfTextLines(doc.firstPage())
+	infoTitle, created := doc.info()
+	title := cleanTitle(documentTitleBoilerplate.ReplaceAllString(infoTitle, ""))
+	if title == "" || isGenericDocumentName(title+".pdf") {
+		title = pageTitle(lines)
+	}
+	var date time.Time
+	for _, line := range lines {
+		if date = findDate(line.text); !date.IsZero() {
+			break
+		}
+	}
+	if date.IsZero() {
+		date = created
+	}
+
+	var parts []string
+	if !date.IsZero() {
+		parts = append(parts, date.Format("2006-01-02"))
+	}
+	if title != "" {
+		parts = append(parts, title)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, " ") + ".pdf"
+}
+
+// pageTitle picks the title among a page's lines: the first of those set
+// largest that reads as text rather than as a date, an amount or noise.
+func pageTitle(lines []pdfLine) string {
+	var title string
+	var size float64
+	for _, line := range lines {
+		text := cleanTitle(line.text)
+		if text == "" || !findDate(text).IsZero() || line.size <= size {
+			continue
+		}
+		title, size = text, line.size
+	}
+	return title
+}
+
+// cleanTitle makes text fit for a file name: the file name's own extension
+// and characters that can't or shouldn't be in one dropped, spaces
+// collapsed and the length capped. Text that's mostly not letters, as text
+// in fonts this can't decode comes out, gives "".
+func cleanTitle(text string) string {
+	for _, ext := range []string{".pdf", ".docx", ".doc", ".odt"} {
+		if strings.HasSuffix(strings.ToLower(text), ext) {
+			text = text[:len(text)-len(ext)]
+		}
+	}
+	letters, other := 0, 0
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r):
+			letters++
+		case unicode.IsDigit(r) || r == ' ':
+		case r == '/' || r == '\\' || r == ':' || unicode.IsControl(r) || r == utf8.RuneError:
+			other++
+			return ' '
+		case !unicode.IsPunct(r) && !unicode.IsSymbol(r):
+			other++
+			return -1
+		}
+		return r
+	}, text)
+	if letters < 3 || other > letters/4 {
+		return ""
+	}
+
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) > documentTitleMax {
+		runes := []rune(text)[:documentTitleMax]
+		text = string(runes)
+		if i := strings.LastIndexByte(text, ' '); i > documentTitleMax/2 {
+			text = text[:i]
+		}
+	}
+	return strings.Trim(text, " .-_,;")
+}
+
+// findDate returns the first date in text, written as 2024-05-12,
+// 12/05/2024 (day first, unless that can't be), 12 May 2024 or May 12, 2024.
+func findDate(text string) time.Time {
+	type candidate struct {
+		at               int
+		year, month, day string
+		monthName        bool
+	}
+	var found []candidate
+	if m := documentISODate.FindStringSubmatchIndex(text); m != nil {
+		found = append(found, candidate{at: m[0], year: text[m[2]:m[3]], month: text[m[4]:m[5]], day: text[m[6]:m[7]]})
+	}
+	if m := documentDigitDate.FindStringSubmatchIndex(text); m != nil {
+		c := candidate{at: m[0], day: text[m[2]:m[3]], month: text[m[4]:m[5]], year: text[m[6]:m[7]]}
+		if n, _ := strconv.Atoi(c.month); n > 12 {
+			c.day, c.month = c.month, c.day
+		}
+		found = append(found, c)
+	}
+	if m := documentDayMonth.FindStringSubmatchIndex(text); m != nil {
+		found = append(found, candidate{at: m[0], day: text[m[2]:m[3]], month: text[m[4]:m[5]], year: text[m[6]:m[7]], monthName: true})
+	}
+	if m := documentMonthDay.FindStringSubmatchIndex(text); m != nil {
+		found = append(found, candidate{at: m[0], month: text[m[2]:m[3]], day: text[m[4]:m[5]], year: text[m[6]:m[7]], monthName: true})
+	}
+
+	var date time.Time
+	first := len(text)
+	for _, c := range found {
+		if c.at >= first {
+			continue
+		}
+		year, _ := strconv.Atoi(c.year)
+		day, _ := strconv.Atoi(c.day)
+		var month time.Month
+		if c.monthName {
+			month = documentMonths[strings.ToLower(c.month)]
+		} else {
+			n, _ := strconv.Atoi(c.month)
+			month = time.Month(n)
+		}
+		d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		// time.Date normalizes 31 April into 1 May; that's not a date
+		if month < time.January || month > time.December || d.Day() != day || d.Month() != month {
+			continue
+		}
+		date, first = d, c.at
+	}
+	return date
+}
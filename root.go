@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// rootStateDir is where runs with --root keep their state and logs, inside
+// the root so a container running them needs no other volume.
+const rootStateDir = ".saafsafai"
+
+// NewRootApp returns an App that treats root as its home: the config is
+// root/saafsafai.json, the config's paths are relative to root and the state
+// and logs are kept in root/.saafsafai. It's meant for containers cleaning
+// mounted volumes, so it never touches systemd.
+func NewRootApp(root string) (*App, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("--root %s is not a directory", root)
+	}
+	return &App{
+		homeDir:      root,
+		downloadsDir: filepath.Join(root, "Downloads"),
+		configPath:   filepath.Join(root, configFileName),
+		stateDir:     filepath.Join(root, rootStateDir),
+		logDir:       filepath.Join(root, rootStateDir, "logs"),
+		noSystemd:    true,
+		summary:      Summary{},
+	}, nil
+}
+
+// NewHomeApp returns an App for the home directory home instead of the
+// user's own, e.g. another user's (with sudo) or a mounted backup of one.
+func NewHomeApp(home string) (*App, error) {
+	home, err := filepath.Abs(home)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(home)
+	if err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("--home %s is not a directory", home)
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && os.Geteuid() == 0 && st.Uid != 0 {
+		log.Printf("Warning: cleaning %s as root; the folders and files created in it will be owned by root (sudo -u its owner avoids that)", home)
+	}
+	return newHomeApp(home), nil
+}
+
+// pathFlags are the flags giving a run's paths one by one, overriding those
+// of its home directory (or --root).
+type pathFlags struct {
+	config, downloads, scan, stateDir, logDir string
+}
+
+func (app *App) usePaths(flags pathFlags) error {
+	abs := func(flag, path string, dest *string) error {
+		if path == "" {
+			return nil
+		}
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("invalid --%s: %w", flag, err)
+		}
+		*dest = path
+		return nil
+	}
+	for _, f := range []struct {
+		flag, path string
+		dest       *string
+	}{
+		{"config", flags.config, &app.configPath},
+		{"downloads", flags.downloads, &app.downloadsDir},
+		{"state-dir", flags.stateDir, &app.stateDir},
+		{"log-dir", flags.logDir, &app.logDir},
+	} {
+		if err := abs(f.flag, f.path, f.dest); err != nil {
+			return err
+		}
+	}
+	app.downloadsFlag = flags.downloads != ""
+
+	if flags.scan == "" {
+		return nil
+	}
+	for _, dir := range strings.Split(flags.scan, ",") {
+		var path string
+		if err := abs("scan", strings.TrimSpace(dir), &path); err != nil {
+			return err
+		}
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			return fmt.Errorf("--scan %s is not a directory", dir)
+		}
+		app.scanDirs = append(app.scanDirs, path)
+	}
+	return nil
+}
+
+// useDownloadsDir points the Downloads cleaner at the config's
+// downloads_dir, resolved against the home directory (or --root) when
+// relative, unless --downloads gave it.
+func (app *App) useDownloadsDir(dir string) {
+	if dir == "" || app.homeDir == "" || app.downloadsFlag {
+		return
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(app.homeDir, dir)
+	}
+	app.downloadsDir = filepath.Clean(dir)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// otherCategory is where Downloads files of extensions no category claims
+// are filed.
+const otherCategory = "Others"
+
+// defaultCategories are the built-in Downloads categories and the
+// extensions filed into each; the categories config adds to and overrides
+// them.
+var defaultCategories = map[string][]string{
+	"Documents":  {".pdf", ".txt", ".docx", ".doc", ".rtf", ".odt", ".pages"},
+	"Images":     {".png", ".jpg", ".jpeg", ".gif", ".bmp", ".svg", ".webp", ".tiff"},
+	"Videos":     {".mp4", ".mkv", ".avi", ".mov", ".wmv", ".flv", ".webm", ".m4v"},
+	"Audio":      {".mp3", ".wav", ".flac", ".aac", ".ogg", ".wma", ".m4a"},
+	"Archives":   {".zip", ".tar", ".gz", ".rar", ".7z", ".bz2", ".xz", ".tar.gz"},
+	"Installers": {".deb", ".rpm", ".dmg", ".exe", ".msi", ".appimage", ".sh", ".pkg"},
+	"Code":       {".py", ".js", ".go", ".java", ".cpp", ".c", ".html", ".css", ".json"},
+}
+
+// compoundExtensions are the built-in extensions of more than one part,
+// which name a file's type better than their last part alone; the
+// compound_extensions config adds to them.
+var compoundExtensions = []string{
+	".tar.gz", ".tar.bz2", ".tar.xz", ".tar.zst", ".tar.lz", ".tar.lz4",
+	".pkg.tar.zst", ".pkg.tar.xz", ".user.js", ".user.css",
+}
+
+// normalizeExt returns a config's extension key as fileExt gives them:
+// lower case, with its dot.
+func normalizeExt(key string) string {
+	key = strings.ToLower(key)
+	if !strings.HasPrefix(key, ".") {
+		key = "." + key
+	}
+	return key
+}
+
+// compoundExts returns the compound extensions files are matched against:
+// the built-in ones, those compound_extensions adds and those any
+// categories, delete_after_days or download_actions rule names.
+func compoundExts(config Config) []string {
+	exts := slices.Clone(compoundExtensions)
+	add := func(key string) {
+		if ext := normalizeExt(key); strings.Count(ext, ".") > 1 {
+			exts = append(exts, ext)
+		}
+	}
+	for _, key := range config.CompoundExtensions {
+		add(key)
+	}
+	for key := range config.Categories {
+		add(key)
+	}
+	for key := range config.DeleteAfterDays {
+		add(key)
+	}
+	for key := range config.DownloadActions {
+		add(key)
+	}
+	return exts
+}
+
+// fileExt returns the extension of the file name, in lower case: the
+// longest compound extension it ends in, such as .tar.gz, or else its last
+// part.
+func fileExt(config Config, name string) string {
+	name = strings.ToLower(name)
+	ext := filepath.Ext(name)
+	for _, compound := range compoundExts(config) {
+		if len(compound) > len(ext) && strings.HasSuffix(name, compound) {
+			ext = compound
+		}
+	}
+	return ext
+}
+
+// extLookup finds the value configured for files with extension ext, in a
+// config map whose keys may be given with or without their dot, in any case.
+// A compound extension without one of its own goes by its last part's, so a
+// .gz rule covers .tar.gz files unless a .tar.gz rule says otherwise.
+func extLookup[V any](m map[string]V, ext string) (V, bool) {
+	if key, ok := extRule(m, ext); ok {
+		return m[key], true
+	}
+	var zero V
+	return zero, false
+}
+
+// extRule returns the key of m that extLookup goes by for ext.
+func extRule[V any](m map[string]V, ext string) (string, bool) {
+	for _, want := range []string{ext, filepath.Ext(ext)} {
+		for key := range m {
+			if normalizeExt(key) == want {
+				return key, true
+			}
+		}
+	}
+	return "", false
+}
+
+// categoryFor returns the category folder files with extension ext are
+// filed into: the configured one, the built-in one, or Others. A compound
+// extension goes by its last part's built-in category if it has none.
+func categoryFor(config Config, ext string) string {
+	if category, ok := extLookup(config.Categories, ext); ok {
+		return category
+	}
+	for _, want := range []string{ext, filepath.Ext(ext)} {
+		for category, exts := range defaultCategories {
+			if slices.Contains(exts, want) {
+				return category
+			}
+		}
+	}
+	return otherCategory
+}
+
+// hasRule reports whether a categories, delete_after_days or
+// download_actions rule names files with extension ext, the only ones strict
+// mode touches.
+func hasRule(config Config, ext string) bool {
+	_, categorized := extLookup(config.Categories, ext)
+	_, expires := extLookup(config.DeleteAfterDays, ext)
+	_, scripted := extLookup(config.DownloadActions, ext)
+	return categorized || expires || scripted
+}
+
+// validCategory reports whether name can be a category folder: a single,
+// ordinary directory name.
+func validCategory(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`) && !strings.HasPrefix(name, ".")
+}
+
+// expireCategories removes the files in the category folders whose
+// extensions have a delete_after_days rule, once they're that old. Files
+// still loose in Downloads are checked as they're filed.
+func (app *App) expireCategories(config Config) {
+	for key, days := range config.DeleteAfterDays {
+		ext := normalizeExt(key)
+		// A .gz rule also covers .tar.gz files, which may be filed elsewhere
+		folders := map[string]bool{categoryFolder(config.CategoryNames, categoryFor(config, ext)): true}
+		for _, compound := range compoundExts(config) {
+			if rule, _ := extRule(config.DeleteAfterDays, compound); rule == key {
+				folders[categoryFolder(config.CategoryNames, categoryFor(config, compound))] = true
+			}
+		}
+		cutoff := app.ageCutoff(days, days)
+
+		var old []string
+		for _, folder := range sortedKeys(folders) {
+			dir := filepath.Join(app.downloadsDir, folder)
+			app.scanTree(dir, nil, func(rel string, d fs.DirEntry, err error) error {
+				if err != nil || !d.Type().IsRegular() {
+					return nil
+				}
+				// The rule the file goes by, a .tar.gz one over a .gz one
+				if rule, _ := extRule(config.DeleteAfterDays, fileExt(config, d.Name())); rule != key {
+					return nil
+				}
+				path := filepath.Join(dir, rel)
+				if info, err := d.Info(); err == nil && app.fileTime(path, info).Before(cutoff) {
+					old = append(old, path)
+				}
+				return nil
+			})
+		}
+		for _, path := range old {
+			app.expire(path)
+		}
+	}
+}
+
+// expired reports whether the Downloads file at path is past its extension's
+// delete_after_days, if it has one.
+func (app *App) expired(config Config, ext, path string, info os.FileInfo) bool {
+	days, ok := extLookup(config.DeleteAfterDays, ext)
+	return ok && app.fileTime(path, info).Before(app.ageCutoff(days, days))
+}
+
+func (app *App) expire(path string) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return
+	}
+	if err := app.remove(path); err != nil {
+		app.skipItem("Failed to remove expired download", path, err)
+		return
+	}
+	app.addItem(&app.summary.ExpiredFiles, fmt.Sprintf("%s (%s, %s)", app.displayPath(path), formatSize(info.Size()), app.fileTime(path, info).Format("2006-01-02")))
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// homeMatcher gets the entries of the shared home directory scan. Returning
+// filepath.SkipDir for a directory stops it getting the entries under it;
+// the scan only skips a directory every matcher is done with.
+type homeMatcher func(path string, d fs.DirEntry) error
+
+// homeScan holds what the matchers found in the home directory scan.
+type homeScan struct {
+	done        bool
+	err         error
+	nodeModules []string
+	// syncConflicts are the sync clients' conflict copies
+	syncConflicts []string
+	// backupFiles are editors' backup and swap files
+	backupFiles []string
+	// metadataFiles are file managers' .DS_Store, Thumbs.db and the like
+	metadataFiles []string
+	// gitRepos are the working trees of git repositories
+	gitRepos []string
+	// cacheSizes are the sizes of the cacheDirs found, for the history
+	cacheSizes map[string]int64
+}
+
+// homeScanners are the cleaners with a matcher. It's filled in init as the
+// cleaners' scan reads it.
+var homeScanners []cleaner
+
+func init() {
+	for _, c := range cleaners {
+		if c.scan != nil {
+			homeScanners = append(homeScanners, c)
+		}
+	}
+}
+
+// scanHome walks the home directory (or the --scan directories) once for
+// every enabled cleaner that looks for things anywhere in it, and for the
+// cache sizes the history records, instead of a walk each. It runs when the
+// first of them needs its results.
+func (app *App) scanHome(config Config) error {
+	if app.scan.done {
+		return app.scan.err
+	}
+	app.scan.done = true
+	defer app.timeAction("home scan", time.Now())
+
+	var matchers []homeMatcher
+	for _, c := range homeScanners {
+		if c.modes&app.mode() != 0 && c.enabled(config) && app.selected(c.name) {
+			if match := c.scan(app, config); match != nil {
+				matchers = append(matchers, match)
+			}
+		}
+	}
+	matchers = append(matchers, app.matchCacheSizes())
+
+	roots := app.scanDirs
+	if roots == nil {
+		roots = []string{app.homeDir}
+	}
+	seen := make(map[inodeKey]bool)
+	for _, root := range roots {
+		if app.scan.err = app.walkScan(root, matchers, seen); app.scan.err != nil {
+			break
+		}
+	}
+	return app.scan.err
+}
+
+// walkScan walks root for the matchers, within the scan limits, leaving out
+// the directories in seen.
+func (app *App) walkScan(root string, matchers []homeMatcher, seen map[inodeKey]bool) error {
+	// pruned[i] is the directory matchers[i] skips, if the walk is in one
+	pruned := make([]string, len(matchers))
+	return app.scanTree(root, seen, func(rel string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Skip directories we can't read
+			return nil
+		}
+		path := filepath.Join(root, rel)
+
+		wanted := false
+		for i, match := range matchers {
+			if pruned[i] != "" {
+				if isUnder(path, pruned[i]) {
+					continue
+				}
+				pruned[i] = ""
+			}
+			if match(path, d) == filepath.SkipDir && d.IsDir() {
+				pruned[i] = path
+				continue
+			}
+			wanted = true
+		}
+		if d.IsDir() && !wanted {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+// resolveRoots resolves a config's roots, the directories a cleaner looks in
+// instead of the home directory, against the home directory.
+func (app *App) resolveRoots(roots []string) []string {
+	var resolved []string
+	for _, root := range roots {
+		if !filepath.IsAbs(root) {
+			root = filepath.Join(app.homeDir, root)
+		}
+		resolved = append(resolved, filepath.Clean(root))
+	}
+	return resolved
+}
+
+// scanRoots walks the config's roots for match, if it has any, or else runs
+// the home directory scan, which has the cleaner's matcher already.
+func (app *App) scanRoots(config Config, roots []string, match homeMatcher) error {
+	if len(roots) == 0 {
+		return app.scanHome(config)
+	}
+	seen := make(map[inodeKey]bool)
+	for _, root := range app.resolveRoots(roots) {
+		if _, err := os.Stat(root); err != nil {
+			continue
+		}
+		if err := app.walkScan(root, []homeMatcher{match}, seen); err != nil {
+			return fmt.Errorf("error scanning %s: %w", root, err)
+		}
+	}
+	return nil
+}
+
+// isUnder reports whether path is inside dir.
+func isUnder(path, dir string) bool {
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// matchNodeModules collects the node_modules folders, without descending
+// into them.
+func (app *App) matchNodeModules() homeMatcher {
+	return func(path string, d fs.DirEntry) error {
+		if d.IsDir() && d.Name() == "node_modules" {
+			app.scan.nodeModules = append(app.scan.nodeModules, path)
+			return filepath.SkipDir
+		}
+		return nil
+	}
+}
+
+// matchCacheSizes adds up the sizes of the files in the cacheDirs, as
+// dirSize would.
+func (app *App) matchCacheSizes() homeMatcher {
+	dirs := app.cacheDirs()
+	app.scan.cacheSizes = make(map[string]int64)
+	return func(path string, d fs.DirEntry) error {
+		for _, dir := range dirs {
+			switch {
+			case path == dir:
+				app.scan.cacheSizes[dir] += 0
+				return nil
+			case isUnder(path, dir):
+				if d.Type().IsRegular() {
+					if info, err := d.Info(); err == nil {
+						app.scan.cacheSizes[dir] += info.Size()
+					}
+				}
+				return nil
+			case isUnder(dir, path):
+				// On the way to a cache
+				return nil
+			}
+		}
+		return filepath.SkipDir
+	}
+}
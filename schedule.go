@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	timerName = "saafsafai.timer"
+
+	// scheduleBoot is accepted as a Schedule meaning "no timer", as written
+	// by configs from before RunOn existed.
+	scheduleBoot    = "boot"
+	defaultSchedule = "daily"
+
+	// defaultRandomizedDelay spreads timer runs so machines sharing a
+	// schedule don't all grind their disks at the same minute.
+	defaultRandomizedDelay = "30min"
+)
+
+// When the service runs: at login (boot, for the system service), from the
+// timer, or both.
+const (
+	runOnLogin = "login"
+	runOnTimer = "timer"
+	runOnBoth  = "both"
+)
+
+var runOnNames = []string{runOnLogin, runOnTimer, runOnBoth}
+
+// scheduleNames are the schedules offered by completion; any other systemd
+// calendar expression (e.g. "Mon *-*-* 09:00") works too.
+var scheduleNames = []string{"hourly", "daily", "weekly", "monthly"}
+
+func init() {
+	flagValues["run-on"] = func() []string { return runOnNames }
+	flagValues["schedule"] = func() []string { return scheduleNames }
+}
+
+type TimerConfig struct {
+	// OnBootSec also fires the timer this long after boot, e.g. "15min".
+	OnBootSec string `json:"on_boot_sec"`
+	// RandomizedDelaySec delays each timer run by a random time up to this
+	// (default 30min, "0" to disable).
+	RandomizedDelaySec string `json:"randomized_delay_sec"`
+}
+
+// triggers reports whether the service runs at login and from the timer.
+// Configs without RunOn use the timer if they have a calendar schedule.
+func (c Config) triggers() (login, timer bool) {
+	switch c.RunOn {
+	case runOnTimer:
+		return false, true
+	case runOnBoth:
+		return true, true
+	case "":
+		if c.Schedule != "" && c.Schedule != scheduleBoot {
+			return false, true
+		}
+	}
+	return true, false
+}
+
+// runOnName returns the run_on choice the triggers amount to.
+func (c Config) runOnName() string {
+	switch login, timer := c.triggers(); {
+	case login && timer:
+		return runOnBoth
+	case timer:
+		return runOnTimer
+	}
+	return runOnLogin
+}
+
+// calendar returns the timer's OnCalendar expression, if any.
+// A timer with only OnBootSec has none; one with neither runs daily.
+func (c Config) calendar() string {
+	schedule := c.Schedule
+	if schedule == scheduleBoot {
+		schedule = ""
+	}
+	if schedule == "" && c.Timer.OnBootSec == "" {
+		return defaultSchedule
+	}
+	return schedule
+}
+
+// scheduleDescription describes when the timer fires, for setup's summary.
+func (c Config) scheduleDescription() string {
+	var parts []string
+	if calendar := c.calendar(); calendar != "" {
+		parts = append(parts, calendar)
+	}
+	if c.Timer.OnBootSec != "" {
+		parts = append(parts, T("schedule.after_boot", c.Timer.OnBootSec))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// triggerFlags are setup's command line settings for when to run; empty
+// fields weren't given.
+type triggerFlags struct {
+	runOn, schedule, onBootSec, randomizedDelay string
+}
+
+func (t triggerFlags) given() bool {
+	return t != triggerFlags{}
+}
+
+func (t triggerFlags) validate() error {
+	if t.runOn != "" && !slices.Contains(runOnNames, t.runOn) {
+		return fmt.Errorf("invalid --run-on %q, expected one of: %s", t.runOn, strings.Join(runOnNames, ", "))
+	}
+	return nil
+}
+
+// apply sets the given fields on c. A schedule without --run-on means the
+// timer alone, as it did before --run-on existed.
+func (t triggerFlags) apply(c *Config) {
+	if t.schedule != "" {
+		c.Schedule = t.schedule
+		if t.runOn == "" {
+			c.RunOn = runOnTimer
+			if t.schedule == scheduleBoot {
+				c.RunOn = runOnLogin
+			}
+		}
+	}
+	if t.runOn != "" {
+		c.RunOn = t.runOn
+	}
+	if t.onBootSec != "" {
+		c.Timer.OnBootSec = t.onBootSec
+	}
+	if t.randomizedDelay != "" {
+		c.Timer.RandomizedDelaySec = t.randomizedDelay
+	}
+}
+
+// askTriggers copies the existing trigger settings into config and, unless
+// they were given on the command line, asks when to run.
+func (app *App) askTriggers(reader *bufio.Reader, config *Config, flags triggerFlags) error {
+	if existing, err := app.existingConfig(); err == nil {
+		config.RunOn, config.Schedule, config.Timer = existing.RunOn, existing.Schedule, existing.Timer
+	}
+	if flags.given() {
+		flags.apply(config)
+		return nil
+	}
+
+	runOn, err := app.askChoice(reader, T("setup.run_on"), runOnNames, config.runOnName())
+	if err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	config.RunOn = runOn
+	if runOn == runOnLogin {
+		return nil
+	}
+
+	schedule := config.calendar()
+	if schedule == "" {
+		schedule = defaultSchedule
+	}
+	if config.Schedule, err = app.askString(reader, T("setup.schedule"), schedule); err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	return nil
+}
+
+// installSchedule enables the service at login and writes and enables the
+// timer (and its catch-up run) of the binary at targetPath as config asks,
+// disabling whichever is unused.
+func (app *App) installSchedule(targetPath string, config Config) error {
+	login, timer := config.triggers()
+	timerFile := filepath.Join(app.systemdUnitDir, timerName)
+
+	if timer {
+		if err := os.WriteFile(timerFile, []byte(timerContent(config)), 0644); err != nil {
+			return fmt.Errorf("failed to write systemd timer file: %w", err)
+		}
+	} else if _, err := os.Stat(timerFile); err == nil {
+		app.runSystemctl(app.systemctl("disable", "--now", timerName))
+		if err := os.Remove(timerFile); err != nil {
+			return fmt.Errorf("failed to remove systemd timer file: %w", err)
+		}
+	}
+
+	catchUpFile := filepath.Join(app.systemdUnitDir, catchUpServiceName)
+	catchUp := app.catchUpContent(targetPath, config)
+	if catchUp != "" {
+		if err := os.WriteFile(catchUpFile, []byte(catchUp), 0644); err != nil {
+			return fmt.Errorf("failed to write systemd catch-up service file: %w", err)
+		}
+	} else if _, err := os.Stat(catchUpFile); err == nil {
+		app.runSystemctl(app.systemctl("disable", catchUpServiceName))
+		if err := os.Remove(catchUpFile); err != nil {
+			return fmt.Errorf("failed to remove systemd catch-up service file: %w", err)
+		}
+	}
+
+	commands := [][]string{app.systemctl("daemon-reload")}
+	if login {
+		commands = append(commands, app.systemctl("enable", serviceName))
+	} else {
+		commands = append(commands, app.systemctl("disable", serviceName))
+	}
+	if timer {
+		commands = append(commands, app.systemctl("enable", "--now", timerName))
+	}
+	if catchUp != "" {
+		commands = append(commands, app.systemctl("enable", catchUpServiceName))
+	}
+	app.runSystemctl(commands...)
+	return nil
+}
+
+// runSystemctl runs each command in turn; failures are only warnings since a
+// unit that couldn't be enabled can still be run by hand.
+func (app *App) runSystemctl(commands ...[]string) {
+	for _, cmd := range commands {
+		if err := exec.Command(cmd[0], cmd[1:]...).Run(); err != nil {
+			log.Printf("Warning: Failed to run %v: %v", cmd, err)
+		}
+	}
+}
+
+// catchUpContent returns the unit that catches up at boot (login, for the
+// user service) with runs the timer missed while the machine was off, or ""
+// if there's none to catch up with. Running at login already covers them.
+// It's not a oneshot, so its random delay doesn't hold up the boot.
+func (app *App) catchUpContent(targetPath string, config Config) string {
+	login, timer := config.triggers()
+	if login || !timer || config.calendar() == "" {
+		return ""
+	}
+
+	if app.system {
+		return fmt.Sprintf(`[Unit]
+Description=Saafsafai Missed Run Catch-up
+After=local-fs.target
+
+[Service]
+Type=exec
+ExecStart=%s --system --catch-up
+SuccessExitStatus=3
+%s
+[Install]
+WantedBy=multi-user.target
+`, targetPath, app.sandbox(config))
+	}
+	return fmt.Sprintf(`[Unit]
+Description=Saafsafai Missed Run Catch-up
+After=default.target
+
+[Service]
+Type=exec
+ExecStart=%s --catch-up
+Environment=HOME=%s
+SuccessExitStatus=3
+%s
+[Install]
+WantedBy=default.target
+`, targetPath, app.homeDir, app.sandbox(config))
+}
+
+func timerContent(config Config) string {
+	var timer strings.Builder
+	// Runs missed while the machine was off are caught up by the login run,
+	// or the catch-up service, rather than Persistent=, which would run
+	// every cleaner again however recently it ran
+	if calendar := config.calendar(); calendar != "" {
+		fmt.Fprintf(&timer, "OnCalendar=%s\n", calendar)
+	}
+	if config.Timer.OnBootSec != "" {
+		fmt.Fprintf(&timer, "OnBootSec=%s\n", config.Timer.OnBootSec)
+	}
+	delay := config.Timer.RandomizedDelaySec
+	if delay == "" {
+		delay = defaultRandomizedDelay
+	}
+	fmt.Fprintf(&timer, "RandomizedDelaySec=%s\n", delay)
+
+	return fmt.Sprintf(`[Unit]
+Description=Saafsafai Cleanup Timer
+
+[Timer]
+%s
+[Install]
+WantedBy=timers.target
+`, timer.String())
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// sizePattern matches the sizes parseSize accepts, e.g. "5GB" or "500 MiB";
+// the empty string means the default.
+const sizePattern = `^$|^ *[0-9]*\.?[0-9]+ *([KkMmGgTt]([Ii]?[Bb])?|[Bb])? *$`
+
+// configDescriptions describes each config key, by its dotted path, for the
+// JSON Schema.
+var configDescriptions = map[string]string{
+	"version":                          "Config schema version, written by saafsafai; older configs are migrated when loaded",
+	"clean_downloads":                  "Organize the Downloads folder into categories and delete temporary files",
+	"browser_history":                  "Record where Downloads files came from, from the Firefox and Chrome download history (needs sqlite3), in the audit log",
+	"downloads_dir":                    "Folder the Downloads cleaner organizes, relative to the home directory (or --root); empty for ~/Downloads",
+	"rename_documents":                 "Rename generically named PDFs such as \"document(3).pdf\" after the date and title on their first page when filing them",
+	"category_index":                   "Keep an index of the files moved into each category folder, with their original names, download dates and origins, in it: csv, json (JSON Lines) or empty for none",
+	"categories":                       "Category folders for extensions, e.g. {\".ics\": \"Calendar\"}, overriding the built-in categories or adding new ones",
+	"category_names":                   "Folder names for categories, e.g. {\"Documents\": \"Dokumente\"}; renamed folders are moved, or merged into the new one, on the next run",
+	"delete_after_days":                "Days after which Downloads files of an extension, e.g. {\".iso\": 30}, are removed, whether filed into their category yet or not",
+	"no_extension":                     "What to do with Downloads files without an extension: sniff (the default) files them by their content, leave leaves them, review moves them into Review, others files them into Others; scripts and programs stay put unless others",
+	"compound_extensions":              "Extensions of more than one part, e.g. [\".tar.lzo\"], matched whole by categories and rules on top of the built-in ones (.tar.gz, .tar.xz, .user.js…) and those rules name",
+	"strict":                           "Only touch Downloads files a categories, delete_after_days or download_actions rule names, listing the rest but leaving them in place",
+	"download_actions":                 "Scripts, by file extension (e.g. \".pdf\"), run with the path of each such Downloads file instead of moving it into a category",
+	"delete_node_modules":              "Remove node_modules directories untouched for 30 days",
+	"clean_wine_prefixes":              "Remove Wine prefixes unused for 90 days",
+	"clean_old_appimages":              "Remove older versions of the same AppImage, keeping the newest",
+	"clean_package_cache":              "Clean the apt/dnf/pacman package cache (requires root)",
+	"remove_orphan_packages":           "Remove packages installed as dependencies that are no longer needed (requires root)",
+	"remove_old_kernels":               "Remove installed kernels beyond the newest two (requires root)",
+	"clean_user_homes":                 "System config only: run each user's cleanup for every home under /home",
+	"clean_mail_attachments":           "Remove Thunderbird and Evolution attachments opened into the temp directory, and Evolution's cached message parts, once old",
+	"mail":                             "Mail attachment cleanup settings",
+	"mail.max_age_days":                "Remove attachment files untouched for this many days (0 for the default)",
+	"clean_sync_conflicts":             "Gather Syncthing, Dropbox and Nextcloud conflict copies anywhere in the home directory",
+	"sync_conflicts":                   "Sync conflict copy settings",
+	"sync_conflicts.max_age_days":      "Only gather conflict copies older than this many days (0 for the default)",
+	"sync_conflicts.action":            "review lists them in the report; quarantine moves them into the quarantine",
+	"clean_backup_files":               "Remove old editor backups (file~, .bak, .orig, .rej) and swap files no editor has open",
+	"backup_files":                     "Backup file settings",
+	"backup_files.max_age_days":        "Only remove backup files older than this many days (0 for the default)",
+	"backup_files.roots":               "Directories to look in, relative to the home directory; empty looks everywhere in it",
+	"clean_metadata_files":             "Remove .DS_Store, ._* AppleDouble, Thumbs.db and desktop.ini files",
+	"metadata_files":                   "Metadata file settings",
+	"metadata_files.roots":             "Directories to look in, relative to the home directory; empty looks everywhere in it",
+	"clean_git_repos":                  "Expire old reflog entries and run git gc --auto in git repositories, never touching their working trees",
+	"git_repos":                        "Git repository settings",
+	"git_repos.roots":                  "Directories to look in, relative to the home directory; empty looks everywhere in it",
+	"git_repos.gc_after_days":          "Tidy a repository once this many days have passed since the last time (0 for the default)",
+	"git_repos.large_ratio":            "Report repositories whose .git is more than this many times the size of their files (0 for the default)",
+	"git_repos.stale_months":           "Report clones, worktrees and branches with no commits or changes in this many months (0 for the default)",
+	"git_repos.archive_dir":            "Where stale clones are archived when you agree to, relative to the home directory",
+	"find_duplicate_photos":            "Look for duplicate photos in Downloads and Pictures, including resized or re-encoded copies; removal always asks first",
+	"photos":                           "Duplicate photo settings",
+	"photos.max_distance":              "How many of the 64 bits of two photos' perceptual hashes may differ for them to count as the same (0 for the default)",
+	"clean_font_caches":                "Clear fontconfig and icon theme caches once old, oversized or out of date, and rebuild them with fc-cache and gtk-update-icon-cache",
+	"remote_config":                    "A centrally managed config, fetched at the start of every run, that this config's own keys override",
+	"remote_config.url":                "HTTPS URL of the config, revalidated with its ETag",
+	"remote_config.git":                "Git repository holding the config, instead of url",
+	"remote_config.ref":                "Branch or tag of the repository (empty for its default branch)",
+	"remote_config.path":               "Path of the config in the repository (empty for saafsafai.json)",
+	"font_cache":                       "Font and icon cache settings",
+	"font_cache.max_age_days":          "Rebuild caches older than this many days (0 for the default)",
+	"font_cache.budget":                "Rebuild the fontconfig cache once it's bigger than this, e.g. \"50MB\"; empty for the default",
+	"clean_docker":                     "Remove dangling Docker images and unused unnamed volumes",
+	"docker":                           "Docker cleanup settings",
+	"docker.volume_max_age_days":       "Only remove unused unnamed volumes older than this many days (0 for the default)",
+	"docker.image_max_age_days":        "Also remove unused tagged images older than this many days; 0 removes dangling images only",
+	"docker.keep_images":               "Repository patterns, e.g. \"postgres\" or \"ghcr.io/me/*\", whose images are never removed",
+	"docker.keep_labels":               "Label keys that protect any image or volume carrying them",
+	"docker.builder_cache_budget":      "Prune the build cache down to this size, e.g. \"10GB\"; empty leaves it alone",
+	"clean_vm_images":                  "Report unused libvirt, VirtualBox, VMware and Vagrant images, removing them once confirmed",
+	"vm":                               "VM image cleanup settings",
+	"vm.vagrant_box_max_age_days":      "Report Vagrant boxes unused for this many days (0 for the default)",
+	"clean_kube_clusters":              "Delete stale stopped kind/k3d clusters and minikube profiles, and their unused node images",
+	"kube":                             "Local Kubernetes cleanup settings",
+	"kube.cluster_max_age_days":        "Age in days after which a local cluster counts as stale (0 for the default)",
+	"removable":                        "Profiles of removable disks and cards, cleaned whenever they're mounted",
+	"removable.*.name":                 "The profile's name in the report",
+	"removable.*.label":                "The media's file system label, as lsblk -o NAME,LABEL,UUID shows it",
+	"removable.*.uuid":                 "The media's file system UUID, as lsblk -o NAME,LABEL,UUID shows it",
+	"removable.*.organize":             "Folders whose files are sorted into dated folders",
+	"removable.*.organize.*.dir":       "The folder to sort, relative to the top of the media, e.g. \"DCIM\"",
+	"removable.*.organize.*.to":        "The folder to sort into, relative to the top of the media (default dir)",
+	"removable.*.organize.*.layout":    "Go time layout of the dated folders' names, by modification time (default \"2006-01\")",
+	"removable.*.prune":                "Folders whose old files are removed",
+	"removable.*.prune.*.dir":          "The folder to prune, relative to the top of the media",
+	"removable.*.prune.*.pattern":      "Glob of the names of the files to remove (default all)",
+	"removable.*.prune.*.max_age_days": "Remove files this many days old (0 for the default, 90)",
+	"removable.*.prune.*.keep_newest":  "Always keep this many of the newest files",
+	"post_clean_hooks":                 "Trim file systems after runs that free space on them, and run the post_clean hooks",
+	"post_clean":                       "Post-clean hook settings",
+	"post_clean.min_freed":             "Space a run has to free on a file system for the hooks to run on it, e.g. \"5GB\"; empty for 1GB",
+	"post_clean.fstrim":                "Run fstrim, so SSDs and thin-provisioned virtual disks get the freed blocks back (needs root)",
+	"post_clean.btrfs_balance_usage":   "Balance btrfs chunks used less than this percent (0 for none; needs root)",
+	"post_clean.btrfs_scrub_days":      "Start a btrfs scrub when the last one saafsafai started is this many days old (0 for none; needs root)",
+	"post_clean.hooks":                 "Shell commands run for each file system, with SAAFSAFAI_MOUNT_POINT, SAAFSAFAI_FS_TYPE, SAAFSAFAI_DEVICE and SAAFSAFAI_FREED_BYTES set",
+	"notify":                           "Notification settings",
+	"notify.mode":                      "When to send the report: never, always, or errors_only when a cleaner hit failures",
+	"notify.desktop":                   "Send notifications with notify-send",
+	"notify.email":                     "Send notifications by email over SMTP",
+	"notify.email.smtp_host":           "SMTP server host",
+	"notify.email.smtp_port":           "SMTP server port; 465 uses implicit TLS, others STARTTLS",
+	"notify.email.username":            "SMTP user name",
+	"notify.email.password":            "SMTP password; better kept in password_file, as a config holding it is written readable by its owner alone",
+	"notify.email.password_file":       "File holding the SMTP password, which only its owner may read; the SAAFSAFAI_SMTP_PASSWORD environment variable overrides both",
+	"notify.email.from":                "Sender address",
+	"notify.email.to":                  "Recipient addresses",
+	"run_on":                           "Run at login (boot for the system service), on the timer's schedule, or both",
+	"schedule":                         "systemd calendar expression for the timer, e.g. \"daily\" or \"Mon *-*-* 03:00\"",
+	"timer":                            "systemd timer settings",
+	"timer.on_boot_sec":                "Also fire the timer this long after boot, e.g. \"15min\"",
+	"timer.randomized_delay_sec":       "Delay each timer run by a random time up to this; \"0\" disables it",
+	"quarantine":                       "Quarantine settings",
+	"quarantine.enabled":               "Move deleted files into the quarantine so saafsafai restore can bring them back",
+	"quarantine.max_age_days":          "Delete quarantined items for good after this many days (0 for the default)",
+	"quarantine.budget":                "Cap the quarantine's size, e.g. \"5GB\"; past it the oldest items are deleted early",
+	"trash":                            "The desktop trash, as an alternative to the quarantine",
+	"trash.enabled":                    "Move deleted items into the trash of their own disk (the home trash, or a drive's .Trash-$UID) instead of deleting them",
+	"trash.root":                       "The home trash, relative to the home directory if not absolute (default ~/.local/share/Trash)",
+	"snapshot":                         "Snapshots of the btrfs subvolumes and ZFS datasets runs clean in, taken before they start",
+	"snapshot.enabled":                 "Snapshot before each run, so removed files can be copied back out; freed space only comes back once the snapshot is deleted",
+	"snapshot.command":                 "Shell command that takes the snapshots instead, for every file system; its last line of output names the snapshot",
+	"snapshot.keep":                    "How many of its snapshots of each subvolume or dataset saafsafai keeps (0 for the default, 3)",
+	"disk_guard":                       "Checks of the disk big copies are written to",
+	"disk_guard.enabled":               "Refuse archives of stale clones, and moves into the quarantine from another file system, that would fill the disk past max_used_percent",
+	"disk_guard.max_used_percent":      "How full the disk may be after the copy, in percent (0 for the default, 95)",
+	"disk_guard.check_health":          "Also refuse them when smartctl says the disk is failing (needs root)",
+	"retention":                        "How long logs and history are kept",
+	"retention.log_days":               "Keep the daily logs this many days (0 for the default)",
+	"retention.history_days":           "Keep run history entries this many days (0 for the default)",
+	"summary":                          "How the run summary, also sent in notifications and healthcheck pings, lists items",
+	"summary.max_items":                "Items named per list, or per group of the lists grouped by Downloads category or kind of file (0 for the default, 10); the run's report lists them all",
+	"log_names":                        "How the logs are named: daily (the default), one a day that each run replaces, or run, one per run named after its time and ID",
+	"analytics":                        "Local-only tracking of folder sizes over time, for saafsafai stats trends and the dashboard; nothing leaves the machine",
+	"analytics.enabled":                "Measure the tracked folders after runs and record their sizes in the history",
+	"analytics.dirs":                   "Folders to track, relative to the home directory if not absolute; empty tracks each top-level folder of the home directory (/home, /opt, /srv, /usr and /var for the system service)",
+	"analytics.interval_days":          "Days between measurements, as each walks the folders in full (0 for the default, 1)",
+	"level":                            "How eagerly runs without --level clean: light, normal or aggressive",
+	"limits":                           "Disk limits by cleaner name, or default for every other cleaner, e.g. for homes on NFS",
+	"limits.*.max_workers":             "Files read at once, e.g. photos hashed (0 for the fastest saafsafai bench measured, else as many as CPUs up to 4, 1 on network file systems)",
+	"limits.*.max_open_files":          "Most files open at once (0 for no cap, 1 on network file systems)",
+	"limits.*.io_throttle_mbps":        "Most megabytes a second read from files (0 for no throttle)",
+	"age_by":                           "What file ages are counted from, by cleaner name, or default for every other cleaner: mtime (last changed), atime (last read or changed) or birthtime (created)",
+	"max_scan_depth":                   "Directories deeper than this below where a scan starts aren't looked in (0 for 64)",
+	"max_entries_per_dir":              "Only this many entries of a directory are looked at by scans (0 for 100000)",
+	"idle_minutes":                     "Defer unattended runs until the system has been idle this long; 0 runs right away",
+	"healthcheck_url":                  "URL pinged at the start and end of every run, healthchecks.io style",
+}
+
+func defineConfigCommand(fs *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		if len(args) != 1 || args[0] != "schema" {
+			return fmt.Errorf("expected: config schema")
+		}
+		data, err := json.MarshalIndent(configSchema(), "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal schema: %w", err)
+		}
+		os.Stdout.Write(append(data, '\n'))
+		return nil
+	}
+}
+
+// configSchema returns a JSON Schema of the config file, with the defaults
+// setup writes, for editors and external validation.
+func configSchema() map[string]any {
+	config := defaultConfig()
+	config.Version = configVersion
+	schema := objectSchema(reflect.ValueOf(config), "")
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "saafsafai config"
+	schema["description"] = "Configuration of saafsafai, ~/.config/saafsafai.json or /etc/saafsafai/saafsafai.json"
+	return schema
+}
+
+// objectSchema describes the struct v, whose fields' values are the
+// defaults. Other keys are flagged, as they're most likely typos.
+func objectSchema(v reflect.Value, prefix string) map[string]any {
+	properties := map[string]any{}
+	t := v.Type()
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		properties[name] = valueSchema(v.Field(i), prefix+name)
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+func valueSchema(v reflect.Value, path string) map[string]any {
+	var schema map[string]any
+	switch v.Kind() {
+	case reflect.Struct:
+		schema = objectSchema(v, path+".")
+	case reflect.Bool:
+		schema = map[string]any{"type": "boolean", "default": v.Bool()}
+	case reflect.Int:
+		schema = map[string]any{"type": "integer", "minimum": 0, "default": v.Int()}
+	case reflect.Float64:
+		schema = map[string]any{"type": "number", "minimum": 0, "default": v.Float()}
+	case reflect.String:
+		schema = map[string]any{"type": "string", "default": v.String()}
+	case reflect.Map:
+		values := map[string]any{"type": "string", "minLength": 1}
+		switch elem := v.Type().Elem(); elem.Kind() {
+		case reflect.Int:
+			values = map[string]any{"type": "integer", "minimum": 1}
+		case reflect.Struct:
+			values = objectSchema(reflect.New(elem).Elem(), path+".*.")
+		}
+		schema = map[string]any{"type": "object", "additionalProperties": values, "default": map[string]any{}}
+	case reflect.Slice:
+		items := map[string]any{"type": "string"}
+		if elem := v.Type().Elem(); elem.Kind() == reflect.Struct {
+			items = objectSchema(reflect.New(elem).Elem(), path+".*.")
+		}
+		schema = map[string]any{"type": "array", "items": items, "default": []string{}}
+	}
+	if description, ok := configDescriptions[path]; ok {
+		schema["description"] = description
+	}
+
+	switch path {
+	case "version":
+		schema["minimum"], schema["maximum"] = 1, configVersion
+	case "run_on":
+		schema["enum"] = append([]string{""}, runOnNames...)
+	case "level":
+		schema["enum"] = append([]string{""}, levelNames()...)
+	case "limits":
+		schema["propertyNames"] = map[string]any{"enum": append([]string{defaultLimitsKey}, cleanerNames()...)}
+	case "age_by":
+		schema["propertyNames"] = map[string]any{"enum": append([]string{defaultLimitsKey}, ageByCleaners...)}
+		schema["additionalProperties"] = map[string]any{"type": "string", "enum": ageByNames}
+	case "notify.mode":
+		schema["enum"] = []string{"", notifyNever, notifyAlways, notifyErrorsOnly}
+	case "log_names":
+		schema["enum"] = append([]string{""}, logNameFormats...)
+	case "no_extension":
+		schema["enum"] = append([]string{""}, noExtensionModes...)
+	case "category_index":
+		schema["enum"] = append([]string{""}, categoryIndexFormats...)
+	case "sync_conflicts.action":
+		schema["enum"] = []string{"", conflictActionReview, conflictActionQuarantine}
+	case "notify.email.smtp_port":
+		schema["maximum"] = 65535
+	case "post_clean.btrfs_balance_usage", "disk_guard.max_used_percent":
+		schema["maximum"] = 100
+	case "quarantine.budget", "docker.builder_cache_budget", "font_cache.budget", "post_clean.min_freed":
+		schema["pattern"] = sizePattern
+	case "healthcheck_url":
+		schema["pattern"] = "^$|^https?://"
+	}
+	return schema
+}
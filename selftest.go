@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fixtureOutcome is what a run should leave of a selftest fixture.
+type fixtureOutcome int
+
+const (
+	fixtureRemoved fixtureOutcome = iota
+	fixtureKept
+	// fixtureMoved is moved to the fixture's dest; undo moves it back
+	fixtureMoved
+	// fixtureListed is kept and named in the fixture's list of the summary
+	fixtureListed
+)
+
+// fixture is a file or folder selftest creates in its fixture home, with
+// what the cleaner should do with it.
+type fixture struct {
+	cleaner string
+	// path is relative to the fixture home
+	path    string
+	ageDays int
+	dir     bool
+	data    string
+	want    fixtureOutcome
+	// dest is where a moved fixture ends up, relative to the fixture home
+	dest string
+	// list is the summary key a listed fixture is named in
+	list string
+}
+
+// appleDoubleData starts like a real AppleDouble file.
+const appleDoubleData = "\x00\x05\x16\x07\x00\x02\x00\x00"
+
+// selftestFixtures are the fixtures for the cleaners of home directories,
+// each going by what the README says the cleaner does, a fresh or
+// look-alike fixture beside each one it should clean.
+func selftestFixtures(config Config) []fixture {
+	filed := func(name string) string {
+		category := categoryFor(config, fileExt(config, name))
+		return filepath.Join("Downloads", categoryFolder(config.CategoryNames, category), name)
+	}
+	return []fixture{
+		{cleaner: "downloads", path: "Downloads/report.pdf", ageDays: 2, want: fixtureMoved, dest: filed("report.pdf")},
+		{cleaner: "downloads", path: "Downloads/holiday.jpg", ageDays: 2, want: fixtureMoved, dest: filed("holiday.jpg")},
+		{cleaner: "downloads", path: "Downloads/notes.unknownext", ageDays: 2, want: fixtureMoved, dest: filed("notes.unknownext")},
+		{cleaner: "downloads", path: "Downloads/movie.mkv.part", ageDays: 2, want: fixtureRemoved},
+		{cleaner: "downloads", path: "Downloads/setup.exe.crdownload", ageDays: 2, want: fixtureRemoved},
+		{cleaner: "downloads", path: "Downloads/install", ageDays: 2, data: "#!/bin/sh\necho installing\n", want: fixtureListed, list: "left_files"},
+
+		{cleaner: "node_modules", path: "projects/old-app/node_modules", ageDays: 90, dir: true, want: fixtureRemoved},
+		{cleaner: "node_modules", path: "projects/new-app/node_modules", ageDays: 1, dir: true, want: fixtureKept},
+		{cleaner: "node_modules", path: "projects/new-app/package.json", ageDays: 90, data: "{}\n", want: fixtureKept},
+
+		{cleaner: "appimages", path: "Applications/Tool-1.0-x86_64.AppImage", ageDays: 20, want: fixtureRemoved},
+		{cleaner: "appimages", path: "Applications/Tool-2.0-x86_64.AppImage", ageDays: 1, want: fixtureKept},
+		{cleaner: "appimages", path: "Applications/Other-1.0-x86_64.AppImage", ageDays: 20, want: fixtureKept},
+
+		{cleaner: "sync_conflicts", path: "Sync/plan.sync-conflict-20240101-120000-ABCDEFG.txt", ageDays: 90, want: fixtureListed, list: "sync_conflicts"},
+		{cleaner: "sync_conflicts", path: "Sync/plan (conflicted copy 2099-01-01).txt", ageDays: 1, want: fixtureKept},
+
+		{cleaner: "backup_files", path: "Documents/essay.txt~", ageDays: 90, want: fixtureRemoved},
+		{cleaner: "backup_files", path: "Documents/config.yaml.orig", ageDays: 90, want: fixtureRemoved},
+		{cleaner: "backup_files", path: "Documents/draft.txt.bak", ageDays: 1, want: fixtureKept},
+		{cleaner: "backup_files", path: "Documents/essay.txt", ageDays: 90, want: fixtureKept},
+
+		{cleaner: "metadata_files", path: "Pictures/.DS_Store", ageDays: 1, want: fixtureRemoved},
+		{cleaner: "metadata_files", path: "Pictures/Thumbs.db", ageDays: 1, want: fixtureRemoved},
+		{cleaner: "metadata_files", path: "Pictures/._holiday.jpg", ageDays: 1, data: appleDoubleData, want: fixtureRemoved},
+		{cleaner: "metadata_files", path: "Pictures/._notes", ageDays: 1, data: "not AppleDouble\n", want: fixtureKept},
+	}
+}
+
+func defineSelftestCommand(fs *flag.FlagSet) func(args []string) error {
+	keep := fs.Bool("keep", false, "keep the fixture home afterwards, to look at what the run left")
+
+	return func(args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+		}
+		return selftest(*keep)
+	}
+}
+
+// selftest builds a fixture home in a temporary directory and runs this
+// saafsafai binary on it, as a dry run and then for real, checking that each
+// cleaner of home directories does what it's documented to on this machine
+// and that undo puts back what the run moved. Nothing outside the fixture
+// home is touched.
+func selftest(keep bool) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+	home, err := os.MkdirTemp("", "saafsafai-selftest-")
+	if err != nil {
+		return fmt.Errorf("failed to create fixture home: %w", err)
+	}
+	if keep {
+		defer fmt.Println(T("selftest.kept_home", home))
+	} else {
+		defer os.RemoveAll(home)
+	}
+
+	config := defaultConfig()
+	config.Level = defaultLevel
+	fixtures, err := writeSelftestHome(home, config)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(T("selftest.heading", home))
+	fmt.Println()
+	failed := 0
+	check := func(text, problem string) {
+		if problem == "" {
+			fmt.Println("✅ " + text)
+			return
+		}
+		fmt.Println("❌ " + text)
+		fmt.Println("   → " + problem)
+		failed++
+	}
+
+	// A dry run changes nothing
+	if _, err := selftestRun(exe, home, "--dry-run"); err != nil {
+		check(T("selftest.dry_run"), err.Error())
+	} else {
+		var changed []string
+		for _, f := range fixtures {
+			if _, err := os.Lstat(filepath.Join(home, f.path)); err != nil {
+				changed = append(changed, f.path)
+			}
+		}
+		problem := ""
+		if len(changed) > 0 {
+			problem = T("selftest.changed", strings.Join(changed, ", "))
+		}
+		check(T("selftest.dry_run"), problem)
+	}
+
+	summary, err := selftestRun(exe, home)
+	if err != nil {
+		check(T("selftest.run"), err.Error())
+		return fmt.Errorf("%d checks failed", failed)
+	}
+	var moved []fixture
+	for _, f := range fixtures {
+		text, problem := checkFixture(home, f, summary)
+		check(text, problem)
+		if f.want == fixtureMoved && problem == "" {
+			moved = append(moved, f)
+		}
+	}
+
+	// Undo puts back what the run moved
+	if runID, _ := summary["run_id"].(string); runID != "" && len(moved) > 0 {
+		problem := ""
+		if _, err := selftestCommand(exe, home, "undo", runID); err != nil {
+			problem = err.Error()
+		} else {
+			var missing []string
+			for _, f := range moved {
+				if _, err := os.Lstat(filepath.Join(home, f.path)); err != nil {
+					missing = append(missing, f.path)
+				}
+			}
+			if len(missing) > 0 {
+				problem = T("selftest.not_back", strings.Join(missing, ", "))
+			}
+		}
+		check(T("selftest.undo", len(moved)), problem)
+	}
+
+	fmt.Println()
+	if failed > 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+	fmt.Println(T("selftest.passed", len(fixtures)))
+	return nil
+}
+
+// writeSelftestHome lays out the fixture home in home: config with the
+// cleaners of the fixtures turned on, and the fixtures themselves.
+func writeSelftestHome(home string, config Config) ([]fixture, error) {
+	fixtures := selftestFixtures(config)
+	for _, f := range fixtures {
+		for _, c := range cleaners {
+			if c.name == f.cleaner {
+				*c.options[0].field(&config) = true
+			}
+		}
+	}
+	if err := writeConfig(filepath.Join(home, ".config", configFileName), config); err != nil {
+		return nil, fmt.Errorf("failed to write fixture config: %w", err)
+	}
+	if err := writeFixtures(home, fixtures); err != nil {
+		return nil, fmt.Errorf("failed to create fixtures: %w", err)
+	}
+	return fixtures, nil
+}
+
+// writeFixtures creates the fixtures in home, dated ageDays ago. Folders get
+// a file inside, so there's something to remove.
+func writeFixtures(home string, fixtures []fixture) error {
+	for _, f := range fixtures {
+		path := filepath.Join(home, f.path)
+		file := path
+		if f.dir {
+			file = filepath.Join(path, "index.js")
+		}
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return err
+		}
+		data := f.data
+		if data == "" {
+			data = "saafsafai selftest fixture\n"
+		}
+		if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+			return err
+		}
+		when := time.Now().AddDate(0, 0, -f.ageDays)
+		if err := os.Chtimes(file, when, when); err != nil {
+			return err
+		}
+		if err := os.Chtimes(path, when, when); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkFixture returns what's expected of f and, if the run left something
+// else, what's wrong.
+func checkFixture(home string, f fixture, summary map[string]any) (string, string) {
+	_, err := os.Lstat(filepath.Join(home, f.path))
+	there := err == nil
+	switch f.want {
+	case fixtureRemoved:
+		if there {
+			return T("selftest.removes", f.cleaner, f.path), T("selftest.still_there")
+		}
+		return T("selftest.removes", f.cleaner, f.path), ""
+	case fixtureMoved:
+		text := T("selftest.moves", f.cleaner, f.path, f.dest)
+		if _, err := os.Lstat(filepath.Join(home, f.dest)); err != nil {
+			return text, T("selftest.not_at", f.dest)
+		}
+		return text, ""
+	case fixtureListed:
+		text := T("selftest.lists", f.cleaner, f.path)
+		if !there {
+			return text, T("selftest.gone")
+		}
+		items, _ := summary[f.list].([]any)
+		for _, item := range items {
+			if s, ok := item.(string); ok && strings.Contains(s, filepath.Base(f.path)) {
+				return text, ""
+			}
+		}
+		return text, T("selftest.not_listed")
+	}
+	if !there {
+		return T("selftest.keeps", f.cleaner, f.path), T("selftest.gone")
+	}
+	return T("selftest.keeps", f.cleaner, f.path), ""
+}
+
+// selftestRun runs saafsafai on the fixture home, returning the summary.
+// Finding nothing to do isn't a failure here; the checks tell.
+func selftestRun(exe, home string, args ...string) (map[string]any, error) {
+	output, err := selftestCommand(exe, home, append([]string{"--home", home, "--no-systemd", "--json"}, args...)...)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == exitNothingToDo {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var summary map[string]any
+	if err := json.Unmarshal(output, &summary); err != nil {
+		return nil, fmt.Errorf("failed to parse the run's summary: %w", err)
+	}
+	return summary, nil
+}
+
+// selftestCommand runs saafsafai with home as the home directory, so its
+// state and config are the fixture home's too.
+func selftestCommand(exe, home string, args ...string) ([]byte, error) {
+	cmd := exec.Command(exe, args...)
+	cmd.Env = append(os.Environ(), "HOME="+home)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == exitNothingToDo {
+			return output, err
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return output, fmt.Errorf("%w: %s", err, msg)
+		}
+	}
+	return output, err
+}
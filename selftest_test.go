@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fixtureHome lays out the selftest's fixture home in a temporary directory,
+// with config changed by configure, if it's set, and returns an App for it
+// and the fixtures.
+func fixtureHome(t *testing.T, configure func(*Config)) (*App, []fixture) {
+	t.Helper()
+	home := t.TempDir()
+	config := defaultConfig()
+	config.Level = defaultLevel
+	if configure != nil {
+		configure(&config)
+	}
+	fixtures, err := writeSelftestHome(home, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := newHomeApp(home)
+	app.noSystemd = true
+	return app, fixtures
+}
+
+// runFixtures runs the cleaners on the fixture home, as selftest runs the
+// binary, returning the summary as the JSON output has it.
+func runFixtures(t *testing.T, app *App) map[string]any {
+	t.Helper()
+	if err := app.run(); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	app.summary.markTruncated()
+	app.summary.markGroups()
+	data, err := json.Marshal(app.summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary map[string]any
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatal(err)
+	}
+	return summary
+}
+
+// fixturesOf returns the fixtures of cleaner.
+func fixturesOf(fixtures []fixture, cleaner string) []fixture {
+	var of []fixture
+	for _, f := range fixtures {
+		if f.cleaner == cleaner {
+			of = append(of, f)
+		}
+	}
+	return of
+}
+
+func TestFixtures(t *testing.T) {
+	app, fixtures := fixtureHome(t, nil)
+	summary := runFixtures(t, app)
+
+	for _, cleaner := range []string{"downloads", "node_modules", "appimages", "sync_conflicts", "backup_files", "metadata_files"} {
+		t.Run(cleaner, func(t *testing.T) {
+			of := fixturesOf(fixtures, cleaner)
+			if len(of) == 0 {
+				t.Fatalf("no fixtures for %s", cleaner)
+			}
+			for _, f := range of {
+				if text, problem := checkFixture(app.homeDir, f, summary); problem != "" {
+					t.Errorf("%s: %s", text, problem)
+				}
+			}
+		})
+	}
+}
+
+func TestFixturesDryRun(t *testing.T) {
+	app, fixtures := fixtureHome(t, nil)
+	app.dryRun = true
+	app.summary.DryRun = true
+	runFixtures(t, app)
+
+	for _, f := range fixtures {
+		if _, err := os.Lstat(filepath.Join(app.homeDir, f.path)); err != nil {
+			t.Errorf("a dry run changed %s: %v", f.path, err)
+		}
+	}
+}
+
+// TestFixturesQuarantine removes the fixtures into the quarantine and
+// restores each, checking it's back as it was.
+func TestFixturesQuarantine(t *testing.T) {
+	app, fixtures := fixtureHome(t, func(config *Config) { config.Quarantine.Enabled = true })
+	var removed []fixture
+	for _, f := range fixtures {
+		if f.want == fixtureRemoved {
+			removed = append(removed, f)
+		}
+	}
+	data := make(map[string][]byte)
+	for _, f := range removed {
+		file := filepath.Join(app.homeDir, f.path)
+		if f.dir {
+			file = filepath.Join(file, "index.js")
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[file] = content
+	}
+
+	runFixtures(t, app)
+	for _, f := range removed {
+		if _, err := os.Lstat(filepath.Join(app.homeDir, f.path)); !os.IsNotExist(err) {
+			t.Errorf("%s wasn't removed: %v", f.path, err)
+		}
+	}
+	records, err := app.loadQuarantine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(removed) {
+		t.Errorf("%d items quarantined, want %d", len(records), len(removed))
+	}
+
+	for _, f := range removed {
+		if err := app.restore(filepath.Join(app.homeDir, f.path), "", false); err != nil {
+			t.Errorf("restore %s: %v", f.path, err)
+		}
+	}
+	for file, want := range data {
+		got, err := os.ReadFile(file)
+		if err != nil {
+			t.Errorf("%s wasn't restored: %v", file, err)
+			continue
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s was restored as %q, want %q", file, got, want)
+		}
+	}
+	if records, err := app.loadQuarantine(); err != nil || len(records) != 0 {
+		t.Errorf("the quarantine still has %d items after restoring them all (%v)", len(records), err)
+	}
+}
+
+// TestFixturesJournalRecovery leaves a journal behind as a run that died
+// halfway through would, and checks the next run finishes it: removals
+// under way are completed unless the file has been replaced since, and
+// moves that didn't happen are left to this run.
+func TestFixturesJournalRecovery(t *testing.T) {
+	app, fixtures := fixtureHome(t, nil)
+	var removed, moved []fixture
+	for _, f := range fixtures {
+		switch f.want {
+		case fixtureRemoved:
+			removed = append(removed, f)
+		case fixtureMoved:
+			moved = append(moved, f)
+		}
+	}
+	if len(removed) < 2 || len(moved) == 0 {
+		t.Fatal("too few fixtures to recover")
+	}
+
+	if err := app.openJournal(); err != nil {
+		t.Fatal(err)
+	}
+	var entries []journalEntry
+	for _, f := range removed {
+		entry := journalEntry{Op: opRemove, Path: filepath.Join(app.homeDir, f.path)}
+		entry.identify()
+		entries = append(entries, entry)
+	}
+	move := moved[0]
+	entries = append(entries, journalEntry{Op: opMove, Path: filepath.Join(app.homeDir, move.path), Dest: filepath.Join(app.homeDir, move.dest)})
+	for i, entry := range entries {
+		entry.ID = i + 1
+		if err := app.journal.write(entry, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The run dies here, leaving the journal
+	app.journal.file.Close()
+	app.journal = nil
+
+	// Replaced since, by a new file of the same name
+	replaced := filepath.Join(app.homeDir, removed[0].path)
+	if err := os.RemoveAll(replaced); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(replaced, []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.recoverJournal(); err != nil {
+		t.Fatalf("recoverJournal: %v", err)
+	}
+	if _, err := os.Lstat(replaced); err != nil {
+		t.Errorf("recovery removed %s, which had been replaced: %v", removed[0].path, err)
+	}
+	for _, f := range removed[1:] {
+		if _, err := os.Lstat(filepath.Join(app.homeDir, f.path)); !os.IsNotExist(err) {
+			t.Errorf("recovery didn't finish removing %s: %v", f.path, err)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(app.homeDir, move.path)); err != nil {
+		t.Errorf("recovery touched %s, which never moved: %v", move.path, err)
+	}
+	if app.summary.Recovered.Count != len(removed)-1 {
+		t.Errorf("%d actions recovered, want %d", app.summary.Recovered.Count, len(removed)-1)
+	}
+	if _, err := os.Lstat(app.journalPath()); !os.IsNotExist(err) {
+		t.Errorf("the journal is still there: %v", err)
+	}
+}
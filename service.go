@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// unitFile is a systemd unit as setup would write it today. An empty content
+// means the unit shouldn't be installed.
+type unitFile struct {
+	name    string
+	content string
+}
+
+func defineServiceCommand(fs *flag.FlagSet) func(args []string) error {
+	system := fs.Bool("system", false, "install or repair the system-wide (root) service")
+
+	return func(args []string) error {
+		if len(args) != 1 || (args[0] != "install" && args[0] != "repair") {
+			return fmt.Errorf("expected: service install|repair")
+		}
+
+		app, err := commandApp(*system)
+		if err != nil {
+			return err
+		}
+		if app.system && os.Geteuid() != 0 {
+			return fmt.Errorf("the system service must be set up as root (try: sudo %s service %s --system)", binaryName, args[0])
+		}
+		if args[0] == "install" {
+			return app.installService()
+		}
+		return app.repairService()
+	}
+}
+
+// installService installs the units for the existing config, as setup does
+// once it has asked its questions, for packages that ship the binary and
+// config but leave enabling the service to the user.
+func (app *App) installService() error {
+	config, err := app.loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := app.installSystemdService(config); err != nil {
+		return fmt.Errorf("failed to install systemd service: %w", err)
+	}
+	fmt.Println()
+	for _, line := range app.setupDone(config) {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// binaryPath returns the saafsafai the units run. That's the binary running,
+// if a package installed it somewhere on PATH (/usr/bin, or the link a
+// Homebrew or Nix profile keeps across upgrades), which then is packaged and
+// never copied; otherwise it's the copy setup keeps in app.binDir.
+func (app *App) binaryPath() (path string, packaged bool, err error) {
+	targetPath := filepath.Join(app.binDir, binaryName)
+	execPath, err := os.Executable()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get executable path: %w", err)
+	}
+	if execPath == targetPath {
+		return targetPath, false, nil
+	}
+	running, err := os.Stat(execPath)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get executable path: %w", err)
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if !filepath.IsAbs(dir) || filepath.Clean(dir) == app.binDir {
+			continue
+		}
+		path := filepath.Join(dir, binaryName)
+		if info, err := os.Stat(path); err == nil && os.SameFile(info, running) {
+			if _, err := os.Stat(targetPath); err == nil {
+				log.Printf("Warning: %s is an older copy setup installed; the service runs %s, so it can be removed", targetPath, path)
+			}
+			return path, true, nil
+		}
+	}
+	return targetPath, false, nil
+}
+
+// repairService reports how the installed binary and units differ from what
+// setup would install for the current config, then reinstalls them.
+func (app *App) repairService() error {
+	config, err := app.loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	drift, err := app.serviceDrift(config)
+	if err != nil {
+		return err
+	}
+	if len(drift) == 0 {
+		fmt.Println(T("service.no_drift"))
+	} else {
+		fmt.Println(T("service.drift"))
+		for _, line := range drift {
+			fmt.Println("   " + line)
+		}
+	}
+	fmt.Println()
+
+	if err := app.installSystemdService(config); err != nil {
+		return fmt.Errorf("failed to reinstall systemd service: %w", err)
+	}
+	fmt.Println(T("service.repaired"))
+	return nil
+}
+
+// expectedUnits returns the units setup generates for config.
+func (app *App) expectedUnits(targetPath string, config Config) []unitFile {
+	units := []unitFile{
+		{serviceName, app.serviceContent(targetPath, config)},
+		{timerName, ""},
+		{catchUpServiceName, app.catchUpContent(targetPath, config)},
+	}
+	if _, timer := config.triggers(); timer {
+		units[1].content = timerContent(config)
+	}
+	return units
+}
+
+// serviceDrift describes every difference between the installed binary and
+// units and the expected ones.
+func (app *App) serviceDrift(config Config) ([]string, error) {
+	var drift []string
+
+	targetPath, packaged, err := app.binaryPath()
+	if err != nil {
+		return nil, err
+	}
+	execPath, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get executable path: %w", err)
+	}
+	if !packaged && execPath != targetPath {
+		same, err := sameContent(execPath, targetPath)
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			drift = append(drift, T("service.binary_differs", targetPath))
+		}
+	}
+
+	for _, unit := range app.expectedUnits(targetPath, config) {
+		path := filepath.Join(app.systemdUnitDir, unit.name)
+		installed, err := os.ReadFile(path)
+		switch {
+		case os.IsNotExist(err):
+			if unit.content != "" {
+				drift = append(drift, T("service.unit_missing", path))
+			}
+		case err != nil:
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		case unit.content == "":
+			drift = append(drift, T("service.unit_unused", path))
+		case string(installed) != unit.content:
+			drift = append(drift, T("service.unit_changed", path))
+			for _, line := range lineDiff(string(installed), unit.content) {
+				drift = append(drift, "    "+line)
+			}
+		}
+	}
+	return drift, nil
+}
+
+// sameContent reports whether two files are identical; a missing file never
+// matches.
+func sameContent(a, b string) (bool, error) {
+	dataA, err := os.ReadFile(a)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", a, err)
+	}
+	dataB, err := os.ReadFile(b)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", b, err)
+	}
+	return bytes.Equal(dataA, dataB), nil
+}
+
+// lineDiff lists the lines only in before ("-") and only in after ("+").
+// Units are short and their lines unique, so this is enough to show what
+// changed.
+func lineDiff(before, after string) []string {
+	beforeLines := strings.Split(strings.TrimSpace(before), "\n")
+	afterLines := strings.Split(strings.TrimSpace(after), "\n")
+
+	var diff []string
+	for _, line := range beforeLines {
+		if !slices.Contains(afterLines, line) {
+			diff = append(diff, "- "+line)
+		}
+	}
+	for _, line := range afterLines {
+		if !slices.Contains(beforeLines, line) {
+			diff = append(diff, "+ "+line)
+		}
+	}
+	return diff
+}
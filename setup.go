@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// defaultConfig is the config setup starts from: every cleaner off and the
+// tunables spelled out, so the written file documents them.
+func defaultConfig() Config {
+	return Config{
+		Categories:      map[string]string{},
+		CategoryNames:   map[string]string{},
+		DeleteAfterDays: map[string]int{},
+		DownloadActions: map[string]string{},
+
+		Docker: DockerConfig{VolumeMaxAgeDays: dockerVolumeMaxAge},
+		VM:     VMConfig{VagrantBoxMaxAgeDays: vagrantBoxMaxAge},
+		Photos: PhotosConfig{MaxDistance: photoMaxDistance},
+
+		FontCache: FontCacheConfig{MaxAgeDays: fontCacheMaxAge, Budget: fontCacheBudget},
+
+		SyncConflicts: SyncConflictConfig{MaxAgeDays: syncConflictMaxAge, Action: conflictActionReview},
+		BackupFiles:   BackupFilesConfig{MaxAgeDays: backupFileMaxAge, Roots: []string{}},
+		MetadataFiles: MetadataFilesConfig{Roots: []string{}},
+		GitRepos:      GitReposConfig{Roots: []string{}, GCAfterDays: gitGCMaxAge, LargeRatio: gitLargeRatio, StaleMonths: gitStaleMonths, ArchiveDir: defaultArchiveDir},
+		Mail:          MailConfig{MaxAgeDays: mailAttachmentMaxAge},
+		Kube:          KubeConfig{ClusterMaxAgeDays: kubeClusterMaxAge},
+		Removable:     []RemovableProfile{},
+		PostClean:     PostCleanConfig{MinFreed: postCleanMinFreed, Fstrim: true, Hooks: []string{}},
+		Notify:        NotifyConfig{Mode: notifyNever},
+		Summary:       SummaryConfig{MaxItems: summaryMaxItems},
+		RunOn:         runOnLogin,
+		Level:         defaultLevel,
+		Limits:        map[string]CleanerLimits{},
+		AgeBy:         map[string]string{},
+		Schedule:      defaultSchedule,
+		Timer:         TimerConfig{RandomizedDelaySec: defaultRandomizedDelay},
+
+		Quarantine: QuarantineConfig{MaxAgeDays: quarantineMaxAge, Budget: quarantineBudget},
+		Retention:  RetentionConfig{LogDays: logRetentionDays, HistoryDays: historyRetentionDays},
+		DiskGuard:  DiskGuardConfig{MaxUsedPercent: diskGuardMaxUsedPercent},
+		Snapshot:   SnapshotConfig{Keep: snapshotKeep},
+		Analytics:  AnalyticsConfig{Dirs: []string{}, IntervalDays: analyticsIntervalDays},
+	}
+}
+
+// setupOptions lists every cleaner option, in registry order.
+func setupOptions() []cleanerOption {
+	var options []cleanerOption
+	for _, c := range cleaners {
+		options = append(options, c.options...)
+	}
+	return options
+}
+
+// askConfig asks about the options of this mode that are available on this
+// machine; the rest keep their defaults.
+func (app *App) askConfig(reader *bufio.Reader, mode int) (Config, error) {
+	config := defaultConfig()
+	for _, o := range setupOptions() {
+		if o.modes&mode == 0 || (o.available != nil && !o.available()) {
+			continue
+		}
+		question := o.question
+		if mode == systemMode && o.systemQuestion != "" {
+			question = o.systemQuestion
+		}
+		answer, err := app.askYesNo(reader, T(question))
+		if err != nil {
+			return config, fmt.Errorf("failed to read input: %w", err)
+		}
+		*o.field(&config) = answer
+	}
+	return config, nil
+}
+
+// defineSetupCommand is "saafsafai setup". Without --yes it runs the
+// interactive wizard, in desktop dialogs with --gui; with --yes it starts from the existing config (or the
+// default), applies the flags that were given and installs the service
+// without asking anything.
+func defineSetupCommand(fs *flag.FlagSet) func(args []string) error {
+	yes := fs.Bool("yes", false, "don't ask; configure from the flags alone")
+	system := fs.Bool("system", false, "configure the system-wide (root) service")
+	gui := fs.Bool("gui", false, "ask in desktop dialogs (zenity) rather than on the terminal")
+	var triggers triggerFlags
+	fs.StringVar(&triggers.runOn, "run-on", "", "run at login (boot, for --system), from a timer, or both")
+	fs.StringVar(&triggers.schedule, "schedule", "", "the timer's schedule: hourly, daily, weekly, monthly or a systemd calendar expression")
+	fs.StringVar(&triggers.onBootSec, "on-boot-sec", "", "also run from the timer this long after boot, e.g. 15min")
+	fs.StringVar(&triggers.randomizedDelay, "randomized-delay-sec", "", "delay timer runs by up to this random time (default 30min, 0 to disable)")
+	values := make(map[string]*bool)
+	for _, o := range setupOptions() {
+		values[o.flag] = fs.Bool(o.flag, false, o.usage)
+	}
+
+	return func(args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+		}
+
+		if err := triggers.validate(); err != nil {
+			return err
+		}
+
+		app, err := commandApp(*system)
+		if err != nil {
+			return err
+		}
+
+		set := make(map[string]bool)
+		fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+		if *gui && (*yes || *system) {
+			return fmt.Errorf("--gui sets up the user service by asking; it can't go with --yes or --system")
+		}
+		if !*yes {
+			for _, o := range setupOptions() {
+				if set[o.flag] {
+					return fmt.Errorf("--%s needs --yes; without it setup asks instead", o.flag)
+				}
+			}
+			if *gui {
+				return app.runGUISetup(triggers)
+			}
+			return app.runSetup(triggers)
+		}
+
+		if app.system && os.Geteuid() != 0 {
+			return fmt.Errorf("system setup must be run as root (try: sudo %s setup --system --yes)", binaryName)
+		}
+
+		config, err := app.existingConfig()
+		if err != nil {
+			return err
+		}
+		for _, o := range setupOptions() {
+			if set[o.flag] {
+				*o.field(&config) = *values[o.flag]
+			}
+		}
+		triggers.apply(&config)
+		return app.provision(config)
+	}
+}
+
+// existingConfig returns the config at configPath, or the default if none
+// has been written yet.
+func (app *App) existingConfig() (Config, error) {
+	if _, err := os.Stat(app.configPath); os.IsNotExist(err) {
+		return defaultConfig(), nil
+	}
+	return app.loadConfig()
+}
+
+// provision saves config and installs the binary and units for it.
+func (app *App) provision(config Config) error {
+	if err := app.saveConfig(config); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	if !app.noSystemd {
+		if err := app.installSystemdService(config); err != nil {
+			return fmt.Errorf("failed to install systemd service: %w", err)
+		}
+	}
+
+	fmt.Println()
+	for _, line := range app.setupDone(config) {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// setupDone returns the lines setup ends with: when the service runs, and
+// where the config and logs are.
+func (app *App) setupDone(config Config) []string {
+	if app.noSystemd {
+		return []string{T("setup.complete_no_systemd"), T("setup.config_saved", app.configPath)}
+	}
+
+	manualRun := binaryName
+	complete := "setup.complete"
+	if app.system {
+		manualRun = "sudo " + binaryName + " --system"
+		complete = "system_setup.complete"
+	}
+
+	var lines []string
+	switch login, timer := config.triggers(); {
+	case login && timer:
+		lines = append(lines, T(complete+"_both", config.scheduleDescription()))
+	case timer:
+		lines = append(lines, T(complete+"_timer", config.scheduleDescription()))
+	default:
+		lines = append(lines, T(complete))
+	}
+	return append(lines,
+		T("setup.config_saved", app.configPath),
+		T("setup.manual_run", manualRun),
+		T("setup.see_logs", app.logDir),
+	)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// errSetupCancelled is returned when a setup dialog is cancelled.
+var errSetupCancelled = errors.New("setup cancelled")
+
+// runGUISetup is the interactive setup in zenity dialogs, for desktop users
+// who didn't start saafsafai from a terminal: the same questions as
+// runSetup, the cleaners as one checklist, and the outcome in a dialog.
+func (app *App) runGUISetup(triggers triggerFlags) error {
+	if !commandExists("zenity") {
+		return fmt.Errorf("setup --gui needs zenity, which is not installed")
+	}
+	err := app.guiSetup(triggers)
+	if err != nil && !errors.Is(err, errSetupCancelled) {
+		zenity("--error", "--title", T("setup.gui_title"), "--text", err.Error())
+	}
+	return err
+}
+
+func (app *App) guiSetup(triggers triggerFlags) error {
+	existing, _ := app.existingConfig()
+	config, err := app.chooseCleaners(existing)
+	if err != nil {
+		return err
+	}
+
+	if !app.noSystemd {
+		config.RunOn, config.Schedule, config.Timer = existing.RunOn, existing.Schedule, existing.Timer
+		if triggers.given() {
+			triggers.apply(&config)
+		} else if err := chooseTriggers(&config); err != nil {
+			return err
+		}
+	}
+	if err := app.provision(config); err != nil {
+		return err
+	}
+	zenity("--info", "--title", T("setup.gui_title"), "--text", strings.Join(app.setupDone(config), "\n"))
+	return nil
+}
+
+// chooseCleaners asks about the user service's options that are available
+// on this machine in one checklist, ticking those existing has on. The rest
+// keep their defaults, as with askConfig.
+func (app *App) chooseCleaners(existing Config) (Config, error) {
+	config := defaultConfig()
+	args := []string{"--list", "--checklist", "--title", T("setup.gui_title"), "--text", T("setup.welcome"),
+		"--column", "", "--column", "flag", "--column", T("setup.gui_cleaner"),
+		"--hide-column", "2", "--print-column", "2", "--separator", "\n",
+		"--width", "720", "--height", "560"}
+	var options []cleanerOption
+	for _, o := range setupOptions() {
+		if o.modes&userMode == 0 || (o.available != nil && !o.available()) {
+			continue
+		}
+		options = append(options, o)
+		args = append(args, strings.ToUpper(fmt.Sprint(*o.field(&existing))), o.flag, T(o.question))
+	}
+
+	output, err := zenity(args...)
+	if err != nil {
+		return config, err
+	}
+	chosen := make(map[string]bool)
+	for _, flag := range strings.Split(output, "\n") {
+		chosen[flag] = true
+	}
+	for _, o := range options {
+		*o.field(&config) = chosen[o.flag]
+	}
+	return config, nil
+}
+
+// chooseTriggers asks when to run, and the timer's schedule if there's one,
+// as askTriggers does.
+func chooseTriggers(config *Config) error {
+	args := []string{"--list", "--radiolist", "--title", T("setup.gui_title"), "--text", T("setup.run_on"),
+		"--column", "", "--column", "run_on"}
+	current := config.runOnName()
+	for _, name := range runOnNames {
+		args = append(args, strings.ToUpper(fmt.Sprint(name == current)), name)
+	}
+	runOn, err := zenity(args...)
+	if err != nil {
+		return err
+	}
+	if runOn == "" {
+		runOn = current
+	}
+	config.RunOn = runOn
+	if runOn == runOnLogin {
+		return nil
+	}
+
+	schedule := config.calendar()
+	if schedule == "" {
+		schedule = defaultSchedule
+	}
+	if schedule, err = zenity("--entry", "--title", T("setup.gui_title"), "--text", T("setup.schedule"), "--entry-text", schedule); err != nil {
+		return err
+	}
+	if schedule != "" {
+		config.Schedule = schedule
+	}
+	return nil
+}
+
+// zenity shows a dialog, returning what it printed. Closing or cancelling it
+// returns errSetupCancelled.
+func zenity(args ...string) (string, error) {
+	output, err := exec.Command("zenity", args...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return "", errSetupCancelled
+	}
+	if err != nil {
+		return "", fmt.Errorf("zenity failed: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// similarNameSeparators are runs of anything but letters and digits,
+	// which become a single space before the copy markers are looked for.
+	similarNameSeparators = regexp.MustCompile(`[^\pL\pN]+`)
+	// similarNameNoise matches what browsers and people add to a name when
+	// saving another copy: "(1)", "copy", "final", "v2", "draft", or a
+	// trailing counter as in "report_2".
+	similarNameNoise = regexp.MustCompile(`\b(copy( of)?|final|draft|latest|edited|v\d+)\b| \d{1,2}$`)
+)
+
+// similarFile is a file in a cluster of similarly named ones.
+type similarFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func defineSimilarCommand(fs *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("expected: similar")
+		}
+		app, err := commandApp(false)
+		if err != nil {
+			return err
+		}
+		return app.showSimilar()
+	}
+}
+
+// normalizeName reduces a file name to what's left once the copy markers
+// are dropped, so "report(1).pdf", "Report final v2.pdf" and "report.pdf"
+// all become "report.pdf".
+func normalizeName(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	base := strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+	base = similarNameSeparators.ReplaceAllString(base, " ")
+	for {
+		trimmed := strings.TrimSpace(similarNameNoise.ReplaceAllString(base, " "))
+		trimmed = similarNameSeparators.ReplaceAllString(trimmed, " ")
+		if trimmed == base {
+			break
+		}
+		base = trimmed
+	}
+	return base + ext
+}
+
+// similarClusters groups the files in Downloads, including its category
+// folders, by normalized name; only names shared by several files are
+// returned, newest file first.
+func (app *App) similarClusters() map[string][]similarFile {
+	clusters := make(map[string][]similarFile)
+	app.scanTree(app.downloadsDir, nil, func(rel string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		key := normalizeName(d.Name())
+		if strings.TrimSuffix(key, filepath.Ext(key)) == "" {
+			return nil
+		}
+		clusters[key] = append(clusters[key], similarFile{filepath.Join(app.downloadsDir, rel), info.Size(), info.ModTime()})
+		return nil
+	})
+	for key, files := range clusters {
+		if len(files) < 2 {
+			delete(clusters, key)
+			continue
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+	}
+	return clusters
+}
+
+// showSimilar prints the clusters of similarly named files in Downloads, so
+// the copies worth keeping can be picked out.
+func (app *App) showSimilar() error {
+	clusters := app.similarClusters()
+	if len(clusters) == 0 {
+		fmt.Println(T("similar.none"))
+		return nil
+	}
+	for _, key := range sortedKeys(clusters) {
+		files := clusters[key]
+		var total int64
+		for _, f := range files {
+			total += f.size
+		}
+		fmt.Println(T("similar.cluster", key, len(files), formatSize(total)))
+		for _, f := range files {
+			fmt.Printf("   %s  %8s  %s\n", f.modTime.Local().Format("2006-01-02 15:04"), formatSize(f.size), displayName(app.displayPath(f.path)))
+		}
+		fmt.Println()
+	}
+	fmt.Println(T("similar.total", len(clusters)))
+	return nil
+}
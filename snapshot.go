@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+const (
+	// snapshotDirName is the directory in a btrfs subvolume that saafsafai's
+	// snapshots of it go in. Scans leave it out.
+	snapshotDirName = ".saafsafai-snapshots"
+	snapshotPrefix  = "saafsafai-"
+	snapshotKeep    = 3
+	// btrfsSubvolumeInode is the inode number of every btrfs subvolume's
+	// top directory.
+	btrfsSubvolumeInode = 256
+)
+
+type SnapshotConfig struct {
+	// Enabled snapshots the btrfs subvolumes and ZFS datasets the cleaners
+	// work in before each run, so what it removes can still be copied back
+	// out of the snapshot. The space a run frees only comes back once its
+	// snapshot is deleted.
+	Enabled bool `json:"enabled"`
+	// Command, if set, takes the snapshots instead, of every file system the
+	// cleaners work in, with its mount point, type and device and the run ID
+	// in SAAFSAFAI_MOUNT_POINT, SAAFSAFAI_FS_TYPE, SAAFSAFAI_DEVICE and
+	// SAAFSAFAI_RUN_ID. The last line it prints names the snapshot.
+	Command string `json:"command"`
+	// Keep is how many of its snapshots of each subvolume or dataset
+	// saafsafai keeps (default 3), deleting the oldest after taking one.
+	// Those Command takes are left to it.
+	Keep int `json:"keep"`
+}
+
+// runSnapshot is a snapshot taken before a run, as its history records it.
+type runSnapshot struct {
+	Name string `json:"name"`
+	// Root is the directory the snapshot is of: the subvolume's top, or the
+	// dataset's or file system's mount point
+	Root string `json:"root"`
+	// Path is where the snapshot's copy of Root can be read, if known
+	Path string `json:"path,omitempty"`
+}
+
+// takeSnapshots snapshots the subvolumes and datasets the run's cleaners
+// work in, once each, recording the snapshots for the report and history.
+func (app *App) takeSnapshots(config Config) {
+	if app.dryRun {
+		return
+	}
+	cfg := config.Snapshot
+	done := make(map[string]bool)
+	for _, dir := range app.cleanerDirs(config) {
+		mount, ok := mountOf(dir)
+		if !ok {
+			continue
+		}
+		root := mount.mountPoint
+		if mount.fsType == "btrfs" && cfg.Command == "" {
+			if subvolume, err := btrfsSubvolume(dir); err == nil {
+				root = subvolume
+			}
+		}
+		if done[root] || (cfg.Command == "" && mount.fsType != "btrfs" && mount.fsType != "zfs") {
+			continue
+		}
+		done[root] = true
+
+		var snap runSnapshot
+		var err error
+		switch {
+		case cfg.Command != "":
+			snap, err = app.commandSnapshot(cfg.Command, mount)
+		case mount.fsType == "btrfs":
+			snap, err = app.btrfsSnapshot(root, cfg.Keep)
+		default:
+			snap, err = app.zfsSnapshot(mount, cfg.Keep)
+		}
+		if err != nil {
+			app.skipItem("Failed to snapshot", root, err)
+			continue
+		}
+		app.snapshots = append(app.snapshots, snap)
+		app.addItem(&app.summary.Snapshots, fmt.Sprintf("%s of %s", snap.Name, app.displayPath(snap.Root)))
+	}
+}
+
+// btrfsSubvolume returns the top directory of the btrfs subvolume dir is
+// in.
+func btrfsSubvolume(dir string) (string, error) {
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return "", err
+		}
+		if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Ino == btrfsSubvolumeInode {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no btrfs subvolume holds %s", dir)
+		}
+		dir = parent
+	}
+}
+
+// btrfsSnapshot takes a read-only snapshot of the subvolume at root, in its
+// snapshotDirName, and deletes those past the newest keep.
+func (app *App) btrfsSnapshot(root string, keep int) (runSnapshot, error) {
+	dir := filepath.Join(root, snapshotDirName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return runSnapshot{}, err
+	}
+	snap := runSnapshot{Name: snapshotPrefix + app.summary.RunID, Root: root}
+	snap.Path = filepath.Join(dir, snap.Name)
+	if err := snapshotCommand("btrfs", "subvolume", "snapshot", "-r", root, snap.Path); err != nil {
+		return runSnapshot{}, err
+	}
+
+	names, _ := filepath.Glob(filepath.Join(dir, snapshotPrefix+"*"))
+	// Run IDs start with the time, so they sort oldest first
+	slices.Sort(names)
+	for _, old := range names[:max(len(names)-snapshotsKept(keep), 0)] {
+		if err := snapshotCommand("btrfs", "subvolume", "delete", old); err != nil {
+			log.Printf("Warning: failed to delete the old snapshot %s: %v", old, err)
+		}
+	}
+	return snap, nil
+}
+
+// zfsSnapshot snapshots the dataset mounted at mount, and destroys its
+// snapshots past the newest keep.
+func (app *App) zfsSnapshot(mount filesystem, keep int) (runSnapshot, error) {
+	dataset := mount.source
+	snap := runSnapshot{Name: dataset + "@" + snapshotPrefix + app.summary.RunID, Root: mount.mountPoint}
+	if err := snapshotCommand("zfs", "snapshot", snap.Name); err != nil {
+		return runSnapshot{}, err
+	}
+	snap.Path = filepath.Join(mount.mountPoint, ".zfs", "snapshot", snapshotPrefix+app.summary.RunID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), actionTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "zfs", "list", "-H", "-o", "name", "-t", "snapshot", "-s", "creation", "-d", "1", dataset).Output()
+	if err != nil {
+		log.Printf("Warning: failed to list the snapshots of %s: %v", dataset, err)
+		return snap, nil
+	}
+	var names []string
+	for _, name := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if strings.HasPrefix(name, dataset+"@"+snapshotPrefix) {
+			names = append(names, name)
+		}
+	}
+	for _, old := range names[:max(len(names)-snapshotsKept(keep), 0)] {
+		if err := snapshotCommand("zfs", "destroy", old); err != nil {
+			log.Printf("Warning: failed to destroy the old snapshot %s: %v", old, err)
+		}
+	}
+	return snap, nil
+}
+
+// commandSnapshot runs the configured snapshot command for the file system.
+func (app *App) commandSnapshot(command string, mount filesystem) (runSnapshot, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), actionTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"SAAFSAFAI_MOUNT_POINT="+mount.mountPoint,
+		"SAAFSAFAI_FS_TYPE="+mount.fsType,
+		"SAAFSAFAI_DEVICE="+mount.source,
+		"SAAFSAFAI_RUN_ID="+app.summary.RunID,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return runSnapshot{}, fmt.Errorf("%w: %s", err, lastLine(output))
+	}
+	name := lastLine(output)
+	if name == "" {
+		name = snapshotPrefix + app.summary.RunID
+	}
+	return runSnapshot{Name: name, Root: mount.mountPoint}, nil
+}
+
+func snapshotCommand(name string, args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), actionTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w: %s", name, args[0], err, lastLine(output))
+	}
+	return nil
+}
+
+// snapshotsKept returns keep, or the default for 0.
+func snapshotsKept(keep int) int {
+	if keep <= 0 {
+		return snapshotKeep
+	}
+	return keep
+}
+
+// snapshotCopy returns where one of snapshots keeps a copy of path, if any
+// still does.
+func snapshotCopy(snapshots []runSnapshot, path string) string {
+	for _, snap := range snapshots {
+		rel, err := filepath.Rel(snap.Root, path)
+		if snap.Path == "" || err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+		kept := filepath.Join(snap.Path, rel)
+		if _, err := os.Lstat(kept); err == nil {
+			return kept
+		}
+	}
+	return ""
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sourcesTop is how many file types and sites stats sources lists.
+const sourcesTop = 10
+
+// clutterStat counts the Downloads files a run handled from one source, and
+// their size.
+type clutterStat struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// countSource adds a Downloads file the run handled to the counts by
+// extension and, when browser_history knows where it came from, by site.
+func (app *App) countSource(path, ext string, info os.FileInfo) {
+	if ext == "" {
+		ext = "(none)"
+	}
+	addClutter(&app.summary.Extensions, ext, info.Size())
+	if origin, ok := app.origins[path]; ok {
+		if domain := originDomain(origin.URL); domain != "" {
+			addClutter(&app.summary.Domains, domain, info.Size())
+		}
+	}
+}
+
+func addClutter(m *map[string]clutterStat, key string, size int64) {
+	if *m == nil {
+		*m = make(map[string]clutterStat)
+	}
+	stat := (*m)[key]
+	stat.Files++
+	stat.Bytes += size
+	(*m)[key] = stat
+}
+
+// originDomain returns the host a download came from, without "www.".
+func originDomain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// statsSources prints which file types and sites the Downloads clutter came
+// from over the recorded runs (those between from and to, if given), with
+// the last 30 days next to the totals to show where things are heading.
+func (app *App) statsSources(from, to string) error {
+	records, err := app.loadHistory()
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	start, end, err := dateRange(from, to)
+	if err != nil {
+		return err
+	}
+
+	recent := time.Now().AddDate(0, 0, -30)
+	totals := map[string]map[string]clutterStat{"ext": {}, "domain": {}}
+	latest := map[string]map[string]int{"ext": {}, "domain": {}}
+	runs := 0
+	var first, last time.Time
+	for _, record := range records {
+		if record.Time.Before(start) || !record.Time.Before(end) {
+			continue
+		}
+		runs++
+		if first.IsZero() {
+			first = record.Time
+		}
+		last = record.Time
+		for kind, counts := range map[string]map[string]clutterStat{"ext": record.Extensions, "domain": record.Domains} {
+			for key, stat := range counts {
+				total := totals[kind][key]
+				total.Files += stat.Files
+				total.Bytes += stat.Bytes
+				totals[kind][key] = total
+				if record.Time.After(recent) {
+					latest[kind][key] += stat.Files
+				}
+			}
+		}
+	}
+	if len(totals["ext"]) == 0 {
+		fmt.Println(T("stats.sources_none"))
+		return nil
+	}
+
+	fmt.Println(T("stats.sources", runs, first.Local().Format("2006-01-02"), last.Local().Format("2006-01-02")))
+	fmt.Println()
+	fmt.Println(T("stats.by_extension"))
+	printSources(totals["ext"], latest["ext"])
+	fmt.Println()
+	if len(totals["domain"]) == 0 {
+		fmt.Println(T("stats.no_domains"))
+		return nil
+	}
+	fmt.Println(T("stats.by_domain"))
+	printSources(totals["domain"], latest["domain"])
+	return nil
+}
+
+// printSources lists the sources with the most files, biggest first on a
+// tie.
+func printSources(totals map[string]clutterStat, recent map[string]int) {
+	keys := sortedKeys(totals)
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, b := totals[keys[i]], totals[keys[j]]
+		if a.Files != b.Files {
+			return a.Files > b.Files
+		}
+		return a.Bytes > b.Bytes
+	})
+	var names []string
+	for _, key := range keys[:min(len(keys), sourcesTop)] {
+		names = append(names, displayName(key))
+	}
+	width := columnWidth(24, names)
+	for i, key := range keys[:len(names)] {
+		stat := totals[key]
+		fmt.Printf("   %s %6d %10s  %s\n", padRight(names[i], width), stat.Files, formatSize(stat.Bytes), T("stats.recent_files", recent[key]))
+	}
+	if len(keys) > sourcesTop {
+		fmt.Println("   " + T("stats.more_sources", len(keys)-sourcesTop))
+	}
+}
+
+// dateRange returns the times between the start of from and the end of to,
+// either of which may be empty for no bound.
+func dateRange(from, to string) (time.Time, time.Time, error) {
+	start, end := time.Time{}, time.Now().AddDate(1, 0, 0)
+	if from != "" {
+		day, err := time.ParseInLocation("2006-01-02", from, time.Local)
+		if err != nil {
+			return start, end, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", from)
+		}
+		start = day
+	}
+	if to != "" {
+		day, err := time.ParseInLocation("2006-01-02", to, time.Local)
+		if err != nil {
+			return start, end, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", to)
+		}
+		end = day.AddDate(0, 0, 1)
+	}
+	return start, end, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// sshFailed is the exit code of ssh itself failing, e.g. to connect.
+const sshFailed = 255
+
+// unameArch maps Go's architecture names to those uname -m prints.
+var unameArch = map[string][]string{
+	"amd64": {"x86_64", "amd64"},
+	"arm64": {"aarch64", "arm64"},
+	"386":   {"i386", "i486", "i586", "i686"},
+	"arm":   {"armv6l", "armv7l"},
+}
+
+func defineRemoteCommand(fs *flag.FlagSet) func(args []string) error {
+	planOnly := fs.Bool("plan-only", false, "only report what the remote cleanup would do (a dry run)")
+	system := fs.Bool("system", false, "run the remote system-wide cleanup (log in as root)")
+	level := fs.String("level", "", "clean at this `level` (default: the remote config's level)")
+	copyBinary := fs.Bool("copy", false, "run a copy of this binary even if the host has saafsafai installed")
+
+	return func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("expected: remote [user@]host")
+		}
+		if strings.HasPrefix(args[0], "-") {
+			return fmt.Errorf("invalid host %q", args[0])
+		}
+		if *level != "" {
+			if _, err := findLevel(*level); err != nil {
+				return err
+			}
+		}
+
+		runArgs := []string{"--json"}
+		if *planOnly {
+			runArgs = append(runArgs, "--dry-run")
+		}
+		if *system {
+			runArgs = append(runArgs, "--system")
+		}
+		if *level != "" {
+			runArgs = append(runArgs, "--level", *level)
+		}
+		return runRemote(args[0], runArgs, *copyBinary)
+	}
+}
+
+// runRemote runs saafsafai with args on host over SSH, with its JSON summary
+// streamed to standard output and its log to standard error. It uses the
+// saafsafai installed there, or else copies this binary over for the run
+// and removes it afterwards. The remote run's exit code becomes ours.
+func runRemote(host string, args []string, copyBinary bool) error {
+	probe, err := exec.Command("ssh", "--", host, `uname -sm; command -v saafsafai || { test -x "$HOME/.local/bin/saafsafai" && echo "$HOME/.local/bin/saafsafai"; }`).Output()
+	if err != nil && len(probe) == 0 {
+		return fmt.Errorf("failed to reach %s: %w", host, err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(probe)), "\n")
+	installed := ""
+	if len(lines) > 1 {
+		installed = strings.TrimSpace(lines[1])
+	}
+
+	remoteArgs := strings.Join(args, " ")
+	cmd := exec.Command("ssh", "--", host, shellQuote(installed)+" "+remoteArgs)
+	if installed == "" || copyBinary {
+		if err := checkRemotePlatform(host, lines[0]); err != nil {
+			return err
+		}
+		self, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to get executable path: %w", err)
+		}
+		binary, err := os.Open(self)
+		if err != nil {
+			return fmt.Errorf("failed to read executable: %w", err)
+		}
+		defer binary.Close()
+		// One connection copies the binary in on standard input, runs it
+		// and removes it
+		script := `f=$(mktemp) && cat >"$f" && chmod 700 "$f" || exit 1; "$f" ` + remoteArgs + `; s=$?; rm -f "$f"; exit $s`
+		cmd = exec.Command("ssh", "--", host, script)
+		cmd.Stdin = binary
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() != sshFailed {
+		return exitError(exitErr.ExitCode())
+	}
+	if err != nil {
+		return fmt.Errorf("ssh to %s failed: %w", host, err)
+	}
+	return nil
+}
+
+// checkRemotePlatform makes sure this binary runs on a host whose uname -sm
+// printed uname.
+func checkRemotePlatform(host, uname string) error {
+	system, machine, _ := strings.Cut(strings.TrimSpace(uname), " ")
+	if !strings.EqualFold(system, runtime.GOOS) {
+		return fmt.Errorf("%s runs %s, this saafsafai is built for %s; install saafsafai there", host, system, runtime.GOOS)
+	}
+	for _, arch := range unameArch[runtime.GOARCH] {
+		if machine == arch {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is %s, this saafsafai is built for %s; install saafsafai there", host, machine, runtime.GOARCH)
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
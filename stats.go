@@ -0,0 +1,188 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+)
+
+func defineStatsCommand(fs *flag.FlagSet) func(args []string) error {
+	from := fs.String("from", "", "compare from the last run on `YYYY-MM-DD` (default: the second to last run); for sources and trends, use runs from then")
+	to := fs.String("to", "", "compare to the last run on `YYYY-MM-DD` (default: the last run); for sources and trends, use runs up to then")
+	system := fs.Bool("system", false, "use the system service's history")
+
+	return func(args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("missing subcommand, expected: diff, sources or trends")
+		}
+		// Flags may also follow the subcommand, e.g. "stats diff --from ..."
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		app, err := commandApp(*system)
+		if err != nil {
+			return err
+		}
+
+		switch args[0] {
+		case "diff":
+			return app.statsDiff(*from, *to)
+		case "sources":
+			return app.statsSources(*from, *to)
+		case "trends":
+			return app.statsTrends(*from, *to)
+		default:
+			return fmt.Errorf("unknown stats subcommand %q, expected: diff, sources or trends", args[0])
+		}
+	}
+}
+
+func (app *App) statsDiff(from, to string) error {
+	records, err := app.loadHistory()
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("no runs recorded yet")
+	}
+
+	// Each end is the given day's last run, or else one of the last two runs
+	var prev, cur runRecord
+	switch {
+	case from != "":
+		if prev, err = lastRunOn(records, from); err != nil {
+			return err
+		}
+	case len(records) >= 2:
+		prev = records[len(records)-2]
+	default:
+		return fmt.Errorf("need at least two recorded runs to compare, found %d", len(records))
+	}
+	if to != "" {
+		if cur, err = lastRunOn(records, to); err != nil {
+			return err
+		}
+	} else {
+		cur = records[len(records)-1]
+	}
+
+	fmt.Println(T("stats.comparing", prev.Time.Format("2006-01-02 15:04"), cur.Time.Format("2006-01-02 15:04")))
+	fmt.Println()
+
+	fmt.Println(T("stats.per_cleaner"))
+	names := unionKeys(prev.Cleaners, cur.Cleaners)
+	width := columnWidth(14, names)
+	for _, name := range names {
+		fmt.Printf("   %s %5d → %d%s\n", padRight(name, width), prev.Cleaners[name], cur.Cleaners[name], change(int64(cur.Cleaners[name]-prev.Cleaners[name]), false))
+	}
+	fmt.Println()
+
+	var newCategories []string
+	for _, category := range sortedKeys(cur.Categories) {
+		if prev.Categories[category] == 0 {
+			newCategories = append(newCategories, T("stats.category_files", category, cur.Categories[category]))
+		}
+	}
+	if len(newCategories) > 0 {
+		fmt.Println(T("stats.new_categories"))
+		for _, c := range newCategories {
+			fmt.Println("   + " + c)
+		}
+		fmt.Println()
+	}
+
+	if len(prev.CacheSizes) > 0 || len(cur.CacheSizes) > 0 {
+		fmt.Println(T("stats.cache_sizes"))
+		dirs := unionKeys(prev.CacheSizes, cur.CacheSizes)
+		width := columnWidth(20, dirs)
+		for _, dir := range dirs {
+			fmt.Printf("   %s %10s → %s%s\n", padRight(dir, width), formatSize(prev.CacheSizes[dir]), formatSize(cur.CacheSizes[dir]), change(cur.CacheSizes[dir]-prev.CacheSizes[dir], true))
+		}
+		fmt.Println()
+	}
+
+	if len(prev.CleanerTimes) > 0 || len(cur.CleanerTimes) > 0 {
+		fmt.Println(T("stats.timings"))
+		names, actions := unionKeys(prev.CleanerTimes, cur.CleanerTimes), unionKeys(prev.ActionTimes, cur.ActionTimes)
+		// The actions are indented under the cleaners, their times lined up
+		width := max(columnWidth(14, names), columnWidth(11, actions)+3)
+		for _, name := range names {
+			fmt.Printf("   %s %8s → %s\n", padRight(name, width), formatMillis(prev.CleanerTimes[name]), formatMillis(cur.CleanerTimes[name]))
+		}
+		for _, name := range actions {
+			fmt.Printf("      %s %8s → %s\n", padRight(name, width-3), formatMillis(prev.ActionTimes[name]), formatMillis(cur.ActionTimes[name]))
+		}
+		fmt.Println()
+	}
+
+	// A cleaner that found things last time and nothing now, while still
+	// enabled, often means a moved directory or a broken path in the config
+	var suspicious []string
+	for _, name := range sortedKeys(cur.Cleaners) {
+		if cur.Cleaners[name] == 0 && prev.Cleaners[name] > 0 {
+			suspicious = append(suspicious, T("stats.found_nothing", name, prev.Cleaners[name]))
+		}
+	}
+	if len(suspicious) > 0 {
+		fmt.Println(T("stats.misconfiguration"))
+		for _, s := range suspicious {
+			fmt.Println("   - " + s)
+		}
+		fmt.Println()
+	}
+
+	if cur.Errors != prev.Errors {
+		fmt.Println(T("stats.errors", prev.Errors, cur.Errors))
+	}
+
+	return nil
+}
+
+func lastRunOn(records []runRecord, date string) (runRecord, error) {
+	day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	if err != nil {
+		return runRecord{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		t := records[i].Time.In(time.Local)
+		if !t.Before(day) && t.Before(day.AddDate(0, 0, 1)) {
+			return records[i], nil
+		}
+	}
+	return runRecord{}, fmt.Errorf("no recorded run on %s", date)
+}
+
+func change(delta int64, isSize bool) string {
+	switch {
+	case delta == 0:
+		return ""
+	case isSize && delta > 0:
+		return " (+" + formatSize(delta) + ")"
+	case isSize:
+		return " (-" + formatSize(-delta) + ")"
+	default:
+		return fmt.Sprintf(" (%+d)", delta)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	union := make(map[string]bool)
+	for k := range a {
+		union[k] = true
+	}
+	for k := range b {
+		union[k] = true
+	}
+	return sortedKeys(union)
+}
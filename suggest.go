@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// suggestMinRuns is how many runs the history needs before suggest has
+	// enough to go on.
+	suggestMinRuns = 5
+	// suggestMinFiles is how many files of an extension it takes to suggest
+	// a rule for it.
+	suggestMinFiles = 3
+	// suggestGoneShare is the share of an extension's filed files the user
+	// must have deleted for suggest to offer deleting them automatically.
+	suggestGoneShare = 0.8
+)
+
+// suggestion is a rule suggest proposes: filing an extension into a
+// category, or deleting it after some days.
+type suggestion struct {
+	ext      string
+	text     string
+	category string // for a categories rule
+	days     int    // for a delete_after_days rule
+}
+
+func defineSuggestCommand(fs *flag.FlagSet) func(args []string) error {
+	others := fs.Bool("others", false, "go through the file types in the Others folder instead, assigning them categories")
+
+	return func(args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("expected: suggest [--others]")
+		}
+		app, err := commandApp(false)
+		if err != nil {
+			return err
+		}
+		if *others {
+			return app.triageOthers()
+		}
+		return app.suggest()
+	}
+}
+
+// guessCategory proposes a category for an extension from its MIME type,
+// or a new category named after it.
+func guessCategory(ext string) string {
+	if category := typeCategory(extType(ext)); category != "" {
+		return category
+	}
+	return strings.ToUpper(strings.TrimPrefix(ext, "."))
+}
+
+// extType returns the MIME type of files with extension ext, without its
+// parameters, going by the last part of a compound one such as .tar.gz.
+func extType(ext string) string {
+	mimeType := mime.TypeByExtension(ext)
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(filepath.Ext(ext))
+	}
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	return mimeType
+}
+
+// typeCategory returns the category for files of a MIME type, if one fits.
+func typeCategory(mimeType string) string {
+	major, minor, _ := strings.Cut(mimeType, "/")
+	switch {
+	case major == "image":
+		return "Images"
+	case major == "video":
+		return "Videos"
+	case major == "audio":
+		return "Audio"
+	case major == "text", minor == "pdf", strings.Contains(minor, "document"), strings.Contains(minor, "msword"):
+		return "Documents"
+	case strings.Contains(minor, "zip"), strings.Contains(minor, "compressed"), strings.Contains(minor, "tar"):
+		return "Archives"
+	case major == "font":
+		return "Fonts"
+	}
+	return ""
+}
+
+// suggestions looks through what runs filed for rules the config lacks:
+// extensions that keep ending up in Others, and extensions whose filed
+// files the user deletes anyway.
+func (app *App) suggestions(config Config) ([]suggestion, error) {
+	records, err := app.fileHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	type filed struct {
+		total, gone int
+		goneAges    []time.Duration
+		category    string
+	}
+	byExt := make(map[string]*filed)
+	for _, record := range records {
+		if record.Action != opMove || record.Cleaner != "downloads" {
+			continue
+		}
+		ext := fileExt(config, record.Dst)
+		if ext == "" {
+			continue
+		}
+		f, ok := byExt[ext]
+		if !ok {
+			f = &filed{}
+			byExt[ext] = f
+		}
+		f.total++
+		f.category = filepath.Base(filepath.Dir(record.Dst))
+		if _, err := os.Lstat(record.Dst); os.IsNotExist(err) {
+			f.gone++
+			f.goneAges = append(f.goneAges, time.Since(record.Time))
+		}
+	}
+
+	var list []suggestion
+	for _, ext := range sortedKeys(byExt) {
+		f := byExt[ext]
+		if f.total < suggestMinFiles {
+			continue
+		}
+		if _, expires := extLookup(config.DeleteAfterDays, ext); expires {
+			continue
+		}
+		if float64(f.gone) >= suggestGoneShare*float64(f.total) {
+			sort.Slice(f.goneAges, func(i, j int) bool { return f.goneAges[i] < f.goneAges[j] })
+			// The median time they were kept at most, rounded up to a week
+			days := int(f.goneAges[len(f.goneAges)/2].Hours()/24/7+1) * 7
+			list = append(list, suggestion{ext: ext, days: days, text: T("suggest.deleted", f.gone, f.total, ext, f.category, days)})
+			continue
+		}
+		if _, configured := extLookup(config.Categories, ext); !configured && categoryFor(config, ext) == otherCategory {
+			category := guessCategory(ext)
+			list = append(list, suggestion{ext: ext, category: category, text: T("suggest.others", f.total, ext, category)})
+		}
+	}
+	return list, nil
+}
+
+// suggest proposes rules from the history of what runs filed and, from a
+// terminal, adds the ones accepted to the config.
+func (app *App) suggest() error {
+	config, err := app.loadConfig()
+	if err != nil {
+		return err
+	}
+	runs, err := app.loadHistory()
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	if len(runs) < suggestMinRuns {
+		fmt.Println(T("suggest.too_few", len(runs), suggestMinRuns))
+		return nil
+	}
+	list, err := app.suggestions(config)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		fmt.Println(T("suggest.none"))
+		return nil
+	}
+
+	if !app.isInteractive() {
+		for _, s := range list {
+			fmt.Println("💡 " + s.text)
+			if s.category != "" {
+				fmt.Printf("   \"categories\": {%q: %q}\n", s.ext, s.category)
+			} else {
+				fmt.Printf("   \"delete_after_days\": {%q: %d}\n", s.ext, s.days)
+			}
+		}
+		fmt.Println()
+		fmt.Println(T("suggest.from_terminal"))
+		return nil
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	accepted := 0
+	for _, s := range list {
+		fmt.Println("💡 " + s.text)
+		ok, err := app.askYesNo(reader, T("suggest.accept"))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if s.category != "" {
+			category, err := app.askString(reader, T("suggest.folder"), categoryFolder(config.CategoryNames, s.category))
+			if err != nil {
+				return err
+			}
+			if !validCategory(category) {
+				fmt.Println(T("suggest.invalid_folder", category))
+				continue
+			}
+			if config.Categories == nil {
+				config.Categories = make(map[string]string)
+			}
+			config.Categories[s.ext] = categoryOfFolder(config, category)
+		} else {
+			answer, err := app.askString(reader, T("suggest.days"), strconv.Itoa(s.days))
+			if err != nil {
+				return err
+			}
+			days, err := strconv.Atoi(answer)
+			if err != nil || days <= 0 {
+				fmt.Println(T("suggest.invalid_days", answer))
+				continue
+			}
+			if config.DeleteAfterDays == nil {
+				config.DeleteAfterDays = make(map[string]int)
+			}
+			config.DeleteAfterDays[s.ext] = days
+		}
+		accepted++
+	}
+
+	if accepted == 0 {
+		return nil
+	}
+	if err := app.saveConfig(config); err != nil {
+		return err
+	}
+	fmt.Println(T("suggest.saved", accepted, app.configPath))
+	return nil
+}
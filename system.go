@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	systemConfigDir = "/etc/saafsafai"
+	systemBinDir    = "/usr/local/bin"
+	systemUnitDir   = "/etc/systemd/system"
+	systemStateDir  = "/var/lib/saafsafai"
+	systemLogDir    = "/var/log/saafsafai"
+	homesRoot       = "/home"
+)
+
+// systemDefaultUserConfig is used by users who haven't run setup themselves.
+var systemDefaultUserConfig = filepath.Join(systemConfigDir, "user-default.json")
+
+// NewSystemApp returns an App that uses the root-owned configuration, log
+// directory and systemd manager instead of the invoking user's.
+func NewSystemApp() *App {
+	return &App{
+		configPath:     filepath.Join(systemConfigDir, configFileName),
+		systemdUnitDir: systemUnitDir,
+		binDir:         systemBinDir,
+		stateDir:       systemStateDir,
+		logDir:         systemLogDir,
+		system:         true,
+		summary:        Summary{},
+	}
+}
+
+func (app *App) runSystemSetup(triggers triggerFlags) error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("system setup must be run as root (try: sudo %s --setup --system)", binaryName)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+
+	fmt.Println(T("system_setup.welcome"))
+	fmt.Println()
+
+	config, err := app.askConfig(reader, systemMode)
+	if err != nil {
+		return err
+	}
+
+	if config.CleanUserHomes {
+		writeDefault, err := app.askYesNo(reader, T("system_setup.default_config"))
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+		if writeDefault {
+			fmt.Println()
+			fmt.Println(T("system_setup.default_heading"))
+			userConfig, err := app.askConfig(reader, userMode)
+			if err != nil {
+				return err
+			}
+			if err := writeConfig(systemDefaultUserConfig, userConfig); err != nil {
+				return fmt.Errorf("failed to save default user config: %w", err)
+			}
+			fmt.Println(T("system_setup.default_saved", systemDefaultUserConfig))
+		}
+	}
+
+	if err := app.askTriggers(reader, &config, triggers); err != nil {
+		return err
+	}
+	return app.provision(config)
+}
+
+func systemServiceContent(targetPath, sandbox string) string {
+	return fmt.Sprintf(`[Unit]
+Description=Saafsafai System Cleanup Service
+After=local-fs.target
+
+[Service]
+Type=oneshot
+ExecStart=%s --system
+SuccessExitStatus=3
+%s
+[Install]
+WantedBy=multi-user.target
+`, targetPath, sandbox)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const trashInfoExt = ".trashinfo"
+
+type TrashConfig struct {
+	// Enabled moves what cleaners delete into the desktop trash, as a file
+	// manager would, rather than deleting it: into Root for what's on the
+	// same disk, and into the trash at the top of any other disk (a USB
+	// drive's .Trash-$UID), so nothing is copied across devices.
+	Enabled bool `json:"enabled"`
+	// Root is the home trash, ~/.local/share/Trash by default; relative to
+	// the home directory if not absolute.
+	Root string `json:"root"`
+}
+
+// trashRoot returns the home trash of the config.
+func (app *App) trashRoot(cfg TrashConfig) string {
+	if cfg.Root == "" {
+		return filepath.Join(app.homeDir, ".local", "share", "Trash")
+	}
+	if !filepath.IsAbs(cfg.Root) {
+		return filepath.Join(app.homeDir, cfg.Root)
+	}
+	return filepath.Clean(cfg.Root)
+}
+
+// trashItem moves path into the trash on its disk, with the .trashinfo the
+// desktop's trash needs to restore it.
+func (app *App) trashItem(path string) error {
+	dev, ok := deviceOf(filepath.Dir(path))
+	if !ok {
+		return fmt.Errorf("failed to find the disk of %s", path)
+	}
+	trash, topdir, err := app.trashFor(path, dev)
+	if err != nil {
+		return err
+	}
+
+	// The path is relative to the disk's top in a disk's own trash
+	original := path
+	if topdir != "" {
+		original, _ = filepath.Rel(topdir, path)
+	}
+	info := "[Trash Info]\nPath=" + (&url.URL{Path: original}).EscapedPath() +
+		"\nDeletionDate=" + time.Now().Format("2006-01-02T15:04:05") + "\n"
+
+	// Writing the .trashinfo exclusively claims its name in files too
+	base := filepath.Base(path)
+	for n := 1; ; n++ {
+		name := base
+		if n > 1 {
+			name = base + "." + strconv.Itoa(n)
+		}
+		infoPath := filepath.Join(trash, "info", name+trashInfoExt)
+		f, err := os.OpenFile(infoPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", infoPath, err)
+		}
+		_, err = f.WriteString(info)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(path, filepath.Join(trash, "files", name))
+		}
+		if err != nil {
+			os.Remove(infoPath)
+			return fmt.Errorf("failed to move %s into the trash: %w", path, err)
+		}
+		return nil
+	}
+}
+
+// trashFor returns the trash for what's on the disk dev, making it if need
+// be: the home trash if it's on that disk, or else the trash at the top of
+// the disk path is on, which it returns too.
+func (app *App) trashFor(path string, dev uint64) (trash, topdir string, err error) {
+	home := app.trashRoot(app.trashConfig)
+	if err := makeTrash(home); err == nil {
+		if d, ok := deviceOf(home); ok && d == dev {
+			return home, "", nil
+		}
+	}
+
+	mount, ok := mountOf(path)
+	if !ok {
+		return "", "", fmt.Errorf("no trash on the disk of %s", path)
+	}
+	topdir = mount.mountPoint
+	for _, trash := range topTrashes(topdir) {
+		if err := makeTrash(trash); err != nil {
+			continue
+		}
+		if d, ok := deviceOf(trash); ok && d == dev {
+			return trash, topdir, nil
+		}
+	}
+	return "", "", fmt.Errorf("no trash on the disk of %s, and %s can't be made", path, filepath.Join(topdir, ".Trash-"+strconv.Itoa(os.Getuid())))
+}
+
+// topTrashes lists the trash directories of the disk mounted at topdir, by
+// the freedesktop.org spec: the user's in an administrator-made .Trash
+// (which has to be sticky, and not a symlink), and .Trash-$UID.
+func topTrashes(topdir string) []string {
+	uid := strconv.Itoa(os.Getuid())
+	var trashes []string
+	if info, err := os.Lstat(filepath.Join(topdir, ".Trash")); err == nil && info.IsDir() && info.Mode()&fs.ModeSticky != 0 {
+		trashes = append(trashes, filepath.Join(topdir, ".Trash", uid))
+	}
+	return append(trashes, filepath.Join(topdir, ".Trash-"+uid))
+}
+
+// makeTrash makes the files and info directories of a trash, refusing one
+// that is a symlink, which could point anywhere.
+func makeTrash(trash string) error {
+	if info, err := os.Lstat(trash); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		return fmt.Errorf("%s is a symlink", trash)
+	}
+	for _, dir := range []string{"files", "info"} {
+		if err := os.MkdirAll(filepath.Join(trash, dir), 0700); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isTrash reports whether dir is a trash: the home one, or one at the top
+// of a disk.
+func (app *App) isTrash(dir string) bool {
+	name := filepath.Base(dir)
+	return dir == app.trashRoot(app.trashConfig) || name == ".Trash" || strings.HasPrefix(name, ".Trash-")
+}
+
+// trashDirs lists the trashes that exist: the home trash and those at the
+// top of every mounted disk.
+func (app *App) trashDirs(cfg TrashConfig) []string {
+	trashes := []string{app.trashRoot(cfg)}
+	for _, fs := range mountedFilesystems() {
+		trashes = append(trashes, topTrashes(fs.mountPoint)...)
+	}
+	var existing []string
+	for _, trash := range trashes {
+		if info, err := os.Lstat(trash); err == nil && info.IsDir() {
+			existing = append(existing, trash)
+		}
+	}
+	return existing
+}
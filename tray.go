@@ -0,0 +1,487 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	trayPollInterval = 5 * time.Second
+
+	sniObject    = "/StatusNotifierItem"
+	sniInterface = "org.kde.StatusNotifierItem"
+	menuObject   = "/MenuBar"
+	menuIface    = "com.canonical.dbusmenu"
+)
+
+// Menu item IDs; 0 is the root.
+const (
+	menuStatus int32 = iota + 1
+	menuSeparator
+	menuCleanNow
+	menuPause
+	menuQuit
+)
+
+// tray shows the daemon in the desktop's system tray as a
+// StatusNotifierItem, with a menu to start a run and pause the schedule.
+// It talks to the daemon over D-Bus like any other client.
+type tray struct {
+	system bool
+	bus    *dbusConn // the session bus, where the item lives
+	name   string
+	quit   chan struct{}
+	stop   func() // closes quit, once
+
+	mu       sync.Mutex
+	daemon   *dbusConn      // the daemon's bus, nil until it's reachable
+	status   map[string]any // the daemon's last status, nil if it isn't running
+	revision uint32         // the menu layout's, bumped when it changes
+}
+
+func defineTrayCommand(fs *flag.FlagSet) func(args []string) error {
+	system := fs.Bool("system", false, "show the system-wide daemon")
+
+	return func(args []string) error {
+		t := &tray{system: *system, quit: make(chan struct{}), revision: 1}
+		t.stop = sync.OnceFunc(func() { close(t.quit) })
+		if err := t.start(); err != nil {
+			return err
+		}
+		defer t.bus.Close()
+
+		t.refresh()
+		ticker := time.NewTicker(trayPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				t.refresh()
+			case <-t.quit:
+				return nil
+			}
+		}
+	}
+}
+
+// start exports the item and its menu on the session bus and registers the
+// item with the tray.
+func (t *tray) start() error {
+	bus, err := dialDBus(dbusBusAddress(false))
+	if err != nil {
+		return err
+	}
+	t.bus = bus
+	t.name = fmt.Sprintf("org.kde.StatusNotifierItem-%d-1", os.Getpid())
+	if err := bus.requestName(t.name); err != nil {
+		bus.Close()
+		return err
+	}
+	if _, err := bus.call("org.kde.StatusNotifierWatcher", "/StatusNotifierWatcher", "org.kde.StatusNotifierWatcher",
+		"RegisterStatusNotifierItem", t.name); err != nil {
+		bus.Close()
+		return fmt.Errorf("no system tray to show the item in: %w", err)
+	}
+
+	go func() {
+		for {
+			msg, err := bus.read()
+			if err != nil {
+				select {
+				case <-t.quit: // closed on the way out
+				default:
+					log.Printf("D-Bus connection lost: %v", err)
+					t.stop()
+				}
+				return
+			}
+			if msg.typ == dbusMethodCall {
+				if err := t.handle(msg); err != nil {
+					log.Printf("Failed to answer %s: %v", msg.member, err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// refresh fetches the daemon's status and tells the tray if what it shows
+// has changed.
+func (t *tray) refresh() {
+	status := t.callDaemon("GetStatus")
+
+	t.mu.Lock()
+	before := t.view()
+	if len(status) > 0 {
+		t.status, _ = status[0].(map[string]any)
+	} else {
+		t.status = nil
+	}
+	after := t.view()
+	if after != before {
+		t.revision++
+	}
+	revision := t.revision
+	t.mu.Unlock()
+
+	if after == before {
+		return
+	}
+	t.bus.emit(sniObject, sniInterface, "NewIcon")
+	t.bus.emit(sniObject, sniInterface, "NewToolTip")
+	t.bus.emit(sniObject, sniInterface, "NewStatus", after.status)
+	t.bus.emit(menuObject, menuIface, "LayoutUpdated", revision, int32(0))
+}
+
+// callDaemon calls one of the daemon's methods, connecting to its bus
+// first if need be, and returns the reply's values (nil on failure).
+func (t *tray) callDaemon(method string) []any {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.daemon == nil {
+		conn, err := dialDBus(dbusBusAddress(t.system))
+		if err != nil {
+			return nil
+		}
+		t.daemon = conn
+	}
+	reply, err := t.daemon.call(dbusName, dbusObject, dbusInterface, method)
+	if err != nil {
+		// Reconnect next time unless the daemon merely isn't running
+		var replied *dbusErrorReply
+		if !errors.As(err, &replied) {
+			t.daemon.Close()
+			t.daemon = nil
+		}
+		return nil
+	}
+	values, err := reply.bodyValues()
+	if err != nil {
+		return nil
+	}
+	return values
+}
+
+// trayView is what the tray shows for a daemon status.
+type trayView struct {
+	icon, status, text string
+	running, paused    bool
+	available          bool
+}
+
+// view describes t.status; t.mu must be held.
+func (t *tray) view() trayView {
+	s := t.status
+	if s == nil {
+		return trayView{icon: "user-trash", status: "Active", text: T("tray.not_running")}
+	}
+
+	v := trayView{icon: "user-trash", status: "Active", available: true}
+	v.paused, _ = s["paused"].(bool)
+	state, _ := s["state"].(string)
+	v.running = state == "running"
+
+	lastRun, _ := s["last_run"].(string)
+	if at, err := time.Parse(time.RFC3339, lastRun); err == nil {
+		items, _ := s["last_items"].(int32)
+		errs, _ := s["last_errors"].(int32)
+		freed, _ := s["last_freed_bytes"].(int64)
+		v.text = T("tray.last_run", at.Local().Format("2006-01-02 15:04"), items, errs, formatSize(freed))
+		if failure, _ := s["last_failure"].(string); failure != "" {
+			v.text = T("tray.last_run_failed", at.Local().Format("2006-01-02 15:04"), failure)
+			errs++
+		}
+		if errs > 0 {
+			v.icon, v.status = "dialog-warning", "NeedsAttention"
+		}
+	} else {
+		v.text = T("tray.no_runs")
+	}
+
+	switch {
+	case v.running:
+		v.icon, v.text = "view-refresh", T("tray.running")
+	case v.paused:
+		v.icon, v.text = "media-playback-pause", v.text+" "+T("tray.paused")
+	}
+	return v
+}
+
+func (t *tray) current() trayView {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.view()
+}
+
+// itemProperties are the StatusNotifierItem's properties.
+func (t *tray) itemProperties() map[string]any {
+	v := t.current()
+	noPixmaps := dbusArray{elem: "(iiay)"}
+	return map[string]any{
+		"Category":   "SystemServices",
+		"Id":         binaryName,
+		"Title":      binaryName,
+		"Status":     v.status,
+		"IconName":   v.icon,
+		"IconPixmap": noPixmaps,
+		"ToolTip":    dbusStruct{v.icon, noPixmaps, binaryName, v.text},
+		"ItemIsMenu": true,
+		"Menu":       dbusPath(menuObject),
+	}
+}
+
+func menuProperties() map[string]any {
+	return map[string]any{
+		"Version":       uint32(3),
+		"TextDirection": "ltr",
+		"Status":        "normal",
+		"IconThemePath": []string{},
+	}
+}
+
+// menuItems returns the properties of each menu item, by ID.
+func (t *tray) menuItems() map[int32]map[string]any {
+	v := t.current()
+	pauseState := int32(0)
+	if v.paused {
+		pauseState = 1
+	}
+	return map[int32]map[string]any{
+		menuStatus:    {"label": v.text, "enabled": false},
+		menuSeparator: {"type": "separator"},
+		menuCleanNow:  {"label": T("tray.clean_now"), "enabled": v.available && !v.running, "icon-name": "edit-clear"},
+		menuPause: {"label": T("tray.pause"), "enabled": v.available,
+			"toggle-type": "checkmark", "toggle-state": pauseState},
+		menuQuit: {"label": T("tray.quit"), "icon-name": "application-exit"},
+	}
+}
+
+// layout is the menu as GetLayout returns it: (id, properties, children).
+func (t *tray) layout() dbusStruct {
+	items := t.menuItems()
+	var children []any
+	for id := menuStatus; id <= menuQuit; id++ {
+		children = append(children, dbusStruct{id, items[id], dbusArray{elem: "v"}})
+	}
+	return dbusStruct{int32(0), map[string]any{"children-display": "submenu"}, dbusArray{elem: "v", items: children}}
+}
+
+// clicked runs the menu item's action.
+func (t *tray) clicked(id int32) {
+	switch id {
+	case menuCleanNow:
+		t.callDaemon("RunNow")
+	case menuPause:
+		if t.current().paused {
+			t.callDaemon("Resume")
+		} else {
+			t.callDaemon("Pause")
+		}
+	case menuQuit:
+		t.stop()
+		return
+	default:
+		return
+	}
+	go t.refresh()
+}
+
+const trayIntrospection = `<!DOCTYPE node PUBLIC "-//freedesktop//DTD D-BUS Object Introspection 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/introspect.dtd">
+<node>
+  <interface name="org.kde.StatusNotifierItem">
+    <property name="Category" type="s" access="read"/>
+    <property name="Id" type="s" access="read"/>
+    <property name="Title" type="s" access="read"/>
+    <property name="Status" type="s" access="read"/>
+    <property name="IconName" type="s" access="read"/>
+    <property name="IconPixmap" type="a(iiay)" access="read"/>
+    <property name="ToolTip" type="(sa(iiay)ss)" access="read"/>
+    <property name="ItemIsMenu" type="b" access="read"/>
+    <property name="Menu" type="o" access="read"/>
+    <method name="ContextMenu"><arg name="x" type="i"/><arg name="y" type="i"/></method>
+    <method name="Activate"><arg name="x" type="i"/><arg name="y" type="i"/></method>
+    <method name="SecondaryActivate"><arg name="x" type="i"/><arg name="y" type="i"/></method>
+    <method name="Scroll"><arg name="delta" type="i"/><arg name="orientation" type="s"/></method>
+    <signal name="NewIcon"/>
+    <signal name="NewToolTip"/>
+    <signal name="NewStatus"><arg name="status" type="s"/></signal>
+  </interface>
+  <interface name="com.canonical.dbusmenu">
+    <property name="Version" type="u" access="read"/>
+    <property name="TextDirection" type="s" access="read"/>
+    <property name="Status" type="s" access="read"/>
+    <property name="IconThemePath" type="as" access="read"/>
+    <method name="GetLayout">
+      <arg name="parentId" type="i" direction="in"/>
+      <arg name="recursionDepth" type="i" direction="in"/>
+      <arg name="propertyNames" type="as" direction="in"/>
+      <arg name="revision" type="u" direction="out"/>
+      <arg name="layout" type="(ia{sv}av)" direction="out"/>
+    </method>
+    <method name="GetGroupProperties">
+      <arg name="ids" type="ai" direction="in"/>
+      <arg name="propertyNames" type="as" direction="in"/>
+      <arg name="properties" type="a(ia{sv})" direction="out"/>
+    </method>
+    <method name="GetProperty">
+      <arg name="id" type="i" direction="in"/>
+      <arg name="name" type="s" direction="in"/>
+      <arg name="value" type="v" direction="out"/>
+    </method>
+    <method name="Event">
+      <arg name="id" type="i" direction="in"/>
+      <arg name="eventId" type="s" direction="in"/>
+      <arg name="data" type="v" direction="in"/>
+      <arg name="timestamp" type="u" direction="in"/>
+    </method>
+    <method name="EventGroup">
+      <arg name="events" type="a(isvu)" direction="in"/>
+      <arg name="idErrors" type="ai" direction="out"/>
+    </method>
+    <method name="AboutToShow">
+      <arg name="id" type="i" direction="in"/>
+      <arg name="needUpdate" type="b" direction="out"/>
+    </method>
+    <method name="AboutToShowGroup">
+      <arg name="ids" type="ai" direction="in"/>
+      <arg name="updatesNeeded" type="ai" direction="out"/>
+      <arg name="idErrors" type="ai" direction="out"/>
+    </method>
+    <signal name="LayoutUpdated"><arg name="revision" type="u"/><arg name="parent" type="i"/></signal>
+  </interface>
+  <interface name="org.freedesktop.DBus.Properties">
+    <method name="Get">
+      <arg name="interface" type="s" direction="in"/>
+      <arg name="name" type="s" direction="in"/>
+      <arg name="value" type="v" direction="out"/>
+    </method>
+    <method name="GetAll">
+      <arg name="interface" type="s" direction="in"/>
+      <arg name="properties" type="a{sv}" direction="out"/>
+    </method>
+  </interface>
+  <interface name="org.freedesktop.DBus.Introspectable">
+    <method name="Introspect"><arg name="xml" type="s" direction="out"/></method>
+  </interface>
+</node>
+`
+
+func (t *tray) handle(call *dbusMessage) error {
+	args, err := call.bodyValues()
+	if err != nil {
+		return t.bus.replyError(call, "org.freedesktop.DBus.Error.InvalidArgs", err.Error())
+	}
+	arg := func(i int) any {
+		if i < len(args) {
+			return args[i]
+		}
+		return nil
+	}
+
+	switch call.member {
+	case "Introspect":
+		return t.bus.reply(call, trayIntrospection)
+	case "Ping":
+		return t.bus.reply(call)
+	case "Get", "GetAll":
+		var props map[string]any
+		switch iface, _ := arg(0).(string); {
+		case call.path == sniObject && iface == sniInterface:
+			props = t.itemProperties()
+		case call.path == menuObject && iface == menuIface:
+			props = menuProperties()
+		default:
+			return t.bus.replyError(call, "org.freedesktop.DBus.Error.UnknownInterface", fmt.Sprintf("no interface %v at %s", arg(0), call.path))
+		}
+		if call.member == "GetAll" {
+			return t.bus.reply(call, props)
+		}
+		name, _ := arg(1).(string)
+		value, ok := props[name]
+		if !ok {
+			return t.bus.replyError(call, "org.freedesktop.DBus.Error.UnknownProperty", "no property "+name)
+		}
+		return t.bus.reply(call, dbusVariant{value})
+	}
+
+	switch call.path {
+	case sniObject:
+		switch call.member {
+		case "Activate", "SecondaryActivate", "ContextMenu", "Scroll":
+			return t.bus.reply(call)
+		}
+	case menuObject:
+		return t.handleMenu(call, arg)
+	default:
+		return t.bus.replyError(call, "org.freedesktop.DBus.Error.UnknownObject", "no object at "+call.path)
+	}
+	return t.bus.replyError(call, "org.freedesktop.DBus.Error.UnknownMethod", "unknown method "+call.member)
+}
+
+func (t *tray) handleMenu(call *dbusMessage, arg func(int) any) error {
+	switch call.member {
+	case "GetLayout":
+		t.mu.Lock()
+		revision := t.revision
+		t.mu.Unlock()
+		return t.bus.reply(call, revision, t.layout())
+	case "GetGroupProperties":
+		items := t.menuItems()
+		ids, _ := arg(0).([]any)
+		var props []any
+		for id := menuStatus; id <= menuQuit; id++ {
+			if len(ids) == 0 || containsID(ids, id) {
+				props = append(props, dbusStruct{id, items[id]})
+			}
+		}
+		return t.bus.reply(call, dbusArray{elem: "(ia{sv})", items: props})
+	case "GetProperty":
+		id, _ := arg(0).(int32)
+		name, _ := arg(1).(string)
+		value, ok := t.menuItems()[id][name]
+		if !ok {
+			return t.bus.replyError(call, "org.freedesktop.DBus.Error.InvalidArgs", fmt.Sprintf("no property %s on item %d", name, id))
+		}
+		return t.bus.reply(call, dbusVariant{value})
+	case "Event":
+		id, _ := arg(0).(int32)
+		if event, _ := arg(1).(string); event == "clicked" {
+			t.clicked(id)
+		}
+		return t.bus.reply(call)
+	case "EventGroup":
+		events, _ := arg(0).([]any)
+		for _, e := range events {
+			fields, _ := e.([]any)
+			if len(fields) < 2 {
+				continue
+			}
+			id, _ := fields[0].(int32)
+			if event, _ := fields[1].(string); event == "clicked" {
+				t.clicked(id)
+			}
+		}
+		return t.bus.reply(call, dbusArray{elem: "i"})
+	case "AboutToShow":
+		return t.bus.reply(call, false)
+	case "AboutToShowGroup":
+		return t.bus.reply(call, dbusArray{elem: "i"}, dbusArray{elem: "i"})
+	}
+	return t.bus.replyError(call, "org.freedesktop.DBus.Error.UnknownMethod", "unknown method "+call.member)
+}
+
+func containsID(ids []any, id int32) bool {
+	for _, v := range ids {
+		if v == any(id) {
+			return true
+		}
+	}
+	return false
+}
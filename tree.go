@@ -0,0 +1,339 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultMaxScanDepth     = 64
+	defaultMaxEntriesPerDir = 100000
+)
+
+var (
+	// errWalkLimit is what a walk that leaves part of a tree out reports for
+	// the directory it didn't walk in full.
+	errWalkLimit      = errors.New("walk limit reached")
+	errWalkedAlready  = fmt.Errorf("%w: directory already walked, by way of a bind mount or loop", errWalkLimit)
+	errTooDeep        = fmt.Errorf("%w: deeper than max_scan_depth", errWalkLimit)
+	errTooManyEntries = fmt.Errorf("%w: more entries than max_entries_per_dir", errWalkLimit)
+)
+
+// walkLimits bound a walk: directories more than maxDepth below its start
+// aren't entered, and only the first maxEntries entries of a directory, by
+// name, are walked. 0 is no limit.
+type walkLimits struct {
+	maxDepth   int
+	maxEntries int
+}
+
+// walkTree is filepath.WalkDir for trees that may be nested deeper than
+// PATH_MAX, as old node_modules folders can be. Entries are opened relative
+// to path's parent directory a component at a time, rather than by full
+// path, so they never hit ENAMETOOLONG. fn gets each entry's path relative
+// to path ("." for path itself). A directory already walked, by its device
+// and inode, as a bind mount or one looping back to a parent brings it up
+// again, is passed to fn with errWalkedAlready instead, and not entered.
+func walkTree(path string, fn func(rel string, d fs.DirEntry, err error) error) error {
+	return walkTreeLimited(path, walkLimits{}, nil, fn)
+}
+
+// walkTreeLimited is walkTree within limits. A directory past them is passed
+// to fn a second time with errTooDeep, and not entered, or errTooManyEntries,
+// and then walked as far as the limit. The directories walked are added to
+// seen, if it isn't nil, so that the walks sharing it walk each just once.
+func walkTreeLimited(path string, limits walkLimits, seen map[inodeKey]bool, fn func(rel string, d fs.DirEntry, err error) error) error {
+	root, err := os.OpenRoot(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	if seen == nil {
+		seen = make(map[inodeKey]bool)
+	}
+	w := treeWalk{root: root, base: filepath.Base(path), limits: limits, seen: seen, fn: fn}
+	info, err := root.Lstat(w.base)
+	if err != nil {
+		err = fn(".", nil, err)
+	} else {
+		err = w.walk(w.base, fs.FileInfoToDirEntry(info), 0)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+type treeWalk struct {
+	root   *os.Root
+	base   string
+	limits walkLimits
+	seen   map[inodeKey]bool
+	fn     func(rel string, d fs.DirEntry, err error) error
+}
+
+// walk walks the entry at name, depth directories below the start.
+func (w *treeWalk) walk(name string, d fs.DirEntry, depth int) error {
+	rel, _ := filepath.Rel(w.base, name)
+	if d.IsDir() {
+		if info, err := d.Info(); err == nil {
+			if st, ok := info.Sys().(*syscall.Stat_t); ok {
+				key := inodeKey{uint64(st.Dev), uint64(st.Ino)}
+				if w.seen[key] {
+					return w.skip(rel, d, errWalkedAlready)
+				}
+				w.seen[key] = true
+			}
+		}
+	}
+	if err := w.fn(rel, d, nil); err != nil || !d.IsDir() {
+		if err == filepath.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+	if w.limits.maxDepth > 0 && depth >= w.limits.maxDepth {
+		return w.skip(rel, d, errTooDeep)
+	}
+
+	entries, err := w.readDir(name)
+	if err != nil {
+		if err := w.fn(rel, d, err); err != nil {
+			if err == filepath.SkipDir {
+				err = nil
+			}
+			return err
+		}
+	}
+	for _, entry := range entries {
+		if err := w.walk(path.Join(name, entry.Name()), entry, depth+1); err != nil {
+			if err == filepath.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// skip tells fn why the directory isn't entered.
+func (w *treeWalk) skip(rel string, d fs.DirEntry, reason error) error {
+	err := w.fn(rel, d, reason)
+	if err == filepath.SkipDir {
+		err = nil
+	}
+	return err
+}
+
+// readDir reads the entries of the directory at name, sorted by name, up to
+// the limit, which it returns errTooManyEntries for going over. It never
+// holds more than one over the limit, however many there are.
+func (w *treeWalk) readDir(name string) ([]fs.DirEntry, error) {
+	f, err := w.root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	n := -1
+	if w.limits.maxEntries > 0 {
+		n = w.limits.maxEntries + 1
+	}
+	entries, err := f.ReadDir(n)
+	if err == io.EOF {
+		err = nil
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	if n > 0 && len(entries) == n {
+		entries = entries[:w.limits.maxEntries]
+		if err == nil {
+			err = errTooManyEntries
+		}
+	}
+	return entries, err
+}
+
+// scanTree walks path like walkTree, within the config's scan limits, for
+// the cleaners looking for things in it. What's past the limits is left out
+// with a warning, rather than a pathological tree making the run explode in
+// time or memory. A cleaner walking several directories passes them all the
+// same seen, so none that overlap, or are bind mounts of one another, are
+// walked, and what's in them found, twice. A path that is a symlink is
+// walked where it points.
+func (app *App) scanTree(path string, seen map[inodeKey]bool, fn func(rel string, d fs.DirEntry, err error) error) error {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		if target, err := filepath.EvalSymlinks(path); err == nil {
+			path = target
+		}
+	}
+	return walkTreeLimited(path, app.scanLimits, seen, func(rel string, d fs.DirEntry, err error) error {
+		switch {
+		case errors.Is(err, errWalkedAlready):
+			log.Printf("Skipping %s: %v", app.displayPath(filepath.Join(path, rel)), err)
+			return nil
+		case errors.Is(err, errWalkLimit):
+			log.Printf("Warning: not scanning all of %s: %v", app.displayPath(filepath.Join(path, rel)), err)
+			return nil
+		case err == nil && d.IsDir() && (d.Name() == snapshotDirName || app.isTrash(filepath.Join(path, rel))):
+			// A snapshot's copies, and what's been trashed, aren't the
+			// user's files any more
+			return filepath.SkipDir
+		}
+		return fn(rel, d, err)
+	})
+}
+
+// scanLimitsFor returns the config's scan limits, with the defaults for 0.
+func scanLimitsFor(config Config) walkLimits {
+	limits := walkLimits{maxDepth: config.MaxScanDepth, maxEntries: config.MaxEntriesPerDir}
+	if limits.maxDepth == 0 {
+		limits.maxDepth = defaultMaxScanDepth
+	}
+	if limits.maxEntries == 0 {
+		limits.maxEntries = defaultMaxEntriesPerDir
+	}
+	return limits
+}
+
+const (
+	// removeBatch is how many entries of a directory removeTree reads at a
+	// time.
+	removeBatch         = 1024
+	removeProgressEvery = 5 * time.Second
+)
+
+// removeTree deletes the tree at path like os.RemoveAll, but with no more
+// than two files open however deep the tree is, where os.RemoveAll keeps a
+// directory open for every level it's in: each directory is read a batch
+// at a time, its files removed as they come and its subdirectories only
+// once it's closed. Entries are opened relative to path's parent, as in
+// walkTree, so trees deeper than PATH_MAX are removed too. progress, if not
+// nil, is called every few seconds with the entries removed so far.
+func removeTree(path string, progress func(removed int)) error {
+	root, err := os.OpenRoot(filepath.Dir(path))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	r := treeRemoval{root: root, progress: progress, reported: time.Now()}
+	return r.remove(filepath.Base(path))
+}
+
+type treeRemoval struct {
+	root     *os.Root
+	progress func(removed int)
+	removed  int
+	reported time.Time
+}
+
+// remove removes the entry at name and everything under it.
+func (r *treeRemoval) remove(name string) error {
+	err := r.root.Remove(name)
+	if err == nil || os.IsNotExist(err) {
+		r.count(err == nil)
+		return nil
+	}
+	info, lerr := r.root.Lstat(name)
+	if lerr != nil || !info.IsDir() {
+		if os.IsNotExist(lerr) {
+			return nil
+		}
+		return err
+	}
+
+	for {
+		// Removing entries while reading a directory may make it skip
+		// others, so it's read again until nothing is left or nothing more
+		// can be removed
+		dirs, removed, err := r.removeFiles(name)
+		for _, dir := range dirs {
+			if suberr := r.remove(path.Join(name, dir)); suberr == nil {
+				removed++
+			} else if err == nil {
+				err = suberr
+			}
+		}
+		rmerr := r.root.Remove(name)
+		if rmerr == nil || os.IsNotExist(rmerr) {
+			r.count(rmerr == nil)
+			return nil
+		}
+		if removed == 0 {
+			if err == nil {
+				err = rmerr
+			}
+			return err
+		}
+	}
+}
+
+// removeFiles removes the entries of the directory at name other than its
+// subdirectories, which it returns the names of, with how many it removed
+// and the first error removing one.
+func (r *treeRemoval) removeFiles(name string) ([]string, int, error) {
+	f, err := r.root.Open(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var dirs []string
+	var first error
+	removed := 0
+	for {
+		entries, err := f.ReadDir(removeBatch)
+		for _, entry := range entries {
+			if entry.IsDir() {
+				dirs = append(dirs, entry.Name())
+				continue
+			}
+			if err := r.root.Remove(path.Join(name, entry.Name())); err == nil {
+				removed++
+				r.count(true)
+			} else if !os.IsNotExist(err) && first == nil {
+				first = err
+			}
+		}
+		if err == io.EOF {
+			return dirs, removed, first
+		}
+		if err != nil {
+			return dirs, removed, err
+		}
+	}
+}
+
+// count counts an entry removed, reporting the progress if it's time.
+func (r *treeRemoval) count(removed bool) {
+	if !removed {
+		return
+	}
+	r.removed++
+	if r.progress != nil && time.Since(r.reported) >= removeProgressEvery {
+		r.progress(r.removed)
+		r.reported = time.Now()
+	}
+}
+
+// removeTree removes path, logging how far it's got every few seconds, so
+// a huge tree doesn't make the run look frozen.
+func (app *App) removeTree(path string) error {
+	return removeTree(path, func(removed int) {
+		log.Printf("Removing %s: %d entries so far", app.displayPath(path), removed)
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pathMax is Linux's PATH_MAX, the larger of Linux's and macOS's.
+const pathMax = 4096
+
+// deepTree creates a node_modules folder in dir nested deeper than PATH_MAX,
+// a directory at a time, with a file at the bottom, returning its path and
+// the path of the file relative to it.
+func deepTree(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	top := filepath.Join(dir, "node_modules")
+	if err := os.Mkdir(top, 0755); err != nil {
+		t.Fatal(err)
+	}
+	root, err := os.OpenRoot(top)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { root.Close() }()
+
+	segment := strings.Repeat("d", 200)
+	var rel []string
+	for len(filepath.Join(rel...)) <= pathMax {
+		if err := root.Mkdir(segment, 0755); err != nil {
+			t.Fatal(err)
+		}
+		sub, err := root.OpenRoot(segment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root.Close()
+		root = sub
+		rel = append(rel, segment)
+	}
+	f, err := root.Create("index.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("module.exports = {}\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return top, filepath.Join(append(rel, "index.js")...)
+}
+
+func TestWalkTreeDeeperThanPathMax(t *testing.T) {
+	top, file := deepTree(t, t.TempDir())
+
+	var entries int
+	found := false
+	err := walkTree(top, func(rel string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		entries++
+		if rel == file {
+			found = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walkTree: %v", err)
+	}
+	if want := strings.Count(file, "/") + 2; entries != want {
+		t.Errorf("walkTree walked %d entries, want %d", entries, want)
+	}
+	if !found {
+		t.Errorf("walkTree didn't get to %d characters down", len(file))
+	}
+}
+
+func TestRemoveTreeDeeperThanPathMax(t *testing.T) {
+	dir := t.TempDir()
+	top, _ := deepTree(t, dir)
+
+	app := newHomeApp(dir)
+	if err := app.removeAll(top); err != nil {
+		t.Fatalf("removeAll: %v", err)
+	}
+	if _, err := os.Lstat(top); !os.IsNotExist(err) {
+		t.Errorf("%s is still there: %v", top, err)
+	}
+	if app.summary.FreedBytes == 0 {
+		t.Error("removeAll freed no space")
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// othersExt is the files of one extension in the Others folder.
+type othersExt struct {
+	ext   string
+	files []string
+	bytes int64
+	// mimeType is the extension's MIME type, or else what the content of
+	// its first file looks like, with sniffed set; empty if neither tells
+	mimeType string
+	sniffed  bool
+}
+
+// othersExtensions lists the files in the Others folder by extension, the
+// most common first.
+func (app *App) othersExtensions(config Config) ([]othersExt, error) {
+	dir := filepath.Join(app.downloadsDir, categoryFolder(config.CategoryNames, otherCategory))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read the Others folder: %w", err)
+	}
+
+	byExt := make(map[string]*othersExt)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), categoryIndexName+".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		ext := fileExt(config, entry.Name())
+		e := byExt[ext]
+		if e == nil {
+			e = &othersExt{ext: ext}
+			byExt[ext] = e
+		}
+		e.files = append(e.files, filepath.Join(dir, entry.Name()))
+		e.bytes += info.Size()
+	}
+
+	var list []othersExt
+	for _, e := range byExt {
+		e.mimeType = extType(e.ext)
+		if e.mimeType == "" {
+			e.mimeType, e.sniffed = sniffedType(fileHead(e.files[0])), true
+		}
+		list = append(list, *e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if len(list[i].files) != len(list[j].files) {
+			return len(list[i].files) > len(list[j].files)
+		}
+		if list[i].bytes != list[j].bytes {
+			return list[i].bytes > list[j].bytes
+		}
+		return list[i].ext < list[j].ext
+	})
+	return list, nil
+}
+
+// triageOthers goes through the extensions in the Others folder, printing
+// how many files of each there are and what type they are. From a terminal
+// it asks for a category for each, adds the ones given to the config's
+// categories, so runs file them there from now on, and offers to move the
+// files already in Others into them.
+func (app *App) triageOthers() error {
+	config, err := app.loadConfig()
+	if err != nil {
+		return err
+	}
+	list, err := app.othersExtensions(config)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		fmt.Println(T("triage.none"))
+		return nil
+	}
+
+	exts := make([]string, len(list))
+	files := 0
+	for i, e := range list {
+		exts[i] = displayExt(e.ext)
+		files += len(e.files)
+	}
+	width := columnWidth(10, exts)
+	fmt.Println(T("triage.title", files, len(list)))
+	for i, e := range list {
+		kind := e.mimeType
+		switch {
+		case kind == "":
+			kind = T("triage.unknown")
+		case e.sniffed:
+			kind = T("triage.sniffed", kind)
+		}
+		fmt.Printf("   %s %6d %10s  %s\n", padRight(exts[i], width), len(e.files), formatSize(e.bytes), kind)
+	}
+	fmt.Println()
+
+	if !app.isInteractive() {
+		rules := 0
+		for _, e := range list {
+			if e.ext != "" {
+				fmt.Printf("   \"categories\": {%q: %q}\n", e.ext, e.guess())
+				rules++
+			}
+		}
+		if rules > 0 {
+			fmt.Println()
+		}
+		fmt.Println(T("triage.from_terminal"))
+		return nil
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	assigned := make(map[string]string)
+	for i, e := range list {
+		// Rules go by extension
+		if e.ext == "" {
+			continue
+		}
+		ok, err := app.askYesNo(reader, T("triage.assign", exts[i], len(e.files)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		folder, err := app.askString(reader, T("suggest.folder"), categoryFolder(config.CategoryNames, e.guess()))
+		if err != nil {
+			return err
+		}
+		if !validCategory(folder) {
+			fmt.Println(T("suggest.invalid_folder", folder))
+			continue
+		}
+		if config.Categories == nil {
+			config.Categories = make(map[string]string)
+		}
+		config.Categories[e.ext] = categoryOfFolder(config, folder)
+		assigned[e.ext] = config.Categories[e.ext]
+	}
+	if len(assigned) == 0 {
+		return nil
+	}
+	if err := app.saveConfig(config); err != nil {
+		return err
+	}
+	fmt.Println(T("suggest.saved", len(assigned), app.configPath))
+
+	moving := 0
+	for _, e := range list {
+		if _, ok := assigned[e.ext]; ok {
+			moving += len(e.files)
+		}
+	}
+	ok, err := app.askYesNo(reader, T("triage.move", moving))
+	if err != nil || !ok {
+		return err
+	}
+	return app.moveOutOfOthers(config, list, assigned)
+}
+
+// moveOutOfOthers moves the files in Others of the extensions assigned a
+// category into its folder, as a run of the downloads cleaner that
+// saafsafai undo can put back.
+func (app *App) moveOutOfOthers(config Config, list []othersExt, assigned map[string]string) error {
+	unlock, err := app.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	start := time.Now()
+	app.cleaner = "downloads"
+	app.summary.RunID = newRunID()
+	finish, err := app.beginDownloadsRun()
+	if err != nil {
+		return err
+	}
+	defer finish()
+	for _, e := range list {
+		category, ok := assigned[e.ext]
+		if !ok {
+			continue
+		}
+		for _, path := range e.files {
+			if err := app.moveToCategory(path, category, filepath.Base(path), e.ext); err != nil {
+				app.skipItem("Failed to move file", path, err)
+			}
+		}
+	}
+	app.endDownloadsRun(config, start)
+	fmt.Println(T("organize.done", app.itemCount(), app.summary.RunID))
+	return nil
+}
+
+// guess proposes a category for the extension from its MIME type.
+func (e othersExt) guess() string {
+	if category := typeCategory(e.mimeType); e.sniffed && category != "" {
+		return category
+	}
+	return guessCategory(e.ext)
+}
+
+// displayExt names an extension in the triage, the empty one included.
+func displayExt(ext string) string {
+	if ext == "" {
+		return T("organize.no_extension")
+	}
+	return ext
+}
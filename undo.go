@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// opRestore is the audit action of an item saafsafai undo put back.
+	opRestore = "restore"
+	// undoWait is how long a run waits for its notification's Undo button
+	// to be pressed, once it's done.
+	undoWait = 15 * time.Minute
+)
+
+func defineUndoCommand(fs *flag.FlagSet) func(args []string) error {
+	system := fs.Bool("system", false, "undo a run of the system service")
+	notify := fs.Bool("notify", false, "report the outcome as a desktop notification too")
+
+	return func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("expected: undo <run-id>")
+		}
+		app, err := commandApp(*system)
+		if err != nil {
+			return err
+		}
+		err = app.undo(args[0])
+		if *notify {
+			text := T("undo.done_notify", args[0])
+			if err != nil {
+				text = err.Error()
+			}
+			if nerr := sendDesktopNotification(T("undo.subject"), text); nerr != nil {
+				log.Printf("Failed to send desktop notification: %v", nerr)
+			}
+		}
+		return err
+	}
+}
+
+// undo puts back what the run with id (or an ID it starts) moved,
+// quarantined or trashed, newest first. What it deleted for good can't be
+// put back, though a snapshot from before the run may have a copy.
+func (app *App) undo(id string) error {
+	if _, err := os.Stat(app.configPath); err == nil {
+		config, err := app.loadConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		app.trashConfig = config.Trash
+	}
+	unlock, err := app.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	defer app.closeAudit()
+
+	records, runID, err := app.runAudit(id)
+	if err != nil {
+		return err
+	}
+	var snapshots []runSnapshot
+	if history, err := app.loadHistory(); err == nil {
+		for _, record := range history {
+			if record.RunID == runID {
+				snapshots = record.Snapshots
+			}
+		}
+	}
+
+	app.summary.RunID = newRunID()
+	app.cleaner = "undo"
+	restored, failed := 0, 0
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		var err error
+		switch record.Action {
+		case opMove:
+			err = app.undoMove(record)
+		case "quarantine":
+			err = app.undoQuarantine(record)
+		case "trash":
+			err = app.undoTrash(record)
+		default:
+			// Deleted, or handed to a script
+			text := T("undo.deleted", displayName(app.displayPath(record.Src)))
+			if kept := snapshotCopy(snapshots, record.Src); kept != "" {
+				text += ", " + T("find.snapshot", app.displayPath(kept))
+			}
+			fmt.Println(text)
+			continue
+		}
+		if err != nil {
+			fmt.Println(T("undo.failed", displayName(app.displayPath(record.Src)), err))
+			failed++
+			continue
+		}
+		fmt.Println(T("undo.restored", displayName(app.displayPath(record.Src))))
+		restored++
+		app.writeAudit(auditRecord{Time: time.Now(), RunID: app.summary.RunID, Cleaner: app.cleaner, Action: opRestore, Src: record.Src, Dst: record.Dst, Size: record.Size})
+	}
+	fmt.Println(T("undo.total", restored, runID))
+	if failed > 0 {
+		return fmt.Errorf("%d items of run %s couldn't be put back", failed, runID)
+	}
+	return nil
+}
+
+// runAudit returns the audit records of the run with id, or the one run
+// whose ID starts with id, oldest first, with its full ID.
+func (app *App) runAudit(id string) ([]auditRecord, string, error) {
+	f, err := os.Open(app.auditPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, "", fmt.Errorf("no run %s in the audit log", id)
+		}
+		return nil, "", err
+	}
+	defer f.Close()
+
+	byRun := make(map[string][]auditRecord)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record auditRecord
+		if json.Unmarshal(scanner.Bytes(), &record) != nil || !strings.HasPrefix(record.RunID, id) {
+			continue
+		}
+		byRun[record.RunID] = append(byRun[record.RunID], record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to read audit log: %w", err)
+	}
+	if records, ok := byRun[id]; ok {
+		return records, id, nil
+	}
+	switch len(byRun) {
+	case 0:
+		return nil, "", fmt.Errorf("no run %s in the audit log", id)
+	case 1:
+		for runID, records := range byRun {
+			return records, runID, nil
+		}
+	}
+	return nil, "", fmt.Errorf("more than one run starts with %s, give more of its ID", id)
+}
+
+// putBack renames what's at from back to path, which has to be free.
+func putBack(from, path string) error {
+	if _, err := os.Lstat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.Rename(from, path)
+}
+
+func (app *App) undoMove(record auditRecord) error {
+	if _, err := os.Lstat(record.Dst); err != nil {
+		return fmt.Errorf("%s is gone", app.displayPath(record.Dst))
+	}
+	return putBack(record.Dst, record.Src)
+}
+
+// undoQuarantine restores the quarantine item of the removal, if it hasn't
+// expired.
+func (app *App) undoQuarantine(record auditRecord) error {
+	items, err := app.loadQuarantine()
+	if err != nil {
+		return fmt.Errorf("failed to read quarantine index: %w", err)
+	}
+	for i, item := range items {
+		if d := item.Time.Sub(record.Time); item.Path == record.Src && d > -time.Minute && d < time.Minute {
+			_, err := app.restoreRecord(items, i, "", false)
+			return err
+		}
+	}
+	return fmt.Errorf("its quarantine item has expired")
+}
+
+// undoTrash takes the item the removal trashed back out of the trash, going
+// by the .trashinfo files.
+func (app *App) undoTrash(record auditRecord) error {
+	for _, trash := range app.trashDirs(app.trashConfig) {
+		top := trashTop(trash)
+		infos, _ := filepath.Glob(filepath.Join(trash, "info", "*"+trashInfoExt))
+		for _, info := range infos {
+			path, deleted, ok := readTrashInfo(info, top)
+			if !ok || path != record.Src {
+				continue
+			}
+			if d := deleted.Sub(record.Time); d <= -time.Minute || d >= time.Minute {
+				continue
+			}
+			name := strings.TrimSuffix(filepath.Base(info), trashInfoExt)
+			if err := putBack(filepath.Join(trash, "files", name), path); err != nil {
+				return err
+			}
+			os.Remove(info)
+			return nil
+		}
+	}
+	return fmt.Errorf("it's no longer in the trash")
+}
+
+// trashTop returns the top of the disk a trash is at the top of, or "" for
+// the home trash.
+func trashTop(trash string) string {
+	switch {
+	case strings.HasPrefix(filepath.Base(trash), ".Trash-"):
+		return filepath.Dir(trash)
+	case filepath.Base(filepath.Dir(trash)) == ".Trash":
+		return filepath.Dir(filepath.Dir(trash))
+	}
+	return ""
+}
+
+// readTrashInfo returns the original path and deletion time of a trashed
+// item, from its .trashinfo. Paths in a disk's own trash are relative to
+// its top.
+func readTrashInfo(info, top string) (string, time.Time, bool) {
+	data, err := os.ReadFile(info)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	var path string
+	var deleted time.Time
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "Path="); ok {
+			path, err = url.PathUnescape(value)
+			if err != nil {
+				return "", time.Time{}, false
+			}
+		} else if value, ok := strings.CutPrefix(line, "DeletionDate="); ok {
+			deleted, _ = time.ParseInLocation("2006-01-02T15:04:05", value, time.Local)
+		}
+	}
+	if path == "" {
+		return "", time.Time{}, false
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(top, path)
+	}
+	return path, deleted, true
+}
+
+// undoNotification is a run's desktop notification with an Undo button.
+type undoNotification struct {
+	conn *dbusConn
+	id   uint32
+}
+
+// showUndoNotification shows a desktop notification whose Undo button
+// undoes the run, through the notification server on the session bus.
+func showUndoNotification(subject, body string) (*undoNotification, error) {
+	conn, err := dialDBus(dbusBusAddress(false))
+	if err != nil {
+		return nil, err
+	}
+	// Before the notification, so none of its signals are missed
+	if err := conn.addMatch("type='signal',interface='org.freedesktop.Notifications'"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reply, err := conn.call("org.freedesktop.Notifications", "/org/freedesktop/Notifications", "org.freedesktop.Notifications", "Notify",
+		"saafsafai", uint32(0), "", subject, body, []string{"undo", T("notify.undo")}, map[string]any{}, int32(-1))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	id, err := reply.bodyUint32()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &undoNotification{conn: conn, id: id}, nil
+}
+
+// waitForUndo waits, for undoWait at most, for the run's notification to be
+// closed or its Undo button pressed, which runs saafsafai undo for the run.
+func (app *App) waitForUndo() {
+	n := app.undoNotice
+	if n == nil {
+		return
+	}
+	app.undoNotice = nil
+	defer n.conn.Close()
+
+	n.conn.conn.SetReadDeadline(time.Now().Add(undoWait))
+	for {
+		msg, err := n.conn.read()
+		if err != nil {
+			// Timed out, or the bus went away
+			return
+		}
+		if msg.typ != dbusSignal || msg.iface != "org.freedesktop.Notifications" {
+			continue
+		}
+		args, err := msg.bodyValues()
+		if err != nil || len(args) < 2 || args[0] != n.id {
+			continue
+		}
+		switch msg.member {
+		case "ActionInvoked":
+			if args[1] != "undo" {
+				continue
+			}
+			log.Printf("Undoing run %s", app.summary.RunID)
+			if err := app.runUndo(); err != nil {
+				log.Printf("Failed to undo run %s: %v", app.summary.RunID, err)
+			}
+			return
+		case "NotificationClosed":
+			return
+		}
+	}
+}
+
+// runUndo runs saafsafai undo for the run, as the notification's button
+// says it does.
+func (app *App) runUndo() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	args := []string{"undo", "--notify"}
+	if app.system {
+		args = append(args, "--system")
+	}
+	cmd := exec.Command(exe, append(args, app.summary.RunID)...)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// cleanUserHomes runs the cleanup of every user with a home directory under
+// /home. Each user's run happens in a child process with that user's
+// credentials, so the files and logs it creates end up owned by the user and
+// a symlink planted in a home directory can't redirect a write made as root.
+func (app *App) cleanUserHomes() error {
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	homes, err := userHomes()
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stat(systemDefaultUserConfig)
+	hasDefault := err == nil
+
+	for _, h := range homes {
+		u, home := h.user, h.home
+		if _, err := os.Stat(filepath.Join(home, ".config", configFileName)); os.IsNotExist(err) && !hasDefault {
+			log.Printf("Skipping %s: no config and no default at %s", u.Username, systemDefaultUserConfig)
+			continue
+		}
+
+		if err := app.runAsUser(self, h); err != nil {
+			var exitErr *exec.ExitError
+			switch {
+			case errors.As(err, &exitErr) && exitErr.ExitCode() == exitNothingToDo:
+			case errors.As(err, &exitErr) && exitErr.ExitCode() == exitPartial:
+				app.logError("Cleanup for %s completed with errors (see their log)", u.Username)
+			default:
+				app.logError("Cleanup for %s failed: %v", u.Username, err)
+				continue
+			}
+		}
+		app.addItem(&app.summary.CleanedUsers, fmt.Sprintf("%s (%s)", u.Username, home))
+	}
+
+	return nil
+}
+
+// homeUser is a user with a home directory under /home, and the IDs the
+// directory is owned by.
+type homeUser struct {
+	user     *user.User
+	home     string
+	uid, gid uint32
+}
+
+// userHomes lists the home directories under /home of known users other
+// than root.
+func userHomes() ([]homeUser, error) {
+	homes, err := filepath.Glob(filepath.Join(homesRoot, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var users []homeUser
+	for _, home := range homes {
+		info, err := os.Stat(home)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		if !ok || stat.Uid == 0 {
+			continue
+		}
+
+		u, err := user.LookupId(strconv.FormatUint(uint64(stat.Uid), 10))
+		if err != nil {
+			log.Printf("Skipping %s: owner %d is not a known user", home, stat.Uid)
+			continue
+		}
+		users = append(users, homeUser{user: u, home: home, uid: stat.Uid, gid: stat.Gid})
+	}
+	return users, nil
+}
+
+// userCleaners returns the names in set of the cleaners user runs have.
+func userCleaners(set map[string]bool) []string {
+	var names []string
+	for _, name := range userCleanerNames {
+		if set[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (app *App) runAsUser(self string, h homeUser) error {
+	defer app.timeAction("user "+h.user.Username, time.Now())
+
+	var args []string
+	if app.dryRun {
+		args = append(args, "--dry-run")
+	}
+	// Pass on the user cleaners of --only and --skip; "--only users" alone
+	// runs each user's usual cleanup
+	if only := userCleaners(app.only); len(only) > 0 {
+		args = append(args, "--only", strings.Join(only, ","))
+	}
+	if skip := userCleaners(app.skip); len(skip) > 0 {
+		args = append(args, "--skip", strings.Join(skip, ","))
+	}
+	if app.levelFlag != "" {
+		args = append(args, "--level", app.levelFlag)
+	}
+
+	cmd := userCommand(self, h, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// userCommand returns the command running saafsafai with args as the user,
+// in their home directory and with a minimal environment.
+func userCommand(self string, h homeUser, args ...string) *exec.Cmd {
+	u, home, uid, gid := h.user, h.home, h.uid, h.gid
+	var groups []uint32
+	if ids, err := u.GroupIds(); err == nil {
+		for _, id := range ids {
+			if g, err := strconv.ParseUint(id, 10, 32); err == nil {
+				groups = append(groups, uint32(g))
+			}
+		}
+	}
+
+	cmd := exec.Command(self, args...)
+	cmd.Dir = home
+	cmd.Env = []string{
+		"HOME=" + home,
+		"USER=" + u.Username,
+		"LOGNAME=" + u.Username,
+		"PATH=" + os.Getenv("PATH"),
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: uid, Gid: gid, Groups: groups},
+	}
+	return cmd
+}
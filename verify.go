@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// errCorrupted is returned for quarantined items whose content no longer
+// matches the checksums taken when they were quarantined.
+var errCorrupted = errors.New("content doesn't match its checksums")
+
+// quarantineManifest returns the SHA-256 of every regular file of the tree
+// at path, by slash-separated path relative to it ("." for a single file).
+func quarantineManifest(path string, lim *readLimiter) (map[string]string, error) {
+	manifest := make(map[string]string)
+	err := walkTree(path, func(rel string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		hash, err := fileHash(filepath.Join(path, rel), lim)
+		if err != nil {
+			return err
+		}
+		manifest[filepath.ToSlash(rel)] = hash
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// verifyObject checks the quarantined object against the checksums in its
+// record; records from before checksums were kept can't be checked and pass.
+func (app *App) verifyObject(record quarantineRecord) error {
+	if record.Checksums == nil {
+		return nil
+	}
+	got, err := quarantineManifest(app.quarantineObject(record.ID), app.limiter())
+	if err != nil {
+		return fmt.Errorf("failed to read quarantined %s: %w", record.ID, err)
+	}
+
+	var problems []string
+	for name, want := range record.Checksums {
+		switch hash, ok := got[name]; {
+		case !ok:
+			problems = append(problems, name+" is missing")
+		case hash != want:
+			problems = append(problems, name+" changed")
+		}
+	}
+	for name := range got {
+		if _, ok := record.Checksums[name]; !ok {
+			problems = append(problems, name+" was added")
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	if len(problems) > 3 {
+		problems = append(problems[:3], fmt.Sprintf("%d more", len(problems)-3))
+	}
+	return fmt.Errorf("%w: %s", errCorrupted, strings.Join(problems, ", "))
+}
+
+func defineVerifyCommand(fs *flag.FlagSet) func(args []string) error {
+	system := fs.Bool("system", false, "verify the system-wide quarantine")
+
+	return func(args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("expected: verify")
+		}
+		app, err := commandApp(*system)
+		if err != nil {
+			return err
+		}
+		return app.verifyQuarantine()
+	}
+}
+
+// verifyQuarantine checks every quarantined item against its checksums.
+func (app *App) verifyQuarantine() error {
+	records, err := app.loadQuarantine()
+	if err != nil {
+		return fmt.Errorf("failed to read quarantine index: %w", err)
+	}
+
+	// Records sharing an ID share the object, so it's checked once
+	checked := make(map[string]error)
+	corrupted, unchecked := 0, 0
+	for _, r := range records {
+		name := displayName(app.displayPath(r.Path))
+		if r.Checksums == nil {
+			unchecked++
+			fmt.Println(T("verify.unchecked", r.ID, name))
+			continue
+		}
+		err, ok := checked[r.ID]
+		if !ok {
+			err = app.verifyObject(r)
+			checked[r.ID] = err
+		}
+		if err != nil {
+			corrupted++
+			fmt.Println(T("verify.corrupted", r.ID, name, err))
+		}
+	}
+
+	fmt.Println(T("verify.summary", len(records)-corrupted-unchecked, corrupted, unchecked))
+	if corrupted > 0 {
+		return fmt.Errorf("%d quarantined items are corrupted", corrupted)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// version, commit and buildDate can be set when building, e.g.
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.buildDate=2024-06-01"
+//
+// A build from a git checkout records its commit itself, and the commit's
+// time stands in for the build date.
+var (
+	version   = "1.0.0"
+	commit    string
+	buildDate string
+)
+
+// buildInfo is what saafsafai version reports, for bug reports and fleet
+// inventories.
+type buildInfo struct {
+	Version   string   `json:"version"`
+	Commit    string   `json:"commit,omitempty"`
+	Modified  bool     `json:"modified,omitempty"`
+	BuildDate string   `json:"build_date,omitempty"`
+	GoVersion string   `json:"go_version"`
+	Platform  string   `json:"platform"`
+	Tags      []string `json:"tags"`
+	Cleaners  []string `json:"cleaners"`
+}
+
+// readBuildInfo gathers the build's information, preferring what was set
+// with -ldflags over what the go tool recorded.
+func readBuildInfo() buildInfo {
+	info := buildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Tags:      []string{},
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			case "-tags":
+				info.Tags = strings.Split(s.Value, ",")
+			}
+		}
+	}
+	for _, c := range cleaners {
+		info.Cleaners = append(info.Cleaners, c.name)
+	}
+	return info
+}
+
+func defineVersionCommand(fs *flag.FlagSet) func(args []string) error {
+	asJSON := fs.Bool("json", false, "print the build information as JSON")
+
+	return func(args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+		}
+		info := readBuildInfo()
+		if *asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(info)
+		}
+		printVersion(info)
+		return nil
+	}
+}
+
+// printVersion prints the build information for people.
+func printVersion(info buildInfo) {
+	fmt.Printf("saafsafai v%s\n", info.Version)
+	if info.Commit != "" {
+		modified := ""
+		if info.Modified {
+			modified = " (modified)"
+		}
+		fmt.Printf("  commit:   %s%s\n", info.Commit, modified)
+	}
+	if info.BuildDate != "" {
+		fmt.Printf("  built:    %s\n", info.BuildDate)
+	}
+	fmt.Printf("  go:       %s %s\n", info.GoVersion, info.Platform)
+	if len(info.Tags) > 0 {
+		fmt.Printf("  tags:     %s\n", strings.Join(info.Tags, ", "))
+	}
+	fmt.Printf("  cleaners: %s\n", strings.Join(info.Cleaners, ", "))
+}
@@ -0,0 +1,486 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const vagrantBoxMaxAge = 90 // days
+
+var (
+	vmDiskExts = []string{".qcow2", ".img", ".raw", ".vdi", ".vmdk", ".vhd", ".vhdx"}
+
+	// vmwareInventoryEntry matches `vmlist1.config = "/path/to/vm.vmx"`.
+	vmwareInventoryEntry = regexp.MustCompile(`^vmlist\d+\.config\s*=\s*"(.+)"`)
+)
+
+type VMConfig struct {
+	// VagrantBoxMaxAgeDays reports Vagrant boxes unused for this many days
+	// (default 90).
+	VagrantBoxMaxAgeDays int `json:"vagrant_box_max_age_days"`
+}
+
+type vmArtifact struct {
+	kind string
+	path string
+	size int64
+	// remove deletes the artifact, for those that have a proper tool
+	remove func() error
+}
+
+// cleanVMImages looks for large VM artifacts that nothing uses any more. They
+// are only ever removed after asking on the terminal; unattended runs just
+// report them.
+func (app *App) cleanVMImages(cfg VMConfig) error {
+	var artifacts []vmArtifact
+	artifacts = append(artifacts, app.orphanedLibvirtImages()...)
+	artifacts = append(artifacts, app.orphanedVirtualBoxDisks()...)
+	artifacts = append(artifacts, app.orphanedVMwareDisks()...)
+	artifacts = append(artifacts, app.unusedVagrantBoxes(cfg)...)
+
+	interactive := app.isInteractive() && !app.dryRun
+	for _, a := range artifacts {
+		item := fmt.Sprintf("%s %s (%s)", a.kind, a.path, formatSize(a.size))
+
+		if !interactive {
+			if app.dryRun {
+				app.addItem(&app.summary.RemovedVMImages, item)
+				app.countFreed(diskFiles(a.path))
+			} else {
+				app.addItem(&app.summary.VMImageCandidates, item)
+			}
+			continue
+		}
+
+		ok, err := app.confirm(T("confirm.remove", item))
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+		if !ok {
+			continue
+		}
+
+		if app.isProtected(a.path) {
+			app.skipItem("Failed to remove", a.path, errProtected)
+			continue
+		}
+		remove := a.remove
+		if remove == nil {
+			remove = func() error { return app.removeTree(a.path) }
+		}
+		files := diskFiles(a.path)
+		if err := remove(); err != nil {
+			app.skipItem("Failed to remove", a.path, err)
+			continue
+		}
+		app.countFreed(files)
+		app.addItem(&app.summary.RemovedVMImages, item)
+	}
+
+	return nil
+}
+
+// orphanedLibvirtImages finds disk images in the libvirt storage directories
+// that no defined domain uses, directly or as a qcow2 backing file. Domains
+// name their disks by file, block device or storage pool volume. An image
+// directory is only looked in if all the domains that use its images could
+// be read: the system ones in /etc/libvirt, root's alone, for
+// /var/lib/libvirt/images, and the user's own for theirs.
+func (app *App) orphanedLibvirtImages() []vmArtifact {
+	defer app.timeAction("libvirt images", time.Now())
+
+	// Each image directory, by the domain directory defining its users
+	imageDirs := map[string]string{
+		filepath.Join(app.homeDir, ".config", "libvirt", "qemu"): filepath.Join(app.homeDir, ".local", "share", "libvirt", "images"),
+		"/etc/libvirt/qemu": "/var/lib/libvirt/images",
+	}
+	pools := make(map[string]string)
+	for _, dir := range []string{filepath.Join(app.homeDir, ".config", "libvirt", "storage"), "/etc/libvirt/storage"} {
+		poolPaths(dir, pools)
+	}
+
+	inUse := make(map[string]bool)
+	var checked []string
+	for _, domainDir := range sortedKeys(imageDirs) {
+		disks, err := domainDisks(domainDir, pools)
+		if err != nil {
+			if _, statErr := os.Stat(imageDirs[domainDir]); statErr == nil {
+				log.Printf("Warning: not looking for unused images in %s: %v", imageDirs[domainDir], err)
+			}
+			continue
+		}
+		checked = append(checked, imageDirs[domainDir])
+		for _, disk := range disks {
+			// Follow the backing chain so base images of overlays stay
+			for disk != "" && !inUse[disk] {
+				inUse[disk] = true
+				disk = qcow2BackingFile(disk)
+			}
+		}
+	}
+
+	var artifacts []vmArtifact
+	for _, dir := range checked {
+		for _, disk := range vmDisksIn(dir) {
+			if !inUse[disk.path] {
+				disk.kind = "libvirt image"
+				artifacts = append(artifacts, disk)
+			}
+		}
+	}
+	return artifacts
+}
+
+// domainDisks returns the disks the libvirt domains defined in dir use,
+// pool volumes resolved through pools. A missing dir defines none; one
+// that can't be read, or a domain that can't be, is an error, as its
+// disks can't be told apart from unused ones.
+func domainDisks(dir string, pools map[string]string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var disks []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".xml" {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		sources, err := diskSources(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, source := range sources {
+			switch {
+			case source.file != "":
+				disks = append(disks, source.file)
+			case source.dev != "":
+				disks = append(disks, source.dev)
+			case source.pool != "":
+				poolDir, ok := pools[source.pool]
+				if !ok {
+					return nil, fmt.Errorf("%s uses the storage pool %s, whose path is unknown", entry.Name(), source.pool)
+				}
+				disks = append(disks, filepath.Join(poolDir, source.volume))
+			}
+		}
+	}
+	return disks, nil
+}
+
+// diskSource is where a libvirt domain's disk, or its backing store, is.
+type diskSource struct {
+	file, dev, pool, volume string
+}
+
+// diskSources returns the sources of the disks in the libvirt domain
+// definition at path, their backing stores included.
+func diskSources(path string) ([]diskSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sources []diskSource
+	depth := 0
+	decoder := xml.NewDecoder(f)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return sources, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "disk" || depth > 0 {
+				depth++
+			}
+			if depth == 0 || t.Name.Local != "source" {
+				continue
+			}
+			var source diskSource
+			for _, a := range t.Attr {
+				switch a.Name.Local {
+				case "file":
+					source.file = a.Value
+				case "dev":
+					source.dev = a.Value
+				case "pool":
+					source.pool = a.Value
+				case "volume":
+					source.volume = a.Value
+				}
+			}
+			sources = append(sources, source)
+		case xml.EndElement:
+			if depth > 0 {
+				depth--
+			}
+		}
+	}
+}
+
+// poolPaths adds the target paths of the libvirt storage pools defined in
+// dir to pools, by pool name.
+func poolPaths(dir string, pools map[string]string) {
+	defs, _ := filepath.Glob(filepath.Join(dir, "*.xml"))
+	for _, def := range defs {
+		data, err := os.ReadFile(def)
+		if err != nil {
+			continue
+		}
+		var pool struct {
+			Name   string `xml:"name"`
+			Target struct {
+				Path string `xml:"path"`
+			} `xml:"target"`
+		}
+		if xml.Unmarshal(data, &pool) == nil && pool.Name != "" && pool.Target.Path != "" {
+			pools[pool.Name] = pool.Target.Path
+		}
+	}
+}
+
+// orphanedVirtualBoxDisks finds disks in VM folders under "~/VirtualBox VMs"
+// whose machine isn't registered with VirtualBox any more. Unless every
+// registered machine could be read, none are: its disks may be anywhere.
+func (app *App) orphanedVirtualBoxDisks() []vmArtifact {
+	defer app.timeAction("VirtualBox disks", time.Now())
+
+	registry := filepath.Join(app.homeDir, ".config", "VirtualBox", "VirtualBox.xml")
+	if _, err := os.Stat(registry); err != nil {
+		return nil
+	}
+	machines, err := xmlAttrValues(registry, "MachineEntry", "src")
+	if err != nil {
+		log.Printf("Warning: not looking for unused VirtualBox disks: %v", err)
+		return nil
+	}
+
+	registered := make(map[string]bool)
+	inUse := make(map[string]bool)
+	for _, vbox := range machines {
+		registered[filepath.Dir(vbox)] = true
+		disks, err := xmlAttrValues(vbox, "HardDisk", "location")
+		if err != nil {
+			log.Printf("Warning: not looking for unused VirtualBox disks: %v", err)
+			return nil
+		}
+		for _, disk := range disks {
+			if !filepath.IsAbs(disk) {
+				disk = filepath.Join(filepath.Dir(vbox), disk)
+			}
+			inUse[disk] = true
+		}
+	}
+
+	vmDirs, _ := filepath.Glob(filepath.Join(app.homeDir, "VirtualBox VMs", "*"))
+	var artifacts []vmArtifact
+	for _, dir := range vmDirs {
+		if registered[dir] {
+			continue
+		}
+		for _, disk := range vmDisksIn(dir) {
+			if !inUse[disk.path] {
+				disk.kind = "VirtualBox disk"
+				artifacts = append(artifacts, disk)
+			}
+		}
+	}
+	return artifacts
+}
+
+// orphanedVMwareDisks finds disks in VM folders under ~/vmware whose .vmx
+// isn't in the VMware Workstation inventory.
+func (app *App) orphanedVMwareDisks() []vmArtifact {
+	defer app.timeAction("VMware disks", time.Now())
+
+	inventory, err := os.Open(filepath.Join(app.homeDir, ".vmware", "inventory.vmls"))
+	if err != nil {
+		return nil
+	}
+	defer inventory.Close()
+
+	registered := make(map[string]bool)
+	scanner := bufio.NewScanner(inventory)
+	for scanner.Scan() {
+		if m := vmwareInventoryEntry.FindStringSubmatch(strings.TrimSpace(scanner.Text())); m != nil {
+			registered[filepath.Dir(m[1])] = true
+		}
+	}
+
+	vmDirs, _ := filepath.Glob(filepath.Join(app.homeDir, "vmware", "*"))
+	var artifacts []vmArtifact
+	for _, dir := range vmDirs {
+		if registered[dir] {
+			continue
+		}
+		for _, disk := range vmDisksIn(dir) {
+			disk.kind = "VMware disk"
+			artifacts = append(artifacts, disk)
+		}
+	}
+	return artifacts
+}
+
+// unusedVagrantBoxes finds box versions whose metadata hasn't been read for
+// the configured number of days. Vagrant reads metadata.json whenever it uses
+// a box, so its access time tracks use even with relatime.
+func (app *App) unusedVagrantBoxes(cfg VMConfig) []vmArtifact {
+	defer app.timeAction("Vagrant boxes", time.Now())
+
+	cutoff := app.ageCutoff(cfg.VagrantBoxMaxAgeDays, vagrantBoxMaxAge)
+
+	_, lookErr := exec.LookPath("vagrant")
+	hasVagrant := lookErr == nil
+
+	providers, _ := filepath.Glob(filepath.Join(app.homeDir, ".vagrant.d", "boxes", "*", "*", "*"))
+	var artifacts []vmArtifact
+	for _, providerDir := range providers {
+		info, err := os.Stat(filepath.Join(providerDir, "metadata.json"))
+		if err != nil || !accessTime(info).Before(cutoff) || !info.ModTime().Before(cutoff) {
+			continue
+		}
+
+		versionDir := filepath.Dir(providerDir)
+		name := strings.ReplaceAll(filepath.Base(filepath.Dir(versionDir)), "-VAGRANTSLASH-", "/")
+		version, provider := filepath.Base(versionDir), filepath.Base(providerDir)
+
+		artifact := vmArtifact{kind: "Vagrant box", path: providerDir, size: dirSize(providerDir)}
+		// vagrant would take a name starting with "-" for an option; such a
+		// box is removed as a plain folder instead
+		if hasVagrant && !strings.HasPrefix(name, "-") {
+			artifact.remove = func() error {
+				return exec.Command("vagrant", "box", "remove", name, "--box-version", version, "--provider", provider).Run()
+			}
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts
+}
+
+// vmDisksIn lists the VM disk images directly inside dir.
+func vmDisksIn(dir string) []vmArtifact {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var disks []vmArtifact
+	for _, entry := range entries {
+		if entry.IsDir() || !hasAnySuffix(strings.ToLower(entry.Name()), vmDiskExts) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		disks = append(disks, vmArtifact{path: filepath.Join(dir, entry.Name()), size: info.Size()})
+	}
+	return disks
+}
+
+// qcow2BackingFile returns the backing file recorded in a qcow2 header, or ""
+// if the file isn't a qcow2 image or has no backing file.
+func qcow2BackingFile(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	header := make([]byte, 20)
+	if _, err := io.ReadFull(f, header); err != nil || string(header[:4]) != "QFI\xfb" {
+		return ""
+	}
+
+	offset := binary.BigEndian.Uint64(header[8:16])
+	size := binary.BigEndian.Uint32(header[16:20])
+	if offset == 0 || size == 0 || size > 1023 {
+		return ""
+	}
+
+	name := make([]byte, size)
+	if _, err := f.ReadAt(name, int64(offset)); err != nil {
+		return ""
+	}
+
+	backing := string(name)
+	if !filepath.IsAbs(backing) {
+		backing = filepath.Join(filepath.Dir(path), backing)
+	}
+	return backing
+}
+
+// xmlAttrValues returns the value of attr on every elem element in the file.
+func xmlAttrValues(path, elem, attr string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var values []string
+	decoder := xml.NewDecoder(f)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != elem {
+			continue
+		}
+		for _, a := range start.Attr {
+			if a.Name.Local == attr && a.Value != "" {
+				values = append(values, a.Value)
+			}
+		}
+	}
+}
+
+func hasAnySuffix(s string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// dirSize returns the total size of the regular files under dir.
+func dirSize(dir string) int64 {
+	var total int64
+	walkTree(dir, func(_ string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
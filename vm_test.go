@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestOrphanedVirtualBoxDisks lists the disks of an unregistered machine,
+// and none while a registered machine's definition can't be read.
+func TestOrphanedVirtualBoxDisks(t *testing.T) {
+	home := t.TempDir()
+	app := newHomeApp(home)
+	vms := filepath.Join(home, "VirtualBox VMs")
+	registeredVbox := filepath.Join(vms, "dev", "dev.vbox")
+	orphan := filepath.Join(vms, "old", "old.vdi")
+	// The registered machine's disk lives in the unregistered one's folder
+	shared := filepath.Join(vms, "old", "dev.vdi")
+	for _, file := range []string{registeredVbox, orphan, shared} {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte("disk"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	registry := filepath.Join(home, ".config", "VirtualBox", "VirtualBox.xml")
+	if err := os.MkdirAll(filepath.Dir(registry), 0755); err != nil {
+		t.Fatal(err)
+	}
+	entries := fmt.Sprintf(`<VirtualBox><Global><MachineRegistry><MachineEntry uuid="{1}" src=%q/></MachineRegistry></Global></VirtualBox>`, registeredVbox)
+	if err := os.WriteFile(registry, []byte(entries), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unreadable: dev's disks can't be told apart from the orphan's
+	if err := os.WriteFile(registeredVbox, []byte("<VirtualBox><Machine"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if artifacts := app.orphanedVirtualBoxDisks(); len(artifacts) != 0 {
+		t.Errorf("with a registered machine unreadable, listed %v as orphaned", artifacts)
+	}
+
+	machine := fmt.Sprintf(`<VirtualBox><Machine><MediaRegistry><HardDisks><HardDisk uuid="{2}" location=%q/></HardDisks></MediaRegistry></Machine></VirtualBox>`, shared)
+	if err := os.WriteFile(registeredVbox, []byte(machine), 0644); err != nil {
+		t.Fatal(err)
+	}
+	artifacts := app.orphanedVirtualBoxDisks()
+	if len(artifacts) != 1 || artifacts[0].path != orphan {
+		t.Errorf("listed %v as orphaned, want just %s", artifacts, orphan)
+	}
+}
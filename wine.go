@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// winePrefixFiles are rewritten by wineserver whenever a prefix is used, so
+// the newest of their modification times tells us when the prefix was last
+// actually run.
+var winePrefixFiles = []string{"user.reg", "system.reg", "userdef.reg"}
+
+func (app *App) cleanWinePrefixes() error {
+	cutoff := app.ageCutoff(0, winePrefixMaxAge)
+
+	prefixes, err := app.findWinePrefixes()
+	if err != nil {
+		return fmt.Errorf("failed to look for Wine prefixes: %w", err)
+	}
+
+	for _, prefix := range prefixes {
+		lastUsed, ok := app.winePrefixLastUsed(prefix)
+		if !ok || !lastUsed.Before(cutoff) {
+			continue
+		}
+
+		if err := app.removeAll(prefix); err != nil {
+			app.skipItem("Failed to remove Wine prefix at", prefix, err)
+			continue
+		}
+		app.addItem(&app.summary.RemovedWinePrefixes, fmt.Sprintf("%s (last used %s)", prefix, lastUsed.Format("2006-01-02")))
+	}
+
+	return nil
+}
+
+// findWinePrefixes returns the Wine prefixes in the usual locations: the
+// default ~/.wine, ad-hoc ~/.wine-* prefixes, and the directories managed by
+// common prefix managers.
+func (app *App) findWinePrefixes() ([]string, error) {
+	patterns := []string{
+		filepath.Join(app.homeDir, ".wine"),
+		filepath.Join(app.homeDir, ".wine-*"),
+		filepath.Join(app.homeDir, ".local", "share", "wineprefixes", "*"),
+		filepath.Join(app.homeDir, ".local", "share", "bottles", "bottles", "*"),
+	}
+
+	var prefixes []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			if app.isWinePrefix(match) {
+				prefixes = append(prefixes, match)
+			}
+		}
+	}
+
+	return prefixes, nil
+}
+
+func (app *App) isWinePrefix(dir string) bool {
+	if info, err := os.Stat(filepath.Join(dir, "drive_c")); err != nil || !info.IsDir() {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(dir, "system.reg"))
+	return err == nil
+}
+
+func (app *App) winePrefixLastUsed(prefix string) (time.Time, bool) {
+	var lastUsed time.Time
+	for _, name := range winePrefixFiles {
+		info, err := os.Stat(filepath.Join(prefix, name))
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(lastUsed) {
+			lastUsed = info.ModTime()
+		}
+	}
+	return lastUsed, !lastUsed.IsZero()
+}